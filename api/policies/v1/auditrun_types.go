@@ -0,0 +1,140 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuditRunPhase is the phase of an audit run.
+// +kubebuilder:validation:Enum=Running;Succeeded;Failed;Interrupted
+type AuditRunPhase string
+
+const (
+	// AuditRunPhaseRunning means that the audit scanner is scanning the resources.
+	AuditRunPhaseRunning AuditRunPhase = "Running"
+	// AuditRunPhaseSucceeded means that all the resources have been scanned.
+	AuditRunPhaseSucceeded AuditRunPhase = "Succeeded"
+	// AuditRunPhaseFailed means that the scan finished with errors, some
+	// resources may not have been scanned.
+	AuditRunPhaseFailed AuditRunPhase = "Failed"
+	// AuditRunPhaseInterrupted means that the audit scanner stopped before
+	// the end of the scan, for example because its pod was evicted.
+	AuditRunPhaseInterrupted AuditRunPhase = "Interrupted"
+)
+
+// AuditRunSpec defines the audit run.
+type AuditRunSpec struct {
+	// RunUID is the UID of the run, set in the
+	// kubewarden.io/audit-scanner-run-uid label of the reports it created.
+	RunUID string `json:"runUID"`
+}
+
+// AuditRunResults counts the results of the policy evaluations by outcome.
+type AuditRunResults struct {
+	// Pass is the number of evaluations whose policy requirements are met.
+	Pass int `json:"pass"`
+	// Fail is the number of evaluations whose policy requirements are not met.
+	Fail int `json:"fail"`
+	// Warn is the number of evaluations reported as warnings.
+	Warn int `json:"warn"`
+	// Error is the number of evaluations that could not be performed.
+	Error int `json:"error"`
+	// Skip is the number of policies that were not evaluated.
+	Skip int `json:"skip"`
+}
+
+// AuditRunChanges counts the results of the policy evaluations that changed
+// since the previous run, for the resources audited by both runs.
+type AuditRunChanges struct {
+	// NewFailures is the number of policies that started failing for a resource.
+	NewFailures int `json:"newFailures"`
+	// ResolvedFailures is the number of policies that stopped failing for a
+	// resource, because the resource now meets their requirements.
+	ResolvedFailures int `json:"resolvedFailures"`
+}
+
+// AuditRunStatus defines the observed state of AuditRun.
+type AuditRunStatus struct {
+	// Phase of the run.
+	// +optional
+	Phase AuditRunPhase `json:"phase,omitempty"`
+	// StartTime is the time the run started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time the run finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// NamespacesTotal is the number of namespaces to scan.
+	// +optional
+	NamespacesTotal int `json:"namespacesTotal,omitempty"`
+	// NamespacesCompleted is the number of namespaces already scanned.
+	// +optional
+	NamespacesCompleted int `json:"namespacesCompleted,omitempty"`
+	// ResourcesAudited is the number of resources audited.
+	// +optional
+	ResourcesAudited int `json:"resourcesAudited,omitempty"`
+	// Results counts the results of the policy evaluations by outcome.
+	// +optional
+	Results AuditRunResults `json:"results,omitempty"`
+	// Changes counts the results that changed since the previous run. They
+	// are only counted when the audit scanner emits the Events of the changes.
+	// +optional
+	Changes AuditRunChanges `json:"changes,omitempty"`
+	// ErroredPolicies are the unique names of the policies that could not be
+	// audited, because they may be misconfigured.
+	// +optional
+	ErroredPolicies []string `json:"erroredPolicies,omitempty"`
+	// SkippedPolicies are the unique names of the policies that were not
+	// audited, because they are not active or have backgroundAudit disabled.
+	// +optional
+	SkippedPolicies []string `json:"skippedPolicies,omitempty"`
+	// Message describes why the run failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`,description="Phase of the audit run"
+//+kubebuilder:printcolumn:name="Namespaces",type=integer,JSONPath=`.status.namespacesCompleted`,description="Namespaces scanned"
+//+kubebuilder:printcolumn:name="Resources",type=integer,JSONPath=`.status.resourcesAudited`,description="Resources audited"
+//+kubebuilder:printcolumn:name="Pass",type=integer,JSONPath=`.status.results.pass`,description="Passed evaluations"
+//+kubebuilder:printcolumn:name="Fail",type=integer,JSONPath=`.status.results.fail`,description="Failed evaluations"
+//+kubebuilder:printcolumn:name="Error",type=integer,JSONPath=`.status.results.error`,description="Errored evaluations"
+//+kubebuilder:printcolumn:name="New",type=integer,JSONPath=`.status.changes.newFailures`,description="Policies that started failing",priority=1
+//+kubebuilder:printcolumn:name="Resolved",type=integer,JSONPath=`.status.changes.resolvedFailures`,description="Policies that stopped failing",priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// AuditRun records the lifecycle and the results of an audit scanner run.
+type AuditRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AuditRunSpec   `json:"spec,omitempty"`
+	Status AuditRunStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AuditRunList contains a list of AuditRun.
+type AuditRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AuditRun `json:"items"`
+}
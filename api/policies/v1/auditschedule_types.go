@@ -0,0 +1,203 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuditScheduleScope restricts the resources audited by the scheduled scans.
+// All the resources of the cluster are audited against all the policies when
+// empty. The reports of the resources out of the scope are not deleted when the
+// scans target some policies, resources or labels.
+type AuditScheduleScope struct {
+	// Namespace restricts the scans to the resources of this namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// ClusterWide restricts the scans to the cluster-wide resources. It's
+	// ignored when Namespace is set.
+	// +optional
+	ClusterWide bool `json:"clusterWide,omitempty"`
+	// IgnoredNamespaces are the namespaces whose resources are not audited.
+	// +optional
+	IgnoredNamespaces []string `json:"ignoredNamespaces,omitempty"`
+	// Policies restricts the scans to these policies. The cluster-wide
+	// policies are referred to by their name, the namespaced ones as
+	// namespace/name. The results of the other policies are kept in the
+	// reports.
+	// +optional
+	Policies []string `json:"policies,omitempty"`
+	// Resources restricts the scans to these resources, in the
+	// resource.group or resource.version.group format, e.g. pods or
+	// deployments.apps.
+	// +optional
+	Resources []string `json:"resources,omitempty"`
+	// NamespaceSelector restricts the scans to the namespaces matching the
+	// selector. It's ignored when Namespace is set.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// ResourceSelector restricts the scans to the resources matching the
+	// selector.
+	// +optional
+	ResourceSelector *metav1.LabelSelector `json:"resourceSelector,omitempty"`
+}
+
+// AuditScheduleParallelism sets how many audits are performed in parallel by
+// the scans. The audit scanner defaults are used for the unset values.
+type AuditScheduleParallelism struct {
+	// Namespaces is the number of namespaces scanned in parallel.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Namespaces int32 `json:"namespaces,omitempty"`
+	// Resources is the number of resources scanned in parallel.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Resources int32 `json:"resources,omitempty"`
+	// Policies is the number of policies evaluated in parallel for a given
+	// resource.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Policies int32 `json:"policies,omitempty"`
+}
+
+// AuditScheduleSpec defines the desired state of AuditSchedule.
+type AuditScheduleSpec struct {
+	// Schedule of the scans, in the cron format, e.g. "*/60 * * * *".
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+	// Suspend stops scheduling new scans. The running scan is not stopped.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// Scope restricts the resources audited by the scans.
+	// +optional
+	Scope AuditScheduleScope `json:"scope,omitempty"`
+	// Parallelism of the scans.
+	// +optional
+	Parallelism AuditScheduleParallelism `json:"parallelism,omitempty"`
+	// ReportKind is the kind of the reports storing the results of the scans.
+	// The audit scanner default is used when empty.
+	// +kubebuilder:validation:Enum=openreports;policyreport
+	// +optional
+	ReportKind string `json:"reportKind,omitempty"`
+	// Limits describes the maximum amount of compute resources allowed to
+	// the scans.
+	// +optional
+	Limits corev1.ResourceList `json:"limits,omitempty"`
+	// Requests describes the minimum amount of compute resources required by
+	// the scans.
+	// +optional
+	Requests corev1.ResourceList `json:"requests,omitempty"`
+	// SuccessfulJobsHistoryLimit is the number of successful Jobs kept.
+	// Defaults to 3.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+	// FailedJobsHistoryLimit is the number of failed Jobs kept.
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+}
+
+// AuditScheduleRunResult is the result of a scheduled scan.
+// +kubebuilder:validation:Enum=Succeeded;Failed
+type AuditScheduleRunResult string
+
+const (
+	// AuditScheduleRunSucceeded means that the Job of the scan completed.
+	AuditScheduleRunSucceeded AuditScheduleRunResult = "Succeeded"
+	// AuditScheduleRunFailed means that the Job of the scan failed.
+	AuditScheduleRunFailed AuditScheduleRunResult = "Failed"
+)
+
+// AuditScheduleRun describes a finished scan.
+type AuditScheduleRun struct {
+	// JobName is the name of the Job that performed the scan.
+	JobName string `json:"jobName"`
+	// Result of the scan.
+	Result AuditScheduleRunResult `json:"result"`
+	// StartTime is the time the Job started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time the Job finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Message describes why the Job failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type AuditScheduleConditionType string
+
+const (
+	// AuditScheduleScheduled represents the condition of the scheduling of
+	// the scans. It's false when the schedule is invalid.
+	AuditScheduleScheduled AuditScheduleConditionType = "Scheduled"
+)
+
+// AuditScheduleStatus defines the observed state of AuditSchedule.
+type AuditScheduleStatus struct {
+	// LastScheduleTime is the last time a scan was scheduled.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// NextScheduleTime is the next time a scan is scheduled.
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+	// ActiveJob is the name of the Job of the running scan.
+	// +optional
+	ActiveJob string `json:"activeJob,omitempty"`
+	// LastRun describes the last finished scan.
+	// +optional
+	LastRun *AuditScheduleRun `json:"lastRun,omitempty"`
+	// Conditions represent the observed conditions of the AuditSchedule
+	// resource. Known .status.conditions.types are: "Scheduled"
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`,description="Schedule of the scans"
+//+kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`,description="Whether new scans are scheduled"
+//+kubebuilder:printcolumn:name="Last Schedule",type=date,JSONPath=`.status.lastScheduleTime`,description="Last time a scan was scheduled"
+//+kubebuilder:printcolumn:name="Last Result",type=string,JSONPath=`.status.lastRun.result`,description="Result of the last finished scan"
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// AuditSchedule schedules audit scanner runs, performed by Jobs created by
+// the controller.
+type AuditSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AuditScheduleSpec   `json:"spec,omitempty"`
+	Status AuditScheduleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AuditScheduleList contains a list of AuditSchedule.
+type AuditScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AuditSchedule `json:"items"`
+}
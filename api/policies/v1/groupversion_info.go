@@ -43,6 +43,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&AdmissionPolicyList{},
 		&AdmissionPolicyGroup{},
 		&AdmissionPolicyGroupList{},
+		&AuditRun{},
+		&AuditRunList{},
+		&AuditSchedule{},
+		&AuditScheduleList{},
 		&ClusterAdmissionPolicy{},
 		&ClusterAdmissionPolicyList{},
 		&ClusterAdmissionPolicyGroup{},
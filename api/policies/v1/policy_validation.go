@@ -31,13 +31,21 @@ import (
 	"k8s.io/apiserver/pkg/cel/environment"
 )
 
-// nonStrictStatelessCELCompiler is a cel Compiler that does not enforce strict cost enforcement.
-//
 //nolint:gochecknoglobals // lets keep the compiler available for the how module
 var (
-	nonStrictStatelessCELCompiler = plugincel.NewCompiler(environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()))
+	// matchConditionsEnvSet is the CEL environment of the matchConditions of
+	// the policies, shared with the audit scanner evaluating them.
+	matchConditionsEnvSet = environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion())
+	// nonStrictStatelessCELCompiler is a cel Compiler that does not enforce strict cost enforcement.
+	nonStrictStatelessCELCompiler = plugincel.NewCompiler(matchConditionsEnvSet)
 )
 
+// MatchConditionsEnvSet returns the CEL environment used to compile the
+// matchConditions of the policies.
+func MatchConditionsEnvSet() *environment.EnvSet {
+	return matchConditionsEnvSet
+}
+
 const (
 	maxMatchConditionsCount = 64
 	wildcardAllResources    = "*/*"
@@ -135,6 +135,8 @@ are configured.
 {{- $parallelResources := .Values.auditScanner.parallelResources | int -}}
 {{- $parallelPolicies := .Values.auditScanner.parallelPolicies | int -}}
 {{- $pageSize := .Values.auditScanner.pageSize| int -}}
+{{- $metricsPort := .Values.auditScanner.metricsPort | int -}}
+{{- $shards := .Values.auditScanner.shards | int -}}
 - /audit-scanner
 - --kubewarden-namespace
 - {{ .Release.Namespace }}
@@ -159,6 +161,69 @@ are configured.
 {{- if .Values.auditScanner.disableStore }}
 - --disable-store
 {{- end }}
+{{- if .Values.auditScanner.incremental }}
+- --incremental
+{{- end }}
+{{- if .Values.auditScanner.checkpoints }}
+- --enable-checkpoints
+{{- end }}
+- --audit-run-history
+- "{{ .Values.auditScanner.auditRunHistory | int }}"
+{{- if .Values.auditScanner.emitEvents }}
+- --emit-events
+{{- end }}
+{{- if .Values.auditScanner.notificationConfigSecret }}
+- --notification-config
+- /notifications/notifications.yaml
+{{- end }}
+{{- if gt $shards 1 }}
+- --shard-count
+- "{{ $shards }}"
+- --shard-index
+- "$(JOB_COMPLETION_INDEX)"
+{{- end }}
+{{- if .Values.auditScanner.disableLoadBalancing }}
+- --disable-load-balancing
+{{- end }}
+{{- if .Values.auditScanner.otlpMetrics }}
+- --enable-otlp-metrics
+{{- end }}
+{{- if gt $metricsPort 0 }}
+- --metrics-bind-address
+- ":{{ $metricsPort }}"
+{{- end }}
+{{- with .Values.auditScanner.wildcardExcludedResources }}
+- --wildcard-excluded-resources
+- {{ join "," . | quote }}
+{{- end }}
+{{- if .Values.auditScanner.auditUser }}
+- --audit-user
+- {{ .Values.auditScanner.auditUser | quote }}
+{{- end }}
+{{- with .Values.auditScanner.auditGroups }}
+- --audit-groups
+- {{ join "," . | quote }}
+{{- end }}
+{{- with .Values.auditScanner.policyServerTimeout }}
+- --policy-server-timeout
+- {{ . | quote }}
+{{- end }}
+- --policy-server-retries
+- "{{ .Values.auditScanner.policyServerRetries | int }}"
+{{- with .Values.auditScanner.policyServerRetryBackoff }}
+- --policy-server-retry-backoff
+- {{ . | quote }}
+{{- end }}
+{{- with .Values.auditScanner.policyServerMaxRetryBackoff }}
+- --policy-server-max-retry-backoff
+- {{ . | quote }}
+{{- end }}
+- --circuit-breaker-threshold
+- "{{ .Values.auditScanner.circuitBreakerThreshold | int }}"
+{{- with .Values.auditScanner.circuitBreakerCooldown }}
+- --circuit-breaker-cooldown
+- {{ . | quote }}
+{{- end }}
 - --extra-ca
 - "/pki/ca.crt"
 - --client-cert
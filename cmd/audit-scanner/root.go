@@ -2,17 +2,38 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/auditrun"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/checkpoint"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/events"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/metrics"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/notification"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/output"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/policies"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/scanner"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/scheme"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -25,17 +46,75 @@ const (
 	defaultParallelPolicies    = 5
 	defaultParallelNamespaces  = 1
 	defaultPageSize            = 100
+	// defaults of the requests sent to the PolicyServers.
+	defaultPolicyServerTimeout     = 10 * time.Second
+	defaultPolicyServerRetries     = 3
+	defaultRetryBackoff            = 500 * time.Millisecond
+	defaultMaxRetryBackoff         = 10 * time.Second
+	defaultCircuitBreakerThreshold = 5
+	defaultCircuitBreakerCooldown  = 30 * time.Second
+	// defaultAuditRunHistory is the number of AuditRuns kept by default.
+	defaultAuditRunHistory = 10
+	// metricsShutdownTimeout is the time given to export the last metrics before exiting.
+	metricsShutdownTimeout = 10 * time.Second
+	// defaultAuditScannerServiceAccount is the name of the ServiceAccount used by the audit scanner Helm chart.
+	defaultAuditScannerServiceAccount = "audit-scanner"
+	// exitCodeViolations is the exit code of the scans finding results selected
+	// by the fail-on and min-severity flags.
+	exitCodeViolations = 2
 )
 
-//nolint:gocognit,funlen // This function is the CLI entrypoint and it's expected to be long.
+// errViolations is returned when the scan found results selected by the
+// fail-on and min-severity flags.
+var errViolations = errors.New("the scan found results exceeding the threshold")
+
+// defaultWildcardExcludedResources are the resources not audited by policies
+// with wildcard rules. They are short-lived or change too often to be worth auditing.
+var defaultWildcardExcludedResources = []string{"events", "events.events.k8s.io", "leases.coordination.k8s.io"}
+
+// scannerFlags holds the values of the flags shared by all the commands that
+// audit the cluster resources.
+type scannerFlags struct {
+	level        string   // log level.
+	outputScan   bool     // print result of scan as JSON to stdout.
+	skippedNs    []string // list of namespaces to be skipped from scan.
+	insecureSSL  bool     // skip SSL cert validation when connecting to PolicyServers endpoints.
+	disableStore bool     // disable storing the results in the k8s cluster.
+	incremental  bool     // reuse the results of the previous scan for unchanged resources and policies.
+	// list of resources, in the resource.group format, never targeted by wildcard rules.
+	wildcardExcludedResources []string
+	auditUser                 string   // username set in the audit admission requests.
+	auditGroups               []string // groups set in the audit admission requests.
+	// retries, backoff and circuit breaker of the requests sent to the PolicyServers.
+	policyServerClient scanner.PolicyServerClientConfig
+	// send the requests to the PolicyServer Services instead of balancing them across the pods.
+	disableLoadBalancing bool
+	otlpMetrics          bool   // export the metrics to an OpenTelemetry collector.
+	metricsBindAddress   string // address serving the metrics in the Prometheus format.
+	outputFormat         string // machine-readable format of the scan results.
+	outputFile           string // file where the scan results are written, stdout when empty.
+	checkpoints          bool   // persist the progress of the scan, so an interrupted scan is resumed.
+	auditRunHistory      int    // number of AuditRuns kept.
+	shardIndex           int    // index of the shard scanned by this replica.
+	shardCount           int    // number of replicas the scan is split across.
+	auditSchedule        string // name of the AuditSchedule running the scan.
+	// policies and resources audited by a targeted scan, everything when empty.
+	policies          []string
+	resources         []string
+	namespaceSelector string // label selector of the namespaces audited.
+	resourceSelector  string // label selector of the resources audited.
+	// results and minimum severity of the results failing the scan.
+	failOn      []string
+	minSeverity string
+	// reuse the verdicts of the policies for equivalent resources during the scan.
+	deduplicateEvaluations bool
+	reportStoreURL         string // store where the reports are written instead of the Kubernetes API server.
+	emitEvents             bool   // emit Events when a policy starts or stops failing for a resource.
+	notificationConfig     string // file configuring the sinks notified of the failures.
+}
+
 func NewRootCommand() *cobra.Command {
-	var (
-		level        string   // log level.
-		outputScan   bool     // print result of scan as JSON to stdout.
-		skippedNs    []string // list of namespaces to be skipped from scan.
-		insecureSSL  bool     // skip SSL cert validation when connecting to PolicyServers endpoints.
-		disableStore bool     // disable storing the results in the k8s cluster.
-	)
+	flags := &scannerFlags{}
 
 	// rootCmd represents the base command when called without any subcommands.
 	rootCmd := &cobra.Command{
@@ -50,111 +129,36 @@ There will be a ClusterPolicyReport with results for cluster-wide resources.`,
 			if err != nil {
 				return fmt.Errorf("failed to get namespace flag %w", err)
 			}
-			kubewardenNamespace, err := cmd.Flags().GetString("kubewarden-namespace")
-			if err != nil {
-				return fmt.Errorf("failed to get kubewarden-namespace flag: %w", err)
-			}
 			clusterWide, err := cmd.Flags().GetBool("cluster")
 			if err != nil {
 				return fmt.Errorf("failed to get cluster flag: %w", err)
 			}
-			policyServerURL, err := cmd.Flags().GetString("policy-server-url")
-			if err != nil {
-				return fmt.Errorf("failed to get policy-server-url flag: %w", err)
-			}
-			caFile, err := cmd.Flags().GetString("extra-ca")
-			if err != nil {
-				return fmt.Errorf("failed to get extra-ca flag: %w", err)
-			}
-			clientCertFile, err := cmd.Flags().GetString("client-cert")
-			if err != nil {
-				return fmt.Errorf("failed to get client-cert flag: %w", err)
-			}
-			clientKeyFile, err := cmd.Flags().GetString("client-key")
-			if err != nil {
-				return fmt.Errorf("failed to get client-key flag: %w", err)
-			}
-			parallelNamespacesAudits, err := cmd.Flags().GetInt("parallel-namespaces")
-			if err != nil {
-				return fmt.Errorf("failed to get parallel-namespaces flag: %w", err)
-			}
-			parallelResourcesAudits, err := cmd.Flags().GetInt("parallel-resources")
-			if err != nil {
-				return fmt.Errorf("failed to get parallel-resources flag: %w", err)
-			}
-			parallelPoliciesAudit, err := cmd.Flags().GetInt("parallel-policies")
+			violations, err := newViolations(flags.failOn, flags.minSeverity)
 			if err != nil {
-				return fmt.Errorf("failed to get parallel-policies flag: %w", err)
-			}
-			pageSize, err := cmd.Flags().GetInt("page-size")
-			if err != nil {
-				return fmt.Errorf("failed to get page-size flag: %w", err)
-			}
-			reportKindStr, err := cmd.Flags().GetString("report-kind")
-			if err != nil {
-				return fmt.Errorf("failed to get report-kind flag: %w", err)
+				return err
 			}
 
-			var reportKind report.CrdKind
-			switch reportKindStr {
-			case report.OpenReportsKind:
-				reportKind = report.ReportKindOpenReport
-			case report.PolicyReportKind:
-				reportKind = report.ReportKindPolicyReport
-			default:
-				return fmt.Errorf("invalid report-kind '%s': supported values are '%s' and '%s'", reportKindStr, report.OpenReportsKind, report.PolicyReportKind)
-			}
-
-			config := ctrl.GetConfigOrDie()
-			dynamicClient := dynamic.NewForConfigOrDie(config)
-			clientset := kubernetes.NewForConfigOrDie(config)
-
-			auditScheme, err := scheme.NewScheme()
-			if err != nil {
-				return fmt.Errorf("failed to create scheme: %w", err)
-			}
-			client, err := client.New(config, client.Options{Scheme: auditScheme})
+			shutdownMetrics, err := startMetrics(flags)
 			if err != nil {
-				return fmt.Errorf("failed to create kubernetes client: %w", err)
+				return err
 			}
-			logger := slog.New(NewHandler(os.Stdout, level))
-			policiesClient := policies.NewClient(client, kubewardenNamespace, policyServerURL, logger)
+			defer shutdownMetrics()
 
-			k8sClient := k8s.NewClient(dynamicClient, clientset, kubewardenNamespace, skippedNs, int64(pageSize), logger)
-			reportStore := report.NewReportStoreOfKind(reportKind, client, logger)
-
-			if reportKind == report.ReportKindOpenReport {
-				if err = report.DeleteAllLegacyPolicyReports(context.Background(), client, logger); err != nil {
-					logger.Warn("Failed to delete legacy wgpolicyk8s.io PolicyReports, continuing", "error", err)
-				}
-			}
-
-			scannerConfig := scanner.Config{
-				PoliciesClient: policiesClient,
-				K8sClient:      k8sClient,
-				ReportStore:    reportStore,
-				TLS: scanner.TLSConfig{
-					Insecure:       insecureSSL,
-					CAFile:         caFile,
-					ClientCertFile: clientCertFile,
-					ClientKeyFile:  clientKeyFile,
-				},
-				Parallelization: scanner.ParallelizationConfig{
-					ParallelNamespacesAudits: parallelNamespacesAudits,
-					ParallelResourcesAudits:  parallelResourcesAudits,
-					PoliciesAudits:           parallelPoliciesAudit,
-				},
-				OutputScan:   outputScan,
-				DisableStore: disableStore,
-				Logger:       logger.With("component", "scanner"),
-				ReportKind:   reportKind,
+			scanOutput, err := openOutput(flags)
+			if err != nil {
+				return err
 			}
 
-			scanner, err := scanner.NewScanner(scannerConfig)
+			scanner, err := newScanner(cmd, flags, scanOutput, violations)
 			if err != nil {
-				return fmt.Errorf("failed to create scanner: %w", err)
+				return errors.Join(err, closeOutput(scanOutput))
 			}
-			return startScanner(namespace, clusterWide, scanner)
+			defer scanner.Close()
+			err = startScanner(namespace, clusterWide, scanner)
+			if err = errors.Join(err, closeOutput(scanOutput)); err != nil {
+				return err
+			}
+			return checkViolations(cmd.ErrOrStderr(), violations)
 		},
 	}
 
@@ -164,43 +168,482 @@ There will be a ClusterPolicyReport with results for cluster-wide resources.`,
 
 	rootCmd.Flags().StringP("namespace", "n", "", "namespace to be evaluated")
 	rootCmd.Flags().BoolP("cluster", "c", false, "scan cluster wide resources")
-	rootCmd.Flags().StringP("kubewarden-namespace", "k", defaultKubewardenNamespace, "namespace where the Kubewarden components (e.g. PolicyServer) are installed (required)")
-	rootCmd.Flags().StringP("policy-server-url", "u", "", "URI to the PolicyServers the Audit Scanner will query. Example: https://localhost:3000. Useful for out-of-cluster debugging")
-	rootCmd.Flags().StringVarP(&level, "loglevel", "l", "", fmt.Sprintf("level of the logs. Supported values are: %v", SupportedLogLevels()))
-	rootCmd.Flags().BoolVarP(&outputScan, "output-scan", "o", false, "print result of scan in JSON to stdout")
-	rootCmd.Flags().StringSliceVarP(&skippedNs, "ignore-namespaces", "i", nil, "comma separated list of namespace names to be skipped from scan. This flag can be repeated")
-	rootCmd.Flags().BoolVar(&insecureSSL, "insecure-ssl", false, "skip SSL cert validation when connecting to PolicyServers endpoints. Useful for development")
-	rootCmd.Flags().StringP("extra-ca", "f", "", "File path to CA cert in PEM format of PolicyServer endpoints")
-	rootCmd.Flags().StringP("client-cert", "", "", "File path to client cert in PEM format used for mTLS communication with the PolicyServer endpoints")
-	rootCmd.Flags().StringP("client-key", "", "", "File path to client key in PEM format used for mTLS communication with the PolicyServer endpoints")
+	rootCmd.Flags().BoolVar(&flags.checkpoints, "enable-checkpoints", false, fmt.Sprintf("persist the progress of the scan in the %s ConfigMap of the kubewarden-namespace, so a scan interrupted halfway through is resumed by the next one under the same run UID", checkpoint.ConfigMapName))
+	rootCmd.Flags().IntVar(&flags.shardIndex, "shard-index", 0, "index of the shard scanned by this replica, between 0 and shard-count - 1. With an Indexed Job, it's the completion index of the pod")
+	rootCmd.Flags().IntVar(&flags.shardCount, "shard-count", 1, "number of replicas the scan is split across. The namespaces and the cluster-wide resources are partitioned between the replicas by a hash, and each replica only garbage-collects the reports of its shard")
+	rootCmd.Flags().StringVar(&flags.auditSchedule, "audit-schedule", "", "name of the AuditSchedule running the scan, set by the controller on the Jobs of the AuditSchedules. The AuditRuns of the scan are labeled with it, and the reports of the resources not audited are kept, as the scans of the other AuditSchedules write them")
+	rootCmd.Flags().StringSliceVar(&flags.policies, "policy", nil, "comma separated list of the policies to audit, by name for the cluster-wide policies and as namespace/name for the namespaced ones. The results of the other policies are kept in the reports. This flag can be repeated")
+	rootCmd.Flags().StringSliceVar(&flags.resources, "resource", nil, "comma separated list of the resources to audit, in the resource.group or resource.version.group format, e.g. pods or deployments.apps. This flag can be repeated")
+	rootCmd.Flags().StringVar(&flags.namespaceSelector, "namespace-selector", "", "label selector of the namespaces to audit when scanning all the namespaces, e.g. team=payments")
+	rootCmd.Flags().StringVar(&flags.resourceSelector, "resource-selector", "", "label selector of the resources to audit, e.g. app=nginx")
+	rootCmd.Flags().StringSliceVar(&flags.failOn, "fail-on", nil, fmt.Sprintf("comma separated list of the results failing the scan with exit code %d, once all the resources are audited. Supported values are: %v. The scan never fails because of the results when not set", exitCodeViolations, report.ThresholdResults))
+	rootCmd.Flags().StringVar(&flags.minSeverity, "min-severity", "", fmt.Sprintf("lowest severity of the results failing the scan, see the fail-on flag. Supported values are: %v. The results of the policies without a severity are ignored when set", report.Severities))
+	rootCmd.Flags().IntVar(&flags.auditRunHistory, "audit-run-history", defaultAuditRunHistory, "number of AuditRun resources recording the scans kept in the cluster, older ones are deleted. Zero keeps all of them. No AuditRun is recorded when the store is disabled")
+	rootCmd.Flags().BoolVar(&flags.deduplicateEvaluations, "deduplicate-evaluations", false, "evaluate a policy only once for the resources whose content is the same once their name, uid, status, the metadata set by the API server and the fields of the Pods that differ between replicas, like their node, are ignored, e.g. the Pods of a Deployment, and reuse its verdict for the other ones. Context-aware policies are always evaluated")
+	rootCmd.PersistentFlags().StringP("kubewarden-namespace", "k", defaultKubewardenNamespace, "namespace where the Kubewarden components (e.g. PolicyServer) are installed (required)")
+	rootCmd.PersistentFlags().StringP("policy-server-url", "u", "", "URI to the PolicyServers the Audit Scanner will query. Example: https://localhost:3000. Useful for out-of-cluster debugging")
+	rootCmd.PersistentFlags().StringVarP(&flags.level, "loglevel", "l", "", fmt.Sprintf("level of the logs. Supported values are: %v", SupportedLogLevels()))
+	rootCmd.PersistentFlags().BoolVarP(&flags.outputScan, "output-scan", "o", false, "print result of scan in JSON to stdout")
+	rootCmd.PersistentFlags().StringSliceVarP(&flags.skippedNs, "ignore-namespaces", "i", nil, "comma separated list of namespace names to be skipped from scan. This flag can be repeated")
+	rootCmd.PersistentFlags().BoolVar(&flags.insecureSSL, "insecure-ssl", false, "skip SSL cert validation when connecting to PolicyServers endpoints. Useful for development")
+	rootCmd.PersistentFlags().StringP("extra-ca", "f", "", "File path to CA cert in PEM format of PolicyServer endpoints")
+	rootCmd.PersistentFlags().StringP("client-cert", "", "", "File path to client cert in PEM format used for mTLS communication with the PolicyServer endpoints")
+	rootCmd.PersistentFlags().StringP("client-key", "", "", "File path to client key in PEM format used for mTLS communication with the PolicyServer endpoints")
 	rootCmd.MarkFlagsRequiredTogether("client-cert", "client-key")
-	rootCmd.Flags().BoolVar(&disableStore, "disable-store", false, "disable storing the results in the k8s cluster")
-	rootCmd.Flags().IntP("parallel-namespaces", "", defaultParallelNamespaces, "number of Namespaces to scan in parallel")
-	rootCmd.Flags().IntP("parallel-resources", "", defaultParallelResources, "number of resources to scan in parallel")
-	rootCmd.Flags().IntP("parallel-policies", "", defaultParallelPolicies, "number of policies to evaluate for a given resource in parallel")
-	rootCmd.Flags().IntP("page-size", "", defaultPageSize, "number of resources to fetch from the Kubernetes API server when paginating")
-	rootCmd.Flags().StringP("report-kind", "", report.PolicyReportKind, "Report resource kind to be used. Supported values are 'openreport' and 'policyreport'")
+	// a resumed scan does not count the results of the resources audited
+	// before the interruption
+	rootCmd.MarkFlagsMutuallyExclusive("fail-on", "enable-checkpoints")
+	rootCmd.PersistentFlags().BoolVar(&flags.disableStore, "disable-store", false, "disable storing the results in the k8s cluster")
+	rootCmd.PersistentFlags().BoolVar(&flags.emitEvents, "emit-events", false, "emit a Kubernetes Event on the resource and on the policy when a policy starts or stops failing for a resource since the previous scan, and count these changes in the AuditRun. Requires the store")
+	rootCmd.PersistentFlags().BoolVar(&flags.incremental, "incremental", false, "reuse the results stored by the previous scan when neither the resource nor the policy changed since then")
+	rootCmd.PersistentFlags().StringSliceVar(&flags.wildcardExcludedResources, "wildcard-excluded-resources", defaultWildcardExcludedResources, "comma separated list of resources, in the resource.group format, not audited by policies with wildcard rules. This flag can be repeated")
+	rootCmd.PersistentFlags().StringVar(&flags.auditUser, "audit-user", "", "username set in the admission requests used to audit the resources. Defaults to the audit-scanner ServiceAccount in the kubewarden-namespace")
+	rootCmd.PersistentFlags().StringSliceVar(&flags.auditGroups, "audit-groups", nil, "comma separated list of groups set in the admission requests used to audit the resources. This flag can be repeated")
+	rootCmd.PersistentFlags().DurationVar(&flags.policyServerClient.Timeout, "policy-server-timeout", defaultPolicyServerTimeout, "timeout of a single request sent to the PolicyServers")
+	rootCmd.PersistentFlags().IntVar(&flags.policyServerClient.MaxRetries, "policy-server-retries", defaultPolicyServerRetries, "number of times a request to a PolicyServer is retried after a connection error or a 429, 502, 503 or 504 status code. Zero disables the retries")
+	rootCmd.PersistentFlags().DurationVar(&flags.policyServerClient.RetryBackoff, "policy-server-retry-backoff", defaultRetryBackoff, "delay before the first retry of a request to a PolicyServer, doubled at every following retry")
+	rootCmd.PersistentFlags().DurationVar(&flags.policyServerClient.MaxRetryBackoff, "policy-server-max-retry-backoff", defaultMaxRetryBackoff, "maximum delay between two retries of a request to a PolicyServer")
+	rootCmd.PersistentFlags().IntVar(&flags.policyServerClient.CircuitBreakerThreshold, "circuit-breaker-threshold", defaultCircuitBreakerThreshold, "number of consecutive failed requests after which the evaluations against a PolicyServer are paused and reported as skipped. Zero disables the circuit breaker")
+	rootCmd.PersistentFlags().DurationVar(&flags.policyServerClient.CircuitBreakerCooldown, "circuit-breaker-cooldown", defaultCircuitBreakerCooldown, "time the evaluations against an unavailable PolicyServer are paused before trying again")
+	rootCmd.PersistentFlags().BoolVar(&flags.disableLoadBalancing, "disable-load-balancing", false, "send the evaluation requests to the PolicyServer Services, opening a new connection for each request, instead of balancing them across the PolicyServer pods discovered through EndpointSlices")
+	rootCmd.PersistentFlags().BoolVar(&flags.otlpMetrics, "enable-otlp-metrics", false, "export the metrics of the audit to an OpenTelemetry collector, configured by the OTEL_EXPORTER_OTLP_* environment variables")
+	rootCmd.PersistentFlags().StringVar(&flags.metricsBindAddress, "metrics-bind-address", "", "address serving the metrics of the audit in the Prometheus format, on the /metrics path. Example: :8080. Disabled when empty")
+	rootCmd.PersistentFlags().StringVar(&flags.notificationConfig, "notification-config", "", "YAML file configuring the sinks notified of the failures found by the scans: generic webhooks, CloudEvents receivers and Slack-compatible incoming webhooks. The ${VAR} references to environment variables are expanded. Disabled when empty")
+	rootCmd.PersistentFlags().StringVar(&flags.outputFormat, "output-format", "", fmt.Sprintf("write the results of the scan in a machine-readable format. Supported values are: %v", output.Formats))
+	rootCmd.PersistentFlags().StringVar(&flags.outputFile, "output-file", "", "file where the results of the scan are written in the format set by output-format. Defaults to stdout, in which case the logs are written to stderr")
+	rootCmd.PersistentFlags().IntP("parallel-namespaces", "", defaultParallelNamespaces, "number of Namespaces to scan in parallel")
+	rootCmd.PersistentFlags().IntP("parallel-resources", "", defaultParallelResources, "number of resources to scan in parallel")
+	rootCmd.PersistentFlags().IntP("parallel-policies", "", defaultParallelPolicies, "number of policies to evaluate for a given resource in parallel")
+	rootCmd.PersistentFlags().IntP("page-size", "", defaultPageSize, "number of resources to fetch from the Kubernetes API server when paginating")
+	rootCmd.PersistentFlags().StringP("report-kind", "", report.PolicyReportKind, "Report resource kind to be used. Supported values are 'openreport' and 'policyreport'")
+	rootCmd.PersistentFlags().StringVar(&flags.reportStoreURL, "report-store-url", "", fmt.Sprintf("URL of the store where the reports are written instead of the Kubernetes API server: file:///path/to/directory, sqlite:///path/to/database.db or s3://bucket/prefix?endpoint=host:port&region=region&insecure=true. Supported schemes are: %v. The reports are encoded like the resources of the report-kind flag", report.StoreSchemes))
+
+	rootCmd.AddCommand(newWatchCommand(flags))
+	rootCmd.AddCommand(newScanFilesCommand(flags))
 
 	return rootCmd
 }
 
+// newScanner builds a Scanner out of the flags shared by all the commands.
+//
+//nolint:gocognit,funlen // This function reads all the CLI flags and it's expected to be long.
+func newScanner(cmd *cobra.Command, flags *scannerFlags, scanOutput output.Writer, violations *report.Violations) (*scanner.Scanner, error) {
+	kubewardenNamespace, err := cmd.Flags().GetString("kubewarden-namespace")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubewarden-namespace flag: %w", err)
+	}
+	policyServerURL, err := cmd.Flags().GetString("policy-server-url")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy-server-url flag: %w", err)
+	}
+	tlsConfig, err := getTLSConfig(cmd, flags)
+	if err != nil {
+		return nil, err
+	}
+	parallelNamespacesAudits, err := cmd.Flags().GetInt("parallel-namespaces")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parallel-namespaces flag: %w", err)
+	}
+	parallelResourcesAudits, err := cmd.Flags().GetInt("parallel-resources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parallel-resources flag: %w", err)
+	}
+	parallelPoliciesAudit, err := cmd.Flags().GetInt("parallel-policies")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parallel-policies flag: %w", err)
+	}
+	pageSize, err := cmd.Flags().GetInt("page-size")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page-size flag: %w", err)
+	}
+	reportKind, err := getReportKind(cmd)
+	if err != nil {
+		return nil, err
+	}
+	auditShard, err := shard.New(flags.shardIndex, flags.shardCount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shard flags: %w", err)
+	}
+	policyFilter := policies.Filter{
+		Policies:  flags.policies,
+		Resources: flags.resources,
+	}
+	if err = policyFilter.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy or resource flags: %w", err)
+	}
+	selectors, err := getSelectors(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	config := ctrl.GetConfigOrDie()
+	dynamicClient := dynamic.NewForConfigOrDie(config)
+	clientset := kubernetes.NewForConfigOrDie(config)
+
+	auditScheme, err := scheme.NewScheme()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheme: %w", err)
+	}
+	client, err := client.New(config, client.Options{Scheme: auditScheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	logger := slog.New(NewHandler(logsOutput(flags), flags.level))
+
+	wildcardExcludedResources := make([]schema.GroupResource, 0, len(flags.wildcardExcludedResources))
+	for _, resource := range flags.wildcardExcludedResources {
+		wildcardExcludedResources = append(wildcardExcludedResources, schema.ParseGroupResource(resource))
+	}
+	discoveryClient := memory.NewMemCacheClient(clientset.Discovery())
+	policiesClient := policies.NewClient(client, discoveryClient, kubewardenNamespace, policyServerURL, wildcardExcludedResources, policyFilter, logger)
+
+	k8sClient := k8s.NewClient(dynamicClient, clientset, kubewardenNamespace, flags.skippedNs, int64(pageSize), selectors, logger)
+	reportStore, err := newReportStore(flags.reportStoreURL, reportKind, client, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	scannerConfig := scanner.Config{
+		PoliciesClient: policiesClient,
+		K8sClient:      k8sClient,
+		ReportStore:    reportStore,
+		TLS:            tlsConfig,
+		Parallelization: scanner.ParallelizationConfig{
+			ParallelNamespacesAudits: parallelNamespacesAudits,
+			ParallelResourcesAudits:  parallelResourcesAudits,
+			PoliciesAudits:           parallelPoliciesAudit,
+		},
+		PolicyServerClient: flags.policyServerClient,
+		Endpoints:          newEndpointsConfig(clientset, kubewardenNamespace, policyServerURL, flags.disableLoadBalancing),
+		OutputScan:         flags.outputScan,
+		Output:             scanOutput,
+		DisableStore:       flags.disableStore,
+		Incremental:        flags.incremental,
+		UserInfo:           newAuditUserInfo(flags.auditUser, flags.auditGroups, kubewardenNamespace),
+		Logger:             logger.With("component", "scanner"),
+		ReportKind:         reportKind,
+		Shard:              auditShard,
+		Violations:         violations,
+		AuditSchedule:      flags.auditSchedule,
+		// the flag is only registered by the root command, the watch
+		// command evaluates the resources as they change
+		DeduplicateEvaluations: flags.deduplicateEvaluations,
+	}
+	if flags.checkpoints {
+		scope, err := checkpointScope(cmd, flags)
+		if err != nil {
+			return nil, err
+		}
+		scannerConfig.Checkpoint = checkpoint.NewStore(clientset, kubewardenNamespace, auditShard, scope, logger)
+	}
+	if !flags.disableStore {
+		scannerConfig.AuditRun = auditrun.NewRecorder(client, flags.auditRunHistory, auditShard, flags.auditSchedule, logger)
+	}
+	if flags.emitEvents {
+		if flags.disableStore {
+			logger.Warn("no Event is emitted when the store is disabled, as the results are not compared with the previous ones")
+		}
+		scannerConfig.Events = events.NewRecorder(clientset, auditScheme, logger)
+	}
+	if flags.notificationConfig != "" {
+		notificationConfig, err := notification.LoadConfig(flags.notificationConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the notification config: %w", err)
+		}
+		scannerConfig.Notifier = notification.NewNotifier(notificationConfig, logger)
+		if flags.disableStore && scannerConfig.Notifier.NotifiesNewFailures() {
+			logger.Warn("no new failure is notified when the store is disabled, as the results are not compared with the previous ones")
+		}
+	}
+
+	scanner, err := scanner.NewScanner(scannerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scanner: %w", err)
+	}
+	return scanner, nil
+}
+
+// newReportStore returns the store of the reports: the Kubernetes API server,
+// unless the URL of another store is set.
+func newReportStore(storeURL string, reportKind report.CrdKind, kubeClient client.Client, logger *slog.Logger) (report.Store, error) {
+	if storeURL != "" {
+		reportStore, err := report.NewDocumentStoreFromURL(context.Background(), reportKind, storeURL, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the report store: %w", err)
+		}
+		return reportStore, nil
+	}
+
+	if reportKind == report.ReportKindOpenReport {
+		if err := report.DeleteAllLegacyPolicyReports(context.Background(), kubeClient, logger); err != nil {
+			logger.Warn("Failed to delete legacy wgpolicyk8s.io PolicyReports, continuing", "error", err)
+		}
+	}
+	return report.NewReportStoreOfKind(reportKind, kubeClient, logger), nil
+}
+
+// getTLSConfig returns the TLS configuration of the connections to the PolicyServers.
+func getTLSConfig(cmd *cobra.Command, flags *scannerFlags) (scanner.TLSConfig, error) {
+	caFile, err := cmd.Flags().GetString("extra-ca")
+	if err != nil {
+		return scanner.TLSConfig{}, fmt.Errorf("failed to get extra-ca flag: %w", err)
+	}
+	clientCertFile, err := cmd.Flags().GetString("client-cert")
+	if err != nil {
+		return scanner.TLSConfig{}, fmt.Errorf("failed to get client-cert flag: %w", err)
+	}
+	clientKeyFile, err := cmd.Flags().GetString("client-key")
+	if err != nil {
+		return scanner.TLSConfig{}, fmt.Errorf("failed to get client-key flag: %w", err)
+	}
+
+	return scanner.TLSConfig{
+		Insecure:       flags.insecureSSL,
+		CAFile:         caFile,
+		ClientCertFile: clientCertFile,
+		ClientKeyFile:  clientKeyFile,
+	}, nil
+}
+
+// getSelectors returns the label selectors of the namespaces and of the resources
+// audited, set by the namespace-selector and resource-selector flags.
+func getSelectors(flags *scannerFlags) (k8s.Selectors, error) {
+	var selectors k8s.Selectors
+	if flags.namespaceSelector != "" {
+		namespaceSelector, err := labels.Parse(flags.namespaceSelector)
+		if err != nil {
+			return k8s.Selectors{}, fmt.Errorf("invalid namespace-selector flag: %w", err)
+		}
+		selectors.Namespace = namespaceSelector
+	}
+	if flags.resourceSelector != "" {
+		resourceSelector, err := labels.Parse(flags.resourceSelector)
+		if err != nil {
+			return k8s.Selectors{}, fmt.Errorf("invalid resource-selector flag: %w", err)
+		}
+		selectors.Resource = resourceSelector
+	}
+	return selectors, nil
+}
+
+// getReportKind returns the kind of the reports selected by the report-kind flag.
+func getReportKind(cmd *cobra.Command) (report.CrdKind, error) {
+	reportKindStr, err := cmd.Flags().GetString("report-kind")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get report-kind flag: %w", err)
+	}
+
+	switch reportKindStr {
+	case report.OpenReportsKind:
+		return report.ReportKindOpenReport, nil
+	case report.PolicyReportKind:
+		return report.ReportKindPolicyReport, nil
+	default:
+		return 0, fmt.Errorf("invalid report-kind '%s': supported values are '%s' and '%s'", reportKindStr, report.OpenReportsKind, report.PolicyReportKind)
+	}
+}
+
+// newEndpointsConfig returns the configuration of the load balancing of the requests
+// across the PolicyServer pods. The load balancing is disabled when the PolicyServers
+// are reached through the policy-server-url flag, for out-of-cluster debugging.
+func newEndpointsConfig(clientset kubernetes.Interface, kubewardenNamespace, policyServerURL string, disableLoadBalancing bool) scanner.EndpointsConfig {
+	if disableLoadBalancing || policyServerURL != "" {
+		return scanner.EndpointsConfig{}
+	}
+
+	return scanner.EndpointsConfig{
+		Clientset: clientset,
+		Namespace: kubewardenNamespace,
+	}
+}
+
+// newAuditUserInfo returns the identity set in the admission requests used to audit the resources.
+// By default, it's the identity of the audit-scanner ServiceAccount.
+func newAuditUserInfo(username string, groups []string, kubewardenNamespace string) authenticationv1.UserInfo {
+	if username == "" {
+		username = serviceaccount.MakeUsername(kubewardenNamespace, defaultAuditScannerServiceAccount)
+		groups = append(serviceaccount.MakeGroupNames(kubewardenNamespace), groups...)
+	}
+
+	if !slices.Contains(groups, user.AllAuthenticated) {
+		groups = append(groups, user.AllAuthenticated)
+	}
+
+	return authenticationv1.UserInfo{
+		Username: username,
+		Groups:   groups,
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute(rootCmd *cobra.Command) {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error on cmd.Execute(): %s\n", err.Error())
+		if errors.Is(err, errViolations) {
+			os.Exit(exitCodeViolations)
+		}
 		os.Exit(1)
 	}
 }
 
-//nolint:wrapcheck // this function calls internal package which already wrap the errors with context
+// startMetrics starts exporting the metrics of the audit as configured by the flags,
+// and returns the function flushing the pending metrics.
+func startMetrics(flags *scannerFlags) (func(), error) {
+	logger := slog.New(NewHandler(logsOutput(flags), flags.level)).With("component", "metrics")
+
+	shutdown, err := metrics.New(context.Background(), metrics.Options{
+		OTLP:              flags.otlpMetrics,
+		PrometheusAddress: flags.metricsBindAddress,
+	}, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the metrics exporters: %w", err)
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+		defer cancel()
+		if shutdownErr := shutdown(ctx); shutdownErr != nil {
+			logger.Error("failed to flush the metrics", slog.String("error", shutdownErr.Error()))
+		}
+	}, nil
+}
+
+// openOutput opens the machine-readable output of the scan results selected by
+// the flags. No output is written when the output-format flag is not set.
+func openOutput(flags *scannerFlags) (output.Writer, error) {
+	if flags.outputFormat == "" {
+		if flags.outputFile != "" {
+			return nil, errors.New("the output-file flag requires the output-format flag")
+		}
+		return nil, nil //nolint:nilnil // no output is written
+	}
+
+	format, err := output.ParseFormat(flags.outputFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output-format flag: %w", err)
+	}
+	scanOutput, err := output.New(format, flags.outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the scan output: %w", err)
+	}
+	return scanOutput, nil
+}
+
+// checkpointScope returns the scope of the checkpoints: the flags selecting the
+// namespaces, resources and policies audited. A scan only resumes the
+// interrupted scans of the same scope.
+func checkpointScope(cmd *cobra.Command, flags *scannerFlags) (string, error) {
+	namespace, err := cmd.Flags().GetString("namespace")
+	if err != nil {
+		return "", fmt.Errorf("failed to get namespace flag %w", err)
+	}
+	clusterWide, err := cmd.Flags().GetBool("cluster")
+	if err != nil {
+		return "", fmt.Errorf("failed to get cluster flag: %w", err)
+	}
+
+	scope := []string{
+		"namespace=" + namespace,
+		"cluster=" + strconv.FormatBool(clusterWide),
+		"policies=" + strings.Join(slices.Sorted(slices.Values(flags.policies)), ","),
+		"resources=" + strings.Join(slices.Sorted(slices.Values(flags.resources)), ","),
+		"namespace-selector=" + flags.namespaceSelector,
+		"resource-selector=" + flags.resourceSelector,
+		"audit-schedule=" + flags.auditSchedule,
+	}
+	return strings.Join(scope, ";"), nil
+}
+
+// outputToStdout returns true when the machine-readable output of the scan
+// is written to stdout.
+func outputToStdout(flags *scannerFlags) bool {
+	return flags.outputFormat != "" && (flags.outputFile == "" || flags.outputFile == "-")
+}
+
+// logsOutput returns where the logs are written: stderr when the
+// machine-readable output of the scan is written to stdout, so it can be
+// parsed, stdout otherwise.
+func logsOutput(flags *scannerFlags) io.Writer {
+	if outputToStdout(flags) {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// closeOutput writes the pending results of the scan, if any.
+func closeOutput(scanOutput output.Writer) error {
+	if scanOutput == nil {
+		return nil
+	}
+	if err := scanOutput.Close(); err != nil {
+		return fmt.Errorf("failed to write the scan output: %w", err)
+	}
+	return nil
+}
+
+// newViolations returns the counter of the results selected by the fail-on and
+// min-severity flags, nil when the results never fail the scan.
+func newViolations(failOn []string, minSeverity string) (*report.Violations, error) {
+	threshold := report.Threshold{
+		FailOn:      failOn,
+		MinSeverity: minSeverity,
+	}
+	if err := threshold.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid fail-on or min-severity flags: %w", err)
+	}
+	if threshold.IsEmpty() {
+		if minSeverity != "" {
+			return nil, errors.New("the min-severity flag requires the fail-on flag")
+		}
+		return nil, nil //nolint:nilnil // the results never fail the scan
+	}
+	return report.NewViolations(threshold), nil
+}
+
+// checkViolations writes the summary of the violations, and returns
+// errViolations when some were found.
+func checkViolations(writer io.Writer, violations *report.Violations) error {
+	if violations == nil || violations.Total() == 0 {
+		return nil
+	}
+	if err := violations.WriteSummary(writer); err != nil {
+		return err //nolint:wrapcheck // the error is already wrapped by the report package
+	}
+	return fmt.Errorf("%w: %d results", errViolations, violations.Total())
+}
+
 func startScanner(namespace string, clusterWide bool, scanner *scanner.Scanner) error {
 	if clusterWide && namespace != "" {
 		fmt.Fprintln(os.Stderr, "Cannot scan cluster wide and only a namespace at the same time")
 	}
 
-	runUID := uuid.New().String()
-	ctx := context.Background()
+	recorder, err := metrics.NewRecorder(otel.GetMeterProvider())
+	if err != nil {
+		return fmt.Errorf("failed to create the metrics recorder: %w", err)
+	}
+
+	// An evicted scan is stopped before the old reports of the namespace
+	// being scanned are deleted, so it can be resumed by the next run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runUID, err := scanner.StartRun(ctx)
+	if err != nil {
+		return err //nolint:wrapcheck // the error is already wrapped by the scanner
+	}
+	start := time.Now()
+	err = runScanner(ctx, namespace, clusterWide, scanner, runUID)
+	recorder.RecordRun(ctx, runUID, time.Since(start), err != nil)
+	scanner.FinishRun(ctx, err)
+
+	return err
+}
+
+// runScanner scans the resources selected by the flags.
+//
+//nolint:wrapcheck // this function calls internal package which already wrap the errors with context
+func runScanner(ctx context.Context, namespace string, clusterWide bool, scanner *scanner.Scanner, runUID string) error {
 	if clusterWide {
 		// only scan clusterwide
 		return scanner.ScanClusterWideResources(ctx, runUID)
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogsOutput(t *testing.T) {
+	tests := []struct {
+		name           string
+		flags          scannerFlags
+		outputToStdout bool
+	}{
+		{"no output format", scannerFlags{}, false},
+		{"output to stdout by default", scannerFlags{outputFormat: "csv"}, true},
+		{"output to stdout", scannerFlags{outputFormat: "csv", outputFile: "-"}, true},
+		{"output to a file", scannerFlags{outputFormat: "csv", outputFile: "results.csv"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.outputToStdout, outputToStdout(&test.flags))
+			if test.outputToStdout {
+				assert.Equal(t, os.Stderr, logsOutput(&test.flags))
+			} else {
+				assert.Equal(t, os.Stdout, logsOutput(&test.flags))
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/manifests"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/output"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/scanner"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/scheme"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultManifestsNamespace = "default"
+
+// scanFilesFlags holds the values of the flags of the scan-files command.
+type scanFilesFlags struct {
+	policies  []string // files or directories with the policies, read from the cluster when empty.
+	namespace string   // namespace of the namespaced resources without one.
+	// results and minimum severity of the results failing the scan.
+	failOn      []string
+	minSeverity string
+}
+
+func newScanFilesCommand(flags *scannerFlags) *cobra.Command {
+	scanFlags := &scanFilesFlags{}
+
+	scanFilesCmd := &cobra.Command{
+		Use:   "scan-files [flags] PATH...",
+		Short: "Audits the resources of local manifests, without reading them from the cluster",
+		Long: `Audits the resources defined by YAML or JSON manifests, read from files, directories or stdin when the path is "-".
+The policies are read from the cluster, or from local manifests with the --policies flag, and are evaluated by the PolicyServer at --policy-server-url.
+The reports are printed to stdout and are not stored in the cluster. The command fails with exit code 2 when a policy rejects a resource, or cannot evaluate it,
+and prints a summary of these results to stderr. The results failing the command are selected by the --fail-on and --min-severity flags.
+
+Example: helm template ./chart | audit-scanner scan-files --policies policies/ --policy-server-url https://localhost:3000 -`,
+		Args: cobra.MinimumNArgs(1),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			violations, err := newViolations(scanFlags.failOn, scanFlags.minSeverity)
+			if err != nil {
+				return err
+			}
+			scanOutput, err := openOutput(flags)
+			if err != nil {
+				return err
+			}
+
+			reports, err := scanFiles(cmd, flags, scanFlags, args, scanOutput, violations)
+			if err = errors.Join(err, closeOutput(scanOutput)); err != nil {
+				return err
+			}
+
+			if !outputToStdout(flags) {
+				if err = printReports(cmd.OutOrStdout(), reports); err != nil {
+					return err
+				}
+			}
+
+			return checkViolations(cmd.ErrOrStderr(), violations)
+		},
+	}
+
+	scanFilesCmd.Flags().StringSliceVar(&scanFlags.policies, "policies", nil, "comma separated list of files or directories with the manifests of the policies to evaluate. The policies are read from the cluster when not set. This flag can be repeated")
+	scanFilesCmd.Flags().StringVarP(&scanFlags.namespace, "namespace", "n", defaultManifestsNamespace, "namespace of the namespaced resources whose manifest does not set one")
+	scanFilesCmd.Flags().StringSliceVar(&scanFlags.failOn, "fail-on", slices.Clone(report.ThresholdResults), fmt.Sprintf("comma separated list of the results failing the command with exit code %d. Supported values are: %v. Set it to an empty value to never fail because of the results", exitCodeViolations, report.ThresholdResults))
+	scanFilesCmd.Flags().StringVar(&scanFlags.minSeverity, "min-severity", "", fmt.Sprintf("lowest severity of the results failing the command, see the fail-on flag. Supported values are: %v. The results of the policies without a severity are ignored when set", report.Severities))
+
+	return scanFilesCmd
+}
+
+// scanFiles audits the resources of the manifests found at the given paths.
+func scanFiles(cmd *cobra.Command, flags *scannerFlags, scanFlags *scanFilesFlags, paths []string, scanOutput output.Writer, violations *report.Violations) ([]report.Report, error) {
+	policyServerURL, err := cmd.Flags().GetString("policy-server-url")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy-server-url flag: %w", err)
+	}
+	if policyServerURL == "" {
+		return nil, errors.New("the scan-files command requires the policy-server-url flag")
+	}
+	kubewardenNamespace, err := cmd.Flags().GetString("kubewarden-namespace")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubewarden-namespace flag: %w", err)
+	}
+	parallelPoliciesAudit, err := cmd.Flags().GetInt("parallel-policies")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parallel-policies flag: %w", err)
+	}
+	tlsConfig, err := getTLSConfig(cmd, flags)
+	if err != nil {
+		return nil, err
+	}
+	reportKind, err := getReportKind(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// the reports are printed to stdout, keep the logs out of them
+	logger := slog.New(NewHandler(os.Stderr, flags.level))
+	ctx := context.Background()
+
+	resources, err := manifests.Load(paths, cmd.InOrStdin())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the manifests: %w", err)
+	}
+
+	matcherConfig := policies.ResourceMatcherConfig{
+		PolicyServerURL:  policyServerURL,
+		DefaultNamespace: scanFlags.namespace,
+	}
+	for _, resource := range flags.wildcardExcludedResources {
+		matcherConfig.WildcardExcludedResources = append(matcherConfig.WildcardExcludedResources, schema.ParseGroupResource(resource))
+	}
+	if len(scanFlags.policies) > 0 {
+		err = loadLocalPolicies(scanFlags.policies, &matcherConfig)
+	} else {
+		err = loadClusterPolicies(ctx, kubewardenNamespace, policyServerURL, logger, &matcherConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := manifestsNamespaces(resources)
+	if err != nil {
+		return nil, err
+	}
+	matcherConfig.Namespaces = append(matcherConfig.Namespaces, namespaces...)
+
+	matcher, err := policies.NewResourceMatcher(matcherConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the policies matcher: %w", err)
+	}
+
+	scanner, err := scanner.NewScanner(scanner.Config{
+		ReportKind: reportKind,
+		TLS:        tlsConfig,
+		Parallelization: scanner.ParallelizationConfig{
+			PoliciesAudits: parallelPoliciesAudit,
+		},
+		PolicyServerClient: flags.policyServerClient,
+		OutputScan:         flags.outputScan,
+		Output:             scanOutput,
+		DisableStore:       true,
+		UserInfo:           newAuditUserInfo(flags.auditUser, flags.auditGroups, kubewardenNamespace),
+		Violations:         violations,
+		Logger:             logger.With("component", "scanner"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scanner: %w", err)
+	}
+	defer scanner.Close()
+
+	reports, err := scanner.ScanResources(ctx, resources, matcher, uuid.New().String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan the manifests: %w", err)
+	}
+	return reports, nil
+}
+
+// loadLocalPolicies reads the policies from the manifests found at the given paths.
+func loadLocalPolicies(paths []string, matcherConfig *policies.ResourceMatcherConfig) error {
+	objects, err := manifests.Load(paths, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load the policies: %w", err)
+	}
+	matcherConfig.Policies, err = policies.FromObjects(objects, matcherConfig.DefaultNamespace)
+	if err != nil {
+		return fmt.Errorf("failed to load the policies: %w", err)
+	}
+	return nil
+}
+
+// loadClusterPolicies reads the policies and the namespaces from the cluster.
+// The RESTMapper of the cluster is used to find the resources of the manifests.
+func loadClusterPolicies(ctx context.Context, kubewardenNamespace, policyServerURL string, logger *slog.Logger, matcherConfig *policies.ResourceMatcherConfig) error {
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get the kubernetes config: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes clientset: %w", err)
+	}
+	auditScheme, err := scheme.NewScheme()
+	if err != nil {
+		return fmt.Errorf("failed to create scheme: %w", err)
+	}
+	client, err := client.New(config, client.Options{Scheme: auditScheme})
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	discoveryClient := memory.NewMemCacheClient(clientset.Discovery())
+	policiesClient := policies.NewClient(client, discoveryClient, kubewardenNamespace, policyServerURL, matcherConfig.WildcardExcludedResources, policies.Filter{}, logger)
+	matcherConfig.Policies, err = policiesClient.ListPolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the policies: %w", err)
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err = client.List(ctx, namespaces); err != nil {
+		return fmt.Errorf("failed to list the namespaces: %w", err)
+	}
+	matcherConfig.Namespaces = namespaces.Items
+	matcherConfig.RESTMapper = client.RESTMapper()
+
+	return nil
+}
+
+// manifestsNamespaces returns the namespaces defined by the manifests, so
+// their labels are used to evaluate the namespaceSelector of the policies.
+func manifestsNamespaces(resources []unstructured.Unstructured) ([]corev1.Namespace, error) {
+	var namespaces []corev1.Namespace
+	for _, resource := range resources {
+		if resource.GroupVersionKind() != corev1.SchemeGroupVersion.WithKind("Namespace") {
+			continue
+		}
+		namespace := corev1.Namespace{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Object, &namespace); err != nil {
+			return nil, fmt.Errorf("failed to decode namespace %s: %w", resource.GetName(), err)
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, nil
+}
+
+// printReports prints the reports as a stream of YAML documents.
+func printReports(writer io.Writer, reports []report.Report) error {
+	for _, r := range reports {
+		reportYAML, err := yaml.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to print the report: %w", err)
+		}
+		if _, err = fmt.Fprintf(writer, "---\n%s", reportYAML); err != nil {
+			return fmt.Errorf("failed to print the report: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testPolicies = `apiVersion: policies.kubewarden.io/v1
+kind: ClusterAdmissionPolicy
+metadata:
+  name: no-privileged
+spec:
+  module: registry://ghcr.io/kubewarden/policies/pod-privileged:v1.0.0
+  rules:
+    - apiGroups: [""]
+      apiVersions: ["v1"]
+      resources: ["pods"]
+      operations: ["CREATE"]
+---
+apiVersion: policies.kubewarden.io/v1
+kind: AdmissionPolicy
+metadata:
+  name: team-policy
+spec:
+  module: registry://ghcr.io/kubewarden/policies/pod-privileged:v1.0.0
+  rules:
+    - apiGroups: [""]
+      apiVersions: ["v1"]
+      resources: ["pods"]
+      operations: ["CREATE"]
+`
+
+const testResources = `apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+spec:
+  containers:
+    - name: nginx
+      image: nginx
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: privileged
+  namespace: default
+spec:
+  containers:
+    - name: nginx
+      image: nginx
+`
+
+// policyServerStub is a PolicyServer rejecting the resources named privileged.
+type policyServerStub struct {
+	*httptest.Server
+	mutex sync.Mutex
+	// requests are the paths of the requests received, one per evaluation
+	requests []string
+}
+
+func newPolicyServerStub(t *testing.T) *policyServerStub {
+	t.Helper()
+	stub := &policyServerStub{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		stub.mutex.Lock()
+		stub.requests = append(stub.requests, request.URL.Path)
+		stub.mutex.Unlock()
+
+		review := admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(request.Body).Decode(&review); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resource := unstructured.Unstructured{}
+		if err := resource.UnmarshalJSON(review.Request.Object.Raw); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		review.Response = &admissionv1.AdmissionResponse{
+			UID:     review.Request.UID,
+			Allowed: resource.GetName() != "privileged",
+		}
+		review.Request = nil
+		if err := json.NewEncoder(writer).Encode(review); err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// evaluatedPolicies returns the sorted paths of the requests received.
+func (s *policyServerStub) evaluatedPolicies() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Sorted(slices.Values(s.requests))
+}
+
+// runScanFiles runs the scan-files command with the given flags, reading the
+// manifests of the resources from stdin, and returns what it printed to stdout
+// and stderr.
+func runScanFiles(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+	policiesPath := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(policiesPath, []byte(testPolicies), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	cmd := NewRootCommand()
+	cmd.SetArgs(append([]string{"scan-files", "--loglevel", "info", "--policies", policiesPath}, args...))
+	cmd.SetIn(strings.NewReader(testResources))
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	err := cmd.Execute()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestScanFiles(t *testing.T) {
+	policyServer := newPolicyServerStub(t)
+
+	stdout, stderr, err := runScanFiles(t, "--policy-server-url", policyServer.URL, "-")
+	require.ErrorIs(t, err, errViolations)
+	assert.ErrorContains(t, err, "2 results")
+
+	// both pods are evaluated by both policies, the AdmissionPolicy without a
+	// namespace being in the namespace of the --namespace flag
+	assert.Equal(t, []string{
+		"/audit/clusterwide-no-privileged",
+		"/audit/clusterwide-no-privileged",
+		"/audit/namespaced-default-team-policy",
+		"/audit/namespaced-default-team-policy",
+	}, policyServer.evaluatedPolicies())
+
+	assert.Equal(t, 2, strings.Count(stdout, "---\n"))
+	assert.Contains(t, stdout, "name: nginx")
+	assert.Contains(t, stdout, "name: privileged")
+	assert.Contains(t, stderr, "clusterwide-no-privileged")
+	assert.Contains(t, stderr, "namespaced-default-team-policy")
+}
+
+func TestScanFilesWithoutViolations(t *testing.T) {
+	policyServer := newPolicyServerStub(t)
+
+	stdout, stderr, err := runScanFiles(t, "--policy-server-url", policyServer.URL, "--fail-on", "error", "-")
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(stdout, "---\n"))
+	assert.Empty(t, stderr)
+}
+
+func TestScanFilesFlagValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{"missing path", []string{"--policy-server-url", "http://localhost"}, "requires at least 1 arg"},
+		{"missing policy-server-url", []string{"-"}, "requires the policy-server-url flag"},
+		{"invalid fail-on", []string{"--policy-server-url", "http://localhost", "--fail-on", "warn", "-"}, "invalid fail-on or min-severity flags"},
+		{"output-file without output-format", []string{"--policy-server-url", "http://localhost", "--output-file", "results.csv", "-"}, "requires the output-format flag"},
+		{"invalid output-format", []string{"--policy-server-url", "http://localhost", "--output-format", "xml", "-"}, "invalid output format"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := runScanFiles(t, test.args...)
+			require.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestScanFilesOutputFile(t *testing.T) {
+	policyServer := newPolicyServerStub(t)
+	outputPath := filepath.Join(t.TempDir(), "results.ndjson")
+
+	stdout, _, err := runScanFiles(t, "--policy-server-url", policyServer.URL, "--output-format", "ndjson", "--output-file", outputPath, "-")
+	require.ErrorIs(t, err, errViolations)
+
+	// the reports are still printed, the results are written to the file
+	assert.Equal(t, 2, strings.Count(stdout, "---\n"))
+	results, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(results)), "\n"), 4)
+}
+
+func TestScanFilesOutputToStdout(t *testing.T) {
+	policyServer := newPolicyServerStub(t)
+
+	// the results are written to the standard output of the process
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = writer
+	t.Cleanup(func() { os.Stdout = stdout })
+	results := make(chan []byte)
+	go func() {
+		content, _ := io.ReadAll(reader)
+		results <- content
+	}()
+
+	cmdStdout, _, err := runScanFiles(t, "--policy-server-url", policyServer.URL, "--output-format", "ndjson", "-")
+	require.ErrorIs(t, err, errViolations)
+	os.Stdout = stdout
+	require.NoError(t, writer.Close())
+
+	// only the results are written to stdout, so they can be parsed
+	assert.Empty(t, cmdStdout)
+	lines := strings.Split(strings.TrimSpace(string(<-results)), "\n")
+	require.Len(t, lines, 4)
+	for _, line := range lines {
+		assert.True(t, json.Valid([]byte(line)), line)
+	}
+}
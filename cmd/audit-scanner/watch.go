@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/output"
+)
+
+func newWatchCommand(flags *scannerFlags) *cobra.Command {
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Continuously audits resources as soon as they, or the policies targeting them, change",
+		Long: `Watches the resources targeted by your already deployed Kubewarden policies and audits them as soon as they change.
+When a policy changes, all the resources targeted by it are audited again.
+Reports are kept up to date without the need of periodic full scans.`,
+
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			resyncPeriod, err := cmd.Flags().GetDuration("resync-period")
+			if err != nil {
+				return fmt.Errorf("failed to get resync-period flag: %w", err)
+			}
+
+			if flags.outputFormat != "" {
+				format, err := output.ParseFormat(flags.outputFormat)
+				if err != nil {
+					return err
+				}
+				if !slices.Contains(output.StreamingFormats, format) {
+					return fmt.Errorf("the watch command only supports the output formats %v", output.StreamingFormats)
+				}
+			}
+
+			shutdownMetrics, err := startMetrics(flags)
+			if err != nil {
+				return err
+			}
+			defer shutdownMetrics()
+
+			scanOutput, err := openOutput(flags)
+			if err != nil {
+				return err
+			}
+
+			scanner, err := newScanner(cmd, flags, scanOutput, nil)
+			if err != nil {
+				return errors.Join(err, closeOutput(scanOutput))
+			}
+			defer scanner.Close()
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			err = scanner.Watch(ctx, uuid.New().String(), resyncPeriod)
+			return errors.Join(err, closeOutput(scanOutput))
+		},
+	}
+
+	watchCmd.Flags().Duration("resync-period", 0, "period after which all the watched resources are audited again, even if they did not change. Disabled when 0")
+
+	return watchCmd
+}
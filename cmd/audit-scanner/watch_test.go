@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apiResources are the resources served by the API server stub, by group version.
+var apiResources = map[string][]map[string]any{
+	"v1": {
+		{"name": "namespaces", "kind": "Namespace", "namespaced": false, "verbs": []string{"get", "list", "watch"}},
+	},
+	"policies.kubewarden.io/v1": {
+		{"name": "clusteradmissionpolicies", "kind": "ClusterAdmissionPolicy", "namespaced": false, "verbs": []string{"get", "list", "watch"}},
+		{"name": "clusteradmissionpolicygroups", "kind": "ClusterAdmissionPolicyGroup", "namespaced": false, "verbs": []string{"get", "list", "watch"}},
+		{"name": "admissionpolicies", "kind": "AdmissionPolicy", "namespaced": true, "verbs": []string{"get", "list", "watch"}},
+		{"name": "admissionpolicygroups", "kind": "AdmissionPolicyGroup", "namespaced": true, "verbs": []string{"get", "list", "watch"}},
+	},
+}
+
+// newAPIServerStub starts a Kubernetes API server without any namespace nor
+// policy and points the KUBECONFIG environment variable to it.
+func newAPIServerStub(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		query := request.URL.Query()
+		switch {
+		case request.URL.Path == "/api":
+			writeJSON(writer, map[string]any{"kind": "APIVersions", "versions": []string{"v1"}})
+		case request.URL.Path == "/apis":
+			writeJSON(writer, map[string]any{
+				"kind":       "APIGroupList",
+				"apiVersion": "v1",
+				"groups": []map[string]any{{
+					"name":             "policies.kubewarden.io",
+					"versions":         []map[string]string{{"groupVersion": "policies.kubewarden.io/v1", "version": "v1"}},
+					"preferredVersion": map[string]string{"groupVersion": "policies.kubewarden.io/v1", "version": "v1"},
+				}},
+			})
+		case request.URL.Path == "/api/v1" || request.URL.Path == "/apis/policies.kubewarden.io/v1":
+			groupVersion := strings.TrimPrefix(strings.TrimPrefix(request.URL.Path, "/apis/"), "/api/")
+			writeJSON(writer, map[string]any{
+				"kind":         "APIResourceList",
+				"apiVersion":   "v1",
+				"groupVersion": groupVersion,
+				"resources":    apiResources[groupVersion],
+			})
+		case query.Get("watch") == "true" && query.Get("sendInitialEvents") == "true":
+			// the informers fall back to list and watch
+			writer.WriteHeader(http.StatusBadRequest)
+			writeJSON(writer, map[string]any{"kind": "Status", "apiVersion": "v1", "status": "Failure", "code": http.StatusBadRequest})
+		case query.Get("watch") == "true":
+			writer.WriteHeader(http.StatusOK)
+			writer.(http.Flusher).Flush()
+			<-request.Context().Done()
+		default:
+			writeList(writer, request.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfig, fmt.Appendf(nil, `apiVersion: v1
+kind: Config
+clusters:
+  - name: stub
+    cluster:
+      server: %s
+contexts:
+  - name: stub
+    context:
+      cluster: stub
+current-context: stub
+`, server.URL), 0o600))
+	t.Setenv("KUBECONFIG", kubeconfig)
+}
+
+// writeList writes an empty list of the resources of the path.
+func writeList(writer http.ResponseWriter, path string) {
+	for groupVersion, resources := range apiResources {
+		for _, resource := range resources {
+			if strings.HasSuffix(path, "/"+resource["name"].(string)) {
+				writeJSON(writer, map[string]any{
+					"kind":       resource["kind"].(string) + "List",
+					"apiVersion": groupVersion,
+					"metadata":   map[string]string{"resourceVersion": "1"},
+					"items":      []any{},
+				})
+				return
+			}
+		}
+	}
+	writer.WriteHeader(http.StatusNotFound)
+	writeJSON(writer, map[string]any{"kind": "Status", "apiVersion": "v1", "status": "Failure", "code": http.StatusNotFound})
+}
+
+func writeJSON(writer http.ResponseWriter, content any) {
+	_ = json.NewEncoder(writer).Encode(content)
+}
+
+func TestWatchStartupAndShutdown(t *testing.T) {
+	newAPIServerStub(t)
+
+	// the logs are written to stdout
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = writer
+	t.Cleanup(func() { os.Stdout = stdout })
+	logs := make(chan string, 100)
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			logs <- scanner.Text()
+		}
+		close(logs)
+	}()
+	waitForLog := func(message string) {
+		t.Helper()
+		timeout := time.After(30 * time.Second)
+		for {
+			select {
+			case line, ok := <-logs:
+				require.True(t, ok, "the logs ended before %q", message)
+				if strings.Contains(line, fmt.Sprintf("%q", message)) {
+					return
+				}
+			case <-timeout:
+				require.FailNow(t, "timed out waiting for the logs", message)
+			}
+		}
+	}
+
+	cmd := NewRootCommand()
+	cmd.SetArgs([]string{"watch", "--loglevel", "info", "--disable-store", "--policy-server-url", "http://localhost"})
+	result := make(chan error, 1)
+	go func() {
+		result <- cmd.Execute()
+	}()
+
+	waitForLog("auditable policies refreshed")
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case err = <-result:
+		require.NoError(t, err)
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "the watch did not stop")
+	}
+	waitForLog("watch finished")
+	os.Stdout = stdout
+	require.NoError(t, writer.Close())
+}
+
+func TestWatchOutputFormats(t *testing.T) {
+	tests := []struct {
+		format        string
+		expectedError string
+	}{
+		{"sarif", "the watch command only supports the output formats"},
+		{"junit", "the watch command only supports the output formats"},
+		{"xml", "invalid output format"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var stderr bytes.Buffer
+			cmd := NewRootCommand()
+			cmd.SetArgs([]string{"watch", "--loglevel", "info", "--output-format", test.format})
+			cmd.SetOut(&stderr)
+			cmd.SetErr(&stderr)
+			// the format is rejected before connecting to the cluster
+			assert.ErrorContains(t, cmd.Execute(), test.expectedError)
+		})
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8spoliciesv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -95,6 +96,20 @@ type Configuration struct {
 	// the Kubernetes API server cannot reach pod-network webhook endpoints
 	// (e.g. clusters using a non-VPC CNI with NAT).
 	HostNetwork bool
+	// AuditScannerImage is the image of the Jobs created for the
+	// AuditSchedules. The AuditSchedules are not reconciled when empty.
+	AuditScannerImage string
+	// AuditScannerServiceAccountName is the ServiceAccount of the Jobs
+	// created for the AuditSchedules.
+	AuditScannerServiceAccountName string
+	// AuditScannerIgnoredNamespaces, AuditScannerReportKind,
+	// AuditScannerLogLevel and AuditScannerPolicyServerClient are the
+	// settings of the audit scanner shared by all the Jobs created for the
+	// AuditSchedules, matching the ones of the audit scanner CronJob.
+	AuditScannerIgnoredNamespaces  []string
+	AuditScannerReportKind         string
+	AuditScannerLogLevel           string
+	AuditScannerPolicyServerClient controller.AuditScannerPolicyServerClient
 }
 
 func init() {
@@ -117,6 +132,9 @@ func main() {
 	var openTelemetryClientCertificateSecret string
 	var openTelemetryCertificateSecret string
 	var imagePullSecretsFlag string
+	var auditScannerIgnoredNamespacesFlag string
+	var auditScannerPolicyServerRetries int
+	var auditScannerCircuitBreakerThreshold int
 
 	flag.StringVar(&mgrOpts.MetricsAddr, "metrics-bind-address", ":8088", "The address the controller-runtime metric endpoint binds to.")
 	flag.StringVar(&mgrOpts.ProbeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -160,12 +178,63 @@ func main() {
 			"WARNING: enabling this increases the attack surface by exposing webhook endpoints "+
 			"on the host network and giving pods visibility of all node network interfaces. "+
 			"Use only when the Kubernetes API server cannot reach pod-network webhook endpoints.")
+	flag.StringVar(&config.AuditScannerImage,
+		"audit-scanner-image",
+		"",
+		"Image of the audit scanner Jobs created for the AuditSchedules. The AuditSchedules are not reconciled when empty.")
+	flag.StringVar(&config.AuditScannerServiceAccountName,
+		"audit-scanner-service-account",
+		constants.AuditScannerServiceAccountName,
+		"Name of the ServiceAccount of the audit scanner Jobs created for the AuditSchedules.")
+	flag.StringVar(&auditScannerIgnoredNamespacesFlag,
+		"audit-scanner-ignore-namespaces",
+		"",
+		"Comma-separated list of the namespaces never audited by the Jobs created for the AuditSchedules.")
+	flag.StringVar(&config.AuditScannerReportKind,
+		"audit-scanner-report-kind",
+		"",
+		"Kind of the reports written by the Jobs of the AuditSchedules not setting their own. The audit scanner default is used when empty.")
+	flag.StringVar(&config.AuditScannerLogLevel,
+		"audit-scanner-log-level",
+		"",
+		"Log level of the Jobs created for the AuditSchedules. The audit scanner default is used when empty.")
+	flag.DurationVar(&config.AuditScannerPolicyServerClient.Timeout,
+		"audit-scanner-policy-server-timeout",
+		0,
+		"Timeout of the requests sent by the Jobs of the AuditSchedules to the PolicyServers. The audit scanner default is used when zero.")
+	flag.IntVar(&auditScannerPolicyServerRetries,
+		"audit-scanner-policy-server-retries",
+		-1,
+		"Number of retries of the requests sent by the Jobs of the AuditSchedules to the PolicyServers. The audit scanner default is used when negative.")
+	flag.DurationVar(&config.AuditScannerPolicyServerClient.RetryBackoff,
+		"audit-scanner-policy-server-retry-backoff",
+		0,
+		"Delay before the first retry of a request sent by the Jobs of the AuditSchedules to a PolicyServer. The audit scanner default is used when zero.")
+	flag.DurationVar(&config.AuditScannerPolicyServerClient.MaxRetryBackoff,
+		"audit-scanner-policy-server-max-retry-backoff",
+		0,
+		"Maximum delay between two retries of a request sent by the Jobs of the AuditSchedules to a PolicyServer. The audit scanner default is used when zero.")
+	flag.IntVar(&auditScannerCircuitBreakerThreshold,
+		"audit-scanner-circuit-breaker-threshold",
+		-1,
+		"Number of consecutive failed requests after which the Jobs of the AuditSchedules pause the evaluations against a PolicyServer. The audit scanner default is used when negative.")
+	flag.DurationVar(&config.AuditScannerPolicyServerClient.CircuitBreakerCooldown,
+		"audit-scanner-circuit-breaker-cooldown",
+		0,
+		"Time the Jobs of the AuditSchedules pause the evaluations against an unavailable PolicyServer. The audit scanner default is used when zero.")
 
 	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 	mgrOpts.EnableMutualTLS = config.ClientCAConfigMapName != ""
 	config.ImagePullSecrets = parseImagePullSecrets(imagePullSecretsFlag)
+	config.AuditScannerIgnoredNamespaces = parseList(auditScannerIgnoredNamespacesFlag)
+	if auditScannerPolicyServerRetries >= 0 {
+		config.AuditScannerPolicyServerClient.Retries = &auditScannerPolicyServerRetries
+	}
+	if auditScannerCircuitBreakerThreshold >= 0 {
+		config.AuditScannerPolicyServerClient.CircuitBreakerThreshold = &auditScannerCircuitBreakerThreshold
+	}
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
 	// Validate --webhook-server-port range.
@@ -355,6 +424,7 @@ func setupManager(mgrOpts ManagerOptions) (ctrl.Manager, error) {
 				&k8spoliciesv1.PodDisruptionBudget{}: namespaceSelector,
 				&corev1.ConfigMap{}:                  namespaceSelector,
 				&appsv1.Deployment{}:                 namespaceSelector,
+				&batchv1.Job{}:                       namespaceSelector,
 			},
 		},
 		WebhookServer: webhook.NewServer(webhook.Options{
@@ -461,6 +531,28 @@ func setupReconcilers(mgr ctrl.Manager,
 	}).SetupWithManager(mgr); err != nil {
 		return errors.Join(errors.New("unable to create DefaultsApplier controller"), err)
 	}
+
+	if config.AuditScannerImage == "" {
+		setupLog.Info("audit scanner image not set, AuditSchedules are not reconciled")
+		return nil
+	}
+	if err := (&controller.AuditScheduleReconciler{
+		Client:               mgr.GetClient(),
+		Scheme:               mgr.GetScheme(),
+		Log:                  ctrl.Log.WithName("audit-schedule-reconciler"),
+		DeploymentsNamespace: deploymentsNamespace,
+		AuditScanner: controller.AuditScannerConfiguration{
+			Image:              config.AuditScannerImage,
+			ServiceAccountName: config.AuditScannerServiceAccountName,
+			ImagePullSecrets:   config.ImagePullSecrets,
+			IgnoredNamespaces:  config.AuditScannerIgnoredNamespaces,
+			ReportKind:         config.AuditScannerReportKind,
+			LogLevel:           config.AuditScannerLogLevel,
+			PolicyServerClient: config.AuditScannerPolicyServerClient,
+		},
+	}).SetupWithManager(mgr); err != nil {
+		return errors.Join(errors.New("unable to create AuditSchedule controller"), err)
+	}
 	return nil
 }
 
@@ -487,15 +579,24 @@ func setupWebhooks(mgr ctrl.Manager, deploymentsNamespace string) error {
 // slice of LocalObjectReferences. Empty names are ignored. An empty or blank
 // input string returns nil.
 func parseImagePullSecrets(s string) []corev1.LocalObjectReference {
+	var refs []corev1.LocalObjectReference
+	for _, name := range parseList(s) {
+		refs = append(refs, corev1.LocalObjectReference{Name: name})
+	}
+	return refs
+}
+
+// parseList returns the non-empty items of the comma-separated list.
+func parseList(s string) []string {
 	if s == "" {
 		return nil
 	}
-	var refs []corev1.LocalObjectReference
-	for _, name := range strings.Split(s, ",") {
-		name = strings.TrimSpace(name)
-		if name != "" {
-			refs = append(refs, corev1.LocalObjectReference{Name: name})
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
 		}
 	}
-	return refs
+	return items
 }
@@ -0,0 +1,366 @@
+package auditrun
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// interruptedMessage is the message of the runs found running when a new run starts.
+	interruptedMessage = "the audit scanner stopped before the end of the run"
+	// finishTimeout is the time allowed to record the end of an interrupted run.
+	finishTimeout = 10 * time.Second
+)
+
+// Recorder records the lifecycle and the results of an audit run in an
+// AuditRun resource named after the run UID.
+type Recorder struct {
+	client client.Client
+	// history is the number of AuditRuns kept, including the current one.
+	// Zero keeps all of them
+	history int
+	// shard of the audit recorded, the runs of the other shards are ignored
+	shard shard.Shard
+	// auditSchedule is the name of the AuditSchedule whose runs are recorded,
+	// empty for the runs not started by an AuditSchedule. The runs of the
+	// other AuditSchedules are ignored
+	auditSchedule string
+	logger        *slog.Logger
+	// mutex serializes the updates of the status and of the AuditRun
+	mutex sync.Mutex
+	// auditRun is the AuditRun of the current run, nil until the run is started
+	auditRun *policiesv1.AuditRun
+	// skippedPolicies and erroredPolicies are the unique names of the
+	// policies not audited by the run
+	skippedPolicies map[string]struct{}
+	erroredPolicies map[string]struct{}
+}
+
+// NewRecorder returns a Recorder keeping the given number of AuditRuns of the
+// given shard and AuditSchedule. The auditSchedule is empty for the runs not
+// started by an AuditSchedule, e.g. the ones of the audit scanner CronJob.
+func NewRecorder(client client.Client, history int, auditShard shard.Shard, auditSchedule string, logger *slog.Logger) *Recorder {
+	return &Recorder{
+		client:        client,
+		history:       history,
+		shard:         auditShard,
+		auditSchedule: auditSchedule,
+		logger:        logger.With("component", "auditrun"),
+	}
+}
+
+// Start creates the AuditRun of the run. When the AuditRun already exists,
+// because an interrupted run is resumed under the same UID, its results are
+// kept and the run is marked as running again. The other AuditRuns still
+// running are marked as interrupted, since a single run of each shard and
+// AuditSchedule happens at a time.
+func (r *Recorder) Start(ctx context.Context, runUID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.interruptStaleRuns(ctx, runUID); err != nil {
+		return err
+	}
+
+	auditRun := &policiesv1.AuditRun{}
+	err := r.client.Get(ctx, client.ObjectKey{Name: runUID}, auditRun)
+	switch {
+	case apierrors.IsNotFound(err):
+		auditRun = &policiesv1.AuditRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: runUID,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "kubewarden",
+				},
+			},
+			Spec: policiesv1.AuditRunSpec{RunUID: runUID},
+		}
+		if r.shard.Enabled() {
+			auditRun.Labels[constants.AuditScannerShardLabel] = r.shard.String()
+		}
+		if r.auditSchedule != "" {
+			auditRun.Labels[constants.AuditScheduleLabel] = r.auditSchedule
+		}
+		if err = r.client.Create(ctx, auditRun); err != nil {
+			return fmt.Errorf("failed to create AuditRun %s: %w", runUID, err)
+		}
+		now := metav1.Now()
+		auditRun.Status = policiesv1.AuditRunStatus{StartTime: &now}
+	case err != nil:
+		return fmt.Errorf("failed to get AuditRun %s: %w", runUID, err)
+	default:
+		r.logger.InfoContext(ctx, "resuming AuditRun", slog.String("RunUID", runUID))
+		auditRun.Status.CompletionTime = nil
+		auditRun.Status.Message = ""
+	}
+	auditRun.Status.Phase = policiesv1.AuditRunPhaseRunning
+
+	r.auditRun = auditRun
+	r.skippedPolicies = toSet(auditRun.Status.SkippedPolicies)
+	r.erroredPolicies = toSet(auditRun.Status.ErroredPolicies)
+	return r.save(ctx)
+}
+
+// SetNamespacesTotal records the number of namespaces to scan.
+func (r *Recorder) SetNamespacesTotal(total int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.auditRun == nil {
+		return
+	}
+	r.auditRun.Status.NamespacesTotal = total
+}
+
+// RecordPolicies records the unique names of the policies skipped or errored
+// while auditing a namespace or the cluster-wide resources.
+func (r *Recorder) RecordPolicies(skipped, errored []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.auditRun == nil {
+		return
+	}
+	for _, name := range skipped {
+		r.skippedPolicies[name] = struct{}{}
+	}
+	for _, name := range errored {
+		r.erroredPolicies[name] = struct{}{}
+	}
+}
+
+// RecordReport counts the audited resource and the results of its report by outcome.
+func (r *Recorder) RecordReport(policyReport report.Report) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.auditRun == nil {
+		return
+	}
+	r.auditRun.Status.ResourcesAudited++
+	results := &r.auditRun.Status.Results
+	for _, result := range policyReport.ResourceResults().Results {
+		switch result.Result {
+		case report.ResultPass:
+			results.Pass++
+		case report.ResultFail:
+			results.Fail++
+		case report.ResultWarn:
+			results.Warn++
+		case report.ResultError:
+			results.Error++
+		case report.ResultSkip:
+			results.Skip++
+		}
+	}
+}
+
+// RecordChanges counts the results of the policies that changed since the
+// previous report of a resource.
+func (r *Recorder) RecordChanges(changes []report.ResultChange) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.auditRun == nil {
+		return
+	}
+	for _, change := range changes {
+		if change.Failing {
+			r.auditRun.Status.Changes.NewFailures++
+		} else {
+			r.auditRun.Status.Changes.ResolvedFailures++
+		}
+	}
+}
+
+// CompleteNamespace records that all the resources of a namespace are audited
+// and updates the status of the AuditRun.
+func (r *Recorder) CompleteNamespace(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.auditRun == nil {
+		return nil
+	}
+	r.auditRun.Status.NamespacesCompleted++
+	return r.save(ctx)
+}
+
+// Flush updates the status of the AuditRun with the results recorded so far.
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.auditRun == nil {
+		return nil
+	}
+	return r.save(ctx)
+}
+
+// Finish records the end of the run and deletes the oldest AuditRuns beyond
+// the history. The run failed when err is not nil, unless it was interrupted.
+func (r *Recorder) Finish(ctx context.Context, runErr error, interrupted bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.auditRun == nil {
+		return nil
+	}
+	// The context of an interrupted run is already canceled
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+
+	now := metav1.Now()
+	status := &r.auditRun.Status
+	status.CompletionTime = &now
+	switch {
+	case interrupted:
+		status.Phase = policiesv1.AuditRunPhaseInterrupted
+		status.Message = interruptedMessage
+	case runErr != nil:
+		status.Phase = policiesv1.AuditRunPhaseFailed
+		status.Message = runErr.Error()
+	default:
+		status.Phase = policiesv1.AuditRunPhaseSucceeded
+	}
+	if err := r.save(ctx); err != nil {
+		return err
+	}
+	return r.prune(ctx)
+}
+
+// interruptStaleRuns marks the AuditRuns still running, other than the one of
+// the given run, as interrupted. It must be called with the mutex held.
+func (r *Recorder) interruptStaleRuns(ctx context.Context, runUID string) error {
+	auditRuns, err := r.listAuditRuns(ctx)
+	if err != nil {
+		return err
+	}
+	for _, auditRun := range auditRuns.Items {
+		if auditRun.Name == runUID || auditRun.Status.Phase != policiesv1.AuditRunPhaseRunning {
+			continue
+		}
+		r.logger.InfoContext(ctx, "marking stale AuditRun as interrupted", slog.String("RunUID", auditRun.Spec.RunUID))
+		auditRun.Status.Phase = policiesv1.AuditRunPhaseInterrupted
+		auditRun.Status.Message = interruptedMessage
+		if err = r.client.Status().Update(ctx, &auditRun); err != nil {
+			return fmt.Errorf("failed to update AuditRun %s: %w", auditRun.Name, err)
+		}
+	}
+	return nil
+}
+
+// prune deletes the oldest AuditRuns beyond the history. It must be called
+// with the mutex held.
+func (r *Recorder) prune(ctx context.Context) error {
+	if r.history <= 0 {
+		return nil
+	}
+	auditRuns, err := r.listAuditRuns(ctx)
+	if err != nil {
+		return err
+	}
+	if len(auditRuns.Items) <= r.history {
+		return nil
+	}
+
+	// The most recent runs first
+	slices.SortFunc(auditRuns.Items, func(a, b policiesv1.AuditRun) int {
+		return startTime(b).Compare(startTime(a))
+	})
+	for _, auditRun := range auditRuns.Items[r.history:] {
+		if auditRun.Name == r.auditRun.Name {
+			continue
+		}
+		r.logger.DebugContext(ctx, "deleting old AuditRun", slog.String("RunUID", auditRun.Spec.RunUID))
+		if err = r.client.Delete(ctx, &auditRun); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete AuditRun %s: %w", auditRun.Name, err)
+		}
+	}
+	return nil
+}
+
+// listAuditRuns lists the AuditRuns of the shard and of the AuditSchedule.
+func (r *Recorder) listAuditRuns(ctx context.Context) (*policiesv1.AuditRunList, error) {
+	selector := labels.NewSelector()
+	if r.shard.Enabled() {
+		requirement, err := labels.NewRequirement(constants.AuditScannerShardLabel, selection.Equals, []string{r.shard.String()})
+		if err != nil {
+			return nil, fmt.Errorf("invalid AuditRun shard selector: %w", err)
+		}
+		selector = selector.Add(*requirement)
+	}
+	// The runs of the AuditSchedules and the other ones, e.g. the ones of the
+	// CronJob, happen concurrently and are kept apart
+	scheduleRequirement, err := labels.NewRequirement(constants.AuditScheduleLabel, selection.DoesNotExist, nil)
+	if r.auditSchedule != "" {
+		scheduleRequirement, err = labels.NewRequirement(constants.AuditScheduleLabel, selection.Equals, []string{r.auditSchedule})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid AuditRun schedule selector: %w", err)
+	}
+	selector = selector.Add(*scheduleRequirement)
+
+	auditRuns := &policiesv1.AuditRunList{}
+	if err = r.client.List(ctx, auditRuns, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list AuditRuns: %w", err)
+	}
+	return auditRuns, nil
+}
+
+// save updates the status of the AuditRun. It must be called with the mutex held.
+func (r *Recorder) save(ctx context.Context) error {
+	r.auditRun.Status.SkippedPolicies = slices.Sorted(maps.Keys(r.skippedPolicies))
+	r.auditRun.Status.ErroredPolicies = slices.Sorted(maps.Keys(r.erroredPolicies))
+	status := *r.auditRun.Status.DeepCopy()
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := r.client.Status().Update(ctx, r.auditRun)
+		if !apierrors.IsConflict(err) {
+			return err //nolint:wrapcheck // wrapped below
+		}
+		// The AuditRun is only written by this run, the status is overwritten
+		if getErr := r.client.Get(ctx, client.ObjectKeyFromObject(r.auditRun), r.auditRun); getErr != nil {
+			return getErr //nolint:wrapcheck // wrapped below
+		}
+		r.auditRun.Status = status
+		return err //nolint:wrapcheck // wrapped below
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the status of AuditRun %s: %w", r.auditRun.Name, err)
+	}
+	return nil
+}
+
+// startTime returns the time the run started, or the creation time of the
+// AuditRun when the status was never recorded.
+func startTime(auditRun policiesv1.AuditRun) time.Time {
+	if auditRun.Status.StartTime != nil {
+		return auditRun.Status.StartTime.Time
+	}
+	return auditRun.CreationTimestamp.Time
+}
+
+func toSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return set
+}
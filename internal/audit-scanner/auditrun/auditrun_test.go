@@ -0,0 +1,191 @@
+package auditrun
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newAuditRun(name string, phase policiesv1.AuditRunPhase, startTime time.Time) *policiesv1.AuditRun {
+	return &policiesv1.AuditRun{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       policiesv1.AuditRunSpec{RunUID: name},
+		Status: policiesv1.AuditRunStatus{
+			Phase:     phase,
+			StartTime: &metav1.Time{Time: startTime},
+		},
+	}
+}
+
+func getAuditRun(t *testing.T, client client.Client, name string) *policiesv1.AuditRun {
+	t.Helper()
+
+	auditRun := &policiesv1.AuditRun{}
+	require.NoError(t, client.Get(t.Context(), types.NamespacedName{Name: name}, auditRun))
+	return auditRun
+}
+
+func TestRecorderRecordsRun(t *testing.T) {
+	client, err := testutils.NewFakeClient(newAuditRun("stale", policiesv1.AuditRunPhaseRunning, time.Now().Add(-time.Hour)))
+	require.NoError(t, err)
+	recorder := NewRecorder(client, 0, shard.Shard{}, "", slog.Default())
+
+	require.NoError(t, recorder.Start(t.Context(), "run"))
+	auditRun := getAuditRun(t, client, "run")
+	assert.Equal(t, "run", auditRun.Spec.RunUID)
+	assert.Equal(t, policiesv1.AuditRunPhaseRunning, auditRun.Status.Phase)
+	assert.NotNil(t, auditRun.Status.StartTime)
+	assert.Equal(t, policiesv1.AuditRunPhaseInterrupted, getAuditRun(t, client, "stale").Status.Phase)
+
+	policy := &policiesv1.ClusterAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy"}}
+	policyReport := report.NewPolicyReport("run", unstructured.Unstructured{})
+	policyReport.AddResult(policy, &admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{Allowed: true}}, false)
+	policyReport.AddResult(policy, &admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{Allowed: false}}, false)
+	policyReport.AddResult(policy, nil, true)
+	policyReport.AddSkippedResult(policy, "PolicyServer unavailable")
+
+	recorder.SetNamespacesTotal(2)
+	recorder.RecordPolicies([]string{"clusterwide-skipped"}, []string{"clusterwide-errored"})
+	recorder.RecordPolicies([]string{"clusterwide-skipped"}, nil)
+	recorder.RecordReport(policyReport)
+	recorder.RecordReport(report.NewPolicyReport("run", unstructured.Unstructured{}))
+	recorder.RecordChanges([]report.ResultChange{
+		{Policy: "clusterwide-policy", Failing: true},
+		{Policy: "clusterwide-other", Failing: true},
+		{Policy: "clusterwide-fixed", Failing: false},
+	})
+	require.NoError(t, recorder.CompleteNamespace(t.Context()))
+
+	auditRun = getAuditRun(t, client, "run")
+	assert.Equal(t, 2, auditRun.Status.NamespacesTotal)
+	assert.Equal(t, 1, auditRun.Status.NamespacesCompleted)
+	assert.Equal(t, 2, auditRun.Status.ResourcesAudited)
+	assert.Equal(t, policiesv1.AuditRunResults{Pass: 1, Fail: 1, Error: 1, Skip: 1}, auditRun.Status.Results)
+	assert.Equal(t, policiesv1.AuditRunChanges{NewFailures: 2, ResolvedFailures: 1}, auditRun.Status.Changes)
+	assert.Equal(t, []string{"clusterwide-skipped"}, auditRun.Status.SkippedPolicies)
+	assert.Equal(t, []string{"clusterwide-errored"}, auditRun.Status.ErroredPolicies)
+
+	require.NoError(t, recorder.Finish(t.Context(), errors.New("failed to list namespaces"), false))
+	auditRun = getAuditRun(t, client, "run")
+	assert.Equal(t, policiesv1.AuditRunPhaseFailed, auditRun.Status.Phase)
+	assert.Equal(t, "failed to list namespaces", auditRun.Status.Message)
+	assert.NotNil(t, auditRun.Status.CompletionTime)
+}
+
+func TestRecorderResumesInterruptedRun(t *testing.T) {
+	client, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+
+	recorder := NewRecorder(client, 0, shard.Shard{}, "", slog.Default())
+	require.NoError(t, recorder.Start(t.Context(), "run"))
+	recorder.RecordPolicies(nil, []string{"clusterwide-errored"})
+	recorder.RecordReport(report.NewPolicyReport("run", unstructured.Unstructured{}))
+	require.NoError(t, recorder.CompleteNamespace(t.Context()))
+	require.NoError(t, recorder.Finish(t.Context(), nil, true))
+	assert.Equal(t, policiesv1.AuditRunPhaseInterrupted, getAuditRun(t, client, "run").Status.Phase)
+
+	resumed := NewRecorder(client, 0, shard.Shard{}, "", slog.Default())
+	require.NoError(t, resumed.Start(t.Context(), "run"))
+	resumed.RecordReport(report.NewPolicyReport("run", unstructured.Unstructured{}))
+	require.NoError(t, resumed.CompleteNamespace(t.Context()))
+	require.NoError(t, resumed.Finish(t.Context(), nil, false))
+
+	auditRun := getAuditRun(t, client, "run")
+	assert.Equal(t, policiesv1.AuditRunPhaseSucceeded, auditRun.Status.Phase)
+	assert.Empty(t, auditRun.Status.Message)
+	assert.Equal(t, 2, auditRun.Status.NamespacesCompleted)
+	assert.Equal(t, 2, auditRun.Status.ResourcesAudited)
+	assert.Equal(t, []string{"clusterwide-errored"}, auditRun.Status.ErroredPolicies)
+}
+
+func TestRecorderPrunesOldRuns(t *testing.T) {
+	now := time.Now()
+	client, err := testutils.NewFakeClient(
+		newAuditRun("oldest", policiesv1.AuditRunPhaseSucceeded, now.Add(-3*time.Hour)),
+		newAuditRun("older", policiesv1.AuditRunPhaseFailed, now.Add(-2*time.Hour)),
+		newAuditRun("old", policiesv1.AuditRunPhaseSucceeded, now.Add(-time.Hour)),
+	)
+	require.NoError(t, err)
+
+	recorder := NewRecorder(client, 2, shard.Shard{}, "", slog.Default())
+	require.NoError(t, recorder.Start(t.Context(), "run"))
+	require.NoError(t, recorder.Finish(t.Context(), nil, false))
+
+	auditRuns := &policiesv1.AuditRunList{}
+	require.NoError(t, client.List(t.Context(), auditRuns))
+	names := make([]string, 0, len(auditRuns.Items))
+	for _, auditRun := range auditRuns.Items {
+		names = append(names, auditRun.Name)
+	}
+	assert.ElementsMatch(t, []string{"old", "run"}, names)
+
+	err = client.Get(t.Context(), types.NamespacedName{Name: "oldest"}, &policiesv1.AuditRun{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestRecorderIgnoresRunsOfOtherShards(t *testing.T) {
+	otherShardRun := newAuditRun("other-shard", policiesv1.AuditRunPhaseRunning, time.Now().Add(-time.Hour))
+	otherShardRun.Labels = map[string]string{constants.AuditScannerShardLabel: "0"}
+	sameShardRun := newAuditRun("same-shard", policiesv1.AuditRunPhaseRunning, time.Now().Add(-time.Hour))
+	sameShardRun.Labels = map[string]string{constants.AuditScannerShardLabel: "1"}
+	client, err := testutils.NewFakeClient(otherShardRun, sameShardRun)
+	require.NoError(t, err)
+
+	recorder := NewRecorder(client, 1, shard.Shard{Index: 1, Count: 2}, "", slog.Default())
+	require.NoError(t, recorder.Start(t.Context(), "run"))
+	assert.Equal(t, "1", getAuditRun(t, client, "run").Labels[constants.AuditScannerShardLabel])
+	assert.Equal(t, policiesv1.AuditRunPhaseRunning, getAuditRun(t, client, "other-shard").Status.Phase)
+	assert.Equal(t, policiesv1.AuditRunPhaseInterrupted, getAuditRun(t, client, "same-shard").Status.Phase)
+
+	// only the runs of the shard are pruned
+	require.NoError(t, recorder.Finish(t.Context(), nil, false))
+	getAuditRun(t, client, "other-shard")
+	err = client.Get(t.Context(), types.NamespacedName{Name: "same-shard"}, &policiesv1.AuditRun{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestRecorderIgnoresRunsOfOtherSchedules(t *testing.T) {
+	cronJobRun := newAuditRun("cronjob", policiesv1.AuditRunPhaseRunning, time.Now().Add(-time.Hour))
+	otherScheduleRun := newAuditRun("other-schedule", policiesv1.AuditRunPhaseRunning, time.Now().Add(-time.Hour))
+	otherScheduleRun.Labels = map[string]string{constants.AuditScheduleLabel: "nightly"}
+	sameScheduleRun := newAuditRun("same-schedule", policiesv1.AuditRunPhaseRunning, time.Now().Add(-time.Hour))
+	sameScheduleRun.Labels = map[string]string{constants.AuditScheduleLabel: "hourly"}
+	client, err := testutils.NewFakeClient(cronJobRun, otherScheduleRun, sameScheduleRun)
+	require.NoError(t, err)
+
+	recorder := NewRecorder(client, 1, shard.Shard{}, "hourly", slog.Default())
+	require.NoError(t, recorder.Start(t.Context(), "run"))
+	assert.Equal(t, "hourly", getAuditRun(t, client, "run").Labels[constants.AuditScheduleLabel])
+	assert.Equal(t, policiesv1.AuditRunPhaseRunning, getAuditRun(t, client, "cronjob").Status.Phase)
+	assert.Equal(t, policiesv1.AuditRunPhaseRunning, getAuditRun(t, client, "other-schedule").Status.Phase)
+	assert.Equal(t, policiesv1.AuditRunPhaseInterrupted, getAuditRun(t, client, "same-schedule").Status.Phase)
+
+	// only the runs of the AuditSchedule are pruned
+	require.NoError(t, recorder.Finish(t.Context(), nil, false))
+	getAuditRun(t, client, "cronjob")
+	getAuditRun(t, client, "other-schedule")
+	err = client.Get(t.Context(), types.NamespacedName{Name: "same-schedule"}, &policiesv1.AuditRun{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// the runs not started by an AuditSchedule ignore the ones of the AuditSchedules
+	cronJobRecorder := NewRecorder(client, 1, shard.Shard{}, "", slog.Default())
+	require.NoError(t, cronJobRecorder.Start(t.Context(), "cronjob-run"))
+	assert.Equal(t, policiesv1.AuditRunPhaseInterrupted, getAuditRun(t, client, "cronjob").Status.Phase)
+	assert.Equal(t, policiesv1.AuditRunPhaseRunning, getAuditRun(t, client, "other-schedule").Status.Phase)
+	assert.Equal(t, policiesv1.AuditRunPhaseSucceeded, getAuditRun(t, client, "run").Status.Phase)
+}
@@ -0,0 +1,220 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap holding the progress of the run.
+	// When the audit is sharded, the index of the shard is appended to it.
+	ConfigMapName = "audit-scanner-checkpoint"
+	// dataKey is the key of the ConfigMap data holding the progress of the run.
+	dataKey = "checkpoint"
+)
+
+// progress is the progress of a run, as persisted in the ConfigMap.
+type progress struct {
+	RunUID    string    `json:"runUID"`
+	StartedAt time.Time `json:"startedAt"`
+	// Scope identifies the namespaces, resources and policies audited by the run
+	Scope string `json:"scope,omitempty"`
+	// Completed holds the namespaces whose resources have all been audited.
+	// The cluster-wide resources are tracked with an empty namespace
+	Completed []string `json:"completed,omitempty"`
+	// Resources is the progress of the resources of the namespaces being audited
+	Resources map[string]map[string]resourcesProgress `json:"resources,omitempty"`
+}
+
+// resourcesProgress is the progress of the resources of a GVR in a namespace.
+type resourcesProgress struct {
+	// Completed is true when all the resources have been audited
+	Completed bool `json:"completed,omitempty"`
+	// Continue is the continue token of the next page of resources to audit
+	Continue string `json:"continue,omitempty"`
+}
+
+// Store persists the progress of an audit run in a ConfigMap, so a run
+// interrupted halfway through, for example because its pod was evicted, is
+// resumed by the next one under the same run UID, as long as it has the same
+// scope.
+type Store struct {
+	clientset kubernetes.Interface
+	namespace string
+	// name of the ConfigMap, specific to the shard of the audit
+	name   string
+	scope  string
+	logger *slog.Logger
+	// mutex serializes the updates of the progress and of the ConfigMap
+	mutex    sync.Mutex
+	progress progress
+}
+
+// NewStore returns a Store persisting the progress of the runs of the given
+// shard in the given namespace. The scope identifies what the runs audit: the
+// progress of a run with another scope is never resumed.
+func NewStore(clientset kubernetes.Interface, namespace string, auditShard shard.Shard, scope string, logger *slog.Logger) *Store {
+	return &Store{
+		clientset: clientset,
+		namespace: namespace,
+		name:      ConfigMapNameOfShard(auditShard),
+		scope:     scope,
+		logger:    logger.With("component", "checkpoint"),
+	}
+}
+
+// ConfigMapNameOfShard returns the name of the ConfigMap holding the progress
+// of the runs of the given shard.
+func ConfigMapNameOfShard(auditShard shard.Shard) string {
+	if !auditShard.Enabled() {
+		return ConfigMapName
+	}
+	return fmt.Sprintf("%s-%s", ConfigMapName, auditShard)
+}
+
+// Start starts a run. When the progress of an interrupted run of the same scope
+// is found, the run is resumed and its UID is returned. Otherwise, a new run is
+// started with the given UID.
+func (s *Store) Start(ctx context.Context, runUID string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	configMap, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return "", fmt.Errorf("failed to get the checkpoint ConfigMap: %w", err)
+	default:
+		previous := progress{}
+		err = json.Unmarshal([]byte(configMap.Data[dataKey]), &previous)
+		switch {
+		case err != nil || previous.RunUID == "":
+			s.logger.WarnContext(ctx, "ignoring invalid checkpoint, starting a new run")
+		case previous.Scope != s.scope:
+			s.logger.InfoContext(ctx, "ignoring the checkpoint of a run with another scope, starting a new run",
+				slog.String("RunUID", previous.RunUID),
+				slog.String("scope", previous.Scope))
+		default:
+			s.progress = previous
+			s.logger.InfoContext(ctx, "resuming interrupted run",
+				slog.String("RunUID", previous.RunUID),
+				slog.Time("started-at", previous.StartedAt),
+				slog.Int("completed-namespaces", len(previous.Completed)))
+			return previous.RunUID, nil
+		}
+	}
+
+	s.progress = progress{
+		RunUID:    runUID,
+		StartedAt: time.Now().UTC(),
+		Scope:     s.scope,
+	}
+	if err = s.save(ctx); err != nil {
+		return "", err
+	}
+	return runUID, nil
+}
+
+// Finish deletes the progress of the run, so the next run starts from scratch.
+func (s *Store) Finish(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.clientset.CoreV1().ConfigMaps(s.namespace).Delete(ctx, s.name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the checkpoint ConfigMap: %w", err)
+	}
+	return nil
+}
+
+// IsNamespaceCompleted returns true when all the resources of the namespace
+// have been audited. An empty namespace refers to the cluster-wide resources.
+func (s *Store) IsNamespaceCompleted(nsName string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return slices.Contains(s.progress.Completed, nsName)
+}
+
+// CompleteNamespace records that all the resources of the namespace have been
+// audited. An empty namespace refers to the cluster-wide resources.
+func (s *Store) CompleteNamespace(ctx context.Context, nsName string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !slices.Contains(s.progress.Completed, nsName) {
+		s.progress.Completed = append(s.progress.Completed, nsName)
+	}
+	delete(s.progress.Resources, nsName)
+	return s.save(ctx)
+}
+
+// ResourcesProgress returns whether all the resources of the GVR in the namespace
+// have been audited and, if not, the continue token of the next page to audit.
+func (s *Store) ResourcesProgress(nsName string, gvr schema.GroupVersionResource) (bool, string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	resources := s.progress.Resources[nsName][gvr.String()]
+	return resources.Completed, resources.Continue
+}
+
+// SaveResourcesProgress records the continue token of the next page of resources
+// of the GVR in the namespace to audit. An empty token means that all the
+// resources have been audited.
+func (s *Store) SaveResourcesProgress(ctx context.Context, nsName string, gvr schema.GroupVersionResource, continueToken string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.progress.Resources == nil {
+		s.progress.Resources = make(map[string]map[string]resourcesProgress)
+	}
+	if s.progress.Resources[nsName] == nil {
+		s.progress.Resources[nsName] = make(map[string]resourcesProgress)
+	}
+	s.progress.Resources[nsName][gvr.String()] = resourcesProgress{
+		Completed: continueToken == "",
+		Continue:  continueToken,
+	}
+	return s.save(ctx)
+}
+
+// save writes the progress to the ConfigMap. It must be called with the mutex held.
+func (s *Store) save(ctx context.Context) error {
+	data, err := json.Marshal(s.progress)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the checkpoint: %w", err)
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.name,
+			Namespace: s.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "kubewarden",
+			},
+		},
+		Data: map[string]string{dataKey: string(data)},
+	}
+
+	// The ConfigMap is only written by this run, so it's updated unconditionally
+	_, err = s.clientset.CoreV1().ConfigMaps(s.namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = s.clientset.CoreV1().ConfigMaps(s.namespace).Create(ctx, configMap, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save the checkpoint: %w", err)
+	}
+	return nil
+}
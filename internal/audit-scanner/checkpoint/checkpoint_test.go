@@ -0,0 +1,100 @@
+package checkpoint
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStoreResumesInterruptedRun(t *testing.T) {
+	clientset := fake.NewClientset()
+	podsGVR := corev1.SchemeGroupVersion.WithResource("pods")
+	servicesGVR := corev1.SchemeGroupVersion.WithResource("services")
+
+	store := NewStore(clientset, "kubewarden", shard.Shard{}, "scope", slog.Default())
+	runUID, err := store.Start(t.Context(), "first-run")
+	require.NoError(t, err)
+	assert.Equal(t, "first-run", runUID)
+
+	require.NoError(t, store.CompleteNamespace(t.Context(), ""))
+	require.NoError(t, store.SaveResourcesProgress(t.Context(), "default", podsGVR, ""))
+	require.NoError(t, store.SaveResourcesProgress(t.Context(), "default", servicesGVR, "token"))
+
+	// the run is interrupted, the next one resumes it
+	resumed := NewStore(clientset, "kubewarden", shard.Shard{}, "scope", slog.Default())
+	runUID, err = resumed.Start(t.Context(), "second-run")
+	require.NoError(t, err)
+	assert.Equal(t, "first-run", runUID)
+
+	assert.True(t, resumed.IsNamespaceCompleted(""))
+	assert.False(t, resumed.IsNamespaceCompleted("default"))
+	completed, continueToken := resumed.ResourcesProgress("default", podsGVR)
+	assert.True(t, completed)
+	assert.Empty(t, continueToken)
+	completed, continueToken = resumed.ResourcesProgress("default", servicesGVR)
+	assert.False(t, completed)
+	assert.Equal(t, "token", continueToken)
+
+	require.NoError(t, resumed.CompleteNamespace(t.Context(), "default"))
+	assert.True(t, resumed.IsNamespaceCompleted("default"))
+	completed, _ = resumed.ResourcesProgress("default", servicesGVR)
+	assert.False(t, completed, "the progress of the resources of completed namespaces is dropped")
+
+	// once the run is finished, the next one starts from scratch
+	require.NoError(t, resumed.Finish(t.Context()))
+	_, err = clientset.CoreV1().ConfigMaps("kubewarden").Get(t.Context(), ConfigMapName, metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err))
+
+	runUID, err = NewStore(clientset, "kubewarden", shard.Shard{}, "scope", slog.Default()).Start(t.Context(), "third-run")
+	require.NoError(t, err)
+	assert.Equal(t, "third-run", runUID)
+}
+
+func TestStoreIgnoresInvalidCheckpoint(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: "kubewarden"},
+		Data:       map[string]string{dataKey: "not json"},
+	})
+
+	store := NewStore(clientset, "kubewarden", shard.Shard{}, "scope", slog.Default())
+	runUID, err := store.Start(t.Context(), "run")
+	require.NoError(t, err)
+	assert.Equal(t, "run", runUID)
+
+	configMap, err := clientset.CoreV1().ConfigMaps("kubewarden").Get(t.Context(), ConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, configMap.Data[dataKey], `"runUID":"run"`)
+}
+
+func TestStoreIgnoresCheckpointOfAnotherScope(t *testing.T) {
+	clientset := fake.NewClientset()
+
+	store := NewStore(clientset, "kubewarden", shard.Shard{}, "namespace=team-a", slog.Default())
+	_, err := store.Start(t.Context(), "team-a-run")
+	require.NoError(t, err)
+	require.NoError(t, store.CompleteNamespace(t.Context(), "team-a"))
+
+	// the interrupted run audited another namespace, a new run is started
+	store = NewStore(clientset, "kubewarden", shard.Shard{}, "namespace=team-b", slog.Default())
+	runUID, err := store.Start(t.Context(), "team-b-run")
+	require.NoError(t, err)
+	assert.Equal(t, "team-b-run", runUID)
+	assert.False(t, store.IsNamespaceCompleted("team-a"))
+
+	configMap, err := clientset.CoreV1().ConfigMaps("kubewarden").Get(t.Context(), ConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, configMap.Data[dataKey], `"scope":"namespace=team-b"`)
+}
+
+func TestConfigMapNameOfShard(t *testing.T) {
+	assert.Equal(t, "audit-scanner-checkpoint", ConfigMapNameOfShard(shard.Shard{}))
+	assert.Equal(t, "audit-scanner-checkpoint", ConfigMapNameOfShard(shard.Shard{Index: 0, Count: 1}))
+	assert.Equal(t, "audit-scanner-checkpoint-2", ConfigMapNameOfShard(shard.Shard{Index: 2, Count: 3}))
+}
@@ -11,6 +11,9 @@ const (
 	KubewardenKindAdmissionPolicyGroup        = "AdmissionPolicyGroup"
 	DefaultClusterwideReportName              = "clusterwide"
 	AuditScannerRunUIDLabel                   = "kubewarden.io/audit-scanner-run-uid"
+	AuditScannerShardKeyLabel                 = "kubewarden.io/audit-scanner-shard-key"
+	AuditScannerShardLabel                    = "kubewarden.io/audit-scanner-shard"
+	AuditScheduleLabel                        = "kubewarden.io/audit-schedule"
 )
 
 // ErrResourceNotFound is an error used to tell that the required resource is not found.
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/reference"
+)
+
+const (
+	// ReasonPolicyViolation is the reason of the Events of the policies that
+	// started failing for a resource.
+	ReasonPolicyViolation = "PolicyViolation"
+	// ReasonPolicyViolationResolved is the reason of the Events of the
+	// policies that stopped failing for a resource.
+	ReasonPolicyViolationResolved = "PolicyViolationResolved"
+	// reportingController is the controller emitting the Events
+	reportingController = "kubewarden.io/audit-scanner"
+	// action is the action of the Events
+	action = "Audit"
+	// maxNoteLength is the maximum length of the note of an Event, as
+	// validated by the Kubernetes API server
+	maxNoteLength = 1024
+)
+
+// Recorder emits Kubernetes Events when a policy starts or stops failing for a
+// resource: one on the resource, related to the policy, and one on the policy,
+// related to the resource. The Events are created synchronously, so none of
+// them is lost when the audit scanner exits at the end of the scan.
+type Recorder struct {
+	clientset kubernetes.Interface
+	// scheme resolves the kind of the policies
+	scheme *runtime.Scheme
+	// instance is the reporting instance of the Events, the name of the pod
+	instance string
+	logger   *slog.Logger
+}
+
+// NewRecorder returns a Recorder creating the Events with the given clientset.
+func NewRecorder(clientset kubernetes.Interface, scheme *runtime.Scheme, logger *slog.Logger) *Recorder {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = reportingController
+	}
+	return &Recorder{
+		clientset: clientset,
+		scheme:    scheme,
+		instance:  instance,
+		logger:    logger.With("component", "events"),
+	}
+}
+
+// RecordChange emits the Events of the change of the result of the policy for
+// the resource.
+func (r *Recorder) RecordChange(ctx context.Context, resource corev1.ObjectReference, policy policiesv1.Policy, change report.ResultChange) error {
+	policyReference, err := reference.GetReference(r.scheme, policy)
+	if err != nil {
+		return fmt.Errorf("failed to get the reference of policy %s: %w", policy.GetName(), err)
+	}
+
+	eventType := corev1.EventTypeWarning
+	reason := ReasonPolicyViolation
+	resourceNote := fmt.Sprintf("Rejected by %s %s: %s", policyReference.Kind, objectName(*policyReference), change.Message)
+	policyNote := fmt.Sprintf("Rejects %s %s: %s", resource.Kind, objectName(resource), change.Message)
+	if !change.Failing {
+		eventType = corev1.EventTypeNormal
+		reason = ReasonPolicyViolationResolved
+		resourceNote = fmt.Sprintf("Accepted by %s %s, which rejected it during the previous scan", policyReference.Kind, objectName(*policyReference))
+		policyNote = fmt.Sprintf("Accepts %s %s, which it rejected during the previous scan", resource.Kind, objectName(resource))
+	}
+
+	r.logger.DebugContext(ctx, "emitting the Events of a result change",
+		slog.String("policy", change.Policy),
+		slog.String("resource", objectName(resource)),
+		slog.String("reason", reason))
+
+	return errors.Join(
+		r.createEvent(ctx, resource, policyReference, eventType, reason, resourceNote),
+		r.createEvent(ctx, *policyReference, &resource, eventType, reason, policyNote),
+	)
+}
+
+// createEvent creates an Event regarding an object, in the namespace of the
+// object, or in the default namespace for the cluster-wide objects.
+func (r *Recorder) createEvent(ctx context.Context, regarding corev1.ObjectReference, related *corev1.ObjectReference, eventType, reason, note string) error {
+	namespace := regarding.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	if len(note) > maxNoteLength {
+		note = note[:maxNoteLength-3] + "..."
+	}
+
+	now := time.Now()
+	event := &eventsv1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// The names of the Events created by the client-go recorders
+			Name:      fmt.Sprintf("%v.%x", regarding.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		EventTime:           metav1.NewMicroTime(now),
+		ReportingController: reportingController,
+		ReportingInstance:   r.instance,
+		Action:              action,
+		Reason:              reason,
+		Regarding:           regarding,
+		Related:             related,
+		Note:                note,
+		Type:                eventType,
+	}
+	if _, err := r.clientset.EventsV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create Event regarding %s %s: %w", regarding.Kind, objectName(regarding), err)
+	}
+	return nil
+}
+
+// objectName returns the name of the object, prefixed by its namespace if any.
+func objectName(object corev1.ObjectReference) string {
+	if object.Namespace == "" {
+		return object.Name
+	}
+	return object.Namespace + "/" + object.Name
+}
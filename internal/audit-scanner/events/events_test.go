@@ -0,0 +1,120 @@
+package events
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/scheme"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRecordChange(t *testing.T) {
+	auditScheme, err := scheme.NewScheme()
+	require.NoError(t, err)
+
+	pod := corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "default", Name: "pod", UID: "pod-uid"}
+	policy := &policiesv1.AdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "team", UID: "policy-uid"}}
+	clusterPolicy := &policiesv1.ClusterAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: "cluster-policy", UID: "cluster-policy-uid"}}
+
+	tests := []struct {
+		name                    string
+		policy                  policiesv1.Policy
+		change                  report.ResultChange
+		expectedType            string
+		expectedReason          string
+		expectedResourceNote    string
+		expectedPolicyNote      string
+		expectedPolicyNamespace string
+	}{
+		{
+			name:                    "newly failing",
+			policy:                  policy,
+			change:                  report.ResultChange{Policy: policy.GetUniqueName(), Failing: true, Message: "privileged containers are not allowed"},
+			expectedType:            corev1.EventTypeWarning,
+			expectedReason:          ReasonPolicyViolation,
+			expectedResourceNote:    "Rejected by AdmissionPolicy team/policy: privileged containers are not allowed",
+			expectedPolicyNote:      "Rejects Pod default/pod: privileged containers are not allowed",
+			expectedPolicyNamespace: "team",
+		},
+		{
+			name:                    "newly passing",
+			policy:                  clusterPolicy,
+			change:                  report.ResultChange{Policy: clusterPolicy.GetUniqueName(), Failing: false},
+			expectedType:            corev1.EventTypeNormal,
+			expectedReason:          ReasonPolicyViolationResolved,
+			expectedResourceNote:    "Accepted by ClusterAdmissionPolicy cluster-policy, which rejected it during the previous scan",
+			expectedPolicyNote:      "Accepts Pod default/pod, which it rejected during the previous scan",
+			expectedPolicyNamespace: metav1.NamespaceDefault,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := fake.NewClientset()
+			recorder := NewRecorder(clientset, auditScheme, slog.Default())
+
+			require.NoError(t, recorder.RecordChange(t.Context(), pod, test.policy, test.change))
+
+			resourceEvents, err := clientset.EventsV1().Events("default").List(t.Context(), metav1.ListOptions{})
+			require.NoError(t, err)
+			policyEvents := resourceEvents
+			if test.expectedPolicyNamespace != metav1.NamespaceDefault {
+				policyEvents, err = clientset.EventsV1().Events(test.expectedPolicyNamespace).List(t.Context(), metav1.ListOptions{})
+				require.NoError(t, err)
+				require.Len(t, resourceEvents.Items, 1)
+				require.Len(t, policyEvents.Items, 1)
+			} else {
+				require.Len(t, resourceEvents.Items, 2)
+			}
+
+			for _, event := range append(resourceEvents.Items, policyEvents.Items...) {
+				assert.Equal(t, test.expectedType, event.Type)
+				assert.Equal(t, test.expectedReason, event.Reason)
+				assert.Equal(t, reportingController, event.ReportingController)
+				assert.Equal(t, action, event.Action)
+				assert.False(t, event.EventTime.IsZero())
+				require.NotNil(t, event.Related)
+
+				switch event.Regarding.UID {
+				case pod.UID:
+					assert.Equal(t, test.expectedResourceNote, event.Note)
+					assert.Equal(t, test.policy.GetUID(), event.Related.UID)
+				case test.policy.GetUID():
+					assert.Equal(t, test.expectedPolicyNote, event.Note)
+					assert.Equal(t, pod, *event.Related)
+					assert.Equal(t, policiesv1.GroupVersion.String(), event.Regarding.APIVersion)
+				default:
+					t.Errorf("unexpected Event regarding %v", event.Regarding)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordChangeTruncatesLongNotes(t *testing.T) {
+	auditScheme, err := scheme.NewScheme()
+	require.NoError(t, err)
+	clientset := fake.NewClientset()
+	recorder := NewRecorder(clientset, auditScheme, slog.Default())
+
+	pod := corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "default", Name: "pod", UID: "pod-uid"}
+	policy := &policiesv1.ClusterAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy"}}
+	change := report.ResultChange{Policy: policy.GetUniqueName(), Failing: true, Message: strings.Repeat("a", 2*maxNoteLength)}
+
+	require.NoError(t, recorder.RecordChange(t.Context(), pod, policy, change))
+
+	events, err := clientset.EventsV1().Events("default").List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 2)
+	for _, event := range events.Items {
+		assert.Len(t, event.Note, maxNoteLength)
+		assert.True(t, strings.HasSuffix(event.Note, "..."))
+	}
+}
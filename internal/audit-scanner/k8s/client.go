@@ -4,18 +4,37 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/pager"
 )
 
+// Selectors restricts the namespaces and the resources audited by label. A nil
+// selector selects everything.
+type Selectors struct {
+	// Namespace selects the audited namespaces, when all the namespaces are scanned
+	Namespace labels.Selector
+	// Resource selects the audited resources
+	Resource labels.Selector
+}
+
+// IsEmpty returns true when the selectors select all the namespaces and the resources.
+func (s Selectors) IsEmpty() bool {
+	return (s.Namespace == nil || s.Namespace.Empty()) && (s.Resource == nil || s.Resource.Empty())
+}
+
 // Client retrieves resources and namespaces from a Kubernetes cluster.
 type Client struct {
 	// dynamicClient is used to get resource lists
@@ -26,12 +45,14 @@ type Client struct {
 	skippedNs []string
 	// pageSize is the number of resources to fetch when paginating
 	pageSize int64
+	// selectors restricts the audited namespaces and resources by label
+	selectors Selectors
 	// logger is used to log the messages
 	logger *slog.Logger
 }
 
 // NewClient returns a new client.
-func NewClient(dynamicClient dynamic.Interface, clientset kubernetes.Interface, kubewardenNamespace string, skippedNs []string, pageSize int64, logger *slog.Logger) *Client {
+func NewClient(dynamicClient dynamic.Interface, clientset kubernetes.Interface, kubewardenNamespace string, skippedNs []string, pageSize int64, selectors Selectors, logger *slog.Logger) *Client {
 	skippedNs = append(skippedNs, kubewardenNamespace)
 
 	return &Client{
@@ -39,10 +60,37 @@ func NewClient(dynamicClient dynamic.Interface, clientset kubernetes.Interface,
 		clientset,
 		skippedNs,
 		pageSize,
+		selectors,
 		logger.With("component", "k8sclient"),
 	}
 }
 
+// NewInformerFactory returns a factory of shared informers watching resources in all the namespaces.
+// Callers are expected to ignore the events of skipped namespaces, see IsSkippedNamespace.
+func (f *Client) NewInformerFactory(resyncPeriod time.Duration) dynamicinformer.DynamicSharedInformerFactory {
+	return dynamicinformer.NewDynamicSharedInformerFactory(f.dynamicClient, resyncPeriod)
+}
+
+// NewInformer returns an informer, indexed by namespace and never resynced,
+// watching the resources of the given GVR in all the namespaces. Unlike the
+// informers of a factory, it can be stopped on its own, by cancelling the
+// context it runs with.
+// Callers are expected to ignore the events of skipped namespaces, see IsSkippedNamespace.
+func (f *Client) NewInformer(gvr schema.GroupVersionResource) cache.SharedIndexInformer {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	return dynamicinformer.NewFilteredDynamicInformer(f.dynamicClient, gvr, metav1.NamespaceAll, 0, indexers, nil).Informer()
+}
+
+// Selectors returns the selectors restricting the audited namespaces and resources.
+func (f *Client) Selectors() Selectors {
+	return f.selectors
+}
+
+// IsSkippedNamespace returns true if the given namespace must not be audited.
+func (f *Client) IsSkippedNamespace(nsName string) bool {
+	return slices.Contains(f.skippedNs, nsName)
+}
+
 func (f *Client) GetResources(gvr schema.GroupVersionResource, nsName string) *pager.ListPager {
 	listPager := pager.New(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
 		list, err := f.listResources(ctx, gvr, nsName, opts)
@@ -53,6 +101,15 @@ func (f *Client) GetResources(gvr schema.GroupVersionResource, nsName string) *p
 	return listPager
 }
 
+// GetResourcesPage returns a page of the resources of the given GVR in the namespace,
+// starting from the continue token. An empty token returns the first page.
+func (f *Client) GetResourcesPage(ctx context.Context, gvr schema.GroupVersionResource, nsName, continueToken string) (*unstructured.UnstructuredList, error) {
+	return f.listResources(ctx, gvr, nsName, metav1.ListOptions{
+		Limit:    f.pageSize,
+		Continue: continueToken,
+	})
+}
+
 func (f *Client) listResources(ctx context.Context,
 	gvr schema.GroupVersionResource,
 	nsName string,
@@ -66,6 +123,9 @@ func (f *Client) listResources(ctx context.Context,
 		Resource: gvr.Resource,
 	}
 
+	if f.selectors.Resource != nil {
+		opts.LabelSelector = f.selectors.Resource.String()
+	}
 	list, err := f.dynamicClient.Resource(resourceID).Namespace(nsName).List(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("can't list resources %s in namespace %s: %w", gvr.String(), nsName, err)
@@ -73,7 +133,17 @@ func (f *Client) listResources(ctx context.Context,
 	return list, nil
 }
 
+// IsAuditedNamespace returns true if the namespace is audited: it's not
+// skipped, and it's selected by the namespace selector, if any.
+func (f *Client) IsAuditedNamespace(namespace *corev1.Namespace) bool {
+	if f.IsSkippedNamespace(namespace.GetName()) {
+		return false
+	}
+	return f.selectors.Namespace == nil || f.selectors.Namespace.Matches(labels.Set(namespace.GetLabels()))
+}
+
 // GetAuditedNamespaces gets all namespaces besides the ones in skippedNs.
+// Only the namespaces matching the namespace selector, if any, are returned.
 func (f *Client) GetAuditedNamespaces(ctx context.Context) (*corev1.NamespaceList, error) {
 	// This function cannot be tested with fake client, as filtering is done server-side
 	skipNsFields := fields.Everything()
@@ -82,7 +152,11 @@ func (f *Client) GetAuditedNamespaces(ctx context.Context) (*corev1.NamespaceLis
 		f.logger.DebugContext(ctx, "skipping ns", slog.String("ns", nsName))
 	}
 
-	namespaceList, err := f.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{FieldSelector: skipNsFields.String()})
+	listOptions := metav1.ListOptions{FieldSelector: skipNsFields.String()}
+	if f.selectors.Namespace != nil {
+		listOptions.LabelSelector = f.selectors.Namespace.String()
+	}
+	namespaceList, err := f.clientset.CoreV1().Namespaces().List(ctx, listOptions)
 	if err != nil {
 		return nil, fmt.Errorf("can't list namespaces: %w", err)
 	}
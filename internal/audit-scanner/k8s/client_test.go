@@ -15,6 +15,7 @@ import (
 	dynamicFake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
 )
 
 const pageSize = 100
@@ -29,7 +30,7 @@ func TestGetResources(t *testing.T) {
 	clientset := fake.NewClientset()
 
 	logger := slog.Default()
-	k8sClient := NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, Selectors{}, logger)
 
 	pager := k8sClient.GetResources(schema.GroupVersionResource{
 		Group:    "",
@@ -46,3 +47,23 @@ func TestGetResources(t *testing.T) {
 	assert.Len(t, unstructuredList.Items, pageSize+5)
 	assert.Equal(t, "PodList", unstructuredList.GetObjectKind().GroupVersionKind().Kind)
 }
+
+func TestGetResourcesPage(t *testing.T) {
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(scheme.Scheme,
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}})
+	var listOptions metav1.ListOptions
+	dynamicClient.PrependReactor("list", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		listAction, ok := action.(clienttesting.ListActionImpl)
+		require.True(t, ok)
+		listOptions = listAction.GetListOptions()
+		return false, nil, nil
+	})
+
+	k8sClient := NewClient(dynamicClient, fake.NewClientset(), "kubewarden", nil, pageSize, Selectors{}, slog.Default())
+	list, err := k8sClient.GetResourcesPage(t.Context(), schema.GroupVersionResource{Version: "v1", Resource: "pods"}, "default", "token")
+	require.NoError(t, err)
+
+	assert.Len(t, list.Items, 1)
+	assert.Equal(t, int64(pageSize), listOptions.Limit)
+	assert.Equal(t, "token", listOptions.Continue)
+}
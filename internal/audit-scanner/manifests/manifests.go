@@ -0,0 +1,120 @@
+package manifests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Stdin is the path reading the manifests from the standard input.
+const Stdin = "-"
+
+// decoderBufferSize is the size of the buffer used to find the end of the YAML and JSON documents.
+const decoderBufferSize = 4096
+
+// extensions are the extensions of the manifest files read from the directories.
+var extensions = []string{".yaml", ".yml", ".json"}
+
+// Load reads the Kubernetes objects of the YAML and JSON manifests at the given
+// paths. Directories are walked recursively, reading the files with a .yaml,
+// .yml or .json extension. The Stdin path reads the manifests from stdin.
+// The items of List objects are returned as separate objects.
+func Load(paths []string, stdin io.Reader) ([]unstructured.Unstructured, error) {
+	var objects []unstructured.Unstructured
+
+	for _, path := range paths {
+		if path == Stdin {
+			decoded, err := Decode(stdin, "stdin")
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, decoded...)
+			continue
+		}
+
+		err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			// Files given explicitly are always read, whatever their extension
+			if filePath != path && !slices.Contains(extensions, strings.ToLower(filepath.Ext(filePath))) {
+				return nil
+			}
+
+			decoded, err := decodeFile(filePath)
+			if err != nil {
+				return err
+			}
+			objects = append(objects, decoded...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifests from %s: %w", path, err)
+		}
+	}
+
+	return objects, nil
+}
+
+func decodeFile(path string) ([]unstructured.Unstructured, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open manifest: %w", err)
+	}
+	defer file.Close()
+
+	return Decode(file, path)
+}
+
+// Decode reads the Kubernetes objects of a stream of YAML or JSON documents.
+// Empty documents are ignored. The source is used in the error messages.
+func Decode(reader io.Reader, source string) ([]unstructured.Unstructured, error) {
+	var objects []unstructured.Unstructured
+
+	decoder := yaml.NewYAMLOrJSONDecoder(reader, decoderBufferSize)
+	for document := 1; ; document++ {
+		object := map[string]any{}
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("cannot decode document %d of %s: %w", document, source, err)
+		}
+		if len(object) == 0 {
+			continue
+		}
+
+		resource := unstructured.Unstructured{Object: object}
+		if resource.GetAPIVersion() == "" || resource.GetKind() == "" {
+			return nil, fmt.Errorf("document %d of %s is not a Kubernetes object: apiVersion and kind are required", document, source)
+		}
+
+		if !resource.IsList() {
+			objects = append(objects, resource)
+			continue
+		}
+		err := resource.EachListItem(func(item runtime.Object) error {
+			itemResource, ok := item.(*unstructured.Unstructured)
+			if !ok {
+				return fmt.Errorf("unexpected list item type %T", item)
+			}
+			objects = append(objects, *itemResource)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the items of the list in document %d of %s: %w", document, source, err)
+		}
+	}
+}
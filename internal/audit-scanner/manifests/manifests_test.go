@@ -0,0 +1,81 @@
+package manifests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+`
+
+const podAndService = `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+---
+# an empty document
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: nginx
+`
+
+const list = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "first"}},
+    {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "second"}}
+  ]
+}`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deployment.yaml"), []byte(deployment), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "list.json"), []byte(list), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# not a manifest"), 0o600))
+	explicitFile := filepath.Join(t.TempDir(), "manifest.txt")
+	require.NoError(t, os.WriteFile(explicitFile, []byte(deployment), 0o600))
+
+	objects, err := Load([]string{dir, Stdin, explicitFile}, strings.NewReader(podAndService))
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, object := range objects {
+		names = append(names, object.GetKind()+"/"+object.GetName())
+	}
+	assert.Equal(t, []string{
+		"Deployment/nginx",
+		"ConfigMap/first",
+		"ConfigMap/second",
+		"Pod/nginx",
+		"Service/nginx",
+		"Deployment/nginx",
+	}, names)
+	assert.Equal(t, "default", objects[0].GetNamespace())
+}
+
+func TestLoadMissingPath(t *testing.T) {
+	_, err := Load([]string{filepath.Join(t.TempDir(), "missing")}, nil)
+	require.Error(t, err)
+}
+
+func TestDecodeInvalidObject(t *testing.T) {
+	_, err := Decode(strings.NewReader("metadata:\n  name: nginx\n"), "test")
+	require.ErrorContains(t, err, "document 1 of test is not a Kubernetes object")
+
+	_, err = Decode(strings.NewReader("kind: [\n"), "test")
+	require.ErrorContains(t, err, "cannot decode document 1 of test")
+}
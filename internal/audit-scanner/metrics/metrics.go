@@ -0,0 +1,242 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	metricSDK "go.opentelemetry.io/otel/sdk/metric"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	meterName                = "kubewarden-audit-scanner"
+	timeBetweenExports       = 2 * time.Second
+	prometheusMetricsPath    = "/metrics"
+	runDurationMetricName    = "kubewarden_audit_run_duration_seconds"
+	resourcesMetricName      = "kubewarden_audit_resources_total"
+	evaluationsMetricName    = "kubewarden_audit_evaluations_total"
+	evaluationLatencyName    = "kubewarden_audit_evaluation_duration_seconds"
+	reusedEvaluationsName    = "kubewarden_audit_reused_evaluations_total"
+	policyResultsMetricName  = "kubewarden_audit_policy_results_total"
+	storeFailuresMetricName  = "kubewarden_audit_report_store_failures_total"
+	runUIDAttribute          = "run_uid"
+	policyServerAttribute    = "policy_server"
+	erroredAttribute         = "errored"
+	policyAttribute          = "policy"
+	resultAttribute          = "result"
+	operationAttribute       = "operation"
+	namespaceAttribute       = "namespace"
+	groupAttribute           = "group"
+	versionAttribute         = "version"
+	resourceAttribute        = "resource"
+	secondsUnit              = "s"
+	defaultReadHeaderTimeout = 5 * time.Second
+)
+
+// Results of the policies.
+const (
+	ResultPass  = "pass"
+	ResultFail  = "fail"
+	ResultWarn  = "warn"
+	ResultError = "error"
+	ResultSkip  = "skip"
+)
+
+// Operations of the report store.
+const (
+	OperationWriteReport         = "write_report"
+	OperationWriteClusterReport  = "write_cluster_report"
+	OperationDeleteReports       = "delete_reports"
+	OperationDeleteClusterReport = "delete_cluster_reports"
+)
+
+// Options configures where the metrics are exported.
+type Options struct {
+	// OTLP enables the export of the metrics to an OpenTelemetry collector.
+	// The exporter is configured by the OTEL_EXPORTER_OTLP_* environment variables
+	OTLP bool
+	// PrometheusAddress is the address serving the metrics in the Prometheus
+	// format. The Prometheus endpoint is disabled when empty
+	PrometheusAddress string
+}
+
+// New sets up the global MeterProvider exporting the metrics as configured by
+// the options, and returns the function flushing the pending metrics and
+// stopping the exporters.
+// When no exporter is enabled, the metrics are not recorded.
+func New(ctx context.Context, options Options, logger *slog.Logger) (func(context.Context) error, error) {
+	var providerOptions []metricSDK.Option
+	var shutdowns []func(context.Context) error
+
+	if options.OTLP {
+		// All the Otel exporter configuration is set by environment variables.
+		exporter, err := otlpmetricgrpc.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot start metric exporter: %w", err)
+		}
+		providerOptions = append(providerOptions, metricSDK.WithReader(
+			metricSDK.NewPeriodicReader(exporter, metricSDK.WithInterval(timeBetweenExports))))
+	}
+
+	if options.PrometheusAddress != "" {
+		registry := prometheus.NewRegistry()
+		exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+		if err != nil {
+			return nil, fmt.Errorf("cannot start Prometheus exporter: %w", err)
+		}
+		providerOptions = append(providerOptions, metricSDK.WithReader(exporter))
+
+		listener, err := net.Listen("tcp", options.PrometheusAddress)
+		if err != nil {
+			return nil, fmt.Errorf("cannot listen on %s: %w", options.PrometheusAddress, err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle(prometheusMetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		server := &http.Server{Handler: mux, ReadHeaderTimeout: defaultReadHeaderTimeout}
+		go func() {
+			if serveErr := server.Serve(listener); serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+				logger.Error("Prometheus metrics endpoint failed", slog.String("error", serveErr.Error()))
+			}
+		}()
+		logger.Info("serving Prometheus metrics", slog.String("address", listener.Addr().String()))
+		shutdowns = append(shutdowns, server.Shutdown)
+	}
+
+	if len(providerOptions) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	meterProvider := metricSDK.NewMeterProvider(providerOptions...)
+	otel.SetMeterProvider(meterProvider)
+	// The meter provider is shut down first, so the last metrics are exported
+	shutdowns = append([]func(context.Context) error{meterProvider.Shutdown}, shutdowns...)
+
+	return func(ctx context.Context) error {
+		var err error
+		for _, shutdown := range shutdowns {
+			err = errors.Join(err, shutdown(ctx))
+		}
+		return err
+	}, nil
+}
+
+// Recorder records the metrics of the audit runs. All the metrics are labelled
+// with the UID of the run.
+type Recorder struct {
+	runDuration       metric.Float64Histogram
+	resources         metric.Int64Counter
+	evaluations       metric.Int64Counter
+	evaluationLatency metric.Float64Histogram
+	reusedEvaluations metric.Int64Counter
+	policyResults     metric.Int64Counter
+	storeFailures     metric.Int64Counter
+}
+
+// NewRecorder creates the instruments of the audit metrics with the given MeterProvider.
+func NewRecorder(meterProvider metric.MeterProvider) (*Recorder, error) {
+	meter := meterProvider.Meter(meterName)
+	var err error
+	recorder := &Recorder{}
+
+	if recorder.runDuration, err = meter.Float64Histogram(runDurationMetricName,
+		metric.WithDescription("Duration of the audit runs"),
+		metric.WithUnit(secondsUnit)); err != nil {
+		return nil, fmt.Errorf("cannot create the instrument: %w", err)
+	}
+	if recorder.resources, err = meter.Int64Counter(resourcesMetricName,
+		metric.WithDescription("How many resources have been audited, by resource and namespace")); err != nil {
+		return nil, fmt.Errorf("cannot create the instrument: %w", err)
+	}
+	if recorder.evaluations, err = meter.Int64Counter(evaluationsMetricName,
+		metric.WithDescription("How many evaluations have been sent to each PolicyServer")); err != nil {
+		return nil, fmt.Errorf("cannot create the instrument: %w", err)
+	}
+	if recorder.evaluationLatency, err = meter.Float64Histogram(evaluationLatencyName,
+		metric.WithDescription("Latency of the evaluations sent to each PolicyServer"),
+		metric.WithUnit(secondsUnit)); err != nil {
+		return nil, fmt.Errorf("cannot create the instrument: %w", err)
+	}
+	if recorder.reusedEvaluations, err = meter.Int64Counter(reusedEvaluationsName,
+		metric.WithDescription("How many evaluations reused the verdict of an equivalent resource instead of being sent to each PolicyServer")); err != nil {
+		return nil, fmt.Errorf("cannot create the instrument: %w", err)
+	}
+	if recorder.policyResults, err = meter.Int64Counter(policyResultsMetricName,
+		metric.WithDescription("How many pass, fail, error and skip results each policy produced")); err != nil {
+		return nil, fmt.Errorf("cannot create the instrument: %w", err)
+	}
+	if recorder.storeFailures, err = meter.Int64Counter(storeFailuresMetricName,
+		metric.WithDescription("How many report store operations failed")); err != nil {
+		return nil, fmt.Errorf("cannot create the instrument: %w", err)
+	}
+
+	return recorder, nil
+}
+
+// RecordRun records the duration of an audit run.
+func (r *Recorder) RecordRun(ctx context.Context, runUID string, duration time.Duration, errored bool) {
+	r.runDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+		attribute.String(runUIDAttribute, runUID),
+		attribute.Bool(erroredAttribute, errored),
+	))
+}
+
+// RecordResourceAudited records the audit of a resource. The namespace is
+// empty for cluster-wide resources.
+func (r *Recorder) RecordResourceAudited(ctx context.Context, runUID string, gvr schema.GroupVersionResource, namespace string) {
+	r.resources.Add(ctx, 1, metric.WithAttributes(
+		attribute.String(runUIDAttribute, runUID),
+		attribute.String(groupAttribute, gvr.Group),
+		attribute.String(versionAttribute, gvr.Version),
+		attribute.String(resourceAttribute, gvr.Resource),
+		attribute.String(namespaceAttribute, namespace),
+	))
+}
+
+// RecordEvaluation records an evaluation sent to a PolicyServer, and its latency.
+func (r *Recorder) RecordEvaluation(ctx context.Context, runUID, policyServer string, latency time.Duration, errored bool) {
+	attributes := metric.WithAttributes(
+		attribute.String(runUIDAttribute, runUID),
+		attribute.String(policyServerAttribute, policyServer),
+		attribute.Bool(erroredAttribute, errored),
+	)
+	r.evaluations.Add(ctx, 1, attributes)
+	r.evaluationLatency.Record(ctx, latency.Seconds(), attributes)
+}
+
+// RecordReusedEvaluation records an evaluation not sent to a PolicyServer
+// because the verdict of an equivalent resource was reused.
+func (r *Recorder) RecordReusedEvaluation(ctx context.Context, runUID, policyServer string) {
+	r.reusedEvaluations.Add(ctx, 1, metric.WithAttributes(
+		attribute.String(runUIDAttribute, runUID),
+		attribute.String(policyServerAttribute, policyServer),
+	))
+}
+
+// RecordPolicyResult records the result of a policy: pass, fail, error or skip.
+func (r *Recorder) RecordPolicyResult(ctx context.Context, runUID, policy, result string) {
+	r.policyResults.Add(ctx, 1, metric.WithAttributes(
+		attribute.String(runUIDAttribute, runUID),
+		attribute.String(policyAttribute, policy),
+		attribute.String(resultAttribute, result),
+	))
+}
+
+// RecordReportStoreFailure records a failed report store operation.
+func (r *Recorder) RecordReportStoreFailure(ctx context.Context, runUID, operation string) {
+	r.storeFailures.Add(ctx, 1, metric.WithAttributes(
+		attribute.String(runUIDAttribute, runUID),
+		attribute.String(operationAttribute, operation),
+	))
+}
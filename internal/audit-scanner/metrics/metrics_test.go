@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	metricSDK "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func collectMetrics(t *testing.T, reader metricSDK.Reader) map[string]metricdata.Aggregation {
+	t.Helper()
+
+	resourceMetrics := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(t.Context(), &resourceMetrics))
+
+	collected := make(map[string]metricdata.Aggregation)
+	for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+		for _, m := range scopeMetrics.Metrics {
+			collected[m.Name] = m.Data
+		}
+	}
+	return collected
+}
+
+func TestRecorder(t *testing.T) {
+	reader := metricSDK.NewManualReader()
+	recorder, err := NewRecorder(metricSDK.NewMeterProvider(metricSDK.WithReader(reader)))
+	require.NoError(t, err)
+
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	recorder.RecordRun(t.Context(), "runUID", time.Minute, false)
+	recorder.RecordResourceAudited(t.Context(), "runUID", podsGVR, "default")
+	recorder.RecordResourceAudited(t.Context(), "runUID", podsGVR, "default")
+	recorder.RecordEvaluation(t.Context(), "runUID", "default", 100*time.Millisecond, false)
+	recorder.RecordEvaluation(t.Context(), "runUID", "default", time.Second, true)
+	recorder.RecordReusedEvaluation(t.Context(), "runUID", "default")
+	recorder.RecordPolicyResult(t.Context(), "runUID", "clusterwide-policy", ResultPass)
+	recorder.RecordPolicyResult(t.Context(), "runUID", "clusterwide-policy", ResultSkip)
+	recorder.RecordReportStoreFailure(t.Context(), "runUID", OperationWriteReport)
+
+	collected := collectMetrics(t, reader)
+
+	runDuration, ok := collected[runDurationMetricName].(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, runDuration.DataPoints, 1)
+	assert.InDelta(t, 60.0, runDuration.DataPoints[0].Sum, 0.001)
+	runUID, found := runDuration.DataPoints[0].Attributes.Value(runUIDAttribute)
+	require.True(t, found)
+	assert.Equal(t, "runUID", runUID.AsString())
+
+	resources, ok := collected[resourcesMetricName].(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, resources.DataPoints, 1)
+	assert.Equal(t, int64(2), resources.DataPoints[0].Value)
+	resource, found := resources.DataPoints[0].Attributes.Value(resourceAttribute)
+	require.True(t, found)
+	assert.Equal(t, "pods", resource.AsString())
+
+	evaluations, ok := collected[evaluationsMetricName].(metricdata.Sum[int64])
+	require.True(t, ok)
+	assert.Len(t, evaluations.DataPoints, 2)
+
+	latency, ok := collected[evaluationLatencyName].(metricdata.Histogram[float64])
+	require.True(t, ok)
+	assert.Len(t, latency.DataPoints, 2)
+
+	reusedEvaluations, ok := collected[reusedEvaluationsName].(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, reusedEvaluations.DataPoints, 1)
+	assert.Equal(t, int64(1), reusedEvaluations.DataPoints[0].Value)
+
+	policyResults, ok := collected[policyResultsMetricName].(metricdata.Sum[int64])
+	require.True(t, ok)
+	results := make(map[string]int64)
+	for _, dataPoint := range policyResults.DataPoints {
+		result, _ := dataPoint.Attributes.Value(attribute.Key(resultAttribute))
+		results[result.AsString()] = dataPoint.Value
+	}
+	assert.Equal(t, map[string]int64{ResultPass: 1, ResultSkip: 1}, results)
+
+	storeFailures, ok := collected[storeFailuresMetricName].(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, storeFailures.DataPoints, 1)
+	operation, found := storeFailures.DataPoints[0].Attributes.Value(operationAttribute)
+	require.True(t, found)
+	assert.Equal(t, OperationWriteReport, operation.AsString())
+}
+
+func TestNewWithoutExporters(t *testing.T) {
+	shutdown, err := New(t.Context(), Options{}, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, shutdown(t.Context()))
+}
@@ -0,0 +1,89 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	// cloudEventsSource is the source of the CloudEvents sent by the audit scanner
+	cloudEventsSource = "kubewarden.io/audit-scanner"
+	// CloudEventTypeNewFailure is the type of the CloudEvents of the new failures
+	CloudEventTypeNewFailure = "io.kubewarden.audit.failure.new"
+	// CloudEventTypeFailure is the type of the CloudEvents of the failures
+	// found by a run, sent at its end
+	CloudEventTypeFailure = "io.kubewarden.audit.failure"
+
+	cloudEventsContentType      = "application/cloudevents+json; charset=UTF-8"
+	cloudEventsBatchContentType = "application/cloudevents-batch+json; charset=UTF-8"
+)
+
+// cloudEvent is a CloudEvent in the JSON event format, holding a finding.
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            Finding   `json:"data"`
+}
+
+// cloudEventsSink posts a CloudEvent for each finding, with the HTTP binding
+// of CloudEvents: in the batched content mode, or in the structured one when
+// the batches hold a single finding, for the receivers not supporting the
+// batched mode.
+type cloudEventsSink struct {
+	client     *httpClient
+	structured bool
+}
+
+func (s *cloudEventsSink) send(ctx context.Context, batch Batch) error {
+	eventType := CloudEventTypeFailure
+	if batch.Trigger == TriggerNewFailures {
+		eventType = CloudEventTypeNewFailure
+	}
+	events := make([]cloudEvent, 0, len(batch.Findings))
+	for _, finding := range batch.Findings {
+		events = append(events, cloudEvent{
+			SpecVersion:     cloudEventsSpecVersion,
+			ID:              uuid.New().String(),
+			Source:          cloudEventsSource,
+			Type:            eventType,
+			Subject:         path.Join(finding.Namespace, finding.Kind, finding.Name),
+			Time:            time.Now(),
+			DataContentType: "application/json",
+			Data:            finding,
+		})
+	}
+
+	if s.structured {
+		for _, event := range events {
+			if err := s.post(ctx, event, cloudEventsContentType); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return s.post(ctx, events, cloudEventsBatchContentType)
+}
+
+// post sends the CloudEvents, a single one or a batch, with the content type
+// of the given mode.
+func (s *cloudEventsSink) post(ctx context.Context, events any, contentType string) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the CloudEvents: %w", err)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	return s.client.post(ctx, body, header)
+}
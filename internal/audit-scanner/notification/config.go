@@ -0,0 +1,187 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// SinkType is the type of a notification sink.
+type SinkType string
+
+const (
+	// SinkTypeWebhook posts the findings as JSON to a generic HTTP endpoint
+	SinkTypeWebhook SinkType = "webhook"
+	// SinkTypeCloudEvents posts the findings as CloudEvents, in the structured
+	// or in the batched content mode of the HTTP binding
+	SinkTypeCloudEvents SinkType = "cloudevents"
+	// SinkTypeSlack posts the findings as a message to a Slack-compatible
+	// incoming webhook
+	SinkTypeSlack SinkType = "slack"
+)
+
+// SinkTypes are the supported types of notification sinks.
+var SinkTypes = []SinkType{SinkTypeWebhook, SinkTypeCloudEvents, SinkTypeSlack}
+
+// Trigger is the moment the findings are sent to a sink.
+type Trigger string
+
+const (
+	// TriggerNewFailures sends the results of the policies that started
+	// failing since the previous report of the resource, as soon as the
+	// resource is audited
+	TriggerNewFailures Trigger = "new-failures"
+	// TriggerRunEnd sends all the failing results of the run once it's finished
+	TriggerRunEnd Trigger = "run-end"
+)
+
+// Triggers are the supported triggers of the notification sinks.
+var Triggers = []Trigger{TriggerNewFailures, TriggerRunEnd}
+
+const (
+	defaultBatchMaxSize = 100
+	defaultBatchMaxWait = 10 * time.Second
+	defaultTimeout      = 10 * time.Second
+)
+
+// Config is the configuration of the notification sinks, read from the file
+// given with the --notification-config flag.
+type Config struct {
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig configures a notification sink.
+type SinkConfig struct {
+	// Name identifies the sink in the logs
+	Name string   `json:"name"`
+	Type SinkType `json:"type"`
+	// URL is the HTTP endpoint the findings are posted to
+	URL string `json:"url"`
+	// Secret signs the body of the webhook requests with HMAC-SHA256. The
+	// requests are not signed when empty. Only supported by the webhook sinks
+	Secret string `json:"secret,omitempty"`
+	// Trigger is the moment the findings are sent, new-failures by default
+	Trigger Trigger `json:"trigger,omitempty"`
+	// MinSeverity is the minimum severity of the findings sent. All the
+	// findings are sent when empty, otherwise the ones without a severity are
+	// ignored
+	MinSeverity string `json:"minSeverity,omitempty"`
+	// Policies restricts the findings sent to the ones of these policies, in
+	// the same format as the --policy flag: name for the cluster-wide policies,
+	// namespace/name for the namespaced ones. All the policies when empty
+	Policies []string    `json:"policies,omitempty"`
+	Batch    BatchConfig `json:"batch,omitempty"`
+	// Timeout of the requests sent to the sink
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// BatchConfig groups the findings sent to a sink in a single request.
+type BatchConfig struct {
+	// MaxSize is the maximum number of findings sent in a request
+	MaxSize int `json:"maxSize,omitempty"`
+	// MaxWait is the maximum time a new failure waits for other ones before
+	// being sent. Not used by the run-end sinks, which send all the findings
+	// at the end of the run
+	MaxWait metav1.Duration `json:"maxWait,omitempty"`
+}
+
+// LoadConfig reads the notification config from the YAML file at path. The
+// ${VAR} references to environment variables are expanded, so the secrets
+// can be read from a Kubernetes Secret mounted as environment variables.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read the notification config: %w", err)
+	}
+	var config Config
+	if err = yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(data))), &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse the notification config %s: %w", path, err)
+	}
+	config.setDefaults()
+	if err = config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid notification config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// setDefaults sets the default values of the fields of the sinks not configured.
+func (c *Config) setDefaults() {
+	for i := range c.Sinks {
+		sink := &c.Sinks[i]
+		if sink.Trigger == "" {
+			sink.Trigger = TriggerNewFailures
+		}
+		if sink.Batch.MaxSize == 0 {
+			sink.Batch.MaxSize = defaultBatchMaxSize
+		}
+		if sink.Batch.MaxWait.Duration == 0 {
+			sink.Batch.MaxWait.Duration = defaultBatchMaxWait
+		}
+		if sink.Timeout.Duration == 0 {
+			sink.Timeout.Duration = defaultTimeout
+		}
+	}
+}
+
+// Validate returns an error when a sink is misconfigured.
+func (c Config) Validate() error {
+	if len(c.Sinks) == 0 {
+		return errors.New("no sink configured")
+	}
+	names := make(map[string]bool, len(c.Sinks))
+	for _, sink := range c.Sinks {
+		if sink.Name == "" {
+			return errors.New("sink without a name")
+		}
+		if names[sink.Name] {
+			return fmt.Errorf("duplicate sink %q", sink.Name)
+		}
+		names[sink.Name] = true
+		if err := sink.validate(); err != nil {
+			return fmt.Errorf("invalid sink %q: %w", sink.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s SinkConfig) validate() error {
+	if !slices.Contains(SinkTypes, s.Type) {
+		return fmt.Errorf("invalid type %q, valid types are %v", s.Type, SinkTypes)
+	}
+	sinkURL, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if (sinkURL.Scheme != "http" && sinkURL.Scheme != "https") || sinkURL.Host == "" {
+		return fmt.Errorf("invalid URL %q: expected an http or https URL", s.URL)
+	}
+	if s.Secret != "" && s.Type != SinkTypeWebhook {
+		return fmt.Errorf("the requests of the %s sinks cannot be signed", s.Type)
+	}
+	if !slices.Contains(Triggers, s.Trigger) {
+		return fmt.Errorf("invalid trigger %q, valid triggers are %v", s.Trigger, Triggers)
+	}
+	if err = s.threshold().Validate(); err != nil {
+		return err
+	}
+	if err = (policies.Filter{Policies: s.Policies}).Validate(); err != nil {
+		return err
+	}
+	if s.Batch.MaxSize < 0 || s.Batch.MaxWait.Duration < 0 || s.Timeout.Duration < 0 {
+		return errors.New("the batch size, the batch wait and the timeout cannot be negative")
+	}
+	return nil
+}
+
+// threshold selects the failing results with at least the minimum severity of the sink.
+func (s SinkConfig) threshold() report.Threshold {
+	return report.Threshold{FailOn: []string{report.ResultFail}, MinSeverity: s.MinSeverity}
+}
@@ -0,0 +1,79 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const (
+	userAgent = "kubewarden-audit-scanner"
+	// maxErrorBodyLength is the maximum length of the body of a failed
+	// response included in the error
+	maxErrorBodyLength = 512
+)
+
+// httpClient posts the requests of a sink to its URL.
+type httpClient struct {
+	client *http.Client
+	url    string
+}
+
+func newHTTPClient(config SinkConfig) *httpClient {
+	return &httpClient{
+		client: &http.Client{Timeout: config.Timeout.Duration},
+		url:    config.URL,
+	}
+}
+
+// post sends the body with the given headers, and returns an error when the
+// response status is not a 2xx one.
+func (c *httpClient) post(ctx context.Context, body []byte, header http.Header) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create the request: %w", err)
+	}
+	request.Header = header
+	request.Header.Set("User-Agent", userAgent)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send the request: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodyLength))
+		return &statusError{statusCode: response.StatusCode, status: response.Status, body: bytes.TrimSpace(responseBody)}
+	}
+	// The body is drained, so the connection is reused
+	_, _ = io.Copy(io.Discard, response.Body)
+	return nil
+}
+
+// statusError is returned when a sink answers with a non 2xx status code.
+type statusError struct {
+	statusCode int
+	status     string
+	body       []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected response status %s: %s", e.status, e.body)
+}
+
+// isRetryable returns true if the request failed because of a connection
+// error, or if the sink is overloaded or failed to handle it.
+func isRetryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode == http.StatusTooManyRequests || statusErr.statusCode >= http.StatusInternalServerError
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,460 @@
+// Package notification sends the failures found by the audit scanner to
+// outbound sinks: generic HTTP webhooks, CloudEvents receivers and
+// Slack-compatible incoming webhooks.
+package notification
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// maxQueuedBatches is the maximum number of batches of a sink waiting to
+	// be sent. Queuing the findings blocks once it's reached
+	maxQueuedBatches = 100
+	// maxRetries is the maximum number of retries of a batch that failed to
+	// be sent
+	maxRetries           = 5
+	retryBackoffDuration = time.Second
+	retryBackoffFactor   = 2.0
+	retryBackoffJitter   = 0.1
+	maxRetryBackoff      = 30 * time.Second
+	// closeTimeout is the maximum time spent sending the queued batches when
+	// closing the notifier
+	closeTimeout = 30 * time.Second
+)
+
+// Finding is a failing result of a policy for a resource.
+type Finding struct {
+	RunUID     string    `json:"runUID"`
+	Timestamp  time.Time `json:"timestamp"`
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	UID        types.UID `json:"uid"`
+	// Policy is the unique name of the policy, as in the reports
+	Policy   string `json:"policy"`
+	Severity string `json:"severity,omitempty"`
+	Category string `json:"category,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// newFinding returns the finding of the result of the policy for the resource.
+func newFinding(runUID string, resource corev1.ObjectReference, result report.Result) Finding {
+	return Finding{
+		RunUID:     runUID,
+		Timestamp:  result.Timestamp,
+		APIVersion: resource.APIVersion,
+		Kind:       resource.Kind,
+		Namespace:  resource.Namespace,
+		Name:       resource.Name,
+		UID:        resource.UID,
+		Policy:     result.Policy,
+		Severity:   result.Severity,
+		Category:   result.Category,
+		Message:    result.Message,
+	}
+}
+
+// Batch is a group of findings sent to a sink in a single request.
+type Batch struct {
+	// Sink is the name of the sink
+	Sink     string    `json:"sink"`
+	Trigger  Trigger   `json:"trigger"`
+	Findings []Finding `json:"findings"`
+}
+
+// sink sends the batches of findings to an outbound endpoint.
+type sink interface {
+	send(ctx context.Context, batch Batch) error
+}
+
+// Notifier sends the failures found by the scans to the configured sinks.
+// It's safe for concurrent use.
+type Notifier struct {
+	dispatchers []*dispatcher
+}
+
+// NewNotifier returns a Notifier sending the findings to the sinks of the
+// given config, which must be valid. The fields of the sinks not configured
+// get their default values.
+func NewNotifier(config Config, logger *slog.Logger) *Notifier {
+	config.Sinks = slices.Clone(config.Sinks)
+	config.setDefaults()
+	logger = logger.With("component", "notification")
+	notifier := &Notifier{}
+	for _, sinkConfig := range config.Sinks {
+		notifier.dispatchers = append(notifier.dispatchers, newDispatcher(sinkConfig, newSink(sinkConfig), logger))
+	}
+	return notifier
+}
+
+// newSink returns the sink of the given type.
+func newSink(config SinkConfig) sink {
+	client := newHTTPClient(config)
+	switch config.Type {
+	case SinkTypeCloudEvents:
+		return &cloudEventsSink{client: client, structured: config.Batch.MaxSize == 1}
+	case SinkTypeSlack:
+		return &slackSink{client: client}
+	default:
+		return &webhookSink{client: client, secret: []byte(config.Secret)}
+	}
+}
+
+// NotifiesNewFailures returns true when a sink is notified of the new
+// failures, which are found by comparing each report with the previous one.
+func (n *Notifier) NotifiesNewFailures() bool {
+	return slices.ContainsFunc(n.dispatchers, func(d *dispatcher) bool {
+		return d.config.Trigger == TriggerNewFailures
+	})
+}
+
+// NotifyNewFailures queues the results of the report of the policies that
+// started failing for the resource, as found by report.ChangedResults, to the
+// sinks notified of the new failures. The findings are sent once a batch is
+// full or its maximum wait has elapsed.
+func (n *Notifier) NotifyNewFailures(ctx context.Context, runUID string, r report.Report, changes []report.ResultChange) {
+	failing := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		if change.Failing {
+			failing[change.Policy] = true
+		}
+	}
+	if len(failing) == 0 {
+		return
+	}
+
+	resourceResults := r.ResourceResults()
+	for _, d := range n.dispatchers {
+		if d.config.Trigger != TriggerNewFailures {
+			continue
+		}
+		var findings []Finding
+		for _, result := range resourceResults.Results {
+			if failing[result.Policy] && d.selects(result) {
+				findings = append(findings, newFinding(runUID, resourceResults.Resource, result))
+			}
+		}
+		if len(findings) > 0 {
+			d.queue(ctx, findings)
+		}
+	}
+}
+
+// RecordReport records the failing results of the report for the sinks
+// notified at the end of the run. A resource audited again, e.g. when
+// watching the cluster, replaces its previous results.
+func (n *Notifier) RecordReport(runUID string, r report.Report) {
+	resourceResults := r.ResourceResults()
+	for _, d := range n.dispatchers {
+		if d.config.Trigger == TriggerRunEnd {
+			d.record(runUID, resourceResults)
+		}
+	}
+}
+
+// FinishRun queues the failing results recorded during the run to the sinks
+// notified at the end of the run.
+func (n *Notifier) FinishRun(ctx context.Context) {
+	for _, d := range n.dispatchers {
+		if d.config.Trigger == TriggerRunEnd {
+			d.flushFailures(ctx)
+		}
+	}
+}
+
+// Close queues the new failures still waiting in a batch, and waits for the
+// queued batches to be sent, so none of them is lost when the audit scanner
+// exits. The batches not sent within closeTimeout are dropped.
+func (n *Notifier) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	var workers sync.WaitGroup
+	for _, d := range n.dispatchers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			d.close(ctx)
+		}()
+	}
+	workers.Wait()
+}
+
+// failureKey identifies the result of a policy for a resource.
+type failureKey struct {
+	resource types.UID
+	policy   string
+}
+
+// dispatcher filters and batches the findings sent to a sink. The batches are
+// sent by a goroutine of the dispatcher, so a slow sink only slows down the
+// audit once maxQueuedBatches are waiting to be sent: queuing the findings
+// then blocks until a batch is sent, or the dispatcher is closed.
+type dispatcher struct {
+	config    SinkConfig
+	sink      sink
+	threshold report.Threshold
+	// policies are the unique names of the policies of the findings sent, all
+	// of them when empty
+	policies []string
+	logger   *slog.Logger
+	// retryBackoff configures the retries of the batches that failed to be sent
+	retryBackoff wait.Backoff
+
+	// batches are the batches waiting to be sent by the goroutine
+	batches chan Batch
+	// done is closed once the goroutine has sent all the batches
+	done chan struct{}
+	// ctx is the context of the requests sent by the goroutine, cancelled
+	// when closing the dispatcher times out
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// queueMutex guards closed, so no batch is queued once the channel of the
+	// batches is closed. It's not held while waiting for room in the queue.
+	queueMutex sync.RWMutex
+	closed     bool
+	// closing is closed when closing the dispatcher, so the findings waiting
+	// for room in the queue are dropped
+	closing chan struct{}
+	// enqueuing tracks the findings being queued, the channel of the batches
+	// is closed once they are all queued or dropped
+	enqueuing sync.WaitGroup
+
+	mutex sync.Mutex
+	// pending are the new failures waiting to be sent
+	pending []Finding
+	// timer queues the pending findings once the maximum wait has elapsed,
+	// nil when no finding is pending
+	timer *time.Timer
+	// failures are the failing results of the run, sent at the end of the run
+	failures map[failureKey]Finding
+}
+
+// newDispatcher returns a dispatcher of the findings to the sink, and starts
+// its goroutine sending the batches.
+func newDispatcher(config SinkConfig, sink sink, logger *slog.Logger) *dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &dispatcher{
+		config:    config,
+		sink:      sink,
+		threshold: config.threshold(),
+		policies:  policies.Filter{Policies: config.Policies}.PolicyUniqueNames(),
+		logger:    logger.With(slog.String("sink", config.Name)),
+		retryBackoff: wait.Backoff{
+			Duration: retryBackoffDuration,
+			Factor:   retryBackoffFactor,
+			Jitter:   retryBackoffJitter,
+			Steps:    maxRetries,
+			Cap:      maxRetryBackoff,
+		},
+		batches:  make(chan Batch, maxQueuedBatches),
+		done:     make(chan struct{}),
+		closing:  make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		failures: make(map[failureKey]Finding),
+	}
+	go d.run()
+	return d
+}
+
+// selects returns true when the result is sent to the sink.
+func (d *dispatcher) selects(result report.Result) bool {
+	if !d.threshold.Exceeds(result) {
+		return false
+	}
+	return len(d.policies) == 0 || slices.Contains(d.policies, result.Policy)
+}
+
+// queue adds the findings to the pending ones, and queues them to be sent
+// once the batch is full. Otherwise, they are queued when the maximum wait
+// has elapsed.
+func (d *dispatcher) queue(ctx context.Context, findings []Finding) {
+	d.mutex.Lock()
+	d.pending = append(d.pending, findings...)
+	var batch []Finding
+	if len(d.pending) >= d.config.Batch.MaxSize {
+		batch = d.takePending()
+	} else if d.timer == nil {
+		d.timer = time.AfterFunc(d.config.Batch.MaxWait.Duration, func() {
+			d.flushPending(context.Background())
+		})
+	}
+	d.mutex.Unlock()
+
+	d.enqueue(ctx, batch)
+}
+
+// takePending returns the pending findings and stops the timer. It must be
+// called with the mutex held.
+func (d *dispatcher) takePending() []Finding {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	pending := d.pending
+	d.pending = nil
+	return pending
+}
+
+// flushPending queues the pending findings, if any.
+func (d *dispatcher) flushPending(ctx context.Context) {
+	d.mutex.Lock()
+	pending := d.takePending()
+	d.mutex.Unlock()
+
+	d.enqueue(ctx, pending)
+}
+
+// record replaces the failures recorded for the resource with its results
+// selected by the sink.
+func (d *dispatcher) record(runUID string, resourceResults report.ResourceResults) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, result := range resourceResults.Results {
+		key := failureKey{resource: resourceResults.Resource.UID, policy: result.Policy}
+		if d.selects(result) {
+			d.failures[key] = newFinding(runUID, resourceResults.Resource, result)
+		} else {
+			delete(d.failures, key)
+		}
+	}
+}
+
+// flushFailures queues the failures recorded during the run, sorted by
+// resource and policy, and forgets them.
+func (d *dispatcher) flushFailures(ctx context.Context) {
+	d.mutex.Lock()
+	failures := slices.SortedFunc(maps.Values(d.failures), func(a, b Finding) int {
+		return cmp.Or(
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Policy, b.Policy),
+		)
+	})
+	clear(d.failures)
+	d.mutex.Unlock()
+
+	d.enqueue(ctx, failures)
+}
+
+// enqueue queues the findings to be sent by the goroutine, in batches of the
+// maximum size. It blocks while the queue of the batches is full, and drops
+// the findings when the context is done or the dispatcher is closed.
+func (d *dispatcher) enqueue(ctx context.Context, findings []Finding) {
+	if len(findings) == 0 {
+		return
+	}
+
+	d.queueMutex.RLock()
+	if d.closed {
+		d.queueMutex.RUnlock()
+		d.logger.WarnContext(ctx, "dropping the findings queued after closing the notification sink", slog.Int("findings", len(findings)))
+		return
+	}
+	d.enqueuing.Add(1)
+	d.queueMutex.RUnlock()
+	defer d.enqueuing.Done()
+
+	for batch := range slices.Chunk(findings, d.config.Batch.MaxSize) {
+		select {
+		case d.batches <- Batch{Sink: d.config.Name, Trigger: d.config.Trigger, Findings: batch}:
+		case <-ctx.Done():
+			d.logger.WarnContext(ctx, "dropping the findings not queued to the notification sink",
+				slog.String("error", ctx.Err().Error()),
+				slog.Int("findings", len(batch)))
+			return
+		case <-d.closing:
+			d.logger.WarnContext(ctx, "dropping the findings not queued before closing the notification sink", slog.Int("findings", len(batch)))
+			return
+		}
+	}
+}
+
+// close queues the pending findings, and waits for the goroutine to send all
+// the queued batches. When the context is done first, the requests in
+// flight are cancelled and the batches left are dropped.
+func (d *dispatcher) close(ctx context.Context) {
+	d.flushPending(ctx)
+
+	d.queueMutex.Lock()
+	alreadyClosed := d.closed
+	d.closed = true
+	d.queueMutex.Unlock()
+	if !alreadyClosed {
+		close(d.closing)
+		d.enqueuing.Wait()
+		close(d.batches)
+	}
+
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+		d.logger.WarnContext(ctx, "timed out sending the queued findings to the notification sink")
+		d.cancel()
+		<-d.done
+	}
+	d.cancel()
+}
+
+// run sends the queued batches until the channel of the batches is closed.
+func (d *dispatcher) run() {
+	defer close(d.done)
+	for batch := range d.batches {
+		if d.ctx.Err() != nil {
+			continue
+		}
+		d.send(d.ctx, batch)
+	}
+}
+
+// send sends the batch to the sink. The requests that failed because of a
+// connection error, or an overloaded or failing sink, are retried with an
+// exponential backoff. The failures are logged, the findings of a batch that
+// could not be sent are dropped.
+func (d *dispatcher) send(ctx context.Context, batch Batch) {
+	d.logger.DebugContext(ctx, "sending the findings to the notification sink", slog.Int("findings", len(batch.Findings)))
+
+	backoff := d.retryBackoff
+	for retry := 0; ; retry++ {
+		err := d.sink.send(ctx, batch)
+		if err == nil {
+			return
+		}
+		if ctx.Err() != nil || !isRetryable(err) || retry >= maxRetries {
+			d.logger.WarnContext(ctx, "failed to send the findings to the notification sink",
+				slog.String("error", err.Error()),
+				slog.Int("retries", retry),
+				slog.Int("findings", len(batch.Findings)))
+			return
+		}
+
+		delay := backoff.Step()
+		d.logger.DebugContext(ctx, "retrying to send the findings to the notification sink",
+			slog.String("error", err.Error()),
+			slog.Int("retry", retry+1),
+			slog.Duration("backoff", delay))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,583 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// receivedRequest is a request received by the stand-in of a sink.
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver returns a local HTTP server standing in for a sink, which
+// forwards the requests it receives to the returned channel.
+func newReceiver(t *testing.T) (*httptest.Server, <-chan receivedRequest) {
+	t.Helper()
+	requests := make(chan receivedRequest, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		requests <- receivedRequest{header: request.Header, body: body}
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// receive returns the next request received by the stand-in of a sink.
+func receive(t *testing.T, requests <-chan receivedRequest) receivedRequest {
+	t.Helper()
+	select {
+	case request := <-requests:
+		return request
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no request received")
+		return receivedRequest{}
+	}
+}
+
+// assertNoRequest asserts the stand-in of a sink received no other request.
+func assertNoRequest(t *testing.T, requests <-chan receivedRequest) {
+	t.Helper()
+	select {
+	case request := <-requests:
+		assert.Failf(t, "unexpected request", "%s", request.body)
+	default:
+	}
+}
+
+func newTestResource(name string) unstructured.Unstructured {
+	resource := unstructured.Unstructured{}
+	resource.SetUID(types.UID(name + "-uid"))
+	resource.SetNamespace("default")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName(name)
+	return resource
+}
+
+func newTestPolicy(name, severity string) *policiesv1.ClusterAdmissionPolicy {
+	return &policiesv1.ClusterAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{
+		Name:        name,
+		Annotations: map[string]string{policiesv1.AnnotationSeverity: severity},
+	}}
+}
+
+var (
+	accepted = &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: true},
+	}
+	rejected = &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Message: "privileged containers are not allowed"},
+		},
+	}
+)
+
+// newTestReports returns the previous and the current report of a resource,
+// where the critical and the low policies started failing.
+func newTestReports(resourceName string) (report.Report, report.Report) {
+	resource := newTestResource(resourceName)
+	previousReport := report.NewOpenReport("previous-uid", resource)
+	previousReport.AddResult(newTestPolicy("critical", "critical"), accepted, false)
+	previousReport.AddResult(newTestPolicy("low", "low"), accepted, false)
+	previousReport.AddResult(newTestPolicy("still-failing", "high"), rejected, false)
+
+	currentReport := report.NewOpenReport("run-uid", resource)
+	currentReport.AddResult(newTestPolicy("critical", "critical"), rejected, false)
+	currentReport.AddResult(newTestPolicy("low", "low"), rejected, false)
+	currentReport.AddResult(newTestPolicy("still-failing", "high"), rejected, false)
+	return previousReport, currentReport
+}
+
+// notifyNewFailures notifies the new failures of the reports of the resource.
+func notifyNewFailures(t *testing.T, notifier *Notifier, resourceName string) {
+	t.Helper()
+	previousReport, currentReport := newTestReports(resourceName)
+	notifier.NotifyNewFailures(t.Context(), "run-uid", currentReport, report.ChangedResults(previousReport, currentReport))
+}
+
+func decodeBatch(t *testing.T, request receivedRequest) Batch {
+	t.Helper()
+	var batch Batch
+	require.NoError(t, json.Unmarshal(request.body, &batch))
+	return batch
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "s3cr3t")
+	path := filepath.Join(t.TempDir(), "notifications.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+sinks:
+  - name: security
+    type: webhook
+    url: https://hooks.example.com/kubewarden
+    secret: ${WEBHOOK_SECRET}
+    minSeverity: high
+    policies:
+      - no-privileged-pod
+      - team/require-labels
+    batch:
+      maxSize: 10
+      maxWait: 1m
+  - name: chat
+    type: slack
+    url: https://hooks.slack.com/services/T000/B000/XXXX
+    trigger: run-end
+`), 0o600))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, config.Sinks, 2)
+	assert.Equal(t, SinkConfig{
+		Name:        "security",
+		Type:        SinkTypeWebhook,
+		URL:         "https://hooks.example.com/kubewarden",
+		Secret:      "s3cr3t",
+		Trigger:     TriggerNewFailures,
+		MinSeverity: "high",
+		Policies:    []string{"no-privileged-pod", "team/require-labels"},
+		Batch:       BatchConfig{MaxSize: 10, MaxWait: metav1.Duration{Duration: time.Minute}},
+		Timeout:     metav1.Duration{Duration: defaultTimeout},
+	}, config.Sinks[0])
+	assert.Equal(t, TriggerRunEnd, config.Sinks[1].Trigger)
+	assert.Equal(t, defaultBatchMaxSize, config.Sinks[1].Batch.MaxSize)
+
+	require.NoError(t, os.WriteFile(path, []byte("sinks:\n  - name: typo\n    type: slack\n    url: https://example.com\n    minSeverty: high\n"), 0o600))
+	_, err = LoadConfig(path)
+	require.Error(t, err)
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := SinkConfig{Name: "sink", Type: SinkTypeWebhook, URL: "https://example.com", Trigger: TriggerNewFailures}
+
+	tests := []struct {
+		name   string
+		mutate func(sink *SinkConfig)
+	}{
+		{"missing name", func(sink *SinkConfig) { sink.Name = "" }},
+		{"unknown type", func(sink *SinkConfig) { sink.Type = "email" }},
+		{"missing URL", func(sink *SinkConfig) { sink.URL = "" }},
+		{"unsupported URL scheme", func(sink *SinkConfig) { sink.URL = "ftp://example.com" }},
+		{"signed Slack sink", func(sink *SinkConfig) { sink.Type = SinkTypeSlack; sink.Secret = "secret" }},
+		{"unknown trigger", func(sink *SinkConfig) { sink.Trigger = "hourly" }},
+		{"unknown severity", func(sink *SinkConfig) { sink.MinSeverity = "severe" }},
+		{"malformed policy", func(sink *SinkConfig) { sink.Policies = []string{"namespace/"} }},
+		{"negative batch size", func(sink *SinkConfig) { sink.Batch.MaxSize = -1 }},
+	}
+
+	require.NoError(t, Config{Sinks: []SinkConfig{valid}}.Validate())
+	require.Error(t, Config{}.Validate())
+	require.Error(t, Config{Sinks: []SinkConfig{valid, valid}}.Validate())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := valid
+			test.mutate(&sink)
+			require.Error(t, Config{Sinks: []SinkConfig{sink}}.Validate())
+		})
+	}
+}
+
+func TestNotifyNewFailures(t *testing.T) {
+	server, requests := newReceiver(t)
+	notifier := NewNotifier(Config{Sinks: []SinkConfig{{
+		Name:        "webhook",
+		Type:        SinkTypeWebhook,
+		URL:         server.URL,
+		Secret:      "secret",
+		MinSeverity: "high",
+		Batch:       BatchConfig{MaxSize: 2, MaxWait: metav1.Duration{Duration: time.Hour}},
+	}}}, slog.Default())
+	assert.True(t, notifier.NotifiesNewFailures())
+
+	// The low policy is filtered out by the severity, and the still failing
+	// one is not a new failure, so the batch waits for another finding
+	notifyNewFailures(t, notifier, "first")
+	assertNoRequest(t, requests)
+	notifyNewFailures(t, notifier, "second")
+
+	request := receive(t, requests)
+	assert.Equal(t, "application/json", request.header.Get("Content-Type"))
+	assert.Equal(t, Sign([]byte("secret"), request.body), request.header.Get(SignatureHeader))
+	batch := decodeBatch(t, request)
+	assert.Equal(t, "webhook", batch.Sink)
+	assert.Equal(t, TriggerNewFailures, batch.Trigger)
+	require.Len(t, batch.Findings, 2)
+	for i, name := range []string{"first", "second"} {
+		finding := batch.Findings[i]
+		assert.Equal(t, "run-uid", finding.RunUID)
+		assert.Equal(t, name, finding.Name)
+		assert.Equal(t, types.UID(name+"-uid"), finding.UID)
+		assert.Equal(t, "clusterwide-critical", finding.Policy)
+		assert.Equal(t, "critical", finding.Severity)
+		assert.Equal(t, "privileged containers are not allowed", finding.Message)
+	}
+
+	// The incomplete batches are sent when closing the notifier
+	notifyNewFailures(t, notifier, "third")
+	assertNoRequest(t, requests)
+	notifier.Close()
+	batch = decodeBatch(t, receive(t, requests))
+	require.Len(t, batch.Findings, 1)
+	assert.Equal(t, "third", batch.Findings[0].Name)
+}
+
+func TestNotifyNewFailuresMaxWait(t *testing.T) {
+	server, requests := newReceiver(t)
+	notifier := NewNotifier(Config{Sinks: []SinkConfig{{
+		Name:     "webhook",
+		Type:     SinkTypeWebhook,
+		URL:      server.URL,
+		Policies: []string{"low"},
+		Batch:    BatchConfig{MaxWait: metav1.Duration{Duration: 10 * time.Millisecond}},
+	}}}, slog.Default())
+
+	notifyNewFailures(t, notifier, "pod")
+
+	request := receive(t, requests)
+	assert.Empty(t, request.header.Get(SignatureHeader))
+	batch := decodeBatch(t, request)
+	require.Len(t, batch.Findings, 1)
+	assert.Equal(t, "clusterwide-low", batch.Findings[0].Policy)
+	notifier.Close()
+	assertNoRequest(t, requests)
+}
+
+func TestFinishRun(t *testing.T) {
+	server, requests := newReceiver(t)
+	notifier := NewNotifier(Config{Sinks: []SinkConfig{{
+		Name:    "webhook",
+		Type:    SinkTypeWebhook,
+		URL:     server.URL,
+		Trigger: TriggerRunEnd,
+		Batch:   BatchConfig{MaxSize: 2},
+	}}}, slog.Default())
+	assert.False(t, notifier.NotifiesNewFailures())
+
+	for _, name := range []string{"b-pod", "a-pod"} {
+		_, currentReport := newTestReports(name)
+		notifier.RecordReport("run-uid", currentReport)
+	}
+	// The pod audited again now passes all the policies
+	passingReport := report.NewOpenReport("run-uid", newTestResource("b-pod"))
+	passingReport.AddResult(newTestPolicy("critical", "critical"), accepted, false)
+	passingReport.AddResult(newTestPolicy("low", "low"), accepted, false)
+	passingReport.AddResult(newTestPolicy("still-failing", "high"), accepted, false)
+	notifier.RecordReport("run-uid", passingReport)
+	assertNoRequest(t, requests)
+
+	notifier.FinishRun(t.Context())
+
+	var policies []string
+	for range 2 {
+		batch := decodeBatch(t, receive(t, requests))
+		assert.Equal(t, TriggerRunEnd, batch.Trigger)
+		for _, finding := range batch.Findings {
+			assert.Equal(t, "a-pod", finding.Name)
+			policies = append(policies, finding.Policy)
+		}
+	}
+	assert.Equal(t, []string{"clusterwide-critical", "clusterwide-low", "clusterwide-still-failing"}, policies)
+	assertNoRequest(t, requests)
+
+	// The failures are forgotten once sent
+	notifier.FinishRun(t.Context())
+	assertNoRequest(t, requests)
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name             string
+		statuses         []int
+		expectedRequests int
+		expectedSent     bool
+	}{
+		{
+			name:             "server errors and rate limiting are retried",
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent},
+			expectedRequests: 3,
+			expectedSent:     true,
+		},
+		{
+			name:             "client errors are not retried",
+			statuses:         []int{http.StatusBadRequest},
+			expectedRequests: 1,
+		},
+		{
+			name:             "the batch is dropped after the maximum number of retries",
+			statuses:         []int{http.StatusInternalServerError},
+			expectedRequests: maxRetries + 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			sent := make(chan struct{}, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				request := int(requests.Add(1))
+				status := test.statuses[min(request, len(test.statuses))-1]
+				writer.WriteHeader(status)
+				if status == http.StatusNoContent {
+					sent <- struct{}{}
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			notifier := NewNotifier(Config{Sinks: []SinkConfig{{
+				Name:  "webhook",
+				Type:  SinkTypeWebhook,
+				URL:   server.URL,
+				Batch: BatchConfig{MaxSize: 2},
+			}}}, slog.Default())
+			notifier.dispatchers[0].retryBackoff.Duration = time.Millisecond
+
+			notifyNewFailures(t, notifier, "pod")
+			notifier.Close()
+
+			assert.Equal(t, test.expectedRequests, int(requests.Load()))
+			assert.Equal(t, test.expectedSent, len(sent) == 1)
+		})
+	}
+}
+
+func TestSlowSink(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		<-release
+		requests.Add(1)
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	notifier := NewNotifier(Config{Sinks: []SinkConfig{{
+		Name:  "webhook",
+		Type:  SinkTypeWebhook,
+		URL:   server.URL,
+		Batch: BatchConfig{MaxSize: 2},
+	}}}, slog.Default())
+
+	// The full batches are queued without waiting for the sink
+	queued := make(chan struct{})
+	go func() {
+		for _, name := range []string{"first", "second", "third"} {
+			notifyNewFailures(t, notifier, name)
+		}
+		close(queued)
+	}()
+	select {
+	case <-queued:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "queuing the findings is blocked by the sink")
+	}
+
+	// Closing the notifier waits for the queued batches to be sent
+	close(release)
+	notifier.Close()
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestCloseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	notifier := NewNotifier(Config{Sinks: []SinkConfig{{
+		Name:    "webhook",
+		Type:    SinkTypeWebhook,
+		URL:     server.URL,
+		Timeout: metav1.Duration{Duration: time.Hour},
+	}}}, slog.Default())
+	notifyNewFailures(t, notifier, "pod")
+
+	// The requests in flight are cancelled once closing times out
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	closed := make(chan struct{})
+	go func() {
+		notifier.dispatchers[0].close(ctx)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "closing the notifier is blocked by the sink")
+	}
+}
+
+func TestCloseWithFullQueue(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	notifier := NewNotifier(Config{Sinks: []SinkConfig{{
+		Name:    "webhook",
+		Type:    SinkTypeWebhook,
+		URL:     server.URL,
+		Timeout: metav1.Duration{Duration: time.Hour},
+		Batch:   BatchConfig{MaxSize: 2},
+	}}}, slog.Default())
+	dispatcher := notifier.dispatchers[0]
+
+	// A batch is in flight, the queue is full and the last batch waits for room
+	queued := make(chan struct{})
+	go func() {
+		for i := range maxQueuedBatches + 2 {
+			notifyNewFailures(t, notifier, fmt.Sprintf("pod-%d", i))
+		}
+		close(queued)
+	}()
+	require.Eventually(t, func() bool {
+		return len(dispatcher.batches) == maxQueuedBatches
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Closing drops the waiting batch instead of waiting for room in the queue
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	closed := make(chan struct{})
+	go func() {
+		dispatcher.close(ctx)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "closing the notifier is blocked by the full queue")
+	}
+	select {
+	case <-queued:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "queuing the findings is still blocked once the notifier is closed")
+	}
+}
+
+func TestCloudEventsSink(t *testing.T) {
+	tests := []struct {
+		name                string
+		maxSize             int
+		expectedContentType string
+		expectedRequests    int
+	}{
+		{"batched", 10, cloudEventsBatchContentType, 1},
+		{"structured", 1, cloudEventsContentType, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := newReceiver(t)
+			notifier := NewNotifier(Config{Sinks: []SinkConfig{{
+				Name:  "cloudevents",
+				Type:  SinkTypeCloudEvents,
+				URL:   server.URL,
+				Batch: BatchConfig{MaxSize: test.maxSize},
+			}}}, slog.Default())
+
+			notifyNewFailures(t, notifier, "pod")
+			notifier.Close()
+
+			var events []cloudEvent
+			for range test.expectedRequests {
+				request := receive(t, requests)
+				assert.Equal(t, test.expectedContentType, request.header.Get("Content-Type"))
+				if test.maxSize == 1 {
+					var event cloudEvent
+					require.NoError(t, json.Unmarshal(request.body, &event))
+					events = append(events, event)
+				} else {
+					var batch []cloudEvent
+					require.NoError(t, json.Unmarshal(request.body, &batch))
+					events = append(events, batch...)
+				}
+			}
+			assertNoRequest(t, requests)
+
+			require.Len(t, events, 2)
+			for _, event := range events {
+				assert.Equal(t, "1.0", event.SpecVersion)
+				assert.NotEmpty(t, event.ID)
+				assert.Equal(t, cloudEventsSource, event.Source)
+				assert.Equal(t, CloudEventTypeNewFailure, event.Type)
+				assert.Equal(t, "default/Pod/pod", event.Subject)
+				assert.Equal(t, "pod", event.Data.Name)
+			}
+			assert.NotEqual(t, events[0].ID, events[1].ID)
+		})
+	}
+}
+
+func TestSlackSink(t *testing.T) {
+	server, requests := newReceiver(t)
+	notifier := NewNotifier(Config{Sinks: []SinkConfig{{
+		Name:     "slack",
+		Type:     SinkTypeSlack,
+		URL:      server.URL,
+		Policies: []string{"critical"},
+	}}}, slog.Default())
+
+	notifyNewFailures(t, notifier, "pod")
+	notifier.Close()
+
+	var message slackMessage
+	require.NoError(t, json.Unmarshal(receive(t, requests).body, &message))
+	assert.Equal(t, "*Kubewarden audit*: 1 new policy failure\n"+
+		"• `Pod/default/pod` rejected by `clusterwide-critical` (critical): privileged containers are not allowed", message.Text)
+}
+
+func TestSlackText(t *testing.T) {
+	findings := make([]Finding, maxSlackFindings+5)
+	for i := range findings {
+		findings[i] = Finding{RunUID: "run-uid", Kind: "Namespace", Name: "namespace", Policy: "clusterwide-policy", Message: "a <b> & c"}
+	}
+
+	text := slackText(Batch{Trigger: TriggerRunEnd, Findings: findings})
+
+	lines := strings.Split(text, "\n")
+	require.Len(t, lines, maxSlackFindings+2)
+	assert.Equal(t, "*Kubewarden audit*: 25 policy failures found by run run-uid", lines[0])
+	assert.Equal(t, "• `Namespace/namespace` rejected by `clusterwide-policy`: a &lt;b&gt; &amp; c", lines[1])
+	assert.Equal(t, "…and 5 more", lines[len(lines)-1])
+}
+
+func TestSinkErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		http.Error(writer, "invalid payload", http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+
+	client := newHTTPClient(SinkConfig{URL: server.URL, Timeout: metav1.Duration{Duration: time.Second}})
+	err := (&webhookSink{client: client}).send(t.Context(), Batch{Findings: []Finding{{Name: "pod"}}})
+	require.ErrorContains(t, err, "400 Bad Request: invalid payload")
+}
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// maxSlackFindings is the maximum number of findings listed in a Slack
+// message, the other ones are only counted
+const maxSlackFindings = 20
+
+// slackEscaper escapes the control characters of the Slack mrkdwn format.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackMessage is the payload of a Slack-compatible incoming webhook.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// slackSink posts the batches as a message to a Slack-compatible incoming
+// webhook, e.g. the ones of Slack, Mattermost or Rocket.Chat.
+type slackSink struct {
+	client *httpClient
+}
+
+func (s *slackSink) send(ctx context.Context, batch Batch) error {
+	body, err := json.Marshal(slackMessage{Text: slackText(batch)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the Slack message: %w", err)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return s.client.post(ctx, body, header)
+}
+
+// slackText returns the mrkdwn text of the message of the batch: a summary
+// followed by a line for each finding.
+func slackText(batch Batch) string {
+	var text strings.Builder
+	if batch.Trigger == TriggerNewFailures {
+		fmt.Fprintf(&text, "*Kubewarden audit*: %d new %s", len(batch.Findings), policyFailures(len(batch.Findings)))
+	} else {
+		fmt.Fprintf(&text, "*Kubewarden audit*: %d %s found by run %s", len(batch.Findings), policyFailures(len(batch.Findings)), batch.Findings[0].RunUID)
+	}
+	for i, finding := range batch.Findings {
+		if i == maxSlackFindings {
+			fmt.Fprintf(&text, "\n…and %d more", len(batch.Findings)-maxSlackFindings)
+			break
+		}
+		fmt.Fprintf(&text, "\n• `%s` rejected by `%s`", slackEscaper.Replace(path.Join(finding.Kind, finding.Namespace, finding.Name)), slackEscaper.Replace(finding.Policy))
+		if finding.Severity != "" {
+			fmt.Fprintf(&text, " (%s)", finding.Severity)
+		}
+		if finding.Message != "" {
+			fmt.Fprintf(&text, ": %s", slackEscaper.Replace(finding.Message))
+		}
+	}
+	return text.String()
+}
+
+// policyFailures returns the noun of the count of findings.
+func policyFailures(count int) string {
+	if count == 1 {
+		return "policy failure"
+	}
+	return "policy failures"
+}
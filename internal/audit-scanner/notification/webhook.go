@@ -0,0 +1,47 @@
+package notification
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// SignatureHeader is the header of the webhook requests holding the
+	// HMAC-SHA256 of their body, as sha256=<hex digest>
+	SignatureHeader = "X-Kubewarden-Signature-256"
+	signaturePrefix = "sha256="
+)
+
+// webhookSink posts the batches as JSON to a generic HTTP endpoint, signed
+// with the secret of the sink, if any.
+type webhookSink struct {
+	client *httpClient
+	secret []byte
+}
+
+func (s *webhookSink) send(ctx context.Context, batch Batch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the findings: %w", err)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if len(s.secret) > 0 {
+		header.Set(SignatureHeader, Sign(s.secret, body))
+	}
+	return s.client.post(ctx, body, header)
+}
+
+// Sign returns the value of the signature header of a webhook request with
+// the given body, so the receivers can check the requests are sent by the
+// audit scanner.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
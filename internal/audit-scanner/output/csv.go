@@ -0,0 +1,61 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+)
+
+var csvHeader = []string{
+	"timestamp", "apiVersion", "kind", "namespace", "name", "uid",
+	"policy", "result", "severity", "category", "message",
+}
+
+// csvWriter writes every result as a row, after a header row.
+type csvWriter struct {
+	mu     sync.Mutex
+	writer *csv.Writer
+}
+
+func newCSVWriter(out io.Writer) (*csvWriter, error) {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(csvHeader); err != nil {
+		return nil, fmt.Errorf("cannot write CSV header: %w", err)
+	}
+	return &csvWriter{writer: writer}, nil
+}
+
+func (w *csvWriter) Write(r report.Report) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, record := range records(r.ResourceResults()) {
+		if err := w.writer.Write([]string{
+			record.Timestamp, record.APIVersion, record.Kind, record.Namespace, record.Name, record.UID,
+			record.Policy, record.Result, record.Severity, record.Category, record.Message,
+		}); err != nil {
+			return fmt.Errorf("cannot write result: %w", err)
+		}
+	}
+	// Rows are flushed with every report, so the output can be followed while
+	// the scan is running
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return fmt.Errorf("cannot write result: %w", err)
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return fmt.Errorf("cannot write result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := newCSVWriter(&out)
+	require.NoError(t, err)
+	for _, r := range newTestReports() {
+		require.NoError(t, writer.Write(r))
+	}
+	require.NoError(t, writer.Close())
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 6)
+
+	assert.Equal(t, csvHeader, rows[0])
+	assert.Equal(t, []string{"v1", "Pod", "default", "nginx", "pod-uid", "clusterwide-no-privileged", "fail", "", "", "privileged containers are not allowed"}, rows[1][1:])
+	assert.Equal(t, []string{"v1", "Namespace", "", "default", "namespace-uid", "clusterwide-labels", "pass", "", "", ""}, rows[5][1:])
+}
+
+func TestCSVWriterWithoutResults(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := newCSVWriter(&out)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{csvHeader}, rows)
+}
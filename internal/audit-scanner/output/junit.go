@@ -0,0 +1,124 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+)
+
+// junitWriter writes a JUnit XML document with a test suite for every
+// audited resource, and a test case for every policy evaluated against it.
+// The document is written on Close.
+type junitWriter struct {
+	out    io.Writer
+	mu     sync.Mutex
+	suites []junitTestSuite
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+func newJUnitWriter(out io.Writer) *junitWriter {
+	return &junitWriter{out: out}
+}
+
+func (w *junitWriter) Write(r report.Report) error {
+	resourceResults := r.ResourceResults()
+	name := resourceName(resourceResults)
+	suite := junitTestSuite{
+		Name:      name,
+		Tests:     len(resourceResults.Results),
+		TestCases: make([]junitTestCase, 0, len(resourceResults.Results)),
+	}
+
+	for _, result := range resourceResults.Results {
+		if suite.Timestamp == "" && !result.Timestamp.IsZero() {
+			suite.Timestamp = result.Timestamp.Format("2006-01-02T15:04:05")
+		}
+		testCase := junitTestCase{Name: result.Policy, ClassName: name}
+		message := &junitMessage{Message: result.Message, Type: result.Severity}
+		switch result.Result {
+		case report.ResultFail:
+			testCase.Failure = message
+			suite.Failures++
+		case report.ResultError:
+			testCase.Error = message
+			suite.Errors++
+		case report.ResultSkip:
+			testCase.Skipped = message
+			suite.Skipped++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.suites = append(w.suites, suite)
+
+	return nil
+}
+
+func (w *junitWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// The resources are audited concurrently, sorting them keeps the output stable
+	slices.SortFunc(w.suites, func(a, b junitTestSuite) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	suites := junitTestSuites{Name: toolName, Suites: w.suites}
+	for _, suite := range w.suites {
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+	}
+
+	if _, err := io.WriteString(w.out, xml.Header); err != nil {
+		return fmt.Errorf("cannot write JUnit report: %w", err)
+	}
+	encoder := xml.NewEncoder(w.out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return fmt.Errorf("cannot write JUnit report: %w", err)
+	}
+	if _, err := io.WriteString(w.out, "\n"); err != nil {
+		return fmt.Errorf("cannot write JUnit report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJUnitWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer := newJUnitWriter(&out)
+	for _, r := range newTestReports() {
+		require.NoError(t, writer.Write(r))
+	}
+	require.NoError(t, writer.Close())
+
+	suites := junitTestSuites{}
+	require.NoError(t, xml.Unmarshal(out.Bytes(), &suites))
+
+	assert.Equal(t, toolName, suites.Name)
+	assert.Equal(t, 5, suites.Tests)
+	assert.Equal(t, 1, suites.Failures)
+	assert.Equal(t, 1, suites.Errors)
+	assert.Equal(t, 1, suites.Skipped)
+
+	// the suites are sorted by resource
+	require.Len(t, suites.Suites, 2)
+	assert.Equal(t, "Namespace/default", suites.Suites[0].Name)
+	assert.Equal(t, 1, suites.Suites[0].Tests)
+
+	podSuite := suites.Suites[1]
+	assert.Equal(t, "default/Pod/nginx", podSuite.Name)
+	require.Len(t, podSuite.TestCases, 4)
+	assert.Equal(t, "clusterwide-no-privileged", podSuite.TestCases[0].Name)
+	require.NotNil(t, podSuite.TestCases[0].Failure)
+	assert.Equal(t, "privileged containers are not allowed", podSuite.TestCases[0].Failure.Message)
+	assert.Nil(t, podSuite.TestCases[1].Failure)
+	require.NotNil(t, podSuite.TestCases[2].Error)
+	assert.Equal(t, "internal error", podSuite.TestCases[2].Error.Message)
+	require.NotNil(t, podSuite.TestCases[3].Skipped)
+	assert.Equal(t, "PolicyServer unavailable", podSuite.TestCases[3].Skipped.Message)
+}
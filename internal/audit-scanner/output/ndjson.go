@@ -0,0 +1,36 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+)
+
+// ndjsonWriter writes every result as a JSON object on its own line.
+type ndjsonWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(out io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{encoder: json.NewEncoder(out)}
+}
+
+func (w *ndjsonWriter) Write(r report.Report) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, record := range records(r.ResourceResults()) {
+		if err := w.encoder.Encode(record); err != nil {
+			return fmt.Errorf("cannot write result: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,45 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer := newNDJSONWriter(&out)
+	for _, r := range newTestReports() {
+		require.NoError(t, writer.Write(r))
+	}
+	require.NoError(t, writer.Close())
+
+	var lines []record
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		line := record{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 5)
+
+	assert.Equal(t, "Pod", lines[0].Kind)
+	assert.Equal(t, "default", lines[0].Namespace)
+	assert.Equal(t, "nginx", lines[0].Name)
+	assert.Equal(t, "pod-uid", lines[0].UID)
+	assert.Equal(t, "clusterwide-no-privileged", lines[0].Policy)
+	assert.Equal(t, "fail", lines[0].Result)
+	assert.Equal(t, "privileged containers are not allowed", lines[0].Message)
+	assert.NotEmpty(t, lines[0].Timestamp)
+
+	assert.Equal(t, "skip", lines[3].Result)
+	assert.Equal(t, "PolicyServer unavailable", lines[3].Message)
+
+	assert.Equal(t, "Namespace", lines[4].Kind)
+	assert.Empty(t, lines[4].Namespace)
+	assert.Equal(t, "pass", lines[4].Result)
+}
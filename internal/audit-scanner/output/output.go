@@ -0,0 +1,145 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+)
+
+// Format is a machine-readable format of the scan results.
+type Format string
+
+const (
+	FormatSARIF  Format = "sarif"
+	FormatJUnit  Format = "junit"
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// Formats are the supported output formats.
+var Formats = []Format{FormatSARIF, FormatJUnit, FormatCSV, FormatNDJSON}
+
+// StreamingFormats are the output formats written as soon as each report is,
+// instead of on Close.
+var StreamingFormats = []Format{FormatCSV, FormatNDJSON}
+
+// toolName identifies the audit scanner in the outputs.
+const toolName = "kubewarden-audit-scanner"
+
+// Writer writes the results of the audited resources in a machine-readable
+// format. Write is safe for concurrent use. Close must be called once all the
+// reports have been written: formats made of a single document are only
+// written on Close.
+type Writer interface {
+	Write(r report.Report) error
+	Close() error
+}
+
+// ParseFormat returns the Format with the given name.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(name, string(format)) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q, valid formats are %v", name, Formats)
+}
+
+// New returns a Writer of the given format writing to the file at path.
+// The file is created or truncated. The standard output is used when the
+// path is empty or "-".
+func New(format Format, path string) (Writer, error) {
+	if path == "" || path == "-" {
+		return newWriter(format, os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create output file: %w", err)
+	}
+	writer, err := newWriter(format, file)
+	if err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+
+	return &fileWriter{Writer: writer, file: file}, nil
+}
+
+func newWriter(format Format, out io.Writer) (Writer, error) {
+	switch format {
+	case FormatSARIF:
+		return newSARIFWriter(out), nil
+	case FormatJUnit:
+		return newJUnitWriter(out), nil
+	case FormatCSV:
+		return newCSVWriter(out)
+	case FormatNDJSON:
+		return newNDJSONWriter(out), nil
+	default:
+		return nil, fmt.Errorf("invalid output format %q, valid formats are %v", format, Formats)
+	}
+}
+
+// fileWriter closes the output file once the Writer is closed.
+type fileWriter struct {
+	Writer
+	file *os.File
+}
+
+func (w *fileWriter) Close() error {
+	err := w.Writer.Close()
+	if closeErr := w.file.Close(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("cannot close output file: %w", closeErr))
+	}
+	return err
+}
+
+// record is a flattened result, as written by the line based formats.
+type record struct {
+	Timestamp  string `json:"timestamp"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+	Policy     string `json:"policy"`
+	Result     string `json:"result"`
+	Severity   string `json:"severity,omitempty"`
+	Category   string `json:"category,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+func records(resourceResults report.ResourceResults) []record {
+	resource := resourceResults.Resource
+	records := make([]record, 0, len(resourceResults.Results))
+	for _, result := range resourceResults.Results {
+		records = append(records, record{
+			Timestamp:  result.Timestamp.Format(time.RFC3339),
+			APIVersion: resource.APIVersion,
+			Kind:       resource.Kind,
+			Namespace:  resource.Namespace,
+			Name:       resource.Name,
+			UID:        string(resource.UID),
+			Policy:     result.Policy,
+			Result:     result.Result,
+			Severity:   result.Severity,
+			Category:   result.Category,
+			Message:    result.Message,
+		})
+	}
+	return records
+}
+
+// resourceName returns the name of the resource, qualified by its kind and namespace.
+func resourceName(resourceResults report.ResourceResults) string {
+	resource := resourceResults.Resource
+	if resource.Namespace == "" {
+		return resource.Kind + "/" + resource.Name
+	}
+	return resource.Namespace + "/" + resource.Kind + "/" + resource.Name
+}
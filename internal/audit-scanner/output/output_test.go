@@ -0,0 +1,88 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/testutils"
+)
+
+// newTestReports returns the report of a Pod, with a result of each kind, and
+// the report of a Namespace with a passing result.
+func newTestReports() []report.Report {
+	allowed := &admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{Allowed: true}}
+	rejected := &admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Message: "privileged containers are not allowed"},
+	}}
+	errored := &admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Message: "internal error"},
+	}}
+
+	pod := unstructured.Unstructured{}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	pod.SetNamespace("default")
+	pod.SetName("nginx")
+	pod.SetUID("pod-uid")
+	podReport := report.NewPolicyReport("runUID", pod)
+	podReport.AddResult(testutils.NewClusterAdmissionPolicyFactory().Name("no-privileged").Build(), rejected, false)
+	podReport.AddResult(testutils.NewClusterAdmissionPolicyFactory().Name("trusted-images").Build(), allowed, false)
+	podReport.AddResult(testutils.NewClusterAdmissionPolicyFactory().Name("broken").Build(), errored, true)
+	podReport.AddSkippedResult(testutils.NewClusterAdmissionPolicyFactory().Name("paused").Build(), "PolicyServer unavailable")
+
+	namespace := unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName("default")
+	namespace.SetUID("namespace-uid")
+	namespaceReport := report.NewClusterOpenReport("runUID", namespace)
+	namespaceReport.AddResult(testutils.NewClusterAdmissionPolicyFactory().Name("labels").Build(), allowed, false)
+
+	return []report.Report{podReport, namespaceReport}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range Formats {
+		parsed, err := ParseFormat(string(format))
+		require.NoError(t, err)
+		assert.Equal(t, format, parsed)
+	}
+
+	parsed, err := ParseFormat("SARIF")
+	require.NoError(t, err)
+	assert.Equal(t, FormatSARIF, parsed)
+
+	_, err = ParseFormat("yaml")
+	require.Error(t, err)
+}
+
+func TestNewWritesToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.ndjson")
+	writer, err := New(FormatNDJSON, path)
+	require.NoError(t, err)
+
+	for _, r := range newTestReports() {
+		require.NoError(t, writer.Write(r))
+	}
+	require.NoError(t, writer.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"policy":"clusterwide-no-privileged"`)
+}
+
+func TestNewInvalidFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results")
+	_, err := New(Format("yaml"), path)
+	require.Error(t, err)
+}
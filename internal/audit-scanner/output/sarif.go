@@ -0,0 +1,198 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+)
+
+const (
+	sarifVersion        = "2.1.0"
+	sarifSchema         = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifInformationURI = "https://kubewarden.io"
+)
+
+// sarifWriter writes a SARIF log with a rule for every policy, and a result
+// for every policy evaluated against a resource. The resources are reported
+// as logical locations. The log is written on Close.
+type sarifWriter struct {
+	out     io.Writer
+	mu      sync.Mutex
+	results []sarifResult
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID         string            `json:"id"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	RuleIndex  int               `json:"ruleIndex"`
+	Kind       string            `json:"kind"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+	// category of the policy, used to fill the properties of its rule
+	category string
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+func newSARIFWriter(out io.Writer) *sarifWriter {
+	return &sarifWriter{out: out}
+}
+
+func (w *sarifWriter) Write(r report.Report) error {
+	resourceResults := r.ResourceResults()
+	resource := resourceResults.Resource
+	location := sarifLocation{LogicalLocations: []sarifLogicalLocation{{
+		Name:               resource.Name,
+		FullyQualifiedName: resourceName(resourceResults),
+		Kind:               "resource",
+	}}}
+
+	results := make([]sarifResult, 0, len(resourceResults.Results))
+	for _, result := range resourceResults.Results {
+		kind, level := sarifKindAndLevel(result)
+		message := result.Message
+		if message == "" {
+			message = fmt.Sprintf("%s: %s", result.Policy, result.Result)
+		}
+		properties := map[string]string{
+			"apiVersion": resource.APIVersion,
+			"uid":        string(resource.UID),
+			"result":     result.Result,
+		}
+		if result.Severity != "" {
+			properties["severity"] = result.Severity
+		}
+		results = append(results, sarifResult{
+			RuleID:     result.Policy,
+			Kind:       kind,
+			Level:      level,
+			Message:    sarifMessage{Text: message},
+			Locations:  []sarifLocation{location},
+			Properties: properties,
+			category:   result.Category,
+		})
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.results = append(w.results, results...)
+
+	return nil
+}
+
+// sarifKindAndLevel maps the result of a policy to the SARIF result kind and
+// level. Only failures have a level, derived from the severity of the policy.
+// Errored evaluations need a review, as the policy could not be evaluated.
+// The mutations a policy would make to a resource are informational.
+func sarifKindAndLevel(result report.Result) (string, string) {
+	switch result.Result {
+	case report.ResultFail:
+		switch result.Severity {
+		case "medium":
+			return "fail", "warning"
+		case "low", "info":
+			return "fail", "note"
+		default:
+			return "fail", "error"
+		}
+	case report.ResultWarn:
+		return "informational", "none"
+	case report.ResultError:
+		return "review", "none"
+	case report.ResultSkip:
+		return "notApplicable", "none"
+	default:
+		return "pass", "none"
+	}
+}
+
+func (w *sarifWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// The resources are audited concurrently, sorting them keeps the output stable
+	slices.SortStableFunc(w.results, func(a, b sarifResult) int {
+		return strings.Compare(a.Locations[0].LogicalLocations[0].FullyQualifiedName, b.Locations[0].LogicalLocations[0].FullyQualifiedName)
+	})
+
+	rules := []sarifRule{}
+	ruleIndexes := make(map[string]int)
+	for i := range w.results {
+		result := &w.results[i]
+		index, found := ruleIndexes[result.RuleID]
+		if !found {
+			index = len(rules)
+			ruleIndexes[result.RuleID] = index
+			rule := sarifRule{ID: result.RuleID}
+			if result.category != "" {
+				rule.Properties = map[string]string{"category": result.category}
+			}
+			rules = append(rules, rule)
+		}
+		result.RuleIndex = index
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           toolName,
+				InformationURI: sarifInformationURI,
+				Rules:          rules,
+			}},
+			Results: append([]sarifResult{}, w.results...),
+		}},
+	}
+
+	encoder := json.NewEncoder(w.out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(log); err != nil {
+		return fmt.Errorf("cannot write SARIF report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+)
+
+func TestSARIFWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer := newSARIFWriter(&out)
+	for _, r := range newTestReports() {
+		require.NoError(t, writer.Write(r))
+	}
+	require.NoError(t, writer.Close())
+
+	log := sarifLog{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &log))
+
+	assert.Equal(t, sarifVersion, log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, toolName, run.Tool.Driver.Name)
+	require.Len(t, run.Results, 5)
+
+	// the results are sorted by resource
+	assert.Equal(t, "Namespace/default", run.Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName)
+
+	failure := run.Results[1]
+	assert.Equal(t, "clusterwide-no-privileged", failure.RuleID)
+	assert.Equal(t, "fail", failure.Kind)
+	assert.Equal(t, "error", failure.Level)
+	assert.Equal(t, "privileged containers are not allowed", failure.Message.Text)
+	assert.Equal(t, "default/Pod/nginx", failure.Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Equal(t, failure.RuleID, run.Tool.Driver.Rules[failure.RuleIndex].ID)
+
+	kinds := []string{}
+	for _, result := range run.Results[1:] {
+		kinds = append(kinds, result.Kind)
+	}
+	assert.Equal(t, []string{"fail", "pass", "review", "notApplicable"}, kinds)
+	assert.Equal(t, "clusterwide-trusted-images: pass", run.Results[2].Message.Text)
+	assert.Len(t, run.Tool.Driver.Rules, 5)
+}
+
+func TestSARIFLevel(t *testing.T) {
+	tests := []struct {
+		severity      string
+		expectedLevel string
+	}{
+		{"critical", "error"},
+		{"high", "error"},
+		{"", "error"},
+		{"medium", "warning"},
+		{"low", "note"},
+		{"info", "note"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.severity, func(t *testing.T) {
+			kind, level := sarifKindAndLevel(report.Result{Result: report.ResultFail, Severity: test.severity})
+			assert.Equal(t, "fail", kind)
+			assert.Equal(t, test.expectedLevel, level)
+		})
+	}
+}
@@ -5,32 +5,49 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/url"
 	"slices"
+	"strings"
 
 	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const appInstanceLabelKey = "app.kubernetes.io/instance"
 
+// Reasons why the policies are skipped.
+const (
+	reasonNoAuditableOperations   = "the policy rules do not have a CREATE, UPDATE or DELETE operation"
+	reasonBackgroundAuditDisabled = "the policy has backgroundAudit set to false"
+	reasonNotActive               = "the policy is not active"
+)
+
 // Client fetches Kubewarden policies from the Kubernetes cluster.
 type Client struct {
 	// client is a controller-runtime client extended with the Kubewarden CRDs
 	client client.Client
+	// discoveryClient is used to expand the wildcards in the policy rules
+	discoveryClient discovery.ServerResourcesInterface
+	// wildcardExcludedResources are the resources never targeted by a wildcard rule
+	wildcardExcludedResources []schema.GroupResource
 	// Namespace where the Kubewarden components (e.g. policy server) are installed
 	// This is the namespace used to get the policy server resources
 	kubewardenNamespace string
 	// FQDN of the policy server to query. If not empty, it will query on port 3000.
 	// Useful for out-of-cluster debugging
 	policyServerURL string
+	// filter restricts the audited policies and resources
+	filter Filter
 	// logger is used to log the messages
 	logger *slog.Logger
 }
@@ -45,29 +62,69 @@ type Policies struct {
 	SkippedNum int
 	// ErroredNum represents the number of errored policies. These policies may be misconfigured
 	ErroredNum int
+	// SkippedPolicies are the sorted unique names of the skipped policies
+	SkippedPolicies []string
+	// ErroredPolicies are the sorted unique names of the errored policies
+	ErroredPolicies []string
+	// NotAuditedByGVR are the skipped and the errored policies, grouped by the
+	// GVR of the resources they target
+	NotAuditedByGVR map[schema.GroupVersionResource][]NotAuditedPolicy
+	// NotAuditedAnyGVR are the skipped and the errored policies whose targeted
+	// resources are unknown, e.g. because they target an unknown GVR. They are
+	// reported for all the resources.
+	NotAuditedAnyGVR []NotAuditedPolicy
+}
+
+// NotAudited returns the skipped and the errored policies targeting the
+// resources of the given GVR.
+func (p *Policies) NotAudited(gvr schema.GroupVersionResource) []NotAuditedPolicy {
+	return slices.Concat(p.NotAuditedByGVR[gvr], p.NotAuditedAnyGVR)
+}
+
+// NotAuditedPolicy is a policy that is not audited, with the reason why.
+type NotAuditedPolicy struct {
+	policiesv1.Policy
+	// Reason explains why the policy is not audited
+	Reason string
+	// Errored is true when the policy cannot be audited because of an error,
+	// the policy may be misconfigured. Otherwise, the policy is skipped.
+	Errored bool
 }
 
 // Policy represents a policy and the URL of the policy server where it is running.
 type Policy struct {
 	policiesv1.Policy
 	PolicyServer *url.URL
+	// Operation is the operation of the admission request used to audit the
+	// resources. It's CREATE when the policy rules targeting the resources
+	// cover it, otherwise UPDATE, otherwise DELETE.
+	Operation admissionv1.Operation
 }
 
 // NewClient returns a policy Client.
-func NewClient(client client.Client, kubewardenNamespace string, policyServerURL string, logger *slog.Logger) *Client {
+func NewClient(client client.Client, discoveryClient discovery.ServerResourcesInterface, kubewardenNamespace string, policyServerURL string, wildcardExcludedResources []schema.GroupResource, filter Filter, logger *slog.Logger) *Client {
 	if policyServerURL != "" {
 		logger.Info(fmt.Sprintf("querying PolicyServers at %s for debugging purposes. Don't forget to start `kubectl port-forward` if needed", policyServerURL))
 	}
 
 	return &Client{
-		client:              client,
-		kubewardenNamespace: kubewardenNamespace,
-		policyServerURL:     policyServerURL,
-		logger:              logger.With("client", "policyclient"),
+		client:                    client,
+		discoveryClient:           discoveryClient,
+		wildcardExcludedResources: wildcardExcludedResources,
+		kubewardenNamespace:       kubewardenNamespace,
+		policyServerURL:           policyServerURL,
+		filter:                    filter,
+		logger:                    logger.With("client", "policyclient"),
 	}
 }
 
+// Filter returns the filter restricting the audited policies and resources.
+func (f *Client) Filter() Filter {
+	return f.filter
+}
+
 // GetPoliciesByNamespace gets all the auditable policies for a given namespace.
+// Only the policies and the resources selected by the filter are returned.
 func (f *Client) GetPoliciesByNamespace(ctx context.Context, namespace *corev1.Namespace) (*Policies, error) {
 	var policies []policiesv1.Policy
 
@@ -103,10 +160,11 @@ func (f *Client) GetPoliciesByNamespace(ctx context.Context, namespace *corev1.N
 		policies = append(policies, &policy)
 	}
 
-	return f.groupPoliciesByGVR(ctx, policies, true)
+	return f.groupPoliciesByGVR(ctx, f.filter.filterPolicies(policies), true)
 }
 
 // GetClusterWidePolicies returns all the auditable cluster-wide policies.
+// Only the policies and the resources selected by the filter are returned.
 func (f *Client) GetClusterWidePolicies(ctx context.Context) (*Policies, error) {
 	var policies []policiesv1.Policy
 
@@ -126,7 +184,49 @@ func (f *Client) GetClusterWidePolicies(ctx context.Context) (*Policies, error)
 		policies = append(policies, &policy)
 	}
 
-	return f.groupPoliciesByGVR(ctx, policies, false)
+	return f.groupPoliciesByGVR(ctx, f.filter.filterPolicies(policies), false)
+}
+
+// ListPolicies returns all the policies of the cluster, whatever their namespace,
+// status or backgroundAudit setting. The filter is not applied.
+func (f *Client) ListPolicies(ctx context.Context) ([]policiesv1.Policy, error) {
+	var policies []policiesv1.Policy
+
+	clusterAdmissionPolicies, err := f.listClusterAdmissionPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range clusterAdmissionPolicies {
+		policies = append(policies, &policy)
+	}
+
+	clusterAdmissionPolicyGroups, err := f.listClusterAdmissionPolicyGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range clusterAdmissionPolicyGroups {
+		policies = append(policies, &policy)
+	}
+
+	// An empty namespace lists the policies of all the namespaces
+	allNamespaces := &corev1.Namespace{}
+	admissionPolicies, err := f.listAdmissionPolicies(ctx, allNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range admissionPolicies {
+		policies = append(policies, &policy)
+	}
+
+	admissionPolicyGroups, err := f.listAdmissionPolicyGroups(ctx, allNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range admissionPolicyGroups {
+		policies = append(policies, &policy)
+	}
+
+	return policies, nil
 }
 
 // findClusterAdmissionPoliciesByNamespace returns all the ClusterAdmissionPolicies that evaluate resources in the given namespace.
@@ -244,33 +344,71 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 	auditablePolicies := map[string]struct{}{}
 	skippedPolicies := map[string]struct{}{}
 	erroredPolicies := map[string]struct{}{}
+	notAuditedByGVR := make(map[schema.GroupVersionResource][]NotAuditedPolicy)
+	var notAuditedAnyGVR []NotAuditedPolicy
+
+	// notAudited records a policy that is not audited, for the resources of
+	// the given GVRs. When the GVRs are nil, the policy is recorded for all the
+	// resources.
+	notAudited := func(policy policiesv1.Policy, reason string, errored bool, gvrs map[schema.GroupVersionResource]admissionv1.Operation) {
+		if errored {
+			erroredPolicies[policy.GetUniqueName()] = struct{}{}
+		} else {
+			skippedPolicies[policy.GetUniqueName()] = struct{}{}
+		}
+		setTypeMeta(policy)
+		notAuditedPolicy := NotAuditedPolicy{Policy: policy, Reason: reason, Errored: errored}
+		if gvrs == nil {
+			notAuditedAnyGVR = append(notAuditedAnyGVR, notAuditedPolicy)
+			return
+		}
+		for gvr := range gvrs {
+			notAuditedByGVR[gvr] = append(notAuditedByGVR[gvr], notAuditedPolicy)
+		}
+	}
+
+	// The API resources served by the cluster are discovered only when a policy has wildcard rules
+	var apiResources *apiResources
 
 	for _, policy := range policies {
-		rules := filterWildcardRules(policy.GetRules())
+		rules := filterNonAuditableOperations(policy.GetRules())
 		if len(rules) == 0 {
-			skippedPolicies[policy.GetUniqueName()] = struct{}{}
-			f.logger.DebugContext(ctx, "the policy targets only wildcard resources, skipping...", slog.String("policy", policy.GetUniqueName()))
+			// The resources of the rules are unknown when they have wildcards,
+			// or target unknown GVRs
+			groupVersionResources, _ := f.getGroupVersionResources(policy.GetRules(), namespaced)
+			notAudited(policy, reasonNoAuditableOperations, false, groupVersionResources)
+			f.logger.DebugContext(ctx, "the policy does not have rules with a CREATE, UPDATE or DELETE operation, skipping...", slog.String("policy", policy.GetUniqueName()))
 
 			continue
 		}
 
-		rules = filterNonCreateOperations(rules)
-		if len(rules) == 0 {
-			skippedPolicies[policy.GetUniqueName()] = struct{}{}
-			f.logger.DebugContext(ctx, "the policy does not have rules with a CREATE operation, skipping...", slog.String("policy", policy.GetUniqueName()))
-
-			continue
+		if slices.ContainsFunc(rules, isWildcardRule) {
+			if apiResources == nil {
+				discovered, err := f.discoverAPIResources(ctx)
+				if err != nil {
+					notAudited(policy, fmt.Sprintf("failed to expand the wildcards of the policy rules: %s", err), true, nil)
+					f.logger.ErrorContext(ctx, "failed to expand the wildcards of the policy rules, skipping as error...",
+						slog.String("error", err.Error()),
+						slog.String("policy", policy.GetUniqueName()))
+					continue
+				}
+				apiResources = discovered
+			}
+			rules = f.expandWildcardRules(rules, apiResources)
 		}
 
 		groupVersionResources, err := f.getGroupVersionResources(rules, namespaced)
 		if err != nil {
-			erroredPolicies[policy.GetUniqueName()] = struct{}{}
+			notAudited(policy, fmt.Sprintf("unknown GVR targeted by the policy: %s", err), true, nil)
 			f.logger.ErrorContext(ctx, "failed to obtain unknown GroupVersion resources. The policy may be misconfigured, skipping as error...",
 				slog.String("error", err.Error()),
 				slog.String("policy", policy.GetUniqueName()))
 			continue
 		}
 
+		maps.DeleteFunc(groupVersionResources, func(gvr schema.GroupVersionResource, _ admissionv1.Operation) bool {
+			return !f.filter.SelectsResource(gvr)
+		})
 		if len(groupVersionResources) == 0 {
 			f.logger.DebugContext(ctx, "the policy does not target resources within the selected scope",
 				slog.String("policy", policy.GetUniqueName()),
@@ -280,7 +418,7 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 		}
 
 		if !policy.GetBackgroundAudit() {
-			skippedPolicies[policy.GetUniqueName()] = struct{}{}
+			notAudited(policy, reasonBackgroundAuditDisabled, false, groupVersionResources)
 			f.logger.DebugContext(ctx, "the policy has backgroundAudit set to false, skipping...",
 				slog.String("policy", policy.GetUniqueName()))
 
@@ -288,7 +426,7 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 		}
 
 		if policy.GetStatus().PolicyStatus != policiesv1.PolicyStatusActive {
-			skippedPolicies[policy.GetUniqueName()] = struct{}{}
+			notAudited(policy, fmt.Sprintf("%s: %s", reasonNotActive, policy.GetStatus().PolicyStatus), false, groupVersionResources)
 			f.logger.DebugContext(ctx, "the policy is not active, skipping...", slog.String("policy", policy.GetUniqueName()))
 
 			continue
@@ -296,7 +434,7 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 
 		url, err := f.getPolicyServerURLRunningPolicy(ctx, policy)
 		if err != nil {
-			erroredPolicies[policy.GetUniqueName()] = struct{}{}
+			notAudited(policy, fmt.Sprintf("failed to obtain the PolicyServer URL: %s", err), true, groupVersionResources)
 			f.logger.ErrorContext(ctx, "failed to obtain matching policy-server URL, skipping as error...",
 				slog.String("error", err.Error()),
 				slog.String("policy", policy.GetUniqueName()))
@@ -308,21 +446,24 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 		setTypeMeta(policy)
 
 		auditablePolicies[policy.GetUniqueName()] = struct{}{}
-		policy := &Policy{
-			Policy:       policy,
-			PolicyServer: url,
-		}
-
-		for _, gvr := range groupVersionResources {
-			addPolicyToMap(policiesByGVR, gvr, policy)
+		for gvr, operation := range groupVersionResources {
+			addPolicyToMap(policiesByGVR, gvr, &Policy{
+				Policy:       policy,
+				PolicyServer: url,
+				Operation:    operation,
+			})
 		}
 	}
 
 	return &Policies{
-		PoliciesByGVR: policiesByGVR,
-		PolicyNum:     len(auditablePolicies),
-		SkippedNum:    len(skippedPolicies),
-		ErroredNum:    len(erroredPolicies),
+		PoliciesByGVR:    policiesByGVR,
+		PolicyNum:        len(auditablePolicies),
+		SkippedNum:       len(skippedPolicies),
+		ErroredNum:       len(erroredPolicies),
+		SkippedPolicies:  slices.Sorted(maps.Keys(skippedPolicies)),
+		ErroredPolicies:  slices.Sorted(maps.Keys(erroredPolicies)),
+		NotAuditedByGVR:  notAuditedByGVR,
+		NotAuditedAnyGVR: notAuditedAnyGVR,
 	}, nil
 }
 
@@ -351,12 +492,14 @@ func getRuleGVRs(rule admissionregistrationv1.RuleWithOperations) []schema.Group
 	return gvrs
 }
 
-// getGroupVersionResources returns a list of GroupVersionResource from a list of policies.
+// getGroupVersionResources returns the GroupVersionResources targeted by the rules of a policy,
+// together with the operation used to audit them.
 // if namespaced is true, it will skip cluster-wide resources, otherwise it will skip namespaced resources.
-func (f *Client) getGroupVersionResources(rules []admissionregistrationv1.RuleWithOperations, namespaced bool) ([]schema.GroupVersionResource, error) {
-	var groupVersionResources []schema.GroupVersionResource
+func (f *Client) getGroupVersionResources(rules []admissionregistrationv1.RuleWithOperations, namespaced bool) (map[schema.GroupVersionResource]admissionv1.Operation, error) {
+	groupVersionResources := make(map[schema.GroupVersionResource]admissionv1.Operation)
 
 	for _, rule := range rules {
+		operation := getRuleAuditOperation(rule)
 		gvrs := getRuleGVRs(rule)
 		for _, gvr := range gvrs {
 			isNamespaced, err := f.isNamespacedResource(gvr)
@@ -372,13 +515,150 @@ func (f *Client) getGroupVersionResources(rules []admissionregistrationv1.RuleWi
 				continue
 			}
 
-			groupVersionResources = append(groupVersionResources, gvr)
+			// When multiple rules target the same resource, CREATE takes
+			// precedence over UPDATE, and UPDATE over DELETE.
+			if previous, found := groupVersionResources[gvr]; found {
+				groupVersionResources[gvr] = preferredAuditOperation(previous, operation)
+				continue
+			}
+			groupVersionResources[gvr] = operation
 		}
 	}
 
 	return groupVersionResources, nil
 }
 
+// auditOperations are the operations used to audit the resources, by order of preference.
+var auditOperations = []admissionv1.Operation{admissionv1.Create, admissionv1.Update, admissionv1.Delete}
+
+// getRuleAuditOperation returns the operation used to audit the resources targeted by the rule.
+// CREATE is preferred, as it's the operation that best describes an existing resource.
+// Otherwise, UPDATE is used, with the existing resource as both the old and the new object.
+// The rules only targeting DELETE are audited with the existing resource as the old object,
+// as if it was being deleted.
+func getRuleAuditOperation(rule admissionregistrationv1.RuleWithOperations) admissionv1.Operation {
+	switch {
+	case slices.Contains(rule.Operations, admissionregistrationv1.Create) || slices.Contains(rule.Operations, admissionregistrationv1.OperationAll):
+		return admissionv1.Create
+	case slices.Contains(rule.Operations, admissionregistrationv1.Update):
+		return admissionv1.Update
+	default:
+		return admissionv1.Delete
+	}
+}
+
+// preferredAuditOperation returns the operation of the two that comes first in auditOperations.
+func preferredAuditOperation(a, b admissionv1.Operation) admissionv1.Operation {
+	if slices.Index(auditOperations, b) < slices.Index(auditOperations, a) {
+		return b
+	}
+	return a
+}
+
+// apiResources holds the resources served by the cluster that can be audited.
+type apiResources struct {
+	// resources are the top-level resources that can be listed, by GroupVersion
+	resources map[schema.GroupVersion][]string
+	// preferredVersions are the versions preferred by the server, by group
+	preferredVersions map[string]string
+}
+
+// discoverAPIResources returns the resources served by the cluster that can be audited.
+// Resources that cannot be listed and subresources are ignored.
+// If some API groups cannot be discovered, the resources of the other groups are returned.
+func (f *Client) discoverAPIResources(ctx context.Context) (*apiResources, error) {
+	groups, resourceLists, err := f.discoveryClient.ServerGroupsAndResources()
+	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			return nil, fmt.Errorf("failed to discover the API resources: %w", err)
+		}
+		f.logger.WarnContext(ctx, "failed to discover some API groups, their resources will not be audited by wildcard rules",
+			slog.String("error", err.Error()))
+	}
+
+	discovered := &apiResources{
+		resources:         make(map[schema.GroupVersion][]string),
+		preferredVersions: make(map[string]string),
+	}
+	for _, group := range groups {
+		discovered.preferredVersions[group.Name] = group.PreferredVersion.Version
+	}
+	for _, resourceList := range resourceLists {
+		groupVersion, parseErr := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if parseErr != nil {
+			return nil, fmt.Errorf("failed to parse GroupVersion %q: %w", resourceList.GroupVersion, parseErr)
+		}
+		for _, resource := range resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") || !slices.Contains(resource.Verbs, "list") {
+				continue
+			}
+			discovered.resources[groupVersion] = append(discovered.resources[groupVersion], resource.Name)
+		}
+	}
+
+	return discovered, nil
+}
+
+// expandWildcardRules replaces the wildcards in the APIGroups, APIVersions and Resources fields of the rules
+// with the matching resources served by the cluster. A wildcard version only matches the version preferred
+// by the server, so that the same object is not audited multiple times.
+// The resources listed in wildcardExcludedResources are never targeted by a wildcard.
+func (f *Client) expandWildcardRules(rules []admissionregistrationv1.RuleWithOperations, discovered *apiResources) []admissionregistrationv1.RuleWithOperations {
+	expandedRules := []admissionregistrationv1.RuleWithOperations{}
+	for _, rule := range rules {
+		if !isWildcardRule(rule) {
+			expandedRules = append(expandedRules, rule)
+			continue
+		}
+
+		for groupVersion, resources := range discovered.resources {
+			if !slices.Contains(rule.APIGroups, "*") && !slices.Contains(rule.APIGroups, groupVersion.Group) {
+				continue
+			}
+			if slices.Contains(rule.APIVersions, "*") {
+				if discovered.preferredVersions[groupVersion.Group] != groupVersion.Version {
+					continue
+				}
+			} else if !slices.Contains(rule.APIVersions, groupVersion.Version) {
+				continue
+			}
+
+			var matchingResources []string
+			for _, resource := range resources {
+				if slices.Contains(f.wildcardExcludedResources, schema.GroupResource{Group: groupVersion.Group, Resource: resource}) {
+					continue
+				}
+				if isWildcardResource(rule.Resources) || slices.Contains(rule.Resources, resource) {
+					matchingResources = append(matchingResources, resource)
+				}
+			}
+			if len(matchingResources) == 0 {
+				continue
+			}
+
+			expandedRule := *rule.DeepCopy()
+			expandedRule.APIGroups = []string{groupVersion.Group}
+			expandedRule.APIVersions = []string{groupVersion.Version}
+			expandedRule.Resources = matchingResources
+			expandedRules = append(expandedRules, expandedRule)
+		}
+	}
+
+	return expandedRules
+}
+
+// isWildcardRule returns true if the rule contains a wildcard in the APIGroups, APIVersions or Resources fields.
+func isWildcardRule(rule admissionregistrationv1.RuleWithOperations) bool {
+	return slices.Contains(rule.APIGroups, "*") ||
+		slices.Contains(rule.APIVersions, "*") ||
+		isWildcardResource(rule.Resources)
+}
+
+// isWildcardResource returns true if the resources contain a wildcard matching all the top-level resources.
+func isWildcardResource(resources []string) bool {
+	return slices.Contains(resources, "*") || slices.Contains(resources, "*/*")
+}
+
 // isNamespacedResource checks if the given resource is namespaced or not.
 func (f *Client) isNamespacedResource(gvr schema.GroupVersionResource) (bool, error) {
 	gvk, err := f.client.RESTMapper().KindFor(gvr)
@@ -448,26 +728,17 @@ func (f *Client) getServiceByInstanceLabel(ctx context.Context, instanceName str
 	return &serviceList.Items[0], nil
 }
 
-// filterWildcardRules filters out rules that contain a wildcard in the APIGroups, APIVersions or Resources fields.
-func filterWildcardRules(rules []admissionregistrationv1.RuleWithOperations) []admissionregistrationv1.RuleWithOperations {
-	filteredRules := []admissionregistrationv1.RuleWithOperations{}
-	for _, rule := range rules {
-		if slices.Contains(rule.APIGroups, "*") ||
-			slices.Contains(rule.APIVersions, "*") ||
-			slices.Contains(rule.Resources, "*") {
-			continue
-		}
-		filteredRules = append(filteredRules, rule)
-	}
-
-	return filteredRules
-}
-
-// filterNonCreateOperations filters out rules that do not contain a CREATE operation.
-func filterNonCreateOperations(rules []admissionregistrationv1.RuleWithOperations) []admissionregistrationv1.RuleWithOperations {
+// filterNonAuditableOperations filters out rules that do not contain a CREATE, UPDATE or DELETE operation.
+// The rules only targeting CONNECT cannot be audited, as there is no resource being connected to.
+func filterNonAuditableOperations(rules []admissionregistrationv1.RuleWithOperations) []admissionregistrationv1.RuleWithOperations {
 	filteredRules := []admissionregistrationv1.RuleWithOperations{}
 	for _, rule := range rules {
-		if slices.Contains(rule.Operations, admissionregistrationv1.Create) {
+		if slices.ContainsFunc(rule.Operations, func(operation admissionregistrationv1.OperationType) bool {
+			return operation == admissionregistrationv1.Create ||
+				operation == admissionregistrationv1.Update ||
+				operation == admissionregistrationv1.Delete ||
+				operation == admissionregistrationv1.OperationAll
+		}) {
 			filteredRules = append(filteredRules, rule)
 		}
 	}
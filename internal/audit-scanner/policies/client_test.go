@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -190,7 +191,7 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 		}).
 		Build()
 
-	// an AdmissionPolicy in targeting wildcard resources, it should be skipped
+	// an AdmissionPolicy targeting wildcard resources, expanded to the namespaced resources served by the cluster
 	admissionPolicy4 := testutils.
 		NewAdmissionPolicyFactory().
 		Name("admissionPolicy4").
@@ -259,7 +260,7 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	policiesClient := NewClient(client, "kubewarden", "", logger)
+	policiesClient := NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", "", nil, Filter{}, logger)
 
 	policies, err := policiesClient.GetPoliciesByNamespace(t.Context(), namespace)
 	require.NoError(t, err)
@@ -274,14 +275,22 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 				{
 					Policy:       clusterAdmissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					Operation:    admissionv1.Create,
 				},
 				{
 					Policy:       admissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					Operation:    admissionv1.Create,
+				},
+				{
+					Policy:       admissionPolicy4,
+					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy4"},
+					Operation:    admissionv1.Create,
 				},
 				{
 					Policy:       admissionPolicyGroup1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-group-test-admissionPolicyGroup1"},
+					Operation:    admissionv1.Create,
 				},
 			},
 			{
@@ -292,20 +301,55 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 				{
 					Policy:       clusterAdmissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					Operation:    admissionv1.Create,
 				},
 				{
 					Policy:       clusterAdmissionPolicyGroup1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-group-clusterAdmissionPolicyGroup1"},
+					Operation:    admissionv1.Create,
 				},
 				{
 					Policy:       admissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					Operation:    admissionv1.Create,
 				},
+				{
+					Policy:       admissionPolicy4,
+					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy4"},
+					Operation:    admissionv1.Create,
+				},
+			},
+		},
+		PolicyNum:       5,
+		SkippedNum:      2,
+		ErroredNum:      1,
+		SkippedPolicies: []string{"clusterwide-clusterAdmissionPolicy3", "namespaced-test-admissionPolicy2"},
+		ErroredPolicies: []string{"namespaced-test-admissionPolicy5"},
+		NotAuditedByGVR: map[schema.GroupVersionResource][]NotAuditedPolicy{
+			{
+				Group:    "",
+				Version:  "v1",
+				Resource: "pods",
+			}: {
+				{Policy: clusterAdmissionPolicy3, Reason: "the policy is not active: pending"},
+				{Policy: admissionPolicy2, Reason: "the policy has backgroundAudit set to false"},
+			},
+			{
+				Group:    "apps",
+				Version:  "v1",
+				Resource: "deployments",
+			}: {
+				{Policy: clusterAdmissionPolicy3, Reason: "the policy is not active: pending"},
+				{Policy: admissionPolicy2, Reason: "the policy has backgroundAudit set to false"},
+			},
+		},
+		NotAuditedAnyGVR: []NotAuditedPolicy{
+			{
+				Policy:  admissionPolicy5,
+				Reason:  "unknown GVR targeted by the policy: failed to get GVK for GVR apps/v1, Resource=foo: no matches for apps/v1, Resource=foo",
+				Errored: true,
 			},
 		},
-		PolicyNum:  4,
-		SkippedNum: 3,
-		ErroredNum: 1,
 	}
 
 	assert.Equal(t, expectedPolicies, policies)
@@ -400,7 +444,7 @@ func TestGetClusterWidePolicies(t *testing.T) {
 		}).
 		Build()
 
-	// a ClusterAdmissionPolicy with no CREATE operation, it should be skipped
+	// a ClusterAdmissionPolicy with no CREATE operation, it should be audited with UPDATE requests
 	clusterAdmissionPolicy6 := testutils.
 		NewClusterAdmissionPolicyFactory().
 		Name("clusterAdmissionPolicy6").
@@ -411,6 +455,17 @@ func TestGetClusterWidePolicies(t *testing.T) {
 		}, admissionregistrationv1.Update, admissionregistrationv1.Delete).
 		Build()
 
+	// a ClusterAdmissionPolicy with only a DELETE operation, it should be audited with DELETE requests
+	clusterAdmissionPolicy8 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy8").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"namespaces"},
+		}, admissionregistrationv1.Delete).
+		Build()
+
 	// a ClusterAdmissionPolicy targeting unknown GVR, it should be errored and skipped
 	clusterAdmissionPolicy7 := testutils.
 		NewClusterAdmissionPolicyFactory().
@@ -462,6 +517,7 @@ func TestGetClusterWidePolicies(t *testing.T) {
 		clusterAdmissionPolicy5,
 		clusterAdmissionPolicy6,
 		clusterAdmissionPolicy7,
+		clusterAdmissionPolicy8,
 		clusterAdmissionPolicyGroup1,
 		clusterAdmissionPolicyGroup2,
 		admissionPolicy1,
@@ -469,7 +525,7 @@ func TestGetClusterWidePolicies(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	policiesClient := NewClient(client, "kubewarden", "", logger)
+	policiesClient := NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", "", nil, Filter{}, logger)
 
 	policies, err := policiesClient.GetClusterWidePolicies(t.Context())
 	require.NoError(t, err)
@@ -484,25 +540,176 @@ func TestGetClusterWidePolicies(t *testing.T) {
 				{
 					Policy:       clusterAdmissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					Operation:    admissionv1.Create,
 				},
 				{
 					Policy:       clusterAdmissionPolicy2,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy2"},
+					Operation:    admissionv1.Create,
 				},
 				{
 					Policy:       clusterAdmissionPolicy3,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy3"},
+					Operation:    admissionv1.Create,
+				},
+				{
+					Policy:       clusterAdmissionPolicy6,
+					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy6"},
+					Operation:    admissionv1.Update,
+				},
+				{
+					Policy:       clusterAdmissionPolicy8,
+					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy8"},
+					Operation:    admissionv1.Delete,
 				},
 				{
 					Policy:       clusterAdmissionPolicyGroup1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-group-clusterAdmissionPolicyGroup1"},
+					Operation:    admissionv1.Create,
 				},
 			},
 		},
-		PolicyNum:  4,
-		SkippedNum: 2,
-		ErroredNum: 1,
+		PolicyNum:       6,
+		SkippedNum:      1,
+		ErroredNum:      1,
+		SkippedPolicies: []string{"clusterwide-clusterAdmissionPolicy4"},
+		ErroredPolicies: []string{"clusterwide-policy8"},
+		NotAuditedByGVR: map[schema.GroupVersionResource][]NotAuditedPolicy{
+			{
+				Group:    "",
+				Version:  "v1",
+				Resource: "namespaces",
+			}: {
+				{Policy: clusterAdmissionPolicy4, Reason: "the policy has backgroundAudit set to false"},
+			},
+		},
+		NotAuditedAnyGVR: []NotAuditedPolicy{
+			{
+				Policy:  clusterAdmissionPolicy7,
+				Reason:  "unknown GVR targeted by the policy: failed to get GVK for GVR /v1, Resource=foo: no matches for /v1, Resource=foo",
+				Errored: true,
+			},
+		},
 	}
 
 	assert.Equal(t, expectedPolicies, policies)
 }
+
+func TestExpandWildcardRules(t *testing.T) {
+	pods := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+	namespaces := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "namespaces"}
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	validatingWebhookConfigurations := schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingwebhookconfigurations"}
+
+	tests := []struct {
+		name              string
+		rule              admissionregistrationv1.Rule
+		excludedResources []schema.GroupResource
+		expectedGVRs      []schema.GroupVersionResource
+	}{
+		{
+			name: "all the resources",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"*"},
+			},
+			expectedGVRs: []schema.GroupVersionResource{pods, namespaces, deployments, validatingWebhookConfigurations},
+		},
+		{
+			name: "all the resources and subresources",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"*/*"},
+			},
+			expectedGVRs: []schema.GroupVersionResource{pods, namespaces},
+		},
+		{
+			name: "all the resources, with exclusions",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"*"},
+			},
+			excludedResources: []schema.GroupResource{{Group: "apps", Resource: "deployments"}, {Group: "", Resource: "pods"}},
+			expectedGVRs:      []schema.GroupVersionResource{namespaces, validatingWebhookConfigurations},
+		},
+		{
+			name: "all the versions of a group",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"apps"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"deployments"},
+			},
+			expectedGVRs: []schema.GroupVersionResource{deployments},
+		},
+		{
+			name: "a resource in all the groups",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+			},
+			expectedGVRs: []schema.GroupVersionResource{pods},
+		},
+		{
+			name: "no wildcard",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+			},
+			excludedResources: []schema.GroupResource{{Group: "", Resource: "pods"}},
+			expectedGVRs:      []schema.GroupVersionResource{pods},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policiesClient := NewClient(nil, testutils.NewFakeDiscovery(), "kubewarden", "", test.excludedResources, Filter{}, slog.Default())
+
+			discovered, err := policiesClient.discoverAPIResources(t.Context())
+			require.NoError(t, err)
+
+			rules := policiesClient.expandWildcardRules([]admissionregistrationv1.RuleWithOperations{
+				{
+					Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+					Rule:       test.rule,
+				},
+			}, discovered)
+
+			var gvrs []schema.GroupVersionResource
+			for _, rule := range rules {
+				assert.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Create}, rule.Operations)
+				gvrs = append(gvrs, getRuleGVRs(rule)...)
+			}
+			assert.ElementsMatch(t, test.expectedGVRs, gvrs)
+		})
+	}
+}
+
+func TestListPolicies(t *testing.T) {
+	client, err := testutils.NewFakeClient(
+		testutils.NewClusterAdmissionPolicyFactory().Name("cluster-policy").BackgroundAudit(false).Build(),
+		testutils.NewClusterAdmissionPolicyGroupFactory().Name("cluster-group").Build(),
+		testutils.NewAdmissionPolicyFactory().Name("policy").Namespace("first").Status(policiesv1.PolicyStatusPending).Build(),
+		testutils.NewAdmissionPolicyGroupFactory().Name("group").Namespace("second").Build(),
+	)
+	require.NoError(t, err)
+
+	policiesClient := NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", "", nil, Filter{}, slog.Default())
+	policies, err := policiesClient.ListPolicies(t.Context())
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, policy := range policies {
+		names = append(names, policy.GetUniqueName())
+	}
+	assert.ElementsMatch(t, []string{
+		"clusterwide-cluster-policy",
+		"clusterwide-group-cluster-group",
+		"namespaced-first-policy",
+		"namespaced-group-second-group",
+	}, names)
+}
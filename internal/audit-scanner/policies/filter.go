@@ -0,0 +1,107 @@
+package policies
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Filter restricts the policies and the resources audited, so a targeted scan
+// only evaluates a subset of the policies against a subset of the resources.
+// Everything is audited when the filter is empty.
+type Filter struct {
+	// Policies are the names of the audited policies. The cluster-wide policies
+	// are referred to by their name, the namespaced ones as namespace/name.
+	// Policies and policy groups sharing the same name are both audited.
+	Policies []string
+	// Resources are the audited resources, in the resource.group or in the
+	// resource.version.group format. The group is omitted for the core resources,
+	// e.g. pods or pods.v1.
+	Resources []string
+}
+
+// Validate returns an error when a policy or a resource of the filter is malformed.
+func (f Filter) Validate() error {
+	for _, policy := range f.Policies {
+		name := policy
+		if namespace, namespacedName, namespaced := strings.Cut(policy, "/"); namespaced {
+			if namespace == "" {
+				return fmt.Errorf("invalid policy %q: expected name or namespace/name", policy)
+			}
+			name = namespacedName
+		}
+		if name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid policy %q: expected name or namespace/name", policy)
+		}
+	}
+	for _, resource := range f.Resources {
+		if resource == "" {
+			return fmt.Errorf("invalid resource %q: expected resource.group or resource.version.group", resource)
+		}
+	}
+	return nil
+}
+
+// IsEmpty returns true when the filter audits all the policies and the resources.
+func (f Filter) IsEmpty() bool {
+	return len(f.Policies) == 0 && len(f.Resources) == 0
+}
+
+// SelectsPolicy returns true when the policy with the given name and namespace,
+// empty for the cluster-wide policies, is audited.
+func (f Filter) SelectsPolicy(name, namespace string) bool {
+	if len(f.Policies) == 0 {
+		return true
+	}
+	if namespace != "" {
+		name = namespace + "/" + name
+	}
+	return slices.Contains(f.Policies, name)
+}
+
+// PolicyUniqueNames returns the unique names of the policies and of the policy
+// groups selected by the filter, as set in the results of the reports. It
+// returns nil when the filter selects all the policies.
+func (f Filter) PolicyUniqueNames() []string {
+	var uniqueNames []string
+	for _, policy := range f.Policies {
+		if namespace, name, namespaced := strings.Cut(policy, "/"); namespaced {
+			meta := metav1.ObjectMeta{Name: name, Namespace: namespace}
+			uniqueNames = append(uniqueNames,
+				(&policiesv1.AdmissionPolicy{ObjectMeta: meta}).GetUniqueName(),
+				(&policiesv1.AdmissionPolicyGroup{ObjectMeta: meta}).GetUniqueName())
+			continue
+		}
+		meta := metav1.ObjectMeta{Name: policy}
+		uniqueNames = append(uniqueNames,
+			(&policiesv1.ClusterAdmissionPolicy{ObjectMeta: meta}).GetUniqueName(),
+			(&policiesv1.ClusterAdmissionPolicyGroup{ObjectMeta: meta}).GetUniqueName())
+	}
+	return uniqueNames
+}
+
+// SelectsResource returns true when the resources of the given GVR are audited.
+func (f Filter) SelectsResource(gvr schema.GroupVersionResource) bool {
+	if len(f.Resources) == 0 {
+		return true
+	}
+	groupVersionResource := gvr.Resource + "." + gvr.Version
+	if gvr.Group != "" {
+		groupVersionResource += "." + gvr.Group
+	}
+	return slices.Contains(f.Resources, gvr.GroupResource().String()) || slices.Contains(f.Resources, groupVersionResource)
+}
+
+// filterPolicies returns the policies selected by the filter.
+func (f Filter) filterPolicies(policies []policiesv1.Policy) []policiesv1.Policy {
+	if len(f.Policies) == 0 {
+		return policies
+	}
+	return slices.DeleteFunc(policies, func(policy policiesv1.Policy) bool {
+		return !f.SelectsPolicy(policy.GetName(), policy.GetNamespace())
+	})
+}
@@ -0,0 +1,84 @@
+package policies
+
+import (
+	"testing"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      Filter
+		expectedErr bool
+	}{
+		{"empty", Filter{}, false},
+		{"cluster-wide policy", Filter{Policies: []string{"policy"}}, false},
+		{"namespaced policy", Filter{Policies: []string{"namespace/policy"}}, false},
+		{"missing namespace", Filter{Policies: []string{"/policy"}}, true},
+		{"missing name", Filter{Policies: []string{"namespace/"}}, true},
+		{"too many slashes", Filter{Policies: []string{"namespace/policy/other"}}, true},
+		{"empty resource", Filter{Resources: []string{""}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.filter.Validate()
+			if test.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFilterSelectsResource(t *testing.T) {
+	pods := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	assert.True(t, Filter{}.SelectsResource(pods))
+	assert.True(t, Filter{Resources: []string{"pods"}}.SelectsResource(pods))
+	assert.True(t, Filter{Resources: []string{"pods.v1"}}.SelectsResource(pods))
+	assert.False(t, Filter{Resources: []string{"pods"}}.SelectsResource(deployments))
+	assert.True(t, Filter{Resources: []string{"deployments.apps"}}.SelectsResource(deployments))
+	assert.True(t, Filter{Resources: []string{"deployments.v1.apps"}}.SelectsResource(deployments))
+	assert.False(t, Filter{Resources: []string{"deployments.v1beta1.apps"}}.SelectsResource(deployments))
+}
+
+func TestFilterPolicyUniqueNames(t *testing.T) {
+	assert.Nil(t, Filter{}.PolicyUniqueNames())
+	assert.Equal(t, []string{
+		"clusterwide-policy",
+		"clusterwide-group-policy",
+		"namespaced-namespace-policy",
+		"namespaced-group-namespace-policy",
+	}, Filter{Policies: []string{"policy", "namespace/policy"}}.PolicyUniqueNames())
+}
+
+func TestFilterPolicies(t *testing.T) {
+	policies := []policiesv1.Policy{
+		testutils.NewClusterAdmissionPolicyFactory().Name("policy").Build(),
+		testutils.NewClusterAdmissionPolicyGroupFactory().Name("policy").Build(),
+		testutils.NewClusterAdmissionPolicyFactory().Name("other").Build(),
+		testutils.NewAdmissionPolicyFactory().Name("policy").Namespace("namespace").Build(),
+		testutils.NewAdmissionPolicyGroupFactory().Name("group").Namespace("namespace").Build(),
+	}
+
+	assert.Len(t, Filter{}.filterPolicies(policies), len(policies))
+
+	filter := Filter{Policies: []string{"policy", "namespace/group"}}
+	names := []string{}
+	for _, policy := range filter.filterPolicies(policies) {
+		names = append(names, policy.GetUniqueName())
+	}
+	assert.ElementsMatch(t, []string{
+		"clusterwide-policy",
+		"clusterwide-group-policy",
+		"namespaced-group-namespace-group",
+	}, names)
+}
@@ -0,0 +1,299 @@
+package policies
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// clusterScopedKinds are the built-in kinds that are not namespaced. They are
+// used to find the scope of the resources when there is no RESTMapper, or
+// when the RESTMapper does not know their kind.
+var clusterScopedKinds = map[schema.GroupKind]struct{}{
+	{Group: "", Kind: "Namespace"}:                                                                        {},
+	{Group: "", Kind: "Node"}:                                                                             {},
+	{Group: "", Kind: "PersistentVolume"}:                                                                 {},
+	{Group: "", Kind: "ComponentStatus"}:                                                                  {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                                             {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                                      {},
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:                                     {},
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                                                 {},
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                                       {},
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                                          {},
+	{Group: "storage.k8s.io", Kind: "CSINode"}:                                                            {},
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                                                   {},
+	{Group: "storage.k8s.io", Kind: "VolumeAttributesClass"}:                                              {},
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                                                   {},
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                                          {},
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                                                    {},
+	{Group: "networking.k8s.io", Kind: "IPAddress"}:                                                       {},
+	{Group: "networking.k8s.io", Kind: "ServiceCIDR"}:                                                     {},
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:                                     {},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                                           {},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:                           {},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}:                       {},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:                         {},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"}:                            {},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"}:                     {},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingAdmissionPolicy"}:                              {},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingAdmissionPolicyBinding"}:                       {},
+	{Group: constants.KubewardenPoliciesGroup, Kind: "PolicyServer"}:                                      {},
+	{Group: constants.KubewardenPoliciesGroup, Kind: constants.KubewardenKindClusterAdmissionPolicy}:      {},
+	{Group: constants.KubewardenPoliciesGroup, Kind: constants.KubewardenKindClusterAdmissionPolicyGroup}: {},
+}
+
+// ResourceMatcherConfig configures a ResourceMatcher.
+type ResourceMatcherConfig struct {
+	// Policies are the policies matched against the resources
+	Policies []policiesv1.Policy
+	// PolicyServerURL is the URL of the PolicyServer evaluating all the policies
+	PolicyServerURL string
+	// RESTMapper maps the kinds of the resources to their resource names and
+	// scopes. When nil, or when it does not know a kind, the resource name is
+	// guessed from the kind, and only the built-in cluster-wide kinds are
+	// considered cluster-wide
+	RESTMapper meta.RESTMapper
+	// Namespaces are used to evaluate the namespaceSelector of the policies.
+	// The namespaces that are not listed only have the kubernetes.io/metadata.name label
+	Namespaces []corev1.Namespace
+	// DefaultNamespace is the namespace of the namespaced resources without one
+	DefaultNamespace string
+	// WildcardExcludedResources are the resources never targeted by a wildcard rule
+	WildcardExcludedResources []schema.GroupResource
+}
+
+// ResourceMatcher selects the policies targeting resources that are not read
+// from the cluster, like the ones of local manifests. The rules of the policies
+// are matched against each resource, so the wildcard rules do not need to be
+// expanded with the discovery API.
+type ResourceMatcher struct {
+	policies                  []policiesv1.Policy
+	policyServerURL           *url.URL
+	restMapper                meta.RESTMapper
+	namespaces                map[string]*corev1.Namespace
+	defaultNamespace          string
+	wildcardExcludedResources []schema.GroupResource
+}
+
+// ResourcePolicies are the policies targeting a resource.
+type ResourcePolicies struct {
+	// GVR is the resource of the object
+	GVR schema.GroupVersionResource
+	// Namespace of the object, empty when the object is cluster-wide
+	Namespace string
+	// Policies are the auditable policies targeting the object
+	Policies []*Policy
+	// NotAudited are the policies targeting the object which are not
+	// auditable, because they are not active or have backgroundAudit disabled,
+	// and the policies that cannot be matched against the object, which may be
+	// misconfigured
+	NotAudited []NotAuditedPolicy
+}
+
+// NewResourceMatcher returns a ResourceMatcher.
+func NewResourceMatcher(config ResourceMatcherConfig) (*ResourceMatcher, error) {
+	policyServerURL, err := url.Parse(config.PolicyServerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy server URL %q: %w", config.PolicyServerURL, err)
+	}
+
+	namespaces := make(map[string]*corev1.Namespace, len(config.Namespaces))
+	for _, namespace := range config.Namespaces {
+		namespaces[namespace.GetName()] = &namespace
+	}
+	for _, policy := range config.Policies {
+		setTypeMeta(policy)
+	}
+
+	return &ResourceMatcher{
+		policies:                  config.Policies,
+		policyServerURL:           policyServerURL,
+		restMapper:                config.RESTMapper,
+		namespaces:                namespaces,
+		defaultNamespace:          config.DefaultNamespace,
+		wildcardExcludedResources: config.WildcardExcludedResources,
+	}, nil
+}
+
+// Match returns the policies targeting the given object.
+func (m *ResourceMatcher) Match(resource unstructured.Unstructured) ResourcePolicies {
+	gvr, namespaced := m.resourceMapping(resource.GroupVersionKind())
+	resourcePolicies := ResourcePolicies{GVR: gvr}
+
+	var namespace *corev1.Namespace
+	if namespaced {
+		resourcePolicies.Namespace = resource.GetNamespace()
+		if resourcePolicies.Namespace == "" {
+			resourcePolicies.Namespace = m.defaultNamespace
+		}
+		namespace = m.namespace(resourcePolicies.Namespace)
+	}
+
+	for _, policy := range m.policies {
+		// Namespaced policies only target the resources of their namespace
+		if policy.GetNamespace() != "" && policy.GetNamespace() != resourcePolicies.Namespace {
+			continue
+		}
+		if namespace != nil && policy.GetNamespace() == "" {
+			matches, err := policyMatchesNamespace(policy, namespace)
+			if err != nil {
+				resourcePolicies.NotAudited = append(resourcePolicies.NotAudited, NotAuditedPolicy{Policy: policy, Reason: err.Error(), Errored: true})
+				continue
+			}
+			if !matches {
+				continue
+			}
+		}
+
+		operation, found := m.matchRules(filterNonAuditableOperations(policy.GetRules()), gvr)
+		if !found {
+			continue
+		}
+
+		// The policies read from local manifests do not have a status
+		status := policy.GetStatus().PolicyStatus
+		if !policy.GetBackgroundAudit() {
+			resourcePolicies.NotAudited = append(resourcePolicies.NotAudited, NotAuditedPolicy{Policy: policy, Reason: reasonBackgroundAuditDisabled})
+			continue
+		}
+		if status != "" && status != policiesv1.PolicyStatusActive {
+			resourcePolicies.NotAudited = append(resourcePolicies.NotAudited, NotAuditedPolicy{Policy: policy, Reason: fmt.Sprintf("%s: %s", reasonNotActive, status)})
+			continue
+		}
+
+		resourcePolicies.Policies = append(resourcePolicies.Policies, &Policy{
+			Policy:       policy,
+			PolicyServer: m.policyServerURL.JoinPath("audit", policy.GetUniqueName()),
+			Operation:    operation,
+		})
+	}
+
+	return resourcePolicies
+}
+
+// resourceMapping returns the resource of the given kind, and whether it is namespaced.
+func (m *ResourceMatcher) resourceMapping(gvk schema.GroupVersionKind) (schema.GroupVersionResource, bool) {
+	if m.restMapper != nil {
+		mapping, err := m.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err == nil {
+			return mapping.Resource, mapping.Scope.Name() == meta.RESTScopeNameNamespace
+		}
+	}
+
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+	_, clusterScoped := clusterScopedKinds[gvk.GroupKind()]
+	return gvr, !clusterScoped
+}
+
+// namespace returns the namespace with the given name. The namespaces that
+// are not known only have the label set by Kubernetes with their name.
+func (m *ResourceMatcher) namespace(name string) *corev1.Namespace {
+	if namespace, found := m.namespaces[name]; found {
+		return namespace
+	}
+
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{corev1.LabelMetadataName: name},
+		},
+	}
+}
+
+// matchRules returns the operation used to audit the resource targeted by the
+// rules. It returns false when no rule targets the resource.
+// When multiple rules target the resource, CREATE takes precedence over UPDATE,
+// and UPDATE over DELETE.
+func (m *ResourceMatcher) matchRules(rules []admissionregistrationv1.RuleWithOperations, gvr schema.GroupVersionResource) (admissionv1.Operation, bool) {
+	var operation admissionv1.Operation
+	found := false
+
+	for _, rule := range rules {
+		if !m.ruleTargets(rule, gvr) {
+			continue
+		}
+		ruleOperation := getRuleAuditOperation(rule)
+		if found {
+			ruleOperation = preferredAuditOperation(operation, ruleOperation)
+		}
+		operation = ruleOperation
+		found = true
+	}
+
+	return operation, found
+}
+
+// ruleTargets returns true if the rule targets the given resource.
+// The resources listed in wildcardExcludedResources are never targeted by a wildcard.
+func (m *ResourceMatcher) ruleTargets(rule admissionregistrationv1.RuleWithOperations, gvr schema.GroupVersionResource) bool {
+	if !slices.Contains(rule.APIGroups, "*") && !slices.Contains(rule.APIGroups, gvr.Group) {
+		return false
+	}
+	if !slices.Contains(rule.APIVersions, "*") && !slices.Contains(rule.APIVersions, gvr.Version) {
+		return false
+	}
+	if isWildcardRule(rule) && slices.Contains(m.wildcardExcludedResources, gvr.GroupResource()) {
+		return false
+	}
+
+	return isWildcardResource(rule.Resources) || slices.Contains(rule.Resources, gvr.Resource)
+}
+
+// FromObjects returns the Kubewarden policies among the given objects, like the
+// ones read from local manifests. Objects of other kinds are ignored.
+// As the API server would, backgroundAudit defaults to true, and the namespaced
+// policies without a namespace are in defaultNamespace.
+func FromObjects(objects []unstructured.Unstructured, defaultNamespace string) ([]policiesv1.Policy, error) {
+	var policies []policiesv1.Policy
+
+	for _, object := range objects {
+		if object.GroupVersionKind().Group != constants.KubewardenPoliciesGroup {
+			continue
+		}
+
+		var policy policiesv1.Policy
+		namespaced := false
+		switch object.GetKind() {
+		case constants.KubewardenKindClusterAdmissionPolicy:
+			policy = &policiesv1.ClusterAdmissionPolicy{}
+		case constants.KubewardenKindClusterAdmissionPolicyGroup:
+			policy = &policiesv1.ClusterAdmissionPolicyGroup{}
+		case constants.KubewardenKindAdmissionPolicy:
+			policy = &policiesv1.AdmissionPolicy{}
+			namespaced = true
+		case constants.KubewardenKindAdmissionPolicyGroup:
+			policy = &policiesv1.AdmissionPolicyGroup{}
+			namespaced = true
+		default:
+			continue
+		}
+
+		object = *object.DeepCopy()
+		if namespaced && object.GetNamespace() == "" {
+			object.SetNamespace(defaultNamespace)
+		}
+		content := object.Object
+		if _, found, _ := unstructured.NestedFieldNoCopy(content, "spec", "backgroundAudit"); !found {
+			if err := unstructured.SetNestedField(content, true, "spec", "backgroundAudit"); err != nil {
+				return nil, fmt.Errorf("cannot default backgroundAudit of %s %s: %w", object.GetKind(), object.GetName(), err)
+			}
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, policy); err != nil {
+			return nil, fmt.Errorf("cannot decode %s %s: %w", object.GetKind(), object.GetName(), err)
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
@@ -0,0 +1,206 @@
+package policies
+
+import (
+	"strings"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	object := unstructured.Unstructured{}
+	object.SetAPIVersion(apiVersion)
+	object.SetKind(kind)
+	object.SetNamespace(namespace)
+	object.SetName(name)
+	return object
+}
+
+func policyNames(policies []*Policy) []string {
+	names := []string{}
+	for _, policy := range policies {
+		names = append(names, policy.GetUniqueName())
+	}
+	return names
+}
+
+func TestResourceMatcher(t *testing.T) {
+	podsRule := admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods"}}
+	wildcardRule := admissionregistrationv1.Rule{APIGroups: []string{"*"}, APIVersions: []string{"*"}, Resources: []string{"*"}}
+
+	pods := testutils.NewClusterAdmissionPolicyFactory().Name("pods").Rule(podsRule).Build()
+	podUpdates := testutils.NewClusterAdmissionPolicyFactory().Name("pod-updates").Rule(podsRule, admissionregistrationv1.Update).Build()
+	wildcard := testutils.NewClusterAdmissionPolicyFactory().Name("wildcard").Rule(wildcardRule).Build()
+	production := testutils.NewClusterAdmissionPolicyFactory().Name("production").Rule(podsRule).
+		NamespaceSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"env": "production"}}).Build()
+	notAudited := testutils.NewClusterAdmissionPolicyFactory().Name("not-audited").Rule(podsRule).BackgroundAudit(false).Build()
+	pending := testutils.NewClusterAdmissionPolicyFactory().Name("pending").Rule(podsRule).Status(policiesv1.PolicyStatusPending).Build()
+	deleteOnly := testutils.NewClusterAdmissionPolicyFactory().Name("delete-only").Rule(podsRule, admissionregistrationv1.Delete).Build()
+	connectOnly := testutils.NewClusterAdmissionPolicyFactory().Name("connect-only").Rule(podsRule, admissionregistrationv1.Connect).Build()
+	namespaced := testutils.NewAdmissionPolicyFactory().Name("namespaced").Namespace("team").Rule(podsRule).Build()
+
+	matcher, err := NewResourceMatcher(ResourceMatcherConfig{
+		Policies:        []policiesv1.Policy{pods, podUpdates, wildcard, production, notAudited, pending, deleteOnly, connectOnly, namespaced},
+		PolicyServerURL: "https://localhost:3000",
+		Namespaces: []corev1.Namespace{{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "production"}},
+		}},
+		DefaultNamespace:          "default",
+		WildcardExcludedResources: []schema.GroupResource{{Group: "", Resource: "events"}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name              string
+		object            unstructured.Unstructured
+		expectedGVR       schema.GroupVersionResource
+		expectedNamespace string
+		expectedPolicies  []string
+		expectedSkipped   int
+	}{
+		{
+			name:              "pod without namespace",
+			object:            newObject("v1", "Pod", "", "nginx"),
+			expectedGVR:       schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+			expectedNamespace: "default",
+			expectedPolicies:  []string{"clusterwide-pods", "clusterwide-pod-updates", "clusterwide-wildcard", "clusterwide-delete-only"},
+			expectedSkipped:   2,
+		},
+		{
+			name:              "pod in a namespace selected by a namespaceSelector",
+			object:            newObject("v1", "Pod", "prod", "nginx"),
+			expectedGVR:       schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+			expectedNamespace: "prod",
+			expectedPolicies:  []string{"clusterwide-pods", "clusterwide-pod-updates", "clusterwide-wildcard", "clusterwide-production", "clusterwide-delete-only"},
+			expectedSkipped:   2,
+		},
+		{
+			name:              "pod in the namespace of a namespaced policy",
+			object:            newObject("v1", "Pod", "team", "nginx"),
+			expectedGVR:       schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+			expectedNamespace: "team",
+			expectedPolicies:  []string{"clusterwide-pods", "clusterwide-pod-updates", "clusterwide-wildcard", "clusterwide-delete-only", "namespaced-team-namespaced"},
+			expectedSkipped:   2,
+		},
+		{
+			name:             "cluster-wide resource",
+			object:           newObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", "admin"),
+			expectedGVR:      schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+			expectedPolicies: []string{"clusterwide-wildcard"},
+		},
+		{
+			name:              "resource excluded from the wildcard rules",
+			object:            newObject("v1", "Event", "default", "event"),
+			expectedGVR:       schema.GroupVersionResource{Version: "v1", Resource: "events"},
+			expectedNamespace: "default",
+			expectedPolicies:  []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resourcePolicies := matcher.Match(test.object)
+
+			assert.Equal(t, test.expectedGVR, resourcePolicies.GVR)
+			assert.Equal(t, test.expectedNamespace, resourcePolicies.Namespace)
+			assert.Equal(t, test.expectedPolicies, policyNames(resourcePolicies.Policies))
+			assert.Len(t, resourcePolicies.NotAudited, test.expectedSkipped)
+		})
+	}
+
+	resourcePolicies := matcher.Match(newObject("v1", "Pod", "", "nginx"))
+	require.Len(t, resourcePolicies.NotAudited, 2)
+	assert.Equal(t, "clusterwide-not-audited", resourcePolicies.NotAudited[0].GetUniqueName())
+	assert.Equal(t, "the policy has backgroundAudit set to false", resourcePolicies.NotAudited[0].Reason)
+	assert.Equal(t, "clusterwide-pending", resourcePolicies.NotAudited[1].GetUniqueName())
+	assert.Equal(t, "the policy is not active: pending", resourcePolicies.NotAudited[1].Reason)
+	assert.False(t, resourcePolicies.NotAudited[1].Errored)
+	assert.Equal(t, "https://localhost:3000/audit/clusterwide-pods", resourcePolicies.Policies[0].PolicyServer.String())
+	assert.Equal(t, admissionv1.Create, resourcePolicies.Policies[0].Operation)
+	assert.Equal(t, admissionv1.Update, resourcePolicies.Policies[1].Operation)
+	assert.Equal(t, admissionv1.Delete, resourcePolicies.Policies[3].Operation)
+}
+
+func TestResourceMatcherWithRESTMapper(t *testing.T) {
+	client, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+
+	matcher, err := NewResourceMatcher(ResourceMatcherConfig{
+		Policies: []policiesv1.Policy{
+			testutils.NewClusterAdmissionPolicyFactory().Name("webhooks").Rule(admissionregistrationv1.Rule{
+				APIGroups:   []string{"admissionregistration.k8s.io"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"validatingwebhookconfigurations"},
+			}).Build(),
+		},
+		PolicyServerURL: "https://localhost:3000",
+		RESTMapper:      client.RESTMapper(),
+	})
+	require.NoError(t, err)
+
+	resourcePolicies := matcher.Match(newObject("admissionregistration.k8s.io/v1", "ValidatingWebhookConfiguration", "", "webhook"))
+	assert.Empty(t, resourcePolicies.Namespace)
+	assert.Equal(t, []string{"clusterwide-webhooks"}, policyNames(resourcePolicies.Policies))
+}
+
+func TestFromObjects(t *testing.T) {
+	objects := []unstructured.Unstructured{
+		{Object: map[string]any{
+			"apiVersion": "policies.kubewarden.io/v1",
+			"kind":       "ClusterAdmissionPolicy",
+			"metadata":   map[string]any{"name": "privileged-pods"},
+			"spec": map[string]any{
+				"module": "registry://ghcr.io/kubewarden/policies/pod-privileged:v1.0.0",
+				"rules": []any{map[string]any{
+					"apiGroups":   []any{""},
+					"apiVersions": []any{"v1"},
+					"resources":   []any{"pods"},
+					"operations":  []any{"CREATE"},
+				}},
+			},
+		}},
+		{Object: map[string]any{
+			"apiVersion": "policies.kubewarden.io/v1",
+			"kind":       "AdmissionPolicy",
+			"metadata":   map[string]any{"name": "not-audited", "namespace": "team"},
+			"spec":       map[string]any{"backgroundAudit": false},
+		}},
+		{Object: map[string]any{
+			"apiVersion": "policies.kubewarden.io/v1",
+			"kind":       "AdmissionPolicyGroup",
+			"metadata":   map[string]any{"name": "without-namespace"},
+		}},
+		newObject("policies.kubewarden.io/v1", "PolicyServer", "", "default"),
+		newObject("v1", "Pod", "default", "nginx"),
+	}
+
+	policies, err := FromObjects(objects, "default")
+	require.NoError(t, err)
+	require.Len(t, policies, 3)
+
+	assert.Equal(t, "clusterwide-privileged-pods", policies[0].GetUniqueName())
+	assert.True(t, policies[0].GetBackgroundAudit())
+	assert.Len(t, policies[0].GetRules(), 1)
+	assert.Equal(t, "namespaced-team-not-audited", policies[1].GetUniqueName())
+	assert.False(t, policies[1].GetBackgroundAudit())
+	assert.Equal(t, "default", policies[2].GetNamespace())
+	assert.Equal(t, "namespaced-group-default-without-namespace", policies[2].GetUniqueName())
+
+	_, err = FromObjects([]unstructured.Unstructured{{Object: map[string]any{
+		"apiVersion": "policies.kubewarden.io/v1",
+		"kind":       "ClusterAdmissionPolicy",
+		"metadata":   map[string]any{"name": "invalid"},
+		"spec":       map[string]any{"rules": "not a list"},
+	}}}, "default")
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "cannot decode ClusterAdmissionPolicy invalid"))
+}
@@ -6,6 +6,28 @@ const (
 	propertyPolicyUID             = "policy-uid"
 	propertyPolicyName            = "policy-name"
 	propertyPolicyNamespace       = "policy-namespace"
+	propertyOperation             = "operation"
+	// propertyPatchOperations is the number of operations of the JSONPatch
+	// returned by a mutating policy. The operations are stored in the
+	// propertyPatchOperationPrefix properties, suffixed with their index.
+	propertyPatchOperations      = "patch-operations"
+	propertyPatchOperationPrefix = "patch-operation-"
+	// propertyPatch holds the raw patch, when it's not a valid JSONPatch
+	propertyPatch = "patch"
+	// maxPatchOperationProperties caps the operations stored in the properties,
+	// so large patches don't bloat the reports
+	maxPatchOperationProperties = 20
+	// messageWouldMutate is the message of the results of the mutating
+	// policies that would change the resource
+	messageWouldMutate = "the resource would be mutated by the policy"
+	// propertyRejectedMembers lists the members of a policy group that
+	// rejected the resource. The message of each member is stored in the
+	// propertyMemberPrefix property, suffixed with the member name.
+	propertyRejectedMembers = "rejected-members"
+	propertyMemberPrefix    = "member-"
+	// groupMemberCauseFieldPrefix is the prefix of the field of the causes
+	// returned by the PolicyServer for the members of a policy group
+	groupMemberCauseFieldPrefix = "spec.policies."
 )
 
 const (
@@ -39,6 +61,9 @@ const (
 	labelApp                      = "kubewarden"
 	labelPolicyReportVersion      = "kubewarden.io/policyreport-version"
 	labelPolicyReportVersionValue = "v2"
+	// annotationContentHash holds the hash of the content of a stored report,
+	// excluding the timestamps of its results
+	annotationContentHash = "kubewarden.io/audit-scanner-content-hash"
 )
 
 const (
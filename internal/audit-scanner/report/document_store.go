@@ -0,0 +1,209 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	auditConstants "github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+// documentMetadata identifies a report stored as a document. The namespace is
+// empty for the reports of the cluster-wide resources.
+type documentMetadata struct {
+	Namespace string
+	Name      string
+	RunUID    string
+	ShardKey  string
+}
+
+// documentBackend stores the reports as JSON documents outside of the
+// Kubernetes API server.
+type documentBackend interface {
+	// getDocument returns the document with the given namespace and name.
+	// It returns constants.ErrResourceNotFound when the document does not exist.
+	getDocument(ctx context.Context, namespace, name string) ([]byte, error)
+	// putDocument creates or replaces the document.
+	putDocument(ctx context.Context, metadata documentMetadata, document []byte) error
+	// deleteDocuments deletes the documents of the namespace selected by the given function.
+	deleteDocuments(ctx context.Context, namespace string, selected func(metadata documentMetadata) bool) error
+	// Close releases the resources of the backend.
+	Close() error
+}
+
+// DocumentStore is a store writing the reports as JSON documents, encoded like
+// the PolicyReport or OpenReport resources, to a backend other than the
+// Kubernetes API server: a directory, a SQLite database or an S3 bucket.
+type DocumentStore struct {
+	kind    CrdKind
+	backend documentBackend
+	logger  *slog.Logger
+}
+
+func newDocumentStore(kind CrdKind, backend documentBackend, logger *slog.Logger) *DocumentStore {
+	return &DocumentStore{
+		kind:    kind,
+		backend: backend,
+		logger:  logger.With("component", "documentstore"),
+	}
+}
+
+// GetReport returns the report of the namespaced resource with the given UID.
+func (s *DocumentStore) GetReport(ctx context.Context, namespace string, resourceUID types.UID) (Report, error) {
+	document, err := s.backend.getDocument(ctx, namespace, string(resourceUID))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.kind == ReportKindPolicyReport {
+		report := &wgpolicy.PolicyReport{}
+		if err = json.Unmarshal(document, report); err != nil {
+			return nil, fmt.Errorf("failed to decode PolicyReport %s/%s: %w", namespace, resourceUID, err)
+		}
+		return &PolicyReport{report: report}, nil
+	}
+	report := &openreports.Report{}
+	if err = json.Unmarshal(document, report); err != nil {
+		return nil, fmt.Errorf("failed to decode Report %s/%s: %w", namespace, resourceUID, err)
+	}
+	return &OpenReport{report: report}, nil
+}
+
+// GetClusterReport returns the report of the cluster-wide resource with the given UID.
+func (s *DocumentStore) GetClusterReport(ctx context.Context, resourceUID types.UID) (Report, error) {
+	document, err := s.backend.getDocument(ctx, "", string(resourceUID))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.kind == ReportKindPolicyReport {
+		report := &wgpolicy.ClusterPolicyReport{}
+		if err = json.Unmarshal(document, report); err != nil {
+			return nil, fmt.Errorf("failed to decode ClusterPolicyReport %s: %w", resourceUID, err)
+		}
+		return &ClusterPolicyReport{report: report}, nil
+	}
+	report := &openreports.ClusterReport{}
+	if err = json.Unmarshal(document, report); err != nil {
+		return nil, fmt.Errorf("failed to decode ClusterReport %s: %w", resourceUID, err)
+	}
+	return &OpenClusterReport{report: report}, nil
+}
+
+// CreateOrPatchReport creates or replaces the document of a namespaced report.
+func (s *DocumentStore) CreateOrPatchReport(ctx context.Context, report any) error {
+	var object metav1.Object
+	switch r := report.(type) {
+	case *PolicyReport:
+		if s.kind == ReportKindPolicyReport {
+			object = r.report
+		}
+	case *OpenReport:
+		if s.kind == ReportKindOpenReport {
+			object = r.report
+		}
+	}
+	if object == nil {
+		return fmt.Errorf("unexpected report %T for the store", report)
+	}
+	return s.putReport(ctx, object, report)
+}
+
+// CreateOrPatchClusterReport creates or replaces the document of a cluster-wide report.
+func (s *DocumentStore) CreateOrPatchClusterReport(ctx context.Context, report any) error {
+	var object metav1.Object
+	switch r := report.(type) {
+	case *ClusterPolicyReport:
+		if s.kind == ReportKindPolicyReport {
+			object = r.report
+		}
+	case *OpenClusterReport:
+		if s.kind == ReportKindOpenReport {
+			object = r.report
+		}
+	}
+	if object == nil {
+		return fmt.Errorf("unexpected cluster report %T for the store", report)
+	}
+	return s.putReport(ctx, object, report)
+}
+
+// putReport writes the report, encoded by its MarshalJSON method.
+func (s *DocumentStore) putReport(ctx context.Context, object metav1.Object, report any) error {
+	document, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode report %s: %w", object.GetName(), err)
+	}
+
+	metadata := documentMetadata{
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		RunUID:    object.GetLabels()[auditConstants.AuditScannerRunUIDLabel],
+		ShardKey:  object.GetLabels()[auditConstants.AuditScannerShardKeyLabel],
+	}
+	if err = s.backend.putDocument(ctx, metadata, document); err != nil {
+		return fmt.Errorf("failed to write report %s: %w", object.GetName(), err)
+	}
+
+	s.logger.DebugContext(ctx, "report written",
+		slog.String("report-name", metadata.Name),
+		slog.String("report-namespace", metadata.Namespace))
+	return nil
+}
+
+// DeleteOldReports deletes the reports of the namespace that do not belong to the current scan run.
+func (s *DocumentStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
+	s.logger.DebugContext(ctx, "Deleting old reports", slog.String("namespace", namespace))
+
+	if err := s.backend.deleteDocuments(ctx, namespace, func(metadata documentMetadata) bool {
+		return metadata.RunUID != scanRunID
+	}); err != nil {
+		return fmt.Errorf("failed to delete reports: %w", err)
+	}
+	return nil
+}
+
+// DeleteOldClusterReports deletes the reports of the cluster-wide resources that do not belong
+// to the current scan run. When the audit is sharded, only the reports of the resources owned
+// by the shard are deleted.
+func (s *DocumentStore) DeleteOldClusterReports(ctx context.Context, scanRunID string, auditShard shard.Shard) error {
+	s.logger.DebugContext(ctx, "Deleting old cluster reports")
+
+	if err := s.backend.deleteDocuments(ctx, "", func(metadata documentMetadata) bool {
+		return metadata.RunUID != scanRunID && (!auditShard.Enabled() || auditShard.OwnsKey(metadata.ShardKey))
+	}); err != nil {
+		return fmt.Errorf("failed to delete cluster reports: %w", err)
+	}
+	return nil
+}
+
+// DeleteReport deletes the report of the namespaced resource with the given UID, if any.
+func (s *DocumentStore) DeleteReport(ctx context.Context, namespace string, resourceUID types.UID) error {
+	if err := s.backend.deleteDocuments(ctx, namespace, func(metadata documentMetadata) bool {
+		return metadata.Name == string(resourceUID)
+	}); err != nil {
+		return fmt.Errorf("failed to delete report %s/%s: %w", namespace, resourceUID, err)
+	}
+	return nil
+}
+
+// DeleteClusterReport deletes the report of the cluster-wide resource with the given UID, if any.
+func (s *DocumentStore) DeleteClusterReport(ctx context.Context, resourceUID types.UID) error {
+	if err := s.backend.deleteDocuments(ctx, "", func(metadata documentMetadata) bool {
+		return metadata.Name == string(resourceUID)
+	}); err != nil {
+		return fmt.Errorf("failed to delete cluster report %s: %w", resourceUID, err)
+	}
+	return nil
+}
+
+// Close releases the resources of the backend, e.g. the connection to the database.
+func (s *DocumentStore) Close() error {
+	return s.backend.Close() //nolint:wrapcheck // the backends wrap their errors
+}
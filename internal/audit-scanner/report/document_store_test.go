@@ -0,0 +1,280 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	auditConstants "github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
+)
+
+// fakeS3Object is an object stored by the fake S3 server.
+type fakeS3Object struct {
+	data     []byte
+	metadata http.Header
+}
+
+// newFakeS3Server returns a server implementing the subset of the S3 API used
+// by the S3 store, for a single bucket and without authentication.
+func newFakeS3Server(t *testing.T, bucket string) *httptest.Server {
+	t.Helper()
+	var mutex sync.Mutex
+	objects := make(map[string]fakeS3Object)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		key, found := strings.CutPrefix(request.URL.Path, "/"+bucket)
+		if !found {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		key = strings.TrimPrefix(key, "/")
+
+		if key == "" && request.Method == http.MethodGet {
+			prefix := request.URL.Query().Get("prefix")
+			result := struct {
+				XMLName  xml.Name `xml:"ListBucketResult"`
+				Name     string
+				Prefix   string
+				KeyCount int
+				Contents []struct{ Key string }
+			}{Name: bucket, Prefix: prefix}
+			for _, objectKey := range slices.Sorted(func(yield func(string) bool) {
+				for objectKey := range objects {
+					if strings.HasPrefix(objectKey, prefix) && !yield(objectKey) {
+						return
+					}
+				}
+			}) {
+				result.Contents = append(result.Contents, struct{ Key string }{objectKey})
+			}
+			result.KeyCount = len(result.Contents)
+			writer.Header().Set("Content-Type", "application/xml")
+			_ = xml.NewEncoder(writer).Encode(result)
+			return
+		}
+
+		object, exists := objects[key]
+		switch request.Method {
+		case http.MethodPut:
+			data, err := io.ReadAll(request.Body)
+			if err != nil {
+				writer.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			metadata := http.Header{}
+			for name, values := range request.Header {
+				if strings.HasPrefix(name, "X-Amz-Meta-") {
+					metadata[name] = values
+				}
+			}
+			objects[key] = fakeS3Object{data: data, metadata: metadata}
+			writer.Header().Set("ETag", `"etag"`)
+		case http.MethodDelete:
+			delete(objects, key)
+			writer.WriteHeader(http.StatusNoContent)
+		case http.MethodGet, http.MethodHead:
+			if !exists {
+				writer.Header().Set("Content-Type", "application/xml")
+				writer.WriteHeader(http.StatusNotFound)
+				if request.Method == http.MethodGet {
+					_, _ = io.WriteString(writer, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+				}
+				return
+			}
+			for name, values := range object.metadata {
+				writer.Header()[name] = values
+			}
+			writer.Header().Set("ETag", `"etag"`)
+			writer.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+			writer.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+			if request.Method == http.MethodGet {
+				_, _ = writer.Write(object.data)
+			}
+		default:
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newDocumentTestResource(namespace, uid string) unstructured.Unstructured {
+	resource := unstructured.Unstructured{}
+	resource.SetUID(types.UID(uid))
+	resource.SetName(uid + "-name")
+	resource.SetNamespace(namespace)
+	resource.SetAPIVersion("v1")
+	resource.SetResourceVersion("1")
+	if namespace == "" {
+		resource.SetKind("Namespace")
+	} else {
+		resource.SetKind("Pod")
+	}
+	return resource
+}
+
+func TestDocumentStores(t *testing.T) {
+	newStores := map[string]func(t *testing.T, kind CrdKind) *DocumentStore{
+		"file": func(t *testing.T, kind CrdKind) *DocumentStore {
+			t.Helper()
+			store, err := NewFileStore(kind, filepath.Join(t.TempDir(), "reports"), slog.Default())
+			require.NoError(t, err)
+			return store
+		},
+		"sqlite": func(t *testing.T, kind CrdKind) *DocumentStore {
+			t.Helper()
+			store, err := NewSQLiteStore(t.Context(), kind, filepath.Join(t.TempDir(), "reports.db"), slog.Default())
+			require.NoError(t, err)
+			return store
+		},
+		"s3": func(t *testing.T, kind CrdKind) *DocumentStore {
+			t.Helper()
+			server := newFakeS3Server(t, "bucket")
+			serverURL, err := url.Parse(server.URL)
+			require.NoError(t, err)
+			store, err := NewS3Store(kind, S3Config{
+				Endpoint:    serverURL.Host,
+				Bucket:      "bucket",
+				Prefix:      "audit",
+				Region:      "us-east-1",
+				Insecure:    true,
+				Credentials: credentials.NewStatic("", "", "", credentials.SignatureAnonymous),
+			}, slog.Default())
+			require.NoError(t, err)
+			return store
+		},
+	}
+
+	for name, newStore := range newStores {
+		for kindName, kind := range map[string]CrdKind{OpenReportsKind: ReportKindOpenReport, PolicyReportKind: ReportKindPolicyReport} {
+			t.Run(name+"/"+kindName, func(t *testing.T) {
+				store := newStore(t, kind)
+				defer store.Close()
+				testDocumentStore(t, store, kind)
+			})
+		}
+	}
+}
+
+func testDocumentStore(t *testing.T, store *DocumentStore, kind CrdKind) {
+	t.Helper()
+	auditShard := shard.Shard{Index: 1, Count: 2}
+
+	_, err := store.GetReport(t.Context(), "namespace", "missing")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+	_, err = store.GetClusterReport(t.Context(), "missing")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	// the reports written by the old run
+	oldReport := NewReportOfKind(kind, "old-uid", newDocumentTestResource("namespace", "old"))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), oldReport))
+	otherNamespaceReport := NewReportOfKind(kind, "old-uid", newDocumentTestResource("other", "other"))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), otherNamespaceReport))
+	ownedClusterReport := NewClusterReportOfKind(kind, "old-uid", newDocumentTestResource("", "owned"))
+	ownedClusterReport.SetShardKey("3")
+	require.NoError(t, store.CreateOrPatchClusterReport(t.Context(), ownedClusterReport))
+	otherShardClusterReport := NewClusterReportOfKind(kind, "old-uid", newDocumentTestResource("", "other-shard"))
+	otherShardClusterReport.SetShardKey("4")
+	require.NoError(t, store.CreateOrPatchClusterReport(t.Context(), otherShardClusterReport))
+
+	// the reports written by the new run
+	newReport := NewReportOfKind(kind, "new-uid", newDocumentTestResource("namespace", "new"))
+	newReport.AddSkippedResult(&policiesv1.AdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "namespace"},
+	}, "the policy is not active")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newReport))
+	newClusterReport := NewClusterReportOfKind(kind, "new-uid", newDocumentTestResource("", "new-cluster"))
+	newClusterReport.SetShardKey("3")
+	require.NoError(t, store.CreateOrPatchClusterReport(t.Context(), newClusterReport))
+
+	storedReport, err := store.GetReport(t.Context(), "namespace", "new")
+	require.NoError(t, err)
+	assert.Equal(t, newReport.ResourceResults(), storedReport.ResourceResults())
+	storedClusterReport, err := store.GetClusterReport(t.Context(), "new-cluster")
+	require.NoError(t, err)
+	assert.Equal(t, newClusterReport.ResourceResults(), storedClusterReport.ResourceResults())
+
+	// the reports of the wrong kind are rejected
+	otherKind := ReportKindPolicyReport
+	if kind == ReportKindPolicyReport {
+		otherKind = ReportKindOpenReport
+	}
+	require.Error(t, store.CreateOrPatchReport(t.Context(), NewReportOfKind(otherKind, "new-uid", newDocumentTestResource("namespace", "new"))))
+
+	require.NoError(t, store.DeleteOldReports(t.Context(), "new-uid", "namespace"))
+	_, err = store.GetReport(t.Context(), "namespace", "old")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+	_, err = store.GetReport(t.Context(), "namespace", "new")
+	require.NoError(t, err)
+	_, err = store.GetReport(t.Context(), "other", "other")
+	require.NoError(t, err)
+
+	require.NoError(t, store.DeleteOldClusterReports(t.Context(), "new-uid", auditShard))
+	_, err = store.GetClusterReport(t.Context(), "owned")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+	_, err = store.GetClusterReport(t.Context(), "other-shard")
+	require.NoError(t, err)
+	_, err = store.GetClusterReport(t.Context(), "new-cluster")
+	require.NoError(t, err)
+
+	require.NoError(t, store.DeleteReport(t.Context(), "namespace", "new"))
+	_, err = store.GetReport(t.Context(), "namespace", "new")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+	_, err = store.GetReport(t.Context(), "other", "other")
+	require.NoError(t, err)
+
+	require.NoError(t, store.DeleteClusterReport(t.Context(), "new-cluster"))
+	_, err = store.GetClusterReport(t.Context(), "new-cluster")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+	_, err = store.GetClusterReport(t.Context(), "other-shard")
+	require.NoError(t, err)
+}
+
+func TestNewDocumentStoreFromURL(t *testing.T) {
+	directory := t.TempDir()
+
+	store, err := NewDocumentStoreFromURL(t.Context(), ReportKindOpenReport, "file://"+filepath.Join(directory, "reports"), slog.Default())
+	require.NoError(t, err)
+	assert.IsType(t, &fileBackend{}, store.backend)
+
+	store, err = NewDocumentStoreFromURL(t.Context(), ReportKindOpenReport, "sqlite://"+filepath.Join(directory, "reports.db"), slog.Default())
+	require.NoError(t, err)
+	assert.IsType(t, &sqliteBackend{}, store.backend)
+	require.NoError(t, store.Close())
+
+	store, err = NewDocumentStoreFromURL(t.Context(), ReportKindOpenReport, "s3://bucket/audit/reports/?endpoint=localhost:9000&insecure=true", slog.Default())
+	require.NoError(t, err)
+	backend, ok := store.backend.(*s3Backend)
+	require.True(t, ok)
+	assert.Equal(t, "bucket", backend.bucket)
+	assert.Equal(t, "audit/reports", backend.prefix)
+	assert.Equal(t, "localhost:9000", backend.client.EndpointURL().Host)
+	assert.Equal(t, "http", backend.client.EndpointURL().Scheme)
+
+	for _, invalidURL := range []string{"s3:///prefix", "file://", "http://example.com", "s3://bucket?insecure=maybe"} {
+		_, err = NewDocumentStoreFromURL(t.Context(), ReportKindOpenReport, invalidURL, slog.Default())
+		require.Error(t, err, invalidURL)
+	}
+}
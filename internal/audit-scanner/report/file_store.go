@@ -0,0 +1,142 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	auditConstants "github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+)
+
+const (
+	// clusterReportsDirectory holds the reports of the cluster-wide resources.
+	// Namespace names cannot contain underscores, so it never clashes with
+	// the directory of a namespace.
+	clusterReportsDirectory = "_cluster"
+	documentExtension       = ".json"
+)
+
+// fileBackend stores each report in its own file, <namespace>/<resource UID>.json,
+// under a directory. Every file holds a single JSON line, so the concatenation
+// of the files is a valid NDJSON stream.
+type fileBackend struct {
+	directory string
+}
+
+// NewFileStore returns a store writing the reports of the given kind as files
+// under the directory, created if needed.
+func NewFileStore(kind CrdKind, directory string, logger *slog.Logger) (*DocumentStore, error) {
+	if err := os.MkdirAll(directory, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create the reports directory %s: %w", directory, err)
+	}
+	return newDocumentStore(kind, &fileBackend{directory: directory}, logger), nil
+}
+
+// namespaceDirectory returns the directory of the reports of the namespace.
+func (b *fileBackend) namespaceDirectory(namespace string) string {
+	if namespace == "" {
+		return filepath.Join(b.directory, clusterReportsDirectory)
+	}
+	return filepath.Join(b.directory, namespace)
+}
+
+func (b *fileBackend) getDocument(_ context.Context, namespace, name string) ([]byte, error) {
+	document, err := os.ReadFile(filepath.Join(b.namespaceDirectory(namespace), name+documentExtension))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, auditConstants.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to read report %s: %w", name, err)
+	}
+	return document, nil
+}
+
+// putDocument writes the document to a temporary file renamed over the
+// previous one, so the readers never see a partially written report.
+func (b *fileBackend) putDocument(_ context.Context, metadata documentMetadata, document []byte) error {
+	directory := b.namespaceDirectory(metadata.Namespace)
+	if err := os.MkdirAll(directory, 0o750); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", directory, err)
+	}
+
+	file, err := os.CreateTemp(directory, "."+metadata.Name+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(file.Name()) //nolint:errcheck // the file is already renamed on success
+
+	_, err = file.Write(append(document, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", file.Name(), err)
+	}
+	if err = os.Rename(file.Name(), filepath.Join(directory, metadata.Name+documentExtension)); err != nil {
+		return fmt.Errorf("failed to rename file %s: %w", file.Name(), err)
+	}
+	return nil
+}
+
+func (b *fileBackend) deleteDocuments(_ context.Context, namespace string, selected func(metadata documentMetadata) bool) error {
+	directory := b.namespaceDirectory(namespace)
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to list directory %s: %w", directory, err)
+	}
+
+	for _, entry := range entries {
+		name, isDocument := strings.CutSuffix(entry.Name(), documentExtension)
+		if entry.IsDir() || !isDocument || strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(directory, entry.Name())
+		document, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read report %s: %w", path, err)
+		}
+		metadata, err := decodeDocumentMetadata(document)
+		if err != nil {
+			return fmt.Errorf("failed to decode report %s: %w", path, err)
+		}
+		metadata.Namespace = namespace
+		metadata.Name = name
+		if !selected(metadata) {
+			continue
+		}
+		if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete report %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (b *fileBackend) Close() error {
+	return nil
+}
+
+// decodeDocumentMetadata returns the run UID and the shard key of an encoded report.
+func decodeDocumentMetadata(document []byte) (documentMetadata, error) {
+	var object struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(document)).Decode(&object); err != nil {
+		return documentMetadata{}, err //nolint:wrapcheck // the callers add the context
+	}
+	return documentMetadata{
+		RunUID:   object.Metadata.Labels[auditConstants.AuditScannerRunUIDLabel],
+		ShardKey: object.Metadata.Labels[auditConstants.AuditScannerShardKeyLabel],
+	}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 
 	"github.com/google/uuid"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/labels"
@@ -50,7 +51,7 @@ func DeleteAllLegacyPolicyReports(ctx context.Context, c client.Client, logger *
 		return fmt.Errorf("failed to list legacy ClusterPolicyReports: %w", err)
 	case len(clusterReportList.Items) > 0:
 		logger.InfoContext(ctx, "Deleting legacy wgpolicyk8s.io ClusterPolicyReports")
-		if err = store.DeleteOldClusterReports(ctx, ephemeralRunUID); err != nil {
+		if err = store.DeleteOldClusterReports(ctx, ephemeralRunUID, shard.Shard{}); err != nil {
 			return err
 		}
 	}
@@ -1,9 +1,11 @@
 package report
 
 import (
+	"slices"
 	"time"
 
 	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
 	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,7 +31,7 @@ func NewOpenReport(runUID string, resource unstructured.Unstructured) *OpenRepor
 			Summary: openreports.ReportSummary{
 				Pass:  0, // count of policies with requirements met
 				Fail:  0, // count of policies with requirements not met
-				Warn:  0, // not used for now
+				Warn:  0, // count of mutating policies that would change the resource
 				Error: 0, // count of policies that couldn't be evaluated
 				Skip:  0, // count of policies that were not selected for evaluation
 			},
@@ -43,7 +45,49 @@ func (r *OpenReport) AddResult(
 	errored bool,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newReportResult(policy, admissionReview, errored, now)
+	r.appendResult(newReportResult(policy, admissionReview, errored, now))
+}
+
+func (r *OpenReport) AddSkippedResult(policy policiesv1.Policy, reason string) {
+	now := metav1.Timestamp{Seconds: time.Now().Unix()}
+	r.appendResult(newNotEvaluatedReportResult(policy, statusSkip, reason, now))
+}
+
+func (r *OpenReport) AddErroredResult(policy policiesv1.Policy, reason string) {
+	now := metav1.Timestamp{Seconds: time.Now().Unix()}
+	r.appendResult(newNotEvaluatedReportResult(policy, statusError, reason, now))
+}
+
+func (r *OpenReport) ReuseResult(previous Report, policy policiesv1.Policy) bool {
+	previousReport, ok := previous.(*OpenReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	for _, result := range previousReport.report.Results {
+		if result.Policy != policy.GetUniqueName() {
+			continue
+		}
+		if isResultReusable(previousReport.report.Scope, r.report.Scope, string(result.Result), result.Properties, policy) {
+			r.appendResult(result)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *OpenReport) KeepResults(previous Report, keep func(policyName, policyNamespace string) bool) {
+	previousReport, ok := previous.(*OpenReport)
+	if !ok || previousReport == nil {
+		return
+	}
+	for _, result := range previousReport.report.Results {
+		if keep(result.Properties[propertyPolicyName], result.Properties[propertyPolicyNamespace]) {
+			r.appendResult(result)
+		}
+	}
+}
+
+func (r *OpenReport) appendResult(result openreports.ReportResult) {
 	switch result.Result {
 	case statusFail:
 		r.report.Summary.Fail++
@@ -51,16 +95,46 @@ func (r *OpenReport) AddResult(
 		r.report.Summary.Error++
 	case statusPass:
 		r.report.Summary.Pass++
+	case statusWarn:
+		r.report.Summary.Warn++
+	case statusSkip:
+		r.report.Summary.Skip++
 	}
 	r.report.Results = append(r.report.Results, result)
 }
 
-func (r *OpenReport) SetSkipPolicies(skippedPoliciesNumber int) {
-	r.report.Summary.Skip = skippedPoliciesNumber
+func (r *OpenReport) ResourceResults() ResourceResults {
+	resourceResults := newResourceResults(r.report.Scope, len(r.report.Results))
+	for _, result := range r.report.Results {
+		resourceResults.Results = append(resourceResults.Results, Result{
+			Policy:    result.Policy,
+			Result:    string(result.Result),
+			Severity:  string(result.Severity),
+			Category:  result.Category,
+			Message:   result.Description,
+			Timestamp: time.Unix(result.Timestamp.Seconds, int64(result.Timestamp.Nanos)).UTC(),
+		})
+	}
+	return resourceResults
+}
+
+// contentHash returns the hash of the content of the report, ignoring the
+// timestamps of the results.
+func (r *OpenReport) contentHash() (string, error) {
+	return contentHash(r.report.Scope, r.report.OwnerReferences, withoutReportTimestamps(r.report.Results))
+}
+
+func (r *OpenReport) SetShardKey(key string) {
+	r.report.Labels[constants.AuditScannerShardKeyLabel] = key
 }
 
-func (r *OpenReport) SetErrorPolicies(erroredPoliciesNumber int) {
-	r.report.Summary.Error = erroredPoliciesNumber
+// MarshalJSON encodes the underlying Report resource, including its type
+// information.
+func (r *OpenReport) MarshalJSON() ([]byte, error) {
+	report := r.report.DeepCopy()
+	report.APIVersion = openreports.GroupVersion.String()
+	report.Kind = "Report"
+	return marshalReport(report)
 }
 
 func (r *OpenClusterReport) AddResult(
@@ -69,7 +143,49 @@ func (r *OpenClusterReport) AddResult(
 	errored bool,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newReportResult(policy, admissionReview, errored, now)
+	r.appendResult(newReportResult(policy, admissionReview, errored, now))
+}
+
+func (r *OpenClusterReport) AddSkippedResult(policy policiesv1.Policy, reason string) {
+	now := metav1.Timestamp{Seconds: time.Now().Unix()}
+	r.appendResult(newNotEvaluatedReportResult(policy, statusSkip, reason, now))
+}
+
+func (r *OpenClusterReport) AddErroredResult(policy policiesv1.Policy, reason string) {
+	now := metav1.Timestamp{Seconds: time.Now().Unix()}
+	r.appendResult(newNotEvaluatedReportResult(policy, statusError, reason, now))
+}
+
+func (r *OpenClusterReport) ReuseResult(previous Report, policy policiesv1.Policy) bool {
+	previousReport, ok := previous.(*OpenClusterReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	for _, result := range previousReport.report.Results {
+		if result.Policy != policy.GetUniqueName() {
+			continue
+		}
+		if isResultReusable(previousReport.report.Scope, r.report.Scope, string(result.Result), result.Properties, policy) {
+			r.appendResult(result)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *OpenClusterReport) KeepResults(previous Report, keep func(policyName, policyNamespace string) bool) {
+	previousReport, ok := previous.(*OpenClusterReport)
+	if !ok || previousReport == nil {
+		return
+	}
+	for _, result := range previousReport.report.Results {
+		if keep(result.Properties[propertyPolicyName], result.Properties[propertyPolicyNamespace]) {
+			r.appendResult(result)
+		}
+	}
+}
+
+func (r *OpenClusterReport) appendResult(result openreports.ReportResult) {
 	switch result.Result {
 	case statusFail:
 		r.report.Summary.Fail++
@@ -77,16 +193,46 @@ func (r *OpenClusterReport) AddResult(
 		r.report.Summary.Error++
 	case statusPass:
 		r.report.Summary.Pass++
+	case statusWarn:
+		r.report.Summary.Warn++
+	case statusSkip:
+		r.report.Summary.Skip++
 	}
 	r.report.Results = append(r.report.Results, result)
 }
 
-func (r *OpenClusterReport) SetSkipPolicies(skippedPoliciesNumber int) {
-	r.report.Summary.Skip = skippedPoliciesNumber
+func (r *OpenClusterReport) ResourceResults() ResourceResults {
+	resourceResults := newResourceResults(r.report.Scope, len(r.report.Results))
+	for _, result := range r.report.Results {
+		resourceResults.Results = append(resourceResults.Results, Result{
+			Policy:    result.Policy,
+			Result:    string(result.Result),
+			Severity:  string(result.Severity),
+			Category:  result.Category,
+			Message:   result.Description,
+			Timestamp: time.Unix(result.Timestamp.Seconds, int64(result.Timestamp.Nanos)).UTC(),
+		})
+	}
+	return resourceResults
+}
+
+// contentHash returns the hash of the content of the report, ignoring the
+// timestamps of the results.
+func (r *OpenClusterReport) contentHash() (string, error) {
+	return contentHash(r.report.Scope, r.report.OwnerReferences, withoutReportTimestamps(r.report.Results))
+}
+
+func (r *OpenClusterReport) SetShardKey(key string) {
+	r.report.Labels[constants.AuditScannerShardKeyLabel] = key
 }
 
-func (r *OpenClusterReport) SetErrorPolicies(erroredPoliciesNumber int) {
-	r.report.Summary.Error = erroredPoliciesNumber
+// MarshalJSON encodes the underlying ClusterReport resource, including its type
+// information.
+func (r *OpenClusterReport) MarshalJSON() ([]byte, error) {
+	report := r.report.DeepCopy()
+	report.APIVersion = openreports.GroupVersion.String()
+	report.Kind = "ClusterReport"
+	return marshalReport(report)
 }
 
 // NewClusterOpenReport creates a new ClusterPolicyReport from a given resource.
@@ -98,7 +244,7 @@ func NewClusterOpenReport(runUID string, resource unstructured.Unstructured) *Op
 			Summary: openreports.ReportSummary{
 				Pass:  0, // count of policies with requirements met
 				Fail:  0, // count of policies with requirements not met
-				Warn:  0, // not used for now
+				Warn:  0, // count of mutating policies that would change the resource
 				Error: 0, // count of policies that couldn't be evaluated
 				Skip:  0, // count of policies that were not selected for evaluation
 			},
@@ -113,13 +259,40 @@ func newReportResult(policy policiesv1.Policy, admissionReview *admissionv1.Admi
 		Source:           policyReportSource,
 		Policy:           policy.GetUniqueName(),
 		Category:         category,
-		Severity:         openreports.ResultSeverity(computePolicyResultSeverity(policy)),   // either info for monitor or empty
-		Timestamp:        timestamp,                                                         // time the result was computed
-		Result:           openreports.Result(computePolicyResult(errored, admissionReview)), // pass, fail, error
+		Severity:         openreports.ResultSeverity(computePolicyResultSeverity(policy)),           // either info for monitor or empty
+		Timestamp:        timestamp,                                                                 // time the result was computed
+		Result:           openreports.Result(computePolicyResult(policy, errored, admissionReview)), // pass, fail, warn, error
 		Scored:           true,
 		ResourceSelector: &metav1.LabelSelector{},
 		// This field is marshalled to `message`
 		Description: message,
-		Properties:  computeProperties(policy),
+		Properties:  computeProperties(policy, admissionReview),
+	}
+}
+
+func newNotEvaluatedReportResult(policy policiesv1.Policy, status, reason string, timestamp metav1.Timestamp) openreports.ReportResult {
+	category, _ := getCategoryAndMessage(policy, nil)
+
+	return openreports.ReportResult{
+		Source:           policyReportSource,
+		Policy:           policy.GetUniqueName(),
+		Category:         category,
+		Severity:         openreports.ResultSeverity(computePolicyResultSeverity(policy)),
+		Timestamp:        timestamp,
+		Result:           openreports.Result(status),
+		Scored:           true,
+		ResourceSelector: &metav1.LabelSelector{},
+		// This field is marshalled to `message`
+		Description: reason,
+		Properties:  computeProperties(policy, nil),
+	}
+}
+
+// withoutReportTimestamps returns a copy of the results with their timestamps cleared.
+func withoutReportTimestamps(results []openreports.ReportResult) []openreports.ReportResult {
+	cleared := slices.Clone(results)
+	for i := range cleared {
+		cleared[i].Timestamp = metav1.Timestamp{}
 	}
+	return cleared
 }
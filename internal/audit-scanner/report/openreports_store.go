@@ -6,9 +6,12 @@ import (
 	"log/slog"
 
 	auditConstants "github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
 	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -29,6 +32,32 @@ func NewOpenReportStore(client client.Client, logger *slog.Logger) Store {
 	}
 }
 
+// GetReport returns the Report of the namespaced resource with the given UID.
+func (s *OpenReportStore) GetReport(ctx context.Context, namespace string, resourceUID types.UID) (Report, error) {
+	report := &openreports.Report{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: string(resourceUID), Namespace: namespace}, report); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, auditConstants.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to get Report %s/%s: %w", namespace, resourceUID, err)
+	}
+
+	return &OpenReport{report: report}, nil
+}
+
+// GetClusterReport returns the ClusterReport of the cluster-wide resource with the given UID.
+func (s *OpenReportStore) GetClusterReport(ctx context.Context, resourceUID types.UID) (Report, error) {
+	report := &openreports.ClusterReport{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: string(resourceUID)}, report); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, auditConstants.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to get ClusterReport %s: %w", resourceUID, err)
+	}
+
+	return &OpenClusterReport{report: report}, nil
+}
+
 // CreateOrPatchReport creates or patches a OpenReports Report.
 func (s *OpenReportStore) CreateOrPatchReport(ctx context.Context, obj any) error {
 	openReport, ok := obj.(*OpenReport)
@@ -41,8 +70,18 @@ func (s *OpenReportStore) CreateOrPatchReport(ctx context.Context, obj any) erro
 		Namespace: policyReport.GetNamespace(),
 	}}
 
+	hash, err := openReport.contentHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash policy report %s: %w", policyReport.GetName(), err)
+	}
+
 	operation, err := controllerutil.CreateOrPatch(ctx, s.client, oldPolicyReport, func() error {
 		oldPolicyReport.ObjectMeta.Labels = policyReport.ObjectMeta.Labels
+		// The results are not rewritten when only the labels changed, e.g. the RunUID
+		if oldPolicyReport.GetAnnotations()[annotationContentHash] == hash {
+			return nil
+		}
+		metav1.SetMetaDataAnnotation(&oldPolicyReport.ObjectMeta, annotationContentHash, hash)
 		oldPolicyReport.ObjectMeta.OwnerReferences = policyReport.ObjectMeta.OwnerReferences
 		oldPolicyReport.Scope = policyReport.Scope
 		oldPolicyReport.Summary = policyReport.Summary
@@ -81,6 +120,15 @@ func (s *OpenReportStore) DeleteOldReports(ctx context.Context, scanRunID, names
 	return nil
 }
 
+// DeleteReport deletes the OpenReports Report of the namespaced resource with the given UID, if any.
+func (s *OpenReportStore) DeleteReport(ctx context.Context, namespace string, resourceUID types.UID) error {
+	report := &openreports.Report{ObjectMeta: metav1.ObjectMeta{Name: string(resourceUID), Namespace: namespace}}
+	if err := s.client.Delete(ctx, report); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete Report %s/%s: %w", namespace, resourceUID, err)
+	}
+	return nil
+}
+
 // CreateOrPatchClusterReport creates or patches a OpenReports ClusterReport.
 //
 //nolint:dupl // Temporary duplicated code with policyreports_store.go, it's planned to be the only implementation in the future.
@@ -94,8 +142,18 @@ func (s *OpenReportStore) CreateOrPatchClusterReport(ctx context.Context, obj an
 		Name: clusterPolicyReport.GetName(),
 	}}
 
+	hash, err := openReport.contentHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash cluster policy report %s: %w", clusterPolicyReport.GetName(), err)
+	}
+
 	operation, err := controllerutil.CreateOrPatch(ctx, s.client, oldClusterPolicyReport, func() error {
 		oldClusterPolicyReport.ObjectMeta.Labels = clusterPolicyReport.ObjectMeta.Labels
+		// The results are not rewritten when only the labels changed, e.g. the RunUID
+		if oldClusterPolicyReport.GetAnnotations()[annotationContentHash] == hash {
+			return nil
+		}
+		metav1.SetMetaDataAnnotation(&oldClusterPolicyReport.ObjectMeta, annotationContentHash, hash)
 		oldClusterPolicyReport.ObjectMeta.OwnerReferences = clusterPolicyReport.ObjectMeta.OwnerReferences
 		oldClusterPolicyReport.Scope = clusterPolicyReport.Scope
 		oldClusterPolicyReport.Summary = clusterPolicyReport.Summary
@@ -117,18 +175,45 @@ func (s *OpenReportStore) CreateOrPatchClusterReport(ctx context.Context, obj an
 	return nil
 }
 
+// DeleteClusterReport deletes the OpenReports ClusterReport of the cluster-wide resource with the given UID, if any.
+func (s *OpenReportStore) DeleteClusterReport(ctx context.Context, resourceUID types.UID) error {
+	report := &openreports.ClusterReport{ObjectMeta: metav1.ObjectMeta{Name: string(resourceUID)}}
+	if err := s.client.Delete(ctx, report); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ClusterReport %s: %w", resourceUID, err)
+	}
+	return nil
+}
+
 // DeleteOldClusterReports deletes all the OpenReports ClusterReports that do not belong to the current scan run.
-func (s *OpenReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
+// When the audit is sharded, only the reports of the resources owned by the shard are deleted.
+func (s *OpenReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID string, auditShard shard.Shard) error {
 	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
 	if err != nil {
 		return fmt.Errorf("failed to parse label selector: %w", err)
 	}
 	s.logger.DebugContext(ctx, "Deleting old ClusterPolicyReports", slog.String("labelSelector", labelSelector.String()))
 
-	if deleteErr := s.client.DeleteAllOf(ctx, &openreports.ClusterReport{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
-		LabelSelector: labelSelector,
-	}}); deleteErr != nil {
-		return fmt.Errorf("failed to delete ClusterPolicyReports: %w", deleteErr)
+	if !auditShard.Enabled() {
+		if deleteErr := s.client.DeleteAllOf(ctx, &openreports.ClusterReport{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
+			LabelSelector: labelSelector,
+		}}); deleteErr != nil {
+			return fmt.Errorf("failed to delete ClusterPolicyReports: %w", deleteErr)
+		}
+		return nil
+	}
+
+	// The shard keys are not known in advance, the reports are filtered one by one
+	reportList := &openreports.ClusterReportList{}
+	if err = s.client.List(ctx, reportList, &client.ListOptions{LabelSelector: labelSelector}); err != nil {
+		return fmt.Errorf("failed to list ClusterReports: %w", err)
+	}
+	for _, report := range reportList.Items {
+		if !auditShard.OwnsKey(report.GetLabels()[auditConstants.AuditScannerShardKeyLabel]) {
+			continue
+		}
+		if err = s.client.Delete(ctx, &report); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ClusterPolicyReports: %w", err)
+		}
 	}
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -45,7 +46,7 @@ func NewPolicyReport(runUID string, resource unstructured.Unstructured) *PolicyR
 			Summary: wgpolicy.PolicyReportSummary{
 				Pass:  0, // count of policies with requirements met
 				Fail:  0, // count of policies with requirements not met
-				Warn:  0, // not used for now
+				Warn:  0, // count of mutating policies that would change the resource
 				Error: 0, // count of policies that couldn't be evaluated
 				Skip:  0, // count of policies that were not selected for evaluation
 			},
@@ -59,7 +60,49 @@ func (r *PolicyReport) AddResult(
 	errored bool,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newPolicyReportResult(policy, admissionReview, errored, now)
+	r.appendResult(newPolicyReportResult(policy, admissionReview, errored, now))
+}
+
+func (r *PolicyReport) AddSkippedResult(policy policiesv1.Policy, reason string) {
+	now := metav1.Timestamp{Seconds: time.Now().Unix()}
+	r.appendResult(newNotEvaluatedPolicyReportResult(policy, statusSkip, reason, now))
+}
+
+func (r *PolicyReport) AddErroredResult(policy policiesv1.Policy, reason string) {
+	now := metav1.Timestamp{Seconds: time.Now().Unix()}
+	r.appendResult(newNotEvaluatedPolicyReportResult(policy, statusError, reason, now))
+}
+
+func (r *PolicyReport) ReuseResult(previous Report, policy policiesv1.Policy) bool {
+	previousReport, ok := previous.(*PolicyReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	for _, result := range previousReport.report.Results {
+		if result.Policy != policy.GetUniqueName() {
+			continue
+		}
+		if isResultReusable(previousReport.report.Scope, r.report.Scope, string(result.Result), result.Properties, policy) {
+			r.appendResult(result)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PolicyReport) KeepResults(previous Report, keep func(policyName, policyNamespace string) bool) {
+	previousReport, ok := previous.(*PolicyReport)
+	if !ok || previousReport == nil {
+		return
+	}
+	for _, result := range previousReport.report.Results {
+		if keep(result.Properties[propertyPolicyName], result.Properties[propertyPolicyNamespace]) {
+			r.appendResult(result)
+		}
+	}
+}
+
+func (r *PolicyReport) appendResult(result *wgpolicy.PolicyReportResult) {
 	switch result.Result {
 	case statusFail:
 		r.report.Summary.Fail++
@@ -67,16 +110,46 @@ func (r *PolicyReport) AddResult(
 		r.report.Summary.Error++
 	case statusPass:
 		r.report.Summary.Pass++
+	case statusWarn:
+		r.report.Summary.Warn++
+	case statusSkip:
+		r.report.Summary.Skip++
 	}
 	r.report.Results = append(r.report.Results, result)
 }
 
-func (r *PolicyReport) SetSkipPolicies(skippedPoliciesNumber int) {
-	r.report.Summary.Skip = skippedPoliciesNumber
+func (r *PolicyReport) ResourceResults() ResourceResults {
+	resourceResults := newResourceResults(r.report.Scope, len(r.report.Results))
+	for _, result := range r.report.Results {
+		resourceResults.Results = append(resourceResults.Results, Result{
+			Policy:    result.Policy,
+			Result:    string(result.Result),
+			Severity:  string(result.Severity),
+			Category:  result.Category,
+			Message:   result.Description,
+			Timestamp: time.Unix(result.Timestamp.Seconds, int64(result.Timestamp.Nanos)).UTC(),
+		})
+	}
+	return resourceResults
+}
+
+// contentHash returns the hash of the content of the report, ignoring the
+// timestamps of the results.
+func (r *PolicyReport) contentHash() (string, error) {
+	return contentHash(r.report.Scope, r.report.OwnerReferences, withoutPolicyReportTimestamps(r.report.Results))
+}
+
+func (r *PolicyReport) SetShardKey(key string) {
+	r.report.Labels[constants.AuditScannerShardKeyLabel] = key
 }
 
-func (r *PolicyReport) SetErrorPolicies(erroredPoliciesNumber int) {
-	r.report.Summary.Error = erroredPoliciesNumber
+// MarshalJSON encodes the underlying PolicyReport resource, including its type
+// information.
+func (r *PolicyReport) MarshalJSON() ([]byte, error) {
+	report := r.report.DeepCopy()
+	report.APIVersion = wgpolicy.SchemeGroupVersion.String()
+	report.Kind = "PolicyReport"
+	return marshalReport(report)
 }
 
 // NewClusterPolicyReport creates a new ClusterPolicyReport from a given resource.
@@ -90,7 +163,7 @@ func NewClusterPolicyReport(runUID string, resource unstructured.Unstructured) *
 			Summary: wgpolicy.PolicyReportSummary{
 				Pass:  0, // count of policies with requirements met
 				Fail:  0, // count of policies with requirements not met
-				Warn:  0, // not used for now
+				Warn:  0, // count of mutating policies that would change the resource
 				Error: 0, // count of policies that couldn't be evaluated
 				Skip:  0, // count of policies that were not selected for evaluation
 			},
@@ -104,7 +177,49 @@ func (r *ClusterPolicyReport) AddResult(
 	errored bool,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newPolicyReportResult(policy, admissionReview, errored, now)
+	r.appendResult(newPolicyReportResult(policy, admissionReview, errored, now))
+}
+
+func (r *ClusterPolicyReport) AddSkippedResult(policy policiesv1.Policy, reason string) {
+	now := metav1.Timestamp{Seconds: time.Now().Unix()}
+	r.appendResult(newNotEvaluatedPolicyReportResult(policy, statusSkip, reason, now))
+}
+
+func (r *ClusterPolicyReport) AddErroredResult(policy policiesv1.Policy, reason string) {
+	now := metav1.Timestamp{Seconds: time.Now().Unix()}
+	r.appendResult(newNotEvaluatedPolicyReportResult(policy, statusError, reason, now))
+}
+
+func (r *ClusterPolicyReport) ReuseResult(previous Report, policy policiesv1.Policy) bool {
+	previousReport, ok := previous.(*ClusterPolicyReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	for _, result := range previousReport.report.Results {
+		if result.Policy != policy.GetUniqueName() {
+			continue
+		}
+		if isResultReusable(previousReport.report.Scope, r.report.Scope, string(result.Result), result.Properties, policy) {
+			r.appendResult(result)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ClusterPolicyReport) KeepResults(previous Report, keep func(policyName, policyNamespace string) bool) {
+	previousReport, ok := previous.(*ClusterPolicyReport)
+	if !ok || previousReport == nil {
+		return
+	}
+	for _, result := range previousReport.report.Results {
+		if keep(result.Properties[propertyPolicyName], result.Properties[propertyPolicyNamespace]) {
+			r.appendResult(result)
+		}
+	}
+}
+
+func (r *ClusterPolicyReport) appendResult(result *wgpolicy.PolicyReportResult) {
 	switch result.Result {
 	case statusFail:
 		r.report.Summary.Fail++
@@ -112,16 +227,46 @@ func (r *ClusterPolicyReport) AddResult(
 		r.report.Summary.Error++
 	case statusPass:
 		r.report.Summary.Pass++
+	case statusWarn:
+		r.report.Summary.Warn++
+	case statusSkip:
+		r.report.Summary.Skip++
 	}
 	r.report.Results = append(r.report.Results, result)
 }
 
-func (r *ClusterPolicyReport) SetSkipPolicies(skippedPoliciesNumber int) {
-	r.report.Summary.Skip = skippedPoliciesNumber
+func (r *ClusterPolicyReport) ResourceResults() ResourceResults {
+	resourceResults := newResourceResults(r.report.Scope, len(r.report.Results))
+	for _, result := range r.report.Results {
+		resourceResults.Results = append(resourceResults.Results, Result{
+			Policy:    result.Policy,
+			Result:    string(result.Result),
+			Severity:  string(result.Severity),
+			Category:  result.Category,
+			Message:   result.Description,
+			Timestamp: time.Unix(result.Timestamp.Seconds, int64(result.Timestamp.Nanos)).UTC(),
+		})
+	}
+	return resourceResults
+}
+
+// contentHash returns the hash of the content of the report, ignoring the
+// timestamps of the results.
+func (r *ClusterPolicyReport) contentHash() (string, error) {
+	return contentHash(r.report.Scope, r.report.OwnerReferences, withoutPolicyReportTimestamps(r.report.Results))
+}
+
+func (r *ClusterPolicyReport) SetShardKey(key string) {
+	r.report.Labels[constants.AuditScannerShardKeyLabel] = key
 }
 
-func (r *ClusterPolicyReport) SetErrorPolicies(erroredPoliciesNumber int) {
-	r.report.Summary.Error = erroredPoliciesNumber
+// MarshalJSON encodes the underlying ClusterPolicyReport resource, including its type
+// information.
+func (r *ClusterPolicyReport) MarshalJSON() ([]byte, error) {
+	report := r.report.DeepCopy()
+	report.APIVersion = wgpolicy.SchemeGroupVersion.String()
+	report.Kind = "ClusterPolicyReport"
+	return marshalReport(report)
 }
 
 func newPolicyReportResult(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview, errored bool, timestamp metav1.Timestamp) *wgpolicy.PolicyReportResult {
@@ -131,13 +276,42 @@ func newPolicyReportResult(policy policiesv1.Policy, admissionReview *admissionv
 		Source:          policyReportSource,
 		Policy:          policy.GetUniqueName(),
 		Category:        category,
-		Severity:        wgpolicy.PolicyResultSeverity(computePolicyResultSeverity(policy)),   // either info for monitor or empty
-		Timestamp:       timestamp,                                                            // time the result was computed
-		Result:          wgpolicy.PolicyResult(computePolicyResult(errored, admissionReview)), // pass, fail, error
+		Severity:        wgpolicy.PolicyResultSeverity(computePolicyResultSeverity(policy)),           // either info for monitor or empty
+		Timestamp:       timestamp,                                                                    // time the result was computed
+		Result:          wgpolicy.PolicyResult(computePolicyResult(policy, errored, admissionReview)), // pass, fail, warn, error
 		Scored:          true,
 		SubjectSelector: &metav1.LabelSelector{},
 		// This field is marshalled to `message`
 		Description: message,
-		Properties:  computeProperties(policy),
+		Properties:  computeProperties(policy, admissionReview),
+	}
+}
+
+func newNotEvaluatedPolicyReportResult(policy policiesv1.Policy, status, reason string, timestamp metav1.Timestamp) *wgpolicy.PolicyReportResult {
+	category, _ := getCategoryAndMessage(policy, nil)
+
+	return &wgpolicy.PolicyReportResult{
+		Source:          policyReportSource,
+		Policy:          policy.GetUniqueName(),
+		Category:        category,
+		Severity:        wgpolicy.PolicyResultSeverity(computePolicyResultSeverity(policy)),
+		Timestamp:       timestamp,
+		Result:          wgpolicy.PolicyResult(status),
+		Scored:          true,
+		SubjectSelector: &metav1.LabelSelector{},
+		// This field is marshalled to `message`
+		Description: reason,
+		Properties:  computeProperties(policy, nil),
+	}
+}
+
+// withoutPolicyReportTimestamps returns a copy of the results with their timestamps cleared.
+func withoutPolicyReportTimestamps(results []*wgpolicy.PolicyReportResult) []wgpolicy.PolicyReportResult {
+	cleared := make([]wgpolicy.PolicyReportResult, 0, len(results))
+	for _, result := range results {
+		clearedResult := *result
+		clearedResult.Timestamp = metav1.Timestamp{}
+		cleared = append(cleared, clearedResult)
 	}
+	return cleared
 }
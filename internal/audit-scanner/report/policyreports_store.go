@@ -6,8 +6,11 @@ import (
 	"log/slog"
 
 	auditConstants "github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
@@ -31,6 +34,32 @@ func NewPolicyReportStore(client client.Client, logger *slog.Logger) *PolicyRepo
 	}
 }
 
+// GetReport returns the PolicyReport of the namespaced resource with the given UID.
+func (s *PolicyReportStore) GetReport(ctx context.Context, namespace string, resourceUID types.UID) (Report, error) {
+	report := &wgpolicy.PolicyReport{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: string(resourceUID), Namespace: namespace}, report); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, auditConstants.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to get PolicyReport %s/%s: %w", namespace, resourceUID, err)
+	}
+
+	return &PolicyReport{report: report}, nil
+}
+
+// GetClusterReport returns the ClusterPolicyReport of the cluster-wide resource with the given UID.
+func (s *PolicyReportStore) GetClusterReport(ctx context.Context, resourceUID types.UID) (Report, error) {
+	report := &wgpolicy.ClusterPolicyReport{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: string(resourceUID)}, report); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, auditConstants.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to get ClusterPolicyReport %s: %w", resourceUID, err)
+	}
+
+	return &ClusterPolicyReport{report: report}, nil
+}
+
 // CreateOrPatchReport creates or patches a PolicyReport.
 func (s *PolicyReportStore) CreateOrPatchReport(ctx context.Context, obj any) error {
 	report, ok := obj.(*PolicyReport)
@@ -43,8 +72,18 @@ func (s *PolicyReportStore) CreateOrPatchReport(ctx context.Context, obj any) er
 		Namespace: policyReport.GetNamespace(),
 	}}
 
+	hash, err := report.contentHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash policy report %s: %w", policyReport.GetName(), err)
+	}
+
 	operation, err := controllerutil.CreateOrPatch(ctx, s.client, oldPolicyReport, func() error {
 		oldPolicyReport.ObjectMeta.Labels = policyReport.ObjectMeta.Labels
+		// The results are not rewritten when only the labels changed, e.g. the RunUID
+		if oldPolicyReport.GetAnnotations()[annotationContentHash] == hash {
+			return nil
+		}
+		metav1.SetMetaDataAnnotation(&oldPolicyReport.ObjectMeta, annotationContentHash, hash)
 		oldPolicyReport.ObjectMeta.OwnerReferences = policyReport.ObjectMeta.OwnerReferences
 		oldPolicyReport.Scope = policyReport.Scope
 		oldPolicyReport.Summary = policyReport.Summary
@@ -83,6 +122,15 @@ func (s *PolicyReportStore) DeleteOldReports(ctx context.Context, scanRunID, nam
 	return nil
 }
 
+// DeleteReport deletes the PolicyReport of the namespaced resource with the given UID, if any.
+func (s *PolicyReportStore) DeleteReport(ctx context.Context, namespace string, resourceUID types.UID) error {
+	report := &wgpolicy.PolicyReport{ObjectMeta: metav1.ObjectMeta{Name: string(resourceUID), Namespace: namespace}}
+	if err := s.client.Delete(ctx, report); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete PolicyReport %s/%s: %w", namespace, resourceUID, err)
+	}
+	return nil
+}
+
 // CreateOrPatchClusterReport creates or patches a ClusterPolicyReport.
 //
 //nolint:dupl // Temporary duplicated code with openreports_store.go, it's planned to be removed in the near future.
@@ -96,8 +144,18 @@ func (s *PolicyReportStore) CreateOrPatchClusterReport(ctx context.Context, obj
 		Name: clusterPolicyReport.GetName(),
 	}}
 
+	hash, err := report.contentHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash cluster policy report %s: %w", clusterPolicyReport.GetName(), err)
+	}
+
 	operation, err := controllerutil.CreateOrPatch(ctx, s.client, oldClusterPolicyReport, func() error {
 		oldClusterPolicyReport.ObjectMeta.Labels = clusterPolicyReport.ObjectMeta.Labels
+		// The results are not rewritten when only the labels changed, e.g. the RunUID
+		if oldClusterPolicyReport.GetAnnotations()[annotationContentHash] == hash {
+			return nil
+		}
+		metav1.SetMetaDataAnnotation(&oldClusterPolicyReport.ObjectMeta, annotationContentHash, hash)
 		oldClusterPolicyReport.ObjectMeta.OwnerReferences = clusterPolicyReport.ObjectMeta.OwnerReferences
 		oldClusterPolicyReport.Scope = clusterPolicyReport.Scope
 		oldClusterPolicyReport.Summary = clusterPolicyReport.Summary
@@ -119,18 +177,45 @@ func (s *PolicyReportStore) CreateOrPatchClusterReport(ctx context.Context, obj
 	return nil
 }
 
+// DeleteClusterReport deletes the ClusterPolicyReport of the cluster-wide resource with the given UID, if any.
+func (s *PolicyReportStore) DeleteClusterReport(ctx context.Context, resourceUID types.UID) error {
+	report := &wgpolicy.ClusterPolicyReport{ObjectMeta: metav1.ObjectMeta{Name: string(resourceUID)}}
+	if err := s.client.Delete(ctx, report); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ClusterPolicyReport %s: %w", resourceUID, err)
+	}
+	return nil
+}
+
 // DeleteOldClusterReports deletes old ClusterPolicyReports that do not belong to the current scan run.
-func (s *PolicyReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
+// When the audit is sharded, only the reports of the resources owned by the shard are deleted.
+func (s *PolicyReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID string, auditShard shard.Shard) error {
 	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
 	if err != nil {
 		return fmt.Errorf("failed to parse label selector: %w", err)
 	}
 	s.logger.DebugContext(ctx, "Deleting old ClusterPolicyReports", slog.String("labelSelector", labelSelector.String()))
 
-	if deleteErr := s.client.DeleteAllOf(ctx, &wgpolicy.ClusterPolicyReport{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
-		LabelSelector: labelSelector,
-	}}); deleteErr != nil {
-		return fmt.Errorf("failed to delete ClusterPolicyReports: %w", deleteErr)
+	if !auditShard.Enabled() {
+		if deleteErr := s.client.DeleteAllOf(ctx, &wgpolicy.ClusterPolicyReport{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
+			LabelSelector: labelSelector,
+		}}); deleteErr != nil {
+			return fmt.Errorf("failed to delete ClusterPolicyReports: %w", deleteErr)
+		}
+		return nil
+	}
+
+	// The shard keys are not known in advance, the reports are filtered one by one
+	reportList := &wgpolicy.ClusterPolicyReportList{}
+	if err = s.client.List(ctx, reportList, &client.ListOptions{LabelSelector: labelSelector}); err != nil {
+		return fmt.Errorf("failed to list ClusterPolicyReports: %w", err)
+	}
+	for _, report := range reportList.Items {
+		if !auditShard.OwnsKey(report.GetLabels()[auditConstants.AuditScannerShardKeyLabel]) {
+			continue
+		}
+		if err = s.client.Delete(ctx, &report); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ClusterPolicyReports: %w", err)
+		}
 	}
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 
 	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
 	auditConstants "github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
 	testutils "github.com/kubewarden/adm-controller/internal/audit-scanner/testutils"
 	"github.com/stretchr/testify/require"
 	admissionv1 "k8s.io/api/admission/v1"
@@ -225,7 +226,7 @@ func TestDeleteClusterPolicyReport(t *testing.T) {
 	logger := slog.Default()
 	store := NewPolicyReportStore(fakeClient, logger)
 
-	err = store.DeleteOldClusterReports(t.Context(), "new-uid")
+	err = store.DeleteOldClusterReports(t.Context(), "new-uid", shard.Shard{})
 	require.NoError(t, err)
 
 	storedPolicyReportList := &wgpolicy.ClusterPolicyReportList{}
@@ -245,3 +246,128 @@ func TestDeleteClusterPolicyReport(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, storedPolicyReportList.Items, 1)
 }
+
+func TestGetPolicyReport(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	logger := slog.Default()
+	store := NewPolicyReportStore(fakeClient, logger)
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetName("test-pod")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetResourceVersion("12345")
+
+	_, err = store.GetReport(t.Context(), "namespace", "uid")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	policyReport := NewPolicyReport("runUID", resource)
+	err = store.CreateOrPatchReport(t.Context(), policyReport)
+	require.NoError(t, err)
+
+	storedReport, err := store.GetReport(t.Context(), "namespace", "uid")
+	require.NoError(t, err)
+	storedPolicyReport, ok := storedReport.(*PolicyReport)
+	require.True(t, ok)
+	require.Equal(t, policyReport.report.Scope, storedPolicyReport.report.Scope)
+	require.Equal(t, policyReport.report.Results, storedPolicyReport.report.Results)
+
+	require.NoError(t, store.DeleteReport(t.Context(), "namespace", "uid"))
+	_, err = store.GetReport(t.Context(), "namespace", "uid")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+	require.NoError(t, store.DeleteReport(t.Context(), "namespace", "uid"))
+}
+
+func TestGetClusterPolicyReport(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	logger := slog.Default()
+	store := NewPolicyReportStore(fakeClient, logger)
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetName("test-namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Namespace")
+	resource.SetResourceVersion("12345")
+
+	_, err = store.GetClusterReport(t.Context(), "uid")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	clusterPolicyReport := NewClusterPolicyReport("runUID", resource)
+	err = store.CreateOrPatchClusterReport(t.Context(), clusterPolicyReport)
+	require.NoError(t, err)
+
+	storedReport, err := store.GetClusterReport(t.Context(), "uid")
+	require.NoError(t, err)
+	storedClusterPolicyReport, ok := storedReport.(*ClusterPolicyReport)
+	require.True(t, ok)
+	require.Equal(t, clusterPolicyReport.report.Scope, storedClusterPolicyReport.report.Scope)
+	require.Equal(t, clusterPolicyReport.report.Results, storedClusterPolicyReport.report.Results)
+
+	require.NoError(t, store.DeleteClusterReport(t.Context(), "uid"))
+	_, err = store.GetClusterReport(t.Context(), "uid")
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+	require.NoError(t, store.DeleteClusterReport(t.Context(), "uid"))
+}
+
+func TestPatchUnchangedPolicyReport(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	logger := slog.Default()
+	store := NewPolicyReportStore(fakeClient, logger)
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetName("test-pod")
+	resource.SetNamespace("test-namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetResourceVersion("12345")
+
+	policy := &policiesv1.AdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:             "policy-uid",
+			ResourceVersion: "1",
+			Name:            "policy-name",
+			Namespace:       "test-namespace",
+		},
+	}
+	newReport := func(runUID string, allowed bool) *PolicyReport {
+		policyReport := NewPolicyReport(runUID, resource)
+		policyReport.AddResult(policy, &admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{Allowed: allowed},
+		}, false)
+		return policyReport
+	}
+
+	policyReport := newReport("runUID", true)
+	policyReport.report.Results[0].Timestamp = metav1.Timestamp{Seconds: 1}
+	err = store.CreateOrPatchReport(t.Context(), policyReport)
+	require.NoError(t, err)
+
+	// Only the labels are updated when the results did not change
+	unchangedReport := newReport("other-runUID", true)
+	err = store.CreateOrPatchReport(t.Context(), unchangedReport)
+	require.NoError(t, err)
+
+	storedPolicyReport := &wgpolicy.PolicyReport{}
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: policyReport.report.GetName(), Namespace: policyReport.report.GetNamespace()}, storedPolicyReport)
+	require.NoError(t, err)
+	require.Equal(t, "other-runUID", storedPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+	require.Equal(t, policyReport.report.Results, storedPolicyReport.Results)
+
+	// The results are rewritten when they changed
+	changedReport := newReport("changed-runUID", false)
+	err = store.CreateOrPatchReport(t.Context(), changedReport)
+	require.NoError(t, err)
+
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: policyReport.report.GetName(), Namespace: policyReport.report.GetNamespace()}, storedPolicyReport)
+	require.NoError(t, err)
+	require.Equal(t, "changed-runUID", storedPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+	require.Equal(t, changedReport.report.Summary, storedPolicyReport.Summary)
+	require.Equal(t, changedReport.report.Results, storedPolicyReport.Results)
+}
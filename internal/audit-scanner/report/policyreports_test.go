@@ -1,12 +1,14 @@
 package report
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -119,6 +121,79 @@ func TestAddResultToPolicyReport(t *testing.T) {
 	}
 }
 
+func TestAddMutationResultToPolicyReport(t *testing.T) {
+	policy := &policiesv1.AdmissionPolicy{
+		Spec: policiesv1.AdmissionPolicySpec{
+			PolicySpec: policiesv1.PolicySpec{
+				Mutating: true,
+			},
+		},
+	}
+	policyReport := NewPolicyReport("runUID", unstructured.Unstructured{})
+
+	// no patch, the resource already complies with the mutation
+	policyReport.AddResult(policy, &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: true},
+	}, false)
+	// the patch is not a valid JSONPatch, it's stored as is
+	policyReport.AddResult(policy, &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: true, Patch: []byte("not a patch")},
+	}, false)
+
+	require.Len(t, policyReport.report.Results, 2)
+	assert.Equal(t, 1, policyReport.report.Summary.Pass)
+	assert.Equal(t, 1, policyReport.report.Summary.Warn)
+	assert.Equal(t, wgpolicy.PolicyResult(statusWarn), policyReport.report.Results[1].Result)
+	assert.Equal(t, "not a patch", policyReport.report.Results[1].Properties[propertyPatch])
+	assert.NotContains(t, policyReport.report.Results[1].Properties, propertyPatchOperations)
+}
+
+func TestAddPolicyGroupResultToPolicyReport(t *testing.T) {
+	policy := &policiesv1.ClusterAdmissionPolicyGroup{
+		Spec: policiesv1.ClusterAdmissionPolicyGroupSpec{
+			ClusterPolicyGroupSpec: policiesv1.ClusterPolicyGroupSpec{
+				Policies: policiesv1.PolicyGroupMembersWithContext{
+					"signed_by_alice": {},
+					"signed_by_bob":   {},
+					"trusted_repo":    {},
+				},
+			},
+		},
+	}
+	policyReport := NewPolicyReport("runUID", unstructured.Unstructured{})
+
+	policyReport.AddResult(policy, &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: "the image must be signed and come from a trusted repository",
+				Details: &metav1.StatusDetails{
+					Causes: []metav1.StatusCause{
+						{Field: "spec.policies.trusted_repo", Message: "not a trusted repository"},
+						{Field: "spec.policies.signed_by_alice", Message: "not signed by alice"},
+						// not a member of the group
+						{Field: "spec.policies.unknown", Message: "ignored"},
+						{Field: "spec.containers", Message: "ignored"},
+					},
+				},
+			},
+		},
+	}, false)
+	policyReport.AddResult(policy, &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: true},
+	}, false)
+
+	require.Len(t, policyReport.report.Results, 2)
+	properties := policyReport.report.Results[0].Properties
+	assert.Equal(t, wgpolicy.PolicyResult(statusFail), policyReport.report.Results[0].Result)
+	assert.Equal(t, "signed_by_alice,trusted_repo", properties[propertyRejectedMembers])
+	assert.Equal(t, "not signed by alice", properties[propertyMemberPrefix+"signed_by_alice"])
+	assert.Equal(t, "not a trusted repository", properties[propertyMemberPrefix+"trusted_repo"])
+	assert.NotContains(t, properties, propertyMemberPrefix+"signed_by_bob")
+	assert.NotContains(t, properties, propertyMemberPrefix+"unknown")
+	assert.NotContains(t, policyReport.report.Results[1].Properties, propertyRejectedMembers)
+}
+
 func TestNewClusterPolicyReport(t *testing.T) {
 	resource := unstructured.Unstructured{}
 	resource.SetUID("uid")
@@ -172,6 +247,95 @@ func TestAddResultToClusterPolicyReport(t *testing.T) {
 	assert.Equal(t, 0, clusterPolicyReport.report.Summary.Error)
 }
 
+func TestAddSkippedResultToPolicyReport(t *testing.T) {
+	policy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "policy",
+			UID:             "policy-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	policyReport := NewPolicyReport("runUID", unstructured.Unstructured{})
+	policyReport.AddSkippedResult(policy, "PolicyServer unavailable")
+	policyReport.AddErroredResult(policy, "unknown GVR targeted by the policy")
+
+	assert.Len(t, policyReport.report.Results, 2)
+	assert.Equal(t, 1, policyReport.report.Summary.Skip)
+	assert.Equal(t, 1, policyReport.report.Summary.Error)
+
+	result := policyReport.report.Results[0]
+	assert.Equal(t, wgpolicy.PolicyResult(statusSkip), result.Result)
+	assert.Equal(t, "PolicyServer unavailable", result.Description)
+	assert.Equal(t, "clusterwide-policy", result.Policy)
+	assert.NotContains(t, result.Properties, propertyOperation)
+
+	erroredResult := policyReport.report.Results[1]
+	assert.Equal(t, wgpolicy.PolicyResult(statusError), erroredResult.Result)
+	assert.Equal(t, "unknown GVR targeted by the policy", erroredResult.Description)
+
+	// skipped results are evaluated again by the next scan
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetResourceVersion("1")
+	previousReport := NewPolicyReport("runUID", resource)
+	previousReport.AddSkippedResult(policy, "PolicyServer unavailable")
+	assert.False(t, NewPolicyReport("runUID", resource).ReuseResult(previousReport, policy))
+}
+
+func TestPolicyReportResourceResults(t *testing.T) {
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName("test-pod")
+
+	policy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+		Spec: policiesv1.ClusterAdmissionPolicySpec{
+			PolicySpec: policiesv1.PolicySpec{Mode: policiesv1.PolicyMode(policiesv1.PolicyModeStatusMonitor)},
+		},
+	}
+	policyReport := NewPolicyReport("runUID", resource)
+	policyReport.AddResult(policy, &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Message: "privileged containers are not allowed"},
+		},
+	}, false)
+	policyReport.AddSkippedResult(policy, "PolicyServer unavailable")
+
+	resourceResults := policyReport.ResourceResults()
+	assert.Equal(t, "Pod", resourceResults.Resource.Kind)
+	assert.Equal(t, "namespace", resourceResults.Resource.Namespace)
+	assert.Equal(t, "test-pod", resourceResults.Resource.Name)
+	assert.Equal(t, []string{ResultFail, ResultSkip}, []string{resourceResults.Results[0].Result, resourceResults.Results[1].Result})
+	assert.Equal(t, "clusterwide-policy", resourceResults.Results[0].Policy)
+	assert.Equal(t, "info", resourceResults.Results[0].Severity)
+	assert.Equal(t, "privileged containers are not allowed", resourceResults.Results[0].Message)
+	assert.Equal(t, "PolicyServer unavailable", resourceResults.Results[1].Message)
+}
+
+func TestMarshalPolicyReport(t *testing.T) {
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName("test-pod")
+
+	policyReportJSON, err := json.Marshal(NewPolicyReport("runUID", resource))
+	require.NoError(t, err)
+
+	policyReport := wgpolicy.PolicyReport{}
+	require.NoError(t, json.Unmarshal(policyReportJSON, &policyReport))
+	assert.Equal(t, "wgpolicyk8s.io/v1alpha2", policyReport.APIVersion)
+	assert.Equal(t, "PolicyReport", policyReport.Kind)
+	assert.Equal(t, "uid", policyReport.Name)
+	assert.Equal(t, "test-pod", policyReport.Scope.Name)
+}
+
 func TestNewPolicyReportResult(t *testing.T) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
 
@@ -266,6 +430,46 @@ func TestNewPolicyReportResult(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Mutating policy, allowed response with a patch",
+			policy: &policiesv1.ClusterAdmissionPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					UID:             "policy-uid",
+					ResourceVersion: "1",
+					Name:            "policy-name",
+				},
+				Spec: policiesv1.ClusterAdmissionPolicySpec{
+					PolicySpec: policiesv1.PolicySpec{
+						Mutating: true,
+					},
+				},
+			},
+			admissionReview: &admissionv1.AdmissionReview{
+				Response: &admissionv1.AdmissionResponse{
+					Allowed: true,
+					Patch:   []byte(`[{"op": "add", "path": "/metadata/labels/team", "value": "payments"}, {"op": "remove", "path": "/spec/hostNetwork"}]`),
+				},
+			},
+			errored: false,
+			expectedResult: &wgpolicy.PolicyReportResult{
+				Source:          policyReportSource,
+				Policy:          "clusterwide-policy-name",
+				Result:          statusWarn,
+				Timestamp:       now,
+				Scored:          true,
+				SubjectSelector: &metav1.LabelSelector{},
+				Description:     messageWouldMutate,
+				Properties: map[string]string{
+					propertyPolicyUID:                  "policy-uid",
+					propertyPolicyResourceVersion:      "1",
+					propertyPolicyName:                 "policy-name",
+					typeMutating:                       valueTypeTrue,
+					propertyPatchOperations:            "2",
+					propertyPatchOperationPrefix + "0": `{"op":"add","path":"/metadata/labels/team","value":"payments"}`,
+					propertyPatchOperationPrefix + "1": `{"op":"remove","path":"/spec/hostNetwork"}`,
+				},
+			},
+		},
 		{
 			name: "Validating policy in monitor mode, response error",
 			policy: &policiesv1.AdmissionPolicy{
@@ -313,6 +517,42 @@ func TestNewPolicyReportResult(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Validating policy, evaluated with an UPDATE request",
+			policy: &policiesv1.ClusterAdmissionPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					UID:             "policy-uid",
+					ResourceVersion: "1",
+					Name:            "policy-name",
+				},
+			},
+			admissionReview: &admissionv1.AdmissionReview{
+				Request: &admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+				},
+				Response: &admissionv1.AdmissionResponse{
+					Allowed: true,
+					Result:  nil,
+				},
+			},
+			errored: false,
+			expectedResult: &wgpolicy.PolicyReportResult{
+				Source:          policyReportSource,
+				Policy:          "clusterwide-policy-name",
+				Result:          statusPass,
+				Timestamp:       now,
+				Scored:          true,
+				SubjectSelector: &metav1.LabelSelector{},
+				Description:     "",
+				Properties: map[string]string{
+					propertyPolicyUID:             "policy-uid",
+					propertyPolicyResourceVersion: "1",
+					propertyPolicyName:            "policy-name",
+					propertyOperation:             "UPDATE",
+					typeValidating:                valueTypeTrue,
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -322,3 +562,74 @@ func TestNewPolicyReportResult(t *testing.T) {
 		})
 	}
 }
+
+func TestReuseResultInPolicyReport(t *testing.T) {
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName("test-pod")
+	resource.SetResourceVersion("12345")
+
+	policy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:             "policy-uid",
+			ResourceVersion: "1",
+			Name:            "policy-name",
+		},
+	}
+	admissionReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Message: "The request was rejected"},
+		},
+	}
+
+	previousReport := NewPolicyReport("previousRunUID", resource)
+	previousReport.AddResult(policy, admissionReview, false)
+
+	changedResource := resource.DeepCopy()
+	changedResource.SetResourceVersion("67890")
+
+	changedPolicy := policy.DeepCopy()
+	changedPolicy.SetResourceVersion("2")
+
+	contextAwarePolicy := policy.DeepCopy()
+	contextAwarePolicy.Spec.ContextAwareResources = []policiesv1.ContextAwareResource{{APIVersion: "v1", Kind: "Pod"}}
+
+	erroredReport := NewPolicyReport("previousRunUID", resource)
+	erroredReport.AddResult(policy, nil, true)
+
+	tests := []struct {
+		name           string
+		resource       unstructured.Unstructured
+		policy         policiesv1.Policy
+		previous       Report
+		expectedReused bool
+	}{
+		{"Unchanged resource and policy", resource, policy, previousReport, true},
+		{"Changed resource", *changedResource, policy, previousReport, false},
+		{"Changed policy", resource, changedPolicy, previousReport, false},
+		{"Context-aware policy", resource, contextAwarePolicy, previousReport, false},
+		{"Errored previous result", resource, policy, erroredReport, false},
+		{"Previous report of another kind", resource, policy, NewOpenReport("previousRunUID", resource), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policyReport := NewPolicyReport("runUID", test.resource)
+
+			reused := policyReport.ReuseResult(test.previous, test.policy)
+
+			assert.Equal(t, test.expectedReused, reused)
+			if test.expectedReused {
+				assert.Len(t, policyReport.report.Results, 1)
+				assert.Equal(t, 1, policyReport.report.Summary.Fail)
+				assert.Equal(t, "The request was rejected", policyReport.report.Results[0].Description)
+			} else {
+				assert.Empty(t, policyReport.report.Results)
+			}
+		})
+	}
+}
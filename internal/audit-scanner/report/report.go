@@ -1,6 +1,16 @@
 package report
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
 	admissionv1 "k8s.io/api/admission/v1"
@@ -20,9 +30,101 @@ const (
 // Report interface to abstract which kind of report are under use. This is useful
 // to support both PolicyReport and OpenReport without duplicating code.
 type Report interface {
-	SetSkipPolicies(n int)
-	SetErrorPolicies(n int)
+	// SetShardKey sets the shard key of the audited resource, so the report is
+	// only deleted by the shard of the audit scanner owning the resource.
+	SetShardKey(key string)
 	AddResult(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview, errored bool)
+	// AddSkippedResult adds a skip result for a policy that was not evaluated,
+	// the reason is reported as the message of the result.
+	AddSkippedResult(policy policiesv1.Policy, reason string)
+	// AddErroredResult adds an error result for a policy that cannot be
+	// evaluated, e.g. because it's misconfigured. The reason is reported as the
+	// message of the result.
+	AddErroredResult(policy policiesv1.Policy, reason string)
+	// ReuseResult copies the result of the given policy from a previous report
+	// of the same resource. It returns false when the previous result cannot be
+	// reused, because either the resource or the policy changed since then.
+	ReuseResult(previous Report, policy policiesv1.Policy) bool
+	// KeepResults copies the results of the policies selected by keep from a
+	// previous report of the same resource. Targeted scans use it to preserve
+	// the results of the policies they don't evaluate.
+	KeepResults(previous Report, keep func(policyName, policyNamespace string) bool)
+	// ResourceResults returns the audited resource and the results of the
+	// policies, independently of the kind of report.
+	ResourceResults() ResourceResults
+}
+
+// Results of the policies, as returned by ResourceResults.
+const (
+	ResultPass  = statusPass
+	ResultFail  = statusFail
+	ResultWarn  = statusWarn
+	ResultError = statusError
+	ResultSkip  = statusSkip
+)
+
+// ResourceResults are the results of the policies evaluated against a resource.
+type ResourceResults struct {
+	Resource corev1.ObjectReference
+	Results  []Result
+}
+
+// Result is the result of a policy evaluated against a resource.
+type Result struct {
+	Policy    string
+	Result    string
+	Severity  string
+	Category  string
+	Message   string
+	Timestamp time.Time
+}
+
+// ResultChange is a policy whose result for a resource changed since the
+// previous report of the resource.
+type ResultChange struct {
+	// Policy is the unique name of the policy
+	Policy string
+	// Failing is true when the policy started failing, false when it stopped
+	// failing because it now passes
+	Failing bool
+	// Message is the message of the new result
+	Message string
+}
+
+// ChangedResults returns the policies that started or stopped failing since the
+// previous report of the resource. The policies without a previous result are
+// ignored, so the first scan of a resource or of a policy does not report all
+// of its failures as new. The transitions to error, skip or warn results are
+// ignored too, as they don't tell whether the resource meets the policy.
+func ChangedResults(previous, current Report) []ResultChange {
+	previousResults := make(map[string]string)
+	for _, result := range previous.ResourceResults().Results {
+		previousResults[result.Policy] = result.Result
+	}
+
+	changes := []ResultChange{}
+	for _, result := range current.ResourceResults().Results {
+		previousResult, found := previousResults[result.Policy]
+		if !found {
+			continue
+		}
+		switch {
+		case result.Result == ResultFail && previousResult != ResultFail:
+			changes = append(changes, ResultChange{Policy: result.Policy, Failing: true, Message: result.Message})
+		case result.Result == ResultPass && previousResult == ResultFail:
+			changes = append(changes, ResultChange{Policy: result.Policy, Failing: false, Message: result.Message})
+		}
+	}
+	return changes
+}
+
+// marshalReport encodes a report resource as JSON.
+func marshalReport(report any) ([]byte, error) {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal report: %w", err)
+	}
+	return reportJSON, nil
 }
 
 func getCategoryAndMessage(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview) (string, string) {
@@ -43,19 +145,91 @@ func getCategoryAndMessage(policy policiesv1.Policy, admissionReview *admissionv
 		// or the reason why the policy returned a failure
 		message = admissionReview.Response.Result.Message
 	}
+	if message == "" && WouldMutate(policy, admissionReview) {
+		message = messageWouldMutate
+	}
 	return category, message
 }
 
-func computePolicyResult(errored bool, admissionReview *admissionv1.AdmissionReview) string {
+func computePolicyResult(policy policiesv1.Policy, errored bool, admissionReview *admissionv1.AdmissionReview) string {
 	if errored {
 		return statusError
 	}
+	if WouldMutate(policy, admissionReview) {
+		return statusWarn
+	}
 	if admissionReview.Response.Allowed {
 		return statusPass
 	}
 	return statusFail
 }
 
+// WouldMutate returns true when a mutating policy accepted the resource with a
+// patch, meaning that the policy would change the resource if it was submitted
+// again. The results of these evaluations are reported as warnings.
+func WouldMutate(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview) bool {
+	return policy.IsMutating() &&
+		admissionReview != nil &&
+		admissionReview.Response != nil &&
+		admissionReview.Response.Allowed &&
+		len(admissionReview.Response.Patch) > 0
+}
+
+// addPatchProperties adds the operations of the JSONPatch returned by a mutating
+// policy to the properties of its result, one property per operation, so the
+// changes the policy would make to the resource are visible in the reports.
+func addPatchProperties(properties map[string]string, patch []byte) {
+	var operations []json.RawMessage
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		properties[propertyPatch] = string(patch)
+		return
+	}
+
+	properties[propertyPatchOperations] = strconv.Itoa(len(operations))
+	for i, operation := range operations[:min(len(operations), maxPatchOperationProperties)] {
+		var compacted bytes.Buffer
+		// The operation was decoded from valid JSON, so it's always compacted
+		_ = json.Compact(&compacted, operation)
+		properties[propertyPatchOperationPrefix+strconv.Itoa(i)] = compacted.String()
+	}
+}
+
+// addGroupMemberProperties adds the members of a policy group that rejected
+// the resource to the properties of its result. When a group rejects a
+// resource, the PolicyServer returns the message of each member that rejected
+// it in the causes of the response, with the spec.policies.<member> field.
+// The other members either accepted the resource or were not evaluated, as the
+// expression of the group is short-circuited.
+func addGroupMemberProperties(properties map[string]string, policyGroup policiesv1.PolicyGroup, admissionReview *admissionv1.AdmissionReview) {
+	if admissionReview == nil ||
+		admissionReview.Response == nil ||
+		admissionReview.Response.Result == nil ||
+		admissionReview.Response.Result.Details == nil {
+		return
+	}
+
+	members := policyGroup.GetPolicyGroupMembersWithContext()
+	rejectedMembers := []string{}
+	for _, cause := range admissionReview.Response.Result.Details.Causes {
+		member, found := strings.CutPrefix(cause.Field, groupMemberCauseFieldPrefix)
+		if !found {
+			continue
+		}
+		if _, isMember := members[member]; !isMember {
+			continue
+		}
+		if _, seen := properties[propertyMemberPrefix+member]; !seen {
+			rejectedMembers = append(rejectedMembers, member)
+		}
+		properties[propertyMemberPrefix+member] = cause.Message
+	}
+	if len(rejectedMembers) == 0 {
+		return
+	}
+	slices.Sort(rejectedMembers)
+	properties[propertyRejectedMembers] = strings.Join(rejectedMembers, ",")
+}
+
 func computePolicyResultSeverity(policy policiesv1.Policy) string {
 	if policy.GetPolicyMode() == policiesv1.PolicyMode(policiesv1.PolicyModeStatusMonitor) {
 		return severityInfo
@@ -68,7 +242,7 @@ func computePolicyResultSeverity(policy policiesv1.Policy) string {
 	return ""
 }
 
-func computeProperties(policy policiesv1.Policy) map[string]string {
+func computeProperties(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview) map[string]string {
 	properties := map[string]string{}
 	if policy.IsMutating() {
 		properties[typeMutating] = valueTypeTrue
@@ -87,10 +261,47 @@ func computeProperties(policy policiesv1.Policy) map[string]string {
 	if policy.GetNamespace() != "" {
 		properties[propertyPolicyNamespace] = policy.GetNamespace()
 	}
+	// The operation tells how the resource was evaluated: policies whose rules
+	// do not cover CREATE operations are evaluated with UPDATE requests.
+	if admissionReview != nil && admissionReview.Request != nil {
+		properties[propertyOperation] = string(admissionReview.Request.Operation)
+	}
+	if WouldMutate(policy, admissionReview) {
+		addPatchProperties(properties, admissionReview.Response.Patch)
+	}
+	if policyGroup, ok := policy.(policiesv1.PolicyGroup); ok {
+		addGroupMemberProperties(properties, policyGroup, admissionReview)
+	}
 
 	return properties
 }
 
+// isResultReusable checks if a result stored in a previous report can be reused
+// for the current scan. This is the case when the resource and the policy have
+// not been changed since the result was computed.
+// Errored results are never reused, so they are retried on every scan.
+// Results of context-aware policies are never reused either, as they depend on
+// other resources that might have changed in the meantime.
+func isResultReusable(previousScope, currentScope *corev1.ObjectReference, result string, properties map[string]string, policy policiesv1.Policy) bool {
+	if previousScope == nil || currentScope == nil {
+		return false
+	}
+	if currentScope.ResourceVersion == "" ||
+		previousScope.UID != currentScope.UID ||
+		previousScope.ResourceVersion != currentScope.ResourceVersion {
+		return false
+	}
+	if result != statusPass && result != statusFail && result != statusWarn {
+		return false
+	}
+	if policy.IsContextAware() {
+		return false
+	}
+
+	return properties[propertyPolicyUID] == string(policy.GetUID()) &&
+		properties[propertyPolicyResourceVersion] == policy.GetResourceVersion()
+}
+
 func getReportObjectMeta(runUID string, resource unstructured.Unstructured) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Name: string(resource.GetUID()),
@@ -110,6 +321,16 @@ func getReportObjectMeta(runUID string, resource unstructured.Unstructured) meta
 	}
 }
 
+// newResourceResults returns the ResourceResults of the given report scope.
+// The results are added by the caller.
+func newResourceResults(scope *corev1.ObjectReference, capacity int) ResourceResults {
+	resourceResults := ResourceResults{Results: make([]Result, 0, capacity)}
+	if scope != nil {
+		resourceResults.Resource = *scope
+	}
+	return resourceResults
+}
+
 func getReportScope(resource unstructured.Unstructured) *corev1.ObjectReference {
 	return &corev1.ObjectReference{
 		APIVersion:      resource.GetAPIVersion(),
@@ -120,3 +341,31 @@ func getReportScope(resource unstructured.Unstructured) *corev1.ObjectReference
 		ResourceVersion: resource.GetResourceVersion(),
 	}
 }
+
+// contentHash returns the hash of the scope, the owner references and the
+// results of a report, stored in the annotationContentHash annotation to skip
+// the writes of the reports that did not change. The results must have their
+// timestamps cleared. They are hashed regardless of their order, since the
+// policies are evaluated in parallel.
+func contentHash[T any](scope *corev1.ObjectReference, ownerReferences []metav1.OwnerReference, results []T) (string, error) {
+	encodedResults := make([]string, 0, len(results))
+	for _, result := range results {
+		encodedResult, err := json.Marshal(result)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode the result: %w", err)
+		}
+		encodedResults = append(encodedResults, string(encodedResult))
+	}
+	slices.Sort(encodedResults)
+
+	content, err := json.Marshal(struct {
+		Scope           *corev1.ObjectReference `json:"scope"`
+		OwnerReferences []metav1.OwnerReference `json:"ownerReferences"`
+		Results         []string                `json:"results"`
+	}{scope, ownerReferences, encodedResults})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the report: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"testing"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestChangedResults(t *testing.T) {
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName("test-pod")
+
+	newPolicy := func(name string) *policiesv1.ClusterAdmissionPolicy {
+		return &policiesv1.ClusterAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	accepted := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: true},
+	}
+	rejected := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Message: "The request was rejected"},
+		},
+	}
+
+	previousReport := NewOpenReport("previousRunUID", resource)
+	previousReport.AddResult(newPolicy("newly-failing"), accepted, false)
+	previousReport.AddResult(newPolicy("newly-passing"), rejected, false)
+	previousReport.AddResult(newPolicy("still-failing"), rejected, false)
+	previousReport.AddResult(newPolicy("still-passing"), accepted, false)
+	previousReport.AddResult(newPolicy("errored"), rejected, false)
+	previousReport.AddErroredResult(newPolicy("failing-after-error"), "the policy is misconfigured")
+
+	currentReport := NewOpenReport("runUID", resource)
+	currentReport.AddResult(newPolicy("newly-failing"), rejected, false)
+	currentReport.AddResult(newPolicy("newly-passing"), accepted, false)
+	currentReport.AddResult(newPolicy("still-failing"), rejected, false)
+	currentReport.AddResult(newPolicy("still-passing"), accepted, false)
+	currentReport.AddResult(newPolicy("errored"), nil, true)
+	currentReport.AddResult(newPolicy("failing-after-error"), rejected, false)
+	currentReport.AddResult(newPolicy("new-policy"), rejected, false)
+
+	assert.Equal(t, []ResultChange{
+		{Policy: "clusterwide-newly-failing", Failing: true, Message: "The request was rejected"},
+		{Policy: "clusterwide-newly-passing", Failing: false},
+		{Policy: "clusterwide-failing-after-error", Failing: true, Message: "The request was rejected"},
+	}, ChangedResults(previousReport, currentReport))
+
+	assert.Empty(t, ChangedResults(NewOpenReport("previousRunUID", resource), currentReport))
+}
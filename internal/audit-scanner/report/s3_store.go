@@ -0,0 +1,169 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	auditConstants "github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// s3RunUIDMetadata and s3ShardKeyMetadata are the user metadata of the
+	// objects holding the run UID and the shard key of the reports
+	s3RunUIDMetadata   = "Run-Uid"
+	s3ShardKeyMetadata = "Shard-Key"
+	documentType       = "application/json"
+	defaultS3Endpoint  = "s3.amazonaws.com"
+)
+
+// S3Config configures the S3-compatible object store where the reports are written.
+type S3Config struct {
+	// Endpoint of the object store, as host:port
+	Endpoint string
+	Bucket   string
+	// Prefix of the keys of the objects, none when empty
+	Prefix string
+	// Region of the bucket, discovered from the object store when empty
+	Region string
+	// Insecure connects to the object store over plain HTTP
+	Insecure bool
+	// Credentials of the object store. When nil, they are read from the
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or MINIO_ACCESS_KEY and
+	// MINIO_SECRET_KEY, environment variables, or from the IAM role of the pod
+	Credentials *credentials.Credentials
+}
+
+// s3Backend stores each report in its own object, <prefix>/<namespace>/<resource UID>.json.
+// The run UID and the shard key of the reports are stored in the user metadata
+// of the objects.
+type s3Backend struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store returns a store writing the reports of the given kind to an
+// S3-compatible object store, e.g. AWS S3 or MinIO. The bucket must exist.
+func NewS3Store(kind CrdKind, config S3Config, logger *slog.Logger) (*DocumentStore, error) {
+	creds := config.Credentials
+	if creds == nil {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+		})
+	}
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !config.Insecure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the S3 client of %s: %w", config.Endpoint, err)
+	}
+	return newDocumentStore(kind, &s3Backend{client: client, bucket: config.Bucket, prefix: config.Prefix}, logger), nil
+}
+
+// namespacePrefix returns the prefix of the keys of the reports of the namespace.
+func (b *s3Backend) namespacePrefix(namespace string) string {
+	if namespace == "" {
+		namespace = clusterReportsDirectory
+	}
+	return path.Join(b.prefix, namespace) + "/"
+}
+
+func (b *s3Backend) key(namespace, name string) string {
+	return b.namespacePrefix(namespace) + name + documentExtension
+}
+
+func (b *s3Backend) getDocument(ctx context.Context, namespace, name string) ([]byte, error) {
+	object, err := b.client.GetObject(ctx, b.bucket, b.key(namespace, name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report %s: %w", name, err)
+	}
+	defer object.Close()
+
+	document, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, auditConstants.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to read report %s: %w", name, err)
+	}
+	return document, nil
+}
+
+func (b *s3Backend) putDocument(ctx context.Context, metadata documentMetadata, document []byte) error {
+	_, err := b.client.PutObject(ctx, b.bucket, b.key(metadata.Namespace, metadata.Name),
+		bytes.NewReader(document), int64(len(document)), minio.PutObjectOptions{
+			ContentType: documentType,
+			UserMetadata: map[string]string{
+				s3RunUIDMetadata:   metadata.RunUID,
+				s3ShardKeyMetadata: metadata.ShardKey,
+			},
+		})
+	if err != nil {
+		return fmt.Errorf("failed to put report %s: %w", metadata.Name, err)
+	}
+	return nil
+}
+
+// deleteDocuments lists the objects of the namespace, and reads the user
+// metadata of each of them, since it's not returned by the listings.
+func (b *s3Backend) deleteDocuments(ctx context.Context, namespace string, selected func(metadata documentMetadata) bool) error {
+	// The listing is stopped when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	prefix := b.namespacePrefix(namespace)
+	for object := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list reports: %w", object.Err)
+		}
+		name, isDocument := cutDocumentKey(object.Key, prefix)
+		if !isDocument {
+			continue
+		}
+
+		info, err := b.client.StatObject(ctx, b.bucket, object.Key, minio.StatObjectOptions{})
+		if err != nil {
+			if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+				continue
+			}
+			return fmt.Errorf("failed to get report %s: %w", name, err)
+		}
+		metadata := documentMetadata{
+			Namespace: namespace,
+			Name:      name,
+			RunUID:    info.UserMetadata[s3RunUIDMetadata],
+			ShardKey:  info.UserMetadata[s3ShardKeyMetadata],
+		}
+		if !selected(metadata) {
+			continue
+		}
+		if err = b.client.RemoveObject(ctx, b.bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete report %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (b *s3Backend) Close() error {
+	return nil
+}
+
+// cutDocumentKey returns the name of the report stored under the key, false
+// when the key is not the one of a report of the prefix.
+func cutDocumentKey(key, prefix string) (string, bool) {
+	name, hasPrefix := strings.CutPrefix(key, prefix)
+	name, isDocument := strings.CutSuffix(name, documentExtension)
+	return name, hasPrefix && isDocument && name != "" && !strings.Contains(name, "/")
+}
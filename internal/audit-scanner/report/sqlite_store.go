@@ -0,0 +1,113 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	auditConstants "github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	_ "modernc.org/sqlite" // registers the sqlite driver
+)
+
+const (
+	sqliteDriver = "sqlite"
+	// sqliteBusyTimeout is how long, in milliseconds, a write waits for the
+	// database to be unlocked by another process
+	sqliteBusyTimeout = 5000
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS reports (
+	namespace TEXT NOT NULL,
+	name      TEXT NOT NULL,
+	run_uid   TEXT NOT NULL,
+	shard_key TEXT NOT NULL,
+	report    TEXT NOT NULL,
+	PRIMARY KEY (namespace, name)
+)`
+
+// sqliteBackend stores the reports in the reports table of a SQLite database,
+// one row per report. The namespace is empty for the reports of the
+// cluster-wide resources.
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore returns a store writing the reports of the given kind in the
+// SQLite database at the given path, created if needed.
+func NewSQLiteStore(ctx context.Context, kind CrdKind, path string, logger *slog.Logger) (*DocumentStore, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)", path, sqliteBusyTimeout)
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the database %s: %w", path, err)
+	}
+	// SQLite allows a single writer at a time, the writes of the parallel
+	// audits are serialized instead of failing because the database is locked
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.ExecContext(ctx, sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create the reports table in %s: %w", path, err)
+	}
+	return newDocumentStore(kind, &sqliteBackend{db: db}, logger), nil
+}
+
+func (b *sqliteBackend) getDocument(ctx context.Context, namespace, name string) ([]byte, error) {
+	var document []byte
+	err := b.db.QueryRowContext(ctx, "SELECT report FROM reports WHERE namespace = ? AND name = ?", namespace, name).Scan(&document)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, auditConstants.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to query report %s: %w", name, err)
+	}
+	return document, nil
+}
+
+func (b *sqliteBackend) putDocument(ctx context.Context, metadata documentMetadata, document []byte) error {
+	_, err := b.db.ExecContext(ctx, `INSERT INTO reports (namespace, name, run_uid, shard_key, report) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (namespace, name) DO UPDATE SET run_uid = excluded.run_uid, shard_key = excluded.shard_key, report = excluded.report`,
+		metadata.Namespace, metadata.Name, metadata.RunUID, metadata.ShardKey, string(document))
+	if err != nil {
+		return fmt.Errorf("failed to upsert report %s: %w", metadata.Name, err)
+	}
+	return nil
+}
+
+func (b *sqliteBackend) deleteDocuments(ctx context.Context, namespace string, selected func(metadata documentMetadata) bool) error {
+	rows, err := b.db.QueryContext(ctx, "SELECT name, run_uid, shard_key FROM reports WHERE namespace = ?", namespace)
+	if err != nil {
+		return fmt.Errorf("failed to query reports: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		metadata := documentMetadata{Namespace: namespace}
+		if err = rows.Scan(&metadata.Name, &metadata.RunUID, &metadata.ShardKey); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read report: %w", err)
+		}
+		if selected(metadata) {
+			names = append(names, metadata.Name)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to query reports: %w", err)
+	}
+
+	for _, name := range names {
+		if _, err = b.db.ExecContext(ctx, "DELETE FROM reports WHERE namespace = ? AND name = ?", namespace, name); err != nil {
+			return fmt.Errorf("failed to delete report %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (b *sqliteBackend) Close() error {
+	if err := b.db.Close(); err != nil {
+		return fmt.Errorf("failed to close the database: %w", err)
+	}
+	return nil
+}
@@ -2,7 +2,14 @@ package report
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
+	"k8s.io/apimachinery/pkg/types"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -10,10 +17,24 @@ import (
 // Store is an interface to abstract the storage of reports. It's agnostic to the
 // kind of report used (PolicyReport or OpenReport).
 type Store interface {
+	// GetReport returns the report of the namespaced resource with the given UID.
+	// It returns constants.ErrResourceNotFound when the report does not exist.
+	GetReport(ctx context.Context, namespace string, resourceUID types.UID) (Report, error)
+	// GetClusterReport returns the report of the cluster-wide resource with the given UID.
+	// It returns constants.ErrResourceNotFound when the report does not exist.
+	GetClusterReport(ctx context.Context, resourceUID types.UID) (Report, error)
 	CreateOrPatchReport(ctx context.Context, report any) error
 	DeleteOldReports(ctx context.Context, scanRunID, namespace string) error
 	CreateOrPatchClusterReport(ctx context.Context, report any) error
-	DeleteOldClusterReports(ctx context.Context, scanRunID string) error
+	// DeleteOldClusterReports deletes the reports of the cluster-wide resources,
+	// owned by the given shard, that were not written by the scan run.
+	DeleteOldClusterReports(ctx context.Context, scanRunID string, auditShard shard.Shard) error
+	// DeleteReport deletes the report of the namespaced resource with the given
+	// UID, if any.
+	DeleteReport(ctx context.Context, namespace string, resourceUID types.UID) error
+	// DeleteClusterReport deletes the report of the cluster-wide resource with
+	// the given UID, if any.
+	DeleteClusterReport(ctx context.Context, resourceUID types.UID) error
 }
 
 func NewReportStoreOfKind(kind CrdKind, client client.Client, logger *slog.Logger) Store {
@@ -22,3 +43,62 @@ func NewReportStoreOfKind(kind CrdKind, client client.Client, logger *slog.Logge
 	}
 	return NewOpenReportStore(client, logger)
 }
+
+// Schemes of the URLs of the stores returned by NewDocumentStoreFromURL.
+const (
+	StoreSchemeFile   = "file"
+	StoreSchemeSQLite = "sqlite"
+	StoreSchemeS3     = "s3"
+)
+
+// StoreSchemes are the schemes of the URLs of the stores returned by NewDocumentStoreFromURL.
+var StoreSchemes = []string{StoreSchemeFile, StoreSchemeSQLite, StoreSchemeS3}
+
+// NewDocumentStoreFromURL returns the store writing the reports of the given
+// kind outside of the Kubernetes API server, selected by the scheme of the URL:
+//   - file:///path/to/directory writes the reports as files in the directory
+//   - sqlite:///path/to/database.db writes the reports in a SQLite database
+//   - s3://bucket/prefix?endpoint=host:port&region=region&insecure=true writes
+//     the reports to an S3-compatible object store. The endpoint defaults to
+//     AWS S3
+func NewDocumentStoreFromURL(ctx context.Context, kind CrdKind, rawURL string, logger *slog.Logger) (*DocumentStore, error) {
+	storeURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report store URL: %w", err)
+	}
+
+	switch storeURL.Scheme {
+	case StoreSchemeFile, StoreSchemeSQLite:
+		// Relative paths are accepted as file://relative/path
+		path := storeURL.Host + storeURL.Path
+		if path == "" {
+			return nil, fmt.Errorf("missing path in the report store URL %s", rawURL)
+		}
+		if storeURL.Scheme == StoreSchemeFile {
+			return NewFileStore(kind, path, logger)
+		}
+		return NewSQLiteStore(ctx, kind, path, logger)
+	case StoreSchemeS3:
+		if storeURL.Host == "" {
+			return nil, fmt.Errorf("missing bucket in the report store URL %s", rawURL)
+		}
+		query := storeURL.Query()
+		config := S3Config{
+			Endpoint: query.Get("endpoint"),
+			Bucket:   storeURL.Host,
+			Prefix:   strings.Trim(storeURL.Path, "/"),
+			Region:   query.Get("region"),
+		}
+		if config.Endpoint == "" {
+			config.Endpoint = defaultS3Endpoint
+		}
+		if insecure := query.Get("insecure"); insecure != "" {
+			if config.Insecure, err = strconv.ParseBool(insecure); err != nil {
+				return nil, fmt.Errorf("invalid insecure parameter in the report store URL %s: %w", rawURL, err)
+			}
+		}
+		return NewS3Store(kind, config, logger)
+	default:
+		return nil, fmt.Errorf("unsupported report store URL %s, supported schemes are %v", rawURL, StoreSchemes)
+	}
+}
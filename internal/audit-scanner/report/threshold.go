@@ -0,0 +1,154 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Severities are the severities of the results, from the lowest to the highest.
+var Severities = []string{severityInfo, severityLow, severityMedium, severityHigh, severityCritical}
+
+// ThresholdResults are the results that can exceed a threshold.
+var ThresholdResults = []string{statusFail, statusError}
+
+// Threshold selects the results that fail a scan, so the audit scanner can
+// gate a CI pipeline: the results in FailOn whose severity is at least
+// MinSeverity. All the severities are selected when MinSeverity is empty,
+// otherwise the results without a severity are ignored.
+type Threshold struct {
+	FailOn      []string
+	MinSeverity string
+}
+
+// Validate returns an error when the results or the severity of the threshold are unknown.
+func (t Threshold) Validate() error {
+	for _, result := range t.FailOn {
+		if !slices.Contains(ThresholdResults, result) {
+			return fmt.Errorf("invalid result %q, valid results are %v", result, ThresholdResults)
+		}
+	}
+	if t.MinSeverity != "" && !slices.Contains(Severities, t.MinSeverity) {
+		return fmt.Errorf("invalid severity %q, valid severities are %v", t.MinSeverity, Severities)
+	}
+	return nil
+}
+
+// IsEmpty returns true when no result exceeds the threshold.
+func (t Threshold) IsEmpty() bool {
+	return len(t.FailOn) == 0
+}
+
+// Exceeds returns true when the result is selected by the threshold.
+func (t Threshold) Exceeds(result Result) bool {
+	if !slices.Contains(t.FailOn, result.Result) {
+		return false
+	}
+	if t.MinSeverity == "" {
+		return true
+	}
+	// The severities missing from the list have an index of -1, so the results
+	// without a severity are always below the minimum one.
+	return slices.Index(Severities, result.Severity) >= slices.Index(Severities, t.MinSeverity)
+}
+
+// violationKey identifies the results of a policy in the summary of the violations.
+type violationKey struct {
+	policy   string
+	severity string
+}
+
+// Violations counts the results of the reports exceeding a threshold, by
+// policy. It's safe for concurrent use.
+type Violations struct {
+	threshold Threshold
+	mutex     sync.Mutex
+	// counts are the number of results exceeding the threshold, by policy and result
+	counts map[violationKey]map[string]int
+	total  int
+}
+
+// NewViolations returns a Violations counting the results exceeding the given threshold.
+func NewViolations(threshold Threshold) *Violations {
+	return &Violations{
+		threshold: threshold,
+		counts:    make(map[violationKey]map[string]int),
+	}
+}
+
+// Record counts the results of the report exceeding the threshold.
+func (v *Violations) Record(r Report) {
+	results := r.ResourceResults().Results
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for _, result := range results {
+		if !v.threshold.Exceeds(result) {
+			continue
+		}
+		key := violationKey{policy: result.Policy, severity: result.Severity}
+		if v.counts[key] == nil {
+			v.counts[key] = make(map[string]int)
+		}
+		v.counts[key][result.Result]++
+		v.total++
+	}
+}
+
+// Total returns the number of results exceeding the threshold.
+func (v *Violations) Total() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.total
+}
+
+// WriteSummary writes a table with the number of results exceeding the
+// threshold, for each policy, sorted by decreasing severity.
+func (v *Violations) WriteSummary(writer io.Writer) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	keys := slices.SortedFunc(maps.Keys(v.counts), func(a, b violationKey) int {
+		if a.severity != b.severity {
+			return slices.Index(Severities, b.severity) - slices.Index(Severities, a.severity)
+		}
+		return strings.Compare(a.policy, b.policy)
+	})
+
+	table := tabwriter.NewWriter(writer, 0, 0, 3, ' ', 0)
+	header := []string{"POLICY", "SEVERITY"}
+	for _, result := range v.threshold.FailOn {
+		header = append(header, strings.ToUpper(result))
+	}
+	fmt.Fprintln(table, strings.Join(header, "\t"))
+
+	totals := make(map[string]int)
+	for _, key := range keys {
+		severity := key.severity
+		if severity == "" {
+			severity = "-"
+		}
+		row := []string{key.policy, severity}
+		for _, result := range v.threshold.FailOn {
+			row = append(row, strconv.Itoa(v.counts[key][result]))
+			totals[result] += v.counts[key][result]
+		}
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+
+	row := []string{"TOTAL", ""}
+	for _, result := range v.threshold.FailOn {
+		row = append(row, strconv.Itoa(totals[result]))
+	}
+	fmt.Fprintln(table, strings.Join(row, "\t"))
+
+	if err := table.Flush(); err != nil {
+		return fmt.Errorf("failed to write the summary of the violations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestThresholdValidate(t *testing.T) {
+	require.NoError(t, Threshold{}.Validate())
+	require.NoError(t, Threshold{FailOn: []string{"fail", "error"}, MinSeverity: "high"}.Validate())
+	require.Error(t, Threshold{FailOn: []string{"pass"}}.Validate())
+	require.Error(t, Threshold{FailOn: []string{"fail"}, MinSeverity: "severe"}.Validate())
+}
+
+func TestThresholdExceeds(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold Threshold
+		result    Result
+		expected  bool
+	}{
+		{"empty threshold", Threshold{}, Result{Result: statusFail, Severity: severityCritical}, false},
+		{"selected result", Threshold{FailOn: []string{statusFail}}, Result{Result: statusFail}, true},
+		{"other result", Threshold{FailOn: []string{statusFail}}, Result{Result: statusError}, false},
+		{"higher severity", Threshold{FailOn: []string{statusFail}, MinSeverity: severityMedium}, Result{Result: statusFail, Severity: severityHigh}, true},
+		{"same severity", Threshold{FailOn: []string{statusFail}, MinSeverity: severityMedium}, Result{Result: statusFail, Severity: severityMedium}, true},
+		{"lower severity", Threshold{FailOn: []string{statusFail}, MinSeverity: severityMedium}, Result{Result: statusFail, Severity: severityLow}, false},
+		{"no severity", Threshold{FailOn: []string{statusFail}, MinSeverity: severityInfo}, Result{Result: statusFail}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.threshold.Exceeds(test.result))
+		})
+	}
+}
+
+func TestViolations(t *testing.T) {
+	newPolicy := func(name, severity string) *policiesv1.ClusterAdmissionPolicy {
+		policy := &policiesv1.ClusterAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if severity != "" {
+			policy.SetAnnotations(map[string]string{policiesv1.AnnotationSeverity: severity})
+		}
+		return policy
+	}
+	rejected := &admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{Allowed: false}}
+	accepted := &admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{Allowed: true}}
+
+	first := NewPolicyReport("runUID", unstructured.Unstructured{})
+	first.AddResult(newPolicy("no-root", severityHigh), rejected, false)
+	first.AddResult(newPolicy("labels", severityLow), rejected, false)
+	first.AddResult(newPolicy("registry", severityCritical), nil, true)
+	second := NewClusterPolicyReport("runUID", unstructured.Unstructured{})
+	second.AddResult(newPolicy("no-root", severityHigh), rejected, false)
+	second.AddResult(newPolicy("registry", severityCritical), accepted, false)
+
+	violations := NewViolations(Threshold{FailOn: []string{statusFail, statusError}, MinSeverity: severityMedium})
+	violations.Record(first)
+	violations.Record(second)
+	assert.Equal(t, 3, violations.Total())
+
+	var summary bytes.Buffer
+	require.NoError(t, violations.WriteSummary(&summary))
+	assert.Equal(t, `POLICY                 SEVERITY   FAIL   ERROR
+clusterwide-registry   critical   0      1
+clusterwide-no-root    high       2      0
+TOTAL                             2      1
+`, summary.String())
+}
@@ -2,13 +2,21 @@ package scanner
 
 import (
 	admv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-func newAdmissionRequest(resource unstructured.Unstructured) *admv1.AdmissionRequest {
+// newAdmissionRequest builds the admission request used to audit an existing resource,
+// as the API server would build it for a dry-run request of the given user.
+// The gvr is the resource used to list the object.
+// UPDATE requests have the resource as both the object and the old object,
+// DELETE requests only have it as the old object.
+func newAdmissionRequest(resource unstructured.Unstructured, gvr schema.GroupVersionResource, operation admv1.Operation, userInfo authenticationv1.UserInfo) *admv1.AdmissionRequest {
 	groupVersionKind := resource.GroupVersionKind()
+	dryRun := true
 	request := admv1.AdmissionRequest{
 		UID:  resource.GetUID(),
 		Name: resource.GetName(),
@@ -18,27 +26,65 @@ func newAdmissionRequest(resource unstructured.Unstructured) *admv1.AdmissionReq
 			Kind:    groupVersionKind.Kind,
 		},
 		Resource: metav1.GroupVersionResource{
-			Group:    groupVersionKind.Group,
-			Version:  groupVersionKind.Version,
-			Resource: groupVersionKind.Kind,
+			Group:    gvr.Group,
+			Version:  gvr.Version,
+			Resource: gvr.Resource,
 		},
 		RequestKind: &metav1.GroupVersionKind{
 			Group:   groupVersionKind.Group,
 			Version: groupVersionKind.Version,
 			Kind:    groupVersionKind.Kind,
 		},
-		Operation: admv1.Create,
-		Namespace: resource.GetNamespace(),
-		Object: runtime.RawExtension{
-			Object: resource.DeepCopyObject(),
-			Raw:    nil,
+		RequestResource: &metav1.GroupVersionResource{
+			Group:    gvr.Group,
+			Version:  gvr.Version,
+			Resource: gvr.Resource,
 		},
+		Operation: operation,
+		Namespace: resource.GetNamespace(),
+		UserInfo:  userInfo,
+		DryRun:    &dryRun,
+		Options:   newOperationOptions(operation),
 	}
+
+	switch operation {
+	case admv1.Update:
+		request.Object = runtime.RawExtension{Object: resource.DeepCopyObject()}
+		request.OldObject = runtime.RawExtension{Object: resource.DeepCopyObject()}
+	case admv1.Delete:
+		request.OldObject = runtime.RawExtension{Object: resource.DeepCopyObject()}
+	default:
+		request.Object = runtime.RawExtension{Object: resource.DeepCopyObject()}
+	}
+
 	return &request
 }
 
-func newAdmissionReview(resource unstructured.Unstructured) *admv1.AdmissionReview {
-	admissionRequest := newAdmissionRequest(resource)
+// newOperationOptions returns the options of a dry-run request performing the given operation.
+func newOperationOptions(operation admv1.Operation) runtime.RawExtension {
+	dryRun := []string{metav1.DryRunAll}
+
+	switch operation {
+	case admv1.Update:
+		return runtime.RawExtension{Object: &metav1.UpdateOptions{
+			TypeMeta: metav1.TypeMeta{Kind: "UpdateOptions", APIVersion: metav1.SchemeGroupVersion.String()},
+			DryRun:   dryRun,
+		}}
+	case admv1.Delete:
+		return runtime.RawExtension{Object: &metav1.DeleteOptions{
+			TypeMeta: metav1.TypeMeta{Kind: "DeleteOptions", APIVersion: metav1.SchemeGroupVersion.String()},
+			DryRun:   dryRun,
+		}}
+	}
+
+	return runtime.RawExtension{Object: &metav1.CreateOptions{
+		TypeMeta: metav1.TypeMeta{Kind: "CreateOptions", APIVersion: metav1.SchemeGroupVersion.String()},
+		DryRun:   dryRun,
+	}}
+}
+
+func newAdmissionReview(resource unstructured.Unstructured, gvr schema.GroupVersionResource, operation admv1.Operation, userInfo authenticationv1.UserInfo) *admv1.AdmissionReview {
+	admissionRequest := newAdmissionRequest(resource, gvr, operation, userInfo)
 	return &admv1.AdmissionReview{
 		Request:  admissionRequest,
 		Response: nil,
@@ -1,11 +1,15 @@
 package scanner
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/google/uuid"
 	admv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -15,6 +19,15 @@ const (
 	resourceNamespace = "testing-namespace"
 )
 
+//nolint:gochecknoglobals // shared by the tests of the admission requests
+var (
+	podGVR        = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+	auditUserInfo = authenticationv1.UserInfo{
+		Username: "system:serviceaccount:kubewarden:audit-scanner",
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:kubewarden", "system:authenticated"},
+	}
+)
+
 func generateUnstructuredPodObject() unstructured.Unstructured {
 	groupVersionKind := schema.GroupVersionKind{
 		Group:   "core",
@@ -32,7 +45,7 @@ func generateUnstructuredPodObject() unstructured.Unstructured {
 
 func TestObjectInAdmissionRequest(t *testing.T) {
 	obj := generateUnstructuredPodObject()
-	admissionRequest := newAdmissionRequest(obj)
+	admissionRequest := newAdmissionRequest(obj, podGVR, admv1.Create, auditUserInfo)
 
 	admReqObj := admissionRequest.Object.Object
 	if admReqObj.GetObjectKind().GroupVersionKind().Group != obj.GroupVersionKind().Group {
@@ -48,7 +61,7 @@ func TestObjectInAdmissionRequest(t *testing.T) {
 
 func TestBasicInfoInAdmissionRequest(t *testing.T) {
 	obj := generateUnstructuredPodObject()
-	admissionRequest := newAdmissionRequest(obj)
+	admissionRequest := newAdmissionRequest(obj, podGVR, admv1.Create, auditUserInfo)
 
 	if admissionRequest.Kind.Group != obj.GroupVersionKind().Group {
 		t.Errorf("Group diverge")
@@ -65,13 +78,13 @@ func TestBasicInfoInAdmissionRequest(t *testing.T) {
 	if admissionRequest.Name != resourceName {
 		t.Errorf("Name diverge")
 	}
-	if admissionRequest.Resource.Group != obj.GroupVersionKind().Group {
+	if admissionRequest.Resource.Group != podGVR.Group {
 		t.Errorf("Resource Group diverge")
 	}
-	if admissionRequest.Resource.Resource != obj.GroupVersionKind().Kind {
+	if admissionRequest.Resource.Resource != podGVR.Resource {
 		t.Errorf("Resource diverge")
 	}
-	if admissionRequest.Resource.Version != obj.GroupVersionKind().Version {
+	if admissionRequest.Resource.Version != podGVR.Version {
 		t.Errorf("Resource version diverge")
 	}
 	if admissionRequest.Namespace != resourceNamespace {
@@ -84,7 +97,7 @@ func TestBasicInfoInAdmissionRequest(t *testing.T) {
 
 func TestGetAdmissionReview(t *testing.T) {
 	obj := generateUnstructuredPodObject()
-	admissionReview := newAdmissionReview(obj)
+	admissionReview := newAdmissionReview(obj, podGVR, admv1.Create, auditUserInfo)
 
 	if admissionReview.Response != nil {
 		t.Fatalf("Response should not be set")
@@ -107,13 +120,13 @@ func TestGetAdmissionReview(t *testing.T) {
 	if admissionRequest.Name != resourceName {
 		t.Errorf("Name diverge")
 	}
-	if admissionRequest.Resource.Group != obj.GroupVersionKind().Group {
+	if admissionRequest.Resource.Group != podGVR.Group {
 		t.Errorf("Resource Group diverge")
 	}
-	if admissionRequest.Resource.Resource != obj.GroupVersionKind().Kind {
+	if admissionRequest.Resource.Resource != podGVR.Resource {
 		t.Errorf("Resource diverge")
 	}
-	if admissionRequest.Resource.Version != obj.GroupVersionKind().Version {
+	if admissionRequest.Resource.Version != podGVR.Version {
 		t.Errorf("Resource version diverge")
 	}
 	if admissionRequest.Namespace != resourceNamespace {
@@ -123,3 +136,74 @@ func TestGetAdmissionReview(t *testing.T) {
 		t.Errorf("Operation diverge")
 	}
 }
+
+func TestOldObjectInAdmissionRequestByOperation(t *testing.T) {
+	obj := generateUnstructuredPodObject()
+
+	tests := []struct {
+		operation         admv1.Operation
+		expectedObject    bool
+		expectedOldObject bool
+	}{
+		{admv1.Create, true, false},
+		{admv1.Update, true, true},
+		{admv1.Delete, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.operation), func(t *testing.T) {
+			admissionRequest := newAdmissionRequest(obj, podGVR, test.operation, auditUserInfo)
+
+			if admissionRequest.Operation != test.operation {
+				t.Errorf("Operation diverge")
+			}
+			if (admissionRequest.Object.Object != nil) != test.expectedObject {
+				t.Errorf("Object presence diverge")
+			}
+			if (admissionRequest.OldObject.Object != nil) != test.expectedOldObject {
+				t.Errorf("OldObject presence diverge")
+			}
+		})
+	}
+}
+
+func TestDryRunInfoInAdmissionRequest(t *testing.T) {
+	obj := generateUnstructuredPodObject()
+
+	tests := []struct {
+		operation       admv1.Operation
+		expectedOptions runtime.Object
+	}{
+		{admv1.Create, &metav1.CreateOptions{
+			TypeMeta: metav1.TypeMeta{Kind: "CreateOptions", APIVersion: "meta.k8s.io/v1"},
+			DryRun:   []string{metav1.DryRunAll},
+		}},
+		{admv1.Update, &metav1.UpdateOptions{
+			TypeMeta: metav1.TypeMeta{Kind: "UpdateOptions", APIVersion: "meta.k8s.io/v1"},
+			DryRun:   []string{metav1.DryRunAll},
+		}},
+		{admv1.Delete, &metav1.DeleteOptions{
+			TypeMeta: metav1.TypeMeta{Kind: "DeleteOptions", APIVersion: "meta.k8s.io/v1"},
+			DryRun:   []string{metav1.DryRunAll},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.operation), func(t *testing.T) {
+			admissionRequest := newAdmissionRequest(obj, podGVR, test.operation, auditUserInfo)
+
+			if admissionRequest.DryRun == nil || !*admissionRequest.DryRun {
+				t.Errorf("DryRun is not set")
+			}
+			if !reflect.DeepEqual(admissionRequest.Options.Object, test.expectedOptions) {
+				t.Errorf("Options diverge")
+			}
+			if !reflect.DeepEqual(admissionRequest.UserInfo, auditUserInfo) {
+				t.Errorf("UserInfo diverge")
+			}
+			if admissionRequest.RequestResource == nil || *admissionRequest.RequestResource != admissionRequest.Resource {
+				t.Errorf("RequestResource diverge")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker pauses the evaluations against an unhealthy PolicyServer.
+// The circuit opens after threshold consecutive failures. Once the cooldown
+// expires, a single probe evaluation is allowed: the circuit closes if it
+// succeeds, and opens again otherwise.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	openedAt            time.Time
+	open                bool
+	probing             bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow returns true if an evaluation can be sent to the PolicyServer.
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.probing || b.now().Before(b.openedAt.Add(b.cooldown)) {
+		return false
+	}
+	b.probing = true
+
+	return true
+}
+
+// recordSuccess records an evaluation answered by the PolicyServer.
+func (b *circuitBreaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutiveFailures = 0
+	b.open = false
+	b.probing = false
+}
+
+// recordFailure records an evaluation that the PolicyServer failed to answer.
+func (b *circuitBreaker) recordFailure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutiveFailures++
+	if b.probing || b.consecutiveFailures >= b.threshold {
+		b.open = true
+		b.openedAt = b.now()
+	}
+	b.probing = false
+}
+
+// release gives back a probe that ended without telling whether the
+// PolicyServer recovered, for example because the scan was canceled.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// circuitBreakers holds a circuitBreaker for each PolicyServer, by host.
+type circuitBreakers struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newCircuitBreakers(threshold int, cooldown time.Duration) *circuitBreakers {
+	return &circuitBreakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  make(map[string]*circuitBreaker),
+	}
+}
+
+// get returns the circuitBreaker of the PolicyServer listening on the given host.
+func (c *circuitBreakers) get(host string) *circuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	breaker, found := c.breakers[host]
+	if !found {
+		breaker = newCircuitBreaker(c.threshold, c.cooldown)
+		c.breakers[host] = breaker
+	}
+
+	return breaker
+}
@@ -0,0 +1,63 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	// the circuit opens after threshold consecutive failures
+	assert.True(t, breaker.allow())
+	breaker.recordFailure()
+	assert.True(t, breaker.allow())
+	breaker.recordFailure()
+	assert.False(t, breaker.allow())
+
+	// a single probe is allowed once the cooldown expires
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.allow())
+	assert.False(t, breaker.allow())
+
+	// a failed probe opens the circuit again
+	breaker.recordFailure()
+	assert.False(t, breaker.allow())
+
+	// a successful probe closes the circuit
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.allow())
+	breaker.recordSuccess()
+	assert.True(t, breaker.allow())
+	breaker.recordFailure()
+	assert.True(t, breaker.allow())
+
+	// a released probe allows a new one
+	breaker.recordFailure()
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.allow())
+	breaker.release()
+	assert.True(t, breaker.allow())
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breaker := newCircuitBreaker(0, time.Minute)
+
+	for range 10 {
+		breaker.recordFailure()
+	}
+	assert.True(t, breaker.allow())
+}
+
+func TestCircuitBreakersByHost(t *testing.T) {
+	breakers := newCircuitBreakers(1, time.Minute)
+
+	breakers.get("policy-server-default:443").recordFailure()
+
+	assert.False(t, breakers.get("policy-server-default:443").allow())
+	assert.True(t, breakers.get("policy-server-other:443").allow())
+}
@@ -2,10 +2,20 @@ package scanner
 
 import (
 	"log/slog"
+	"time"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/auditrun"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/checkpoint"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/events"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/notification"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/output"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/policies"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
 )
 
 type ParallelizationConfig struct {
@@ -21,6 +31,34 @@ type TLSConfig struct {
 	ClientKeyFile  string
 }
 
+// PolicyServerClientConfig configures the requests sent to the PolicyServers.
+type PolicyServerClientConfig struct {
+	// Timeout of a single request. Defaults to 10 seconds when zero
+	Timeout time.Duration
+	// MaxRetries is the number of times a request is retried after a
+	// connection error or a retryable status code. Zero disables the retries
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled at every
+	// following retry up to MaxRetryBackoff
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// CircuitBreakerThreshold is the number of consecutive failed requests
+	// after which the evaluations against a PolicyServer are paused for
+	// CircuitBreakerCooldown. Zero disables the circuit breaker
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
+}
+
+// EndpointsConfig configures the load balancing of the evaluation requests
+// across the pods of the PolicyServers, discovered through EndpointSlices.
+type EndpointsConfig struct {
+	// Clientset is used to watch the Services and the EndpointSlices of the
+	// PolicyServers. The load balancing is disabled when nil
+	Clientset kubernetes.Interface
+	// Namespace where the PolicyServers are deployed
+	Namespace string
+}
+
 type Config struct {
 	PoliciesClient *policies.Client
 	K8sClient      *k8s.Client
@@ -28,11 +66,51 @@ type Config struct {
 	ReportStore report.Store
 	ReportKind  report.CrdKind
 
-	TLS             TLSConfig
-	Parallelization ParallelizationConfig
+	TLS                TLSConfig
+	Parallelization    ParallelizationConfig
+	PolicyServerClient PolicyServerClientConfig
+	Endpoints          EndpointsConfig
 
-	OutputScan   bool
+	OutputScan bool
+	// Output writes the results of the audited resources in a machine-readable
+	// format. It is owned by the caller, which closes it once the scan is done.
+	// Nothing is written when nil
+	Output       output.Writer
 	DisableStore bool
+	// Incremental enables the reuse of the results stored by the previous
+	// scan when neither the resource nor the policy changed since then
+	Incremental bool
+	// UserInfo is the identity set in the admission requests sent to the
+	// policies, as the one of the user performing the request
+	UserInfo authenticationv1.UserInfo
+	// Checkpoint persists the progress of the runs, so an interrupted run is
+	// resumed by the next one. The runs are not resumable when nil
+	Checkpoint *checkpoint.Store
+	// AuditRun records the lifecycle and the results of the runs in AuditRun
+	// resources. The runs are not recorded when nil
+	AuditRun *auditrun.Recorder
+	// Shard is the portion of the namespaces and of the cluster-wide resources
+	// scanned, when the scan is split across several replicas. Everything is
+	// scanned when zero
+	Shard shard.Shard
+	// Violations counts the results of the audited resources exceeding the
+	// threshold of the scan. They are not counted when nil
+	Violations *report.Violations
+	// AuditSchedule is the name of the AuditSchedule whose Job runs the scan,
+	// empty otherwise. The scans of the AuditSchedules run alongside the other
+	// ones, so, like the targeted scans, they never delete the reports of the
+	// resources they did not audit
+	AuditSchedule string
+	// DeduplicateEvaluations enables the reuse, during a run, of the verdicts
+	// of the policies for the resources equivalent to an already evaluated one,
+	// e.g. the Pods of a Deployment
+	DeduplicateEvaluations bool
+	// Events emits Kubernetes Events when a policy starts or stops failing
+	// for a resource since its previous report. No Event is emitted when nil
+	Events *events.Recorder
+	// Notifier sends the failures found by the scans to the configured
+	// notification sinks. Nothing is sent when nil
+	Notifier *notification.Notifier
 
 	Logger *slog.Logger
 }
@@ -0,0 +1,311 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// endpointMaxIdleConns is the number of idle keep-alive connections kept open with each PolicyServer pod.
+const endpointMaxIdleConns = 100
+
+// endpointsBalancer is an http.RoundTripper spreading the requests sent to a
+// PolicyServer Service across the pods backing it. The pods are discovered
+// through the EndpointSlices of the Service, which are watched to react to
+// the endpoint changes during the scan.
+// Every pod has its own pool of keep-alive connections, and each request is
+// sent to the pod with the least outstanding requests.
+// Requests to hosts that are not a Service of the namespace, or to Services
+// without ready endpoints, are sent through the fallback RoundTripper.
+type endpointsBalancer struct {
+	namespace      string
+	baseTransport  *http.Transport
+	fallback       http.RoundTripper
+	serviceLister  corelisters.ServiceLister
+	endpointLister discoverylisters.EndpointSliceLister
+	informers      informers.SharedInformerFactory
+	stopCh         chan struct{}
+	logger         *slog.Logger
+
+	mu sync.Mutex
+	// endpoints holds the transport of each pod, by server name and address
+	endpoints map[string]*endpoint
+	// next is used to break the ties between endpoints in round robin
+	next uint64
+}
+
+// endpoint is a PolicyServer pod, with its pool of connections.
+type endpoint struct {
+	address     string
+	transport   *http.Transport
+	outstanding atomic.Int64
+}
+
+func newEndpointsBalancer(clientset kubernetes.Interface, namespace string, baseTransport *http.Transport, fallback http.RoundTripper, logger *slog.Logger) (*endpointsBalancer, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace(namespace))
+	balancer := &endpointsBalancer{
+		namespace:      namespace,
+		baseTransport:  baseTransport,
+		fallback:       fallback,
+		serviceLister:  factory.Core().V1().Services().Lister(),
+		endpointLister: factory.Discovery().V1().EndpointSlices().Lister(),
+		informers:      factory,
+		stopCh:         make(chan struct{}),
+		logger:         logger,
+		endpoints:      make(map[string]*endpoint),
+	}
+
+	_, err := factory.Discovery().V1().EndpointSlices().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, _ any) { balancer.pruneEndpoints() },
+		DeleteFunc: func(_ any) { balancer.pruneEndpoints() },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch EndpointSlices: %w", err)
+	}
+
+	return balancer, nil
+}
+
+// start watches the Services and the EndpointSlices, and waits for the caches to be synced.
+func (b *endpointsBalancer) start() error {
+	b.informers.Start(b.stopCh)
+	for informerType, synced := range b.informers.WaitForCacheSync(b.stopCh) {
+		if !synced {
+			return fmt.Errorf("failed to sync the cache of %v", informerType)
+		}
+	}
+	return nil
+}
+
+// stop stops watching the EndpointSlices and closes the idle connections.
+func (b *endpointsBalancer) stop() {
+	close(b.stopCh)
+	b.informers.Shutdown()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, endpoint := range b.endpoints {
+		endpoint.transport.CloseIdleConnections()
+		delete(b.endpoints, key)
+	}
+}
+
+func (b *endpointsBalancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	serverName := req.URL.Hostname()
+	addresses, err := b.endpointAddresses(serverName, req.URL.Port())
+	if err != nil || len(addresses) == 0 {
+		if err != nil {
+			b.logger.Debug("cannot balance the request across the PolicyServer endpoints",
+				slog.String("host", req.URL.Host),
+				slog.String("error", err.Error()))
+		}
+		return b.fallback.RoundTrip(req) //nolint:wrapcheck // the http.Client wraps the errors
+	}
+
+	endpoint := b.pickEndpoint(serverName, addresses)
+	endpoint.outstanding.Add(1)
+
+	// The request keeps the Service as Host, and the TLS connection checks the
+	// certificate against the Service name
+	endpointReq := req.Clone(req.Context())
+	endpointReq.URL.Host = endpoint.address
+	endpointReq.Host = req.URL.Host
+
+	res, err := endpoint.transport.RoundTrip(endpointReq)
+	if err != nil {
+		endpoint.outstanding.Add(-1)
+		return nil, err //nolint:wrapcheck // the http.Client wraps the errors
+	}
+	res.Body = &endpointResponseBody{ReadCloser: res.Body, endpoint: endpoint}
+
+	return res, nil
+}
+
+// endpointAddresses returns the addresses of the ready endpoints of the Service
+// with the given DNS name and port. No addresses are returned when the name is
+// not the one of a Service of the namespace.
+func (b *endpointsBalancer) endpointAddresses(serverName, port string) ([]string, error) {
+	serviceName, found := strings.CutSuffix(serverName, "."+b.namespace+".svc")
+	if !found || strings.Contains(serviceName, ".") {
+		return nil, nil
+	}
+	servicePort, err := strconv.ParseInt(port, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", port, err)
+	}
+
+	service, err := b.serviceLister.Services(b.namespace).Get(serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Service %s: %w", serviceName, err)
+	}
+	portName, err := servicePortName(service, int32(servicePort))
+	if err != nil {
+		return nil, err
+	}
+
+	endpointSlices, err := b.endpointLister.EndpointSlices(b.namespace).List(labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: serviceName}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the EndpointSlices of Service %s: %w", serviceName, err)
+	}
+
+	var addresses []string
+	for _, endpointSlice := range endpointSlices {
+		addresses = append(addresses, readyEndpointAddresses(endpointSlice, portName)...)
+	}
+	// The listers return the EndpointSlices in random order, the round robin
+	// needs a stable one
+	slices.Sort(addresses)
+
+	return addresses, nil
+}
+
+// servicePortName returns the name of the given port of the Service.
+func servicePortName(service *corev1.Service, port int32) (string, error) {
+	for _, servicePort := range service.Spec.Ports {
+		if servicePort.Port == port {
+			return servicePort.Name, nil
+		}
+	}
+	return "", fmt.Errorf("service %s does not expose port %d", service.Name, port)
+}
+
+// readyEndpointAddresses returns the addresses of the ready endpoints of the
+// EndpointSlice, using the port with the given name.
+func readyEndpointAddresses(endpointSlice *discoveryv1.EndpointSlice, portName string) []string {
+	if endpointSlice.AddressType != discoveryv1.AddressTypeIPv4 && endpointSlice.AddressType != discoveryv1.AddressTypeIPv6 {
+		return nil
+	}
+
+	var port *int32
+	for _, endpointPort := range endpointSlice.Ports {
+		name := ""
+		if endpointPort.Name != nil {
+			name = *endpointPort.Name
+		}
+		if name == portName {
+			port = endpointPort.Port
+			break
+		}
+	}
+	if port == nil {
+		return nil
+	}
+
+	var addresses []string
+	for _, endpoint := range endpointSlice.Endpoints {
+		if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+			continue
+		}
+		if len(endpoint.Addresses) == 0 {
+			continue
+		}
+		addresses = append(addresses, net.JoinHostPort(endpoint.Addresses[0], strconv.Itoa(int(*port))))
+	}
+
+	return addresses
+}
+
+// pickEndpoint returns the endpoint with the least outstanding requests among
+// the given addresses. Ties are broken in round robin.
+func (b *endpointsBalancer) pickEndpoint(serverName string, addresses []string) *endpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := b.next
+	b.next++
+
+	var picked *endpoint
+	for i := range addresses {
+		address := addresses[(start+uint64(i))%uint64(len(addresses))]
+		candidate := b.endpoint(serverName, address)
+		if picked == nil || candidate.outstanding.Load() < picked.outstanding.Load() {
+			picked = candidate
+		}
+	}
+
+	return picked
+}
+
+// endpoint returns the endpoint with the given address, creating its transport if needed.
+// It must be called with the mutex held.
+func (b *endpointsBalancer) endpoint(serverName, address string) *endpoint {
+	key := serverName + "/" + address
+	if existing, found := b.endpoints[key]; found {
+		return existing
+	}
+
+	transport := b.baseTransport.Clone()
+	transport.DisableKeepAlives = false
+	transport.MaxIdleConnsPerHost = endpointMaxIdleConns
+	if transport.TLSClientConfig != nil {
+		transport.TLSClientConfig.ServerName = serverName
+	}
+
+	created := &endpoint{address: address, transport: transport}
+	b.endpoints[key] = created
+
+	return created
+}
+
+// pruneEndpoints closes the connections to the endpoints removed from the EndpointSlices.
+func (b *endpointsBalancer) pruneEndpoints() {
+	endpointSlices, err := b.endpointLister.EndpointSlices(b.namespace).List(labels.Everything())
+	if err != nil {
+		b.logger.Warn("cannot list EndpointSlices", slog.String("error", err.Error()))
+		return
+	}
+
+	active := make(map[string]struct{})
+	for _, endpointSlice := range endpointSlices {
+		for _, port := range endpointSlice.Ports {
+			portName := ""
+			if port.Name != nil {
+				portName = *port.Name
+			}
+			for _, address := range readyEndpointAddresses(endpointSlice, portName) {
+				active[address] = struct{}{}
+			}
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, endpoint := range b.endpoints {
+		if _, found := active[endpoint.address]; found {
+			continue
+		}
+		b.logger.Debug("PolicyServer endpoint removed", slog.String("address", endpoint.address))
+		endpoint.transport.CloseIdleConnections()
+		delete(b.endpoints, key)
+	}
+}
+
+// endpointResponseBody tracks the requests outstanding on an endpoint until their response is consumed.
+type endpointResponseBody struct {
+	io.ReadCloser
+	endpoint *endpoint
+	closed   atomic.Bool
+}
+
+func (r *endpointResponseBody) Close() error {
+	if r.closed.CompareAndSwap(false, true) {
+		r.endpoint.outstanding.Add(-1)
+	}
+	return r.ReadCloser.Close() //nolint:wrapcheck // returned as-is to the http.Client
+}
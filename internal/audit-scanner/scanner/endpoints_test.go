@@ -0,0 +1,155 @@
+package scanner
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func newEndpointSlice(t *testing.T, name, serviceName, serverURL string, ready bool) *discoveryv1.EndpointSlice {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(serverURL[len("http://"):])
+	require.NoError(t, err)
+	portNumber, err := strconv.ParseInt(port, 10, 32)
+	require.NoError(t, err)
+
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kubewarden",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: serviceName},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{
+			{
+				Addresses:  []string{host},
+				Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(ready)},
+			},
+		},
+		Ports: []discoveryv1.EndpointPort{
+			{
+				Name: ptr.To("policy-server"),
+				Port: ptr.To(int32(portNumber)),
+			},
+		},
+	}
+}
+
+func newCountingServer(requests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Host != "policy-server-default.kubewarden.svc:443" {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestEndpointsBalancer(t *testing.T) {
+	var requests1, requests2, requests3 atomic.Int32
+	server1 := newCountingServer(&requests1)
+	defer server1.Close()
+	server2 := newCountingServer(&requests2)
+	defer server2.Close()
+	notReadyServer := newCountingServer(&requests3)
+	defer notReadyServer.Close()
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "policy-server",
+					Port: 443,
+				},
+			},
+		},
+	}
+	clientset := fake.NewClientset(
+		service,
+		newEndpointSlice(t, "endpoints-1", service.Name, server1.URL, true),
+		newEndpointSlice(t, "endpoints-2", service.Name, server2.URL, true),
+		newEndpointSlice(t, "endpoints-3", service.Name, notReadyServer.URL, false),
+	)
+
+	var fallbackRequests atomic.Int32
+	fallbackServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		fallbackRequests.Add(1)
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer fallbackServer.Close()
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	require.True(t, ok)
+	balancer, err := newEndpointsBalancer(clientset, "kubewarden", transport.Clone(), transport.Clone(), slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, balancer.start())
+	defer balancer.stop()
+
+	client := http.Client{Transport: balancer}
+	send := func(url string) {
+		res, sendErr := client.Get(url) //nolint:noctx // test request
+		require.NoError(t, sendErr)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	// the requests are spread across the ready endpoints
+	for range 10 {
+		send("http://policy-server-default.kubewarden.svc:443/audit/policy")
+	}
+	assert.Equal(t, int32(5), requests1.Load())
+	assert.Equal(t, int32(5), requests2.Load())
+	assert.Equal(t, int32(0), requests3.Load())
+
+	// the requests to other hosts are sent through the fallback transport
+	send(fallbackServer.URL)
+	assert.Equal(t, int32(1), fallbackRequests.Load())
+
+	// the removed endpoints are not used anymore
+	require.NoError(t, clientset.DiscoveryV1().EndpointSlices("kubewarden").Delete(t.Context(), "endpoints-2", metav1.DeleteOptions{}))
+	require.Eventually(t, func() bool {
+		addresses, addressesErr := balancer.endpointAddresses("policy-server-default.kubewarden.svc", "443")
+		return addressesErr == nil && len(addresses) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for range 10 {
+		send("http://policy-server-default.kubewarden.svc:443/audit/policy")
+	}
+	assert.Equal(t, int32(15), requests1.Load())
+	assert.Equal(t, int32(5), requests2.Load())
+}
+
+func TestPickEndpointLeastOutstanding(t *testing.T) {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	require.True(t, ok)
+	balancer, err := newEndpointsBalancer(fake.NewClientset(), "kubewarden", transport.Clone(), transport, slog.Default())
+	require.NoError(t, err)
+
+	addresses := []string{"10.0.0.1:3000", "10.0.0.2:3000"}
+	busy := balancer.pickEndpoint("policy-server", addresses)
+	busy.outstanding.Add(1)
+
+	for range 3 {
+		picked := balancer.pickEndpoint("policy-server", addresses)
+		assert.NotEqual(t, busy.address, picked.address)
+	}
+}
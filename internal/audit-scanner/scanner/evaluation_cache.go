@@ -0,0 +1,213 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"golang.org/x/sync/singleflight"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ignoredMetadataFields are the metadata fields that differ between the
+// resources created by the same controller, e.g. the Pods of a Deployment.
+// They are ignored when comparing the content of the resources.
+var ignoredMetadataFields = []string{
+	"name",
+	"generateName",
+	"uid",
+	"resourceVersion",
+	"generation",
+	"creationTimestamp",
+	"managedFields",
+}
+
+// ignoredPodLabels are the labels that differ between the Pods of the same
+// StatefulSet.
+var ignoredPodLabels = []string{
+	"statefulset.kubernetes.io/pod-name",
+	"apps.kubernetes.io/pod-index",
+}
+
+// ignoredPodSpecFields are the fields of the spec that differ between the
+// Pods created from the same template: the node they are scheduled on, and
+// the hostname of the Pods of a StatefulSet.
+var ignoredPodSpecFields = []string{
+	"nodeName",
+	"hostname",
+	"subdomain",
+}
+
+// serviceAccountTokenVolumePrefix is the prefix of the name of the projected
+// volume of the ServiceAccount token, suffixed with a random string by the
+// API server for each Pod.
+const serviceAccountTokenVolumePrefix = "kube-api-access-"
+
+// evaluationCache shares the verdicts of the policies between the equivalent
+// resources audited during a run: the resources whose content is the same once
+// their name, uid, status and the other fields set by the API server are
+// ignored, as well as the fields of the Pods that differ between replicas.
+// The verdicts are kept until a resource of another run is evaluated.
+// It's safe for concurrent use.
+type evaluationCache struct {
+	mutex  sync.Mutex
+	runUID string
+	// reviews are the admission reviews returned by the PolicyServers, without their request
+	reviews map[string]*admissionv1.AdmissionReview
+	// inflight deduplicates the concurrent evaluations of equivalent resources
+	inflight singleflight.Group
+}
+
+func newEvaluationCache() *evaluationCache {
+	return &evaluationCache{
+		reviews: make(map[string]*admissionv1.AdmissionReview),
+	}
+}
+
+// evaluate returns the admission review evaluated for an equivalent resource
+// during the run, if any, adapted to the given request. Otherwise, the request
+// is evaluated, and the review is kept when the evaluation succeeded. The
+// returned bool is true when the review was evaluated for another resource.
+// Concurrent evaluations of equivalent resources wait for the first one.
+func (c *evaluationCache) evaluate(runUID, key string, request *admissionv1.AdmissionReview, evaluate func() (*admissionv1.AdmissionReview, error)) (*admissionv1.AdmissionReview, bool, error) {
+	key = runUID + "/" + key
+	if review := c.get(runUID, key); review != nil {
+		return reuseReview(review, request), true, nil
+	}
+
+	evaluated := false
+	value, err, _ := c.inflight.Do(key, func() (any, error) {
+		evaluated = true
+		review, err := evaluate()
+		if err != nil {
+			return nil, err
+		}
+		if review.Response != nil && (review.Response.Result == nil || review.Response.Result.Code != http.StatusInternalServerError) {
+			c.set(runUID, key, review)
+		}
+		return review, nil
+	})
+	if evaluated {
+		if err != nil {
+			return nil, false, err
+		}
+		review, _ := value.(*admissionv1.AdmissionReview)
+		return review, false, nil
+	}
+
+	// The evaluation of an equivalent resource failed, this one is evaluated on its own
+	if review := c.get(runUID, key); review != nil {
+		return reuseReview(review, request), true, nil
+	}
+	review, err := evaluate()
+	return review, false, err
+}
+
+// get returns the review kept for the key during the run, nil when there is none.
+func (c *evaluationCache) get(runUID, key string) *admissionv1.AdmissionReview {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.runUID != runUID {
+		return nil
+	}
+	return c.reviews[key]
+}
+
+// set keeps the review for the key, dropping the reviews of the previous run.
+func (c *evaluationCache) set(runUID, key string, review *admissionv1.AdmissionReview) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.runUID != runUID {
+		c.runUID = runUID
+		c.reviews = make(map[string]*admissionv1.AdmissionReview)
+	}
+	kept := *review
+	kept.Request = nil
+	c.reviews[key] = &kept
+}
+
+// reuseReview returns a copy of the review answering the given request.
+func reuseReview(review *admissionv1.AdmissionReview, request *admissionv1.AdmissionReview) *admissionv1.AdmissionReview {
+	reused := review.DeepCopy()
+	reused.Request = request.Request
+	reused.Response.UID = request.Request.UID
+	return reused
+}
+
+// evaluationKey returns the key of the verdict of the policy for the resources
+// having the given content hash, audited with the given operation.
+func evaluationKey(policy policiesv1.Policy, gvr schema.GroupVersionResource, operation admissionv1.Operation, contentHash string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s", policy.GetUID(), policy.GetResourceVersion(), operation, gvr.String(), contentHash)
+}
+
+// contentHash returns the hash of the content of the resource, ignoring the
+// metadata fields set by the API server, the name and the status. The fields
+// of the Pods that differ between the replicas of a workload are ignored too.
+func contentHash(resource unstructured.Unstructured) (string, error) {
+	content := runtime.DeepCopyJSON(resource.Object)
+	for _, field := range ignoredMetadataFields {
+		unstructured.RemoveNestedField(content, "metadata", field)
+	}
+	delete(content, "status")
+	if resource.GetAPIVersion() == "v1" && resource.GetKind() == "Pod" {
+		ignorePodReplicaFields(content)
+	}
+
+	// The keys of the maps are sorted by the JSON encoder, so equivalent
+	// resources have the same encoding.
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the resource: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ignorePodReplicaFields removes the fields of the Pod that differ between the
+// replicas of a workload, and renames the volume of the ServiceAccount token,
+// so the Pods created from the same template have the same content.
+func ignorePodReplicaFields(pod map[string]any) {
+	for _, label := range ignoredPodLabels {
+		unstructured.RemoveNestedField(pod, "metadata", "labels", label)
+	}
+	spec, ok := pod["spec"].(map[string]any)
+	if !ok {
+		return
+	}
+	for _, field := range ignoredPodSpecFields {
+		delete(spec, field)
+	}
+
+	renameTokenVolumes(spec["volumes"])
+	for _, containersField := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		containers, _ := spec[containersField].([]any)
+		for _, container := range containers {
+			if container, ok := container.(map[string]any); ok {
+				renameTokenVolumes(container["volumeMounts"])
+			}
+		}
+	}
+}
+
+// renameTokenVolumes removes the random suffix of the ServiceAccount token
+// volumes, or volume mounts, of the list.
+func renameTokenVolumes(volumes any) {
+	list, _ := volumes.([]any)
+	for _, volume := range list {
+		volume, ok := volume.(map[string]any)
+		if !ok {
+			continue
+		}
+		if name, _ := volume["name"].(string); strings.HasPrefix(name, serviceAccountTokenVolumePrefix) {
+			volume["name"] = serviceAccountTokenVolumePrefix
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package scanner
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newCacheTestPod(name string, image string) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]any{
+			"name":              name,
+			"generateName":      "nginx-",
+			"namespace":         "default",
+			"uid":               name + "-uid",
+			"resourceVersion":   "1",
+			"creationTimestamp": "2025-01-01T00:00:00Z",
+			"labels":            map[string]any{"app": "nginx"},
+		},
+		"spec": map[string]any{
+			"containers": []any{map[string]any{"name": "nginx", "image": image}},
+		},
+		"status": map[string]any{"podIP": name},
+	}}
+}
+
+func TestContentHash(t *testing.T) {
+	hash, err := contentHash(newCacheTestPod("nginx-abcde", "nginx:1.27"))
+	require.NoError(t, err)
+
+	// the name, the uid and the status are ignored
+	replicaHash, err := contentHash(newCacheTestPod("nginx-fghij", "nginx:1.27"))
+	require.NoError(t, err)
+	assert.Equal(t, hash, replicaHash)
+
+	// the spec is not
+	otherHash, err := contentHash(newCacheTestPod("nginx-abcde", "nginx:1.28"))
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, otherHash)
+
+	// nor are the fields that differ between the replicas of a workload
+	scheduled := newCacheTestPod("nginx-abcde", "nginx:1.27")
+	setReplicaFields(t, scheduled, "node-1", "kube-api-access-x7k2p")
+	scheduledHash, err := contentHash(scheduled)
+	require.NoError(t, err)
+	otherScheduled := newCacheTestPod("nginx-fghij", "nginx:1.27")
+	setReplicaFields(t, otherScheduled, "node-2", "kube-api-access-9zqrt")
+	otherScheduledHash, err := contentHash(otherScheduled)
+	require.NoError(t, err)
+	assert.Equal(t, scheduledHash, otherScheduledHash)
+
+	// neither are the labels
+	labelled := newCacheTestPod("nginx-abcde", "nginx:1.27")
+	labelled.SetLabels(map[string]string{"app": "nginx", "tier": "frontend"})
+	labelledHash, err := contentHash(labelled)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, labelledHash)
+}
+
+// setReplicaFields sets the fields of the pod set by the API server and by
+// the scheduler that differ between the replicas of a workload.
+func setReplicaFields(t *testing.T, pod unstructured.Unstructured, nodeName, tokenVolume string) {
+	t.Helper()
+
+	require.NoError(t, unstructured.SetNestedField(pod.Object, nodeName, "spec", "nodeName"))
+	require.NoError(t, unstructured.SetNestedField(pod.Object, pod.GetName(), "spec", "hostname"))
+	require.NoError(t, unstructured.SetNestedSlice(pod.Object, []any{
+		map[string]any{"name": tokenVolume, "projected": map[string]any{"defaultMode": int64(420)}},
+	}, "spec", "volumes"))
+	require.NoError(t, unstructured.SetNestedSlice(pod.Object, []any{map[string]any{
+		"name":         "nginx",
+		"image":        "nginx:1.27",
+		"volumeMounts": []any{map[string]any{"name": tokenVolume, "mountPath": "/var/run/secrets/kubernetes.io/serviceaccount"}},
+	}}, "spec", "containers"))
+}
+
+func newCacheTestRequest(uid types.UID) *admissionv1.AdmissionReview {
+	return &admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{UID: uid},
+	}
+}
+
+func TestEvaluationCache(t *testing.T) {
+	cache := newEvaluationCache()
+	evaluations := 0
+	evaluate := func(request *admissionv1.AdmissionReview, code int32) func() (*admissionv1.AdmissionReview, error) {
+		return func() (*admissionv1.AdmissionReview, error) {
+			evaluations++
+			review := &admissionv1.AdmissionReview{
+				Request:  request.Request,
+				Response: &admissionv1.AdmissionResponse{UID: request.Request.UID, Allowed: code == 0},
+			}
+			if code != 0 {
+				review.Response.Result = &metav1.Status{Code: code}
+			}
+			return review, nil
+		}
+	}
+
+	// the first evaluation is sent, the following ones reuse its verdict
+	request := newCacheTestRequest("first")
+	review, reused, err := cache.evaluate("run", "key", request, evaluate(request, 0))
+	require.NoError(t, err)
+	assert.False(t, reused)
+	assert.Equal(t, types.UID("first"), review.Response.UID)
+
+	request = newCacheTestRequest("second")
+	review, reused, err = cache.evaluate("run", "key", request, evaluate(request, 0))
+	require.NoError(t, err)
+	assert.True(t, reused)
+	assert.True(t, review.Response.Allowed)
+	assert.Equal(t, types.UID("second"), review.Response.UID)
+	assert.Same(t, request.Request, review.Request)
+	assert.Equal(t, 1, evaluations)
+
+	// the verdicts are not reused across runs
+	_, reused, err = cache.evaluate("other-run", "key", request, evaluate(request, 0))
+	require.NoError(t, err)
+	assert.False(t, reused)
+	assert.Equal(t, 2, evaluations)
+
+	// the errored evaluations are not reused
+	_, reused, err = cache.evaluate("other-run", "errored", request, evaluate(request, http.StatusInternalServerError))
+	require.NoError(t, err)
+	assert.False(t, reused)
+	_, reused, err = cache.evaluate("other-run", "errored", request, evaluate(request, http.StatusInternalServerError))
+	require.NoError(t, err)
+	assert.False(t, reused)
+	assert.Equal(t, 4, evaluations)
+
+	// neither are the failed ones
+	failure := errors.New("connection refused")
+	_, _, err = cache.evaluate("other-run", "failed", request, func() (*admissionv1.AdmissionReview, error) {
+		return nil, failure
+	})
+	require.ErrorIs(t, err, failure)
+	_, reused, err = cache.evaluate("other-run", "failed", request, evaluate(request, 0))
+	require.NoError(t, err)
+	assert.False(t, reused)
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// matchConditionsMatcherKind is the kind reported by the matchConditions matchers.
+const matchConditionsMatcherKind = "audit"
+
+// matchConditionsEvaluator evaluates the matchConditions of the policies the
+// same way the Kubernetes API server does before sending a request to a webhook.
+// The compiled matchConditions are cached by policy and policy resourceVersion.
+type matchConditionsEvaluator struct {
+	// compiler uses the CEL environment used to validate the matchConditions
+	// of the policies, see api/policies/v1/policy_validation.go
+	compiler plugincel.ConditionCompiler
+	mu       sync.Mutex
+	matchers map[string]matchconditions.Matcher
+}
+
+func newMatchConditionsEvaluator() *matchConditionsEvaluator {
+	return &matchConditionsEvaluator{
+		compiler: plugincel.NewConditionCompiler(policiesv1.MatchConditionsEnvSet()),
+		matchers: make(map[string]matchconditions.Matcher),
+	}
+}
+
+// matches returns true if all the matchConditions of the policy evaluate to true for the given admission request.
+// Evaluation errors are handled according to the failurePolicy of the policy: with the Fail policy the error is returned,
+// with the Ignore policy the request does not match.
+func (e *matchConditionsEvaluator) matches(ctx context.Context, policy policiesv1.Policy, request *admissionv1.AdmissionRequest) (bool, error) {
+	if len(policy.GetMatchConditions()) == 0 {
+		return true, nil
+	}
+
+	kind := schema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind}
+	attributes := admission.NewAttributesRecord(
+		request.Object.Object,
+		request.OldObject.Object,
+		kind,
+		request.Namespace,
+		request.Name,
+		schema.GroupVersionResource{Group: request.Resource.Group, Version: request.Resource.Version, Resource: request.Resource.Resource},
+		request.SubResource,
+		admission.Operation(request.Operation),
+		request.Options.Object,
+		request.DryRun != nil && *request.DryRun,
+		newUserInfo(request.UserInfo),
+	)
+	versionedAttributes := &admission.VersionedAttributes{
+		Attributes:         attributes,
+		VersionedObject:    request.Object.Object,
+		VersionedOldObject: request.OldObject.Object,
+		VersionedKind:      kind,
+	}
+
+	// The authorizer is not available outside of the API server: the
+	// matchConditions using it fail and the failurePolicy applies.
+	result := e.matcher(policy).Match(ctx, versionedAttributes, nil, nil)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to evaluate the matchConditions of policy %s: %w", policy.GetUniqueName(), result.Error)
+	}
+
+	return result.Matches, nil
+}
+
+// newUserInfo converts the user info of an admission request to the user info of the admission attributes.
+func newUserInfo(userInfo authenticationv1.UserInfo) user.Info {
+	extra := make(map[string][]string, len(userInfo.Extra))
+	for key, value := range userInfo.Extra {
+		extra[key] = value
+	}
+
+	return &user.DefaultInfo{
+		Name:   userInfo.Username,
+		UID:    userInfo.UID,
+		Groups: userInfo.Groups,
+		Extra:  extra,
+	}
+}
+
+// matcher returns the matcher of the matchConditions of the given policy, compiling them if needed.
+func (e *matchConditionsEvaluator) matcher(policy policiesv1.Policy) matchconditions.Matcher {
+	key := policy.GetUniqueName() + "/" + policy.GetResourceVersion()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if matcher, found := e.matchers[key]; found {
+		return matcher
+	}
+
+	var expressions []plugincel.ExpressionAccessor
+	for _, matchCondition := range policy.GetMatchConditions() {
+		expressions = append(expressions, &matchconditions.MatchCondition{
+			Name:       matchCondition.Name,
+			Expression: matchCondition.Expression,
+		})
+	}
+	condition := e.compiler.CompileCondition(expressions, plugincel.OptionalVariableDeclarations{
+		HasParams:     false,
+		HasAuthorizer: true,
+	}, environment.StoredExpressions)
+
+	matcher := matchconditions.NewMatcher(condition, policy.GetFailurePolicy(), matchConditionsMatcherKind, "policy", policy.GetUniqueName())
+	e.matchers[key] = matcher
+
+	return matcher
+}
@@ -0,0 +1,142 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestMatchConditions(t *testing.T) {
+	pod := unstructured.Unstructured{}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	pod.SetName("pod")
+	pod.SetNamespace("default")
+	pod.SetLabels(map[string]string{"env": "prod"})
+
+	tests := []struct {
+		name            string
+		policyFactory   *testutils.ClusterAdmissionPolicyFactory
+		operation       admv1.Operation
+		expectedMatches bool
+		expectedErr     bool
+	}{
+		{
+			name:            "no matchConditions",
+			policyFactory:   testutils.NewClusterAdmissionPolicyFactory(),
+			operation:       admv1.Create,
+			expectedMatches: true,
+		},
+		{
+			name: "all the matchConditions are true",
+			policyFactory: testutils.NewClusterAdmissionPolicyFactory().
+				MatchCondition("prod", "object.metadata.labels['env'] == 'prod'").
+				MatchCondition("create", "request.operation == 'CREATE'"),
+			operation:       admv1.Create,
+			expectedMatches: true,
+		},
+		{
+			name: "a matchCondition is false",
+			policyFactory: testutils.NewClusterAdmissionPolicyFactory().
+				MatchCondition("prod", "object.metadata.labels['env'] == 'prod'").
+				MatchCondition("create", "request.operation == 'CREATE'"),
+			operation:       admv1.Update,
+			expectedMatches: false,
+		},
+		{
+			name: "the request has the resource and the user of the audit",
+			policyFactory: testutils.NewClusterAdmissionPolicyFactory().
+				MatchCondition("pods", "request.resource.resource == 'pods'").
+				MatchCondition("user", "request.userInfo.username == 'system:serviceaccount:kubewarden:audit-scanner'").
+				MatchCondition("dry-run", "request.dryRun"),
+			operation:       admv1.Create,
+			expectedMatches: true,
+		},
+		{
+			name: "oldObject is available with UPDATE requests",
+			policyFactory: testutils.NewClusterAdmissionPolicyFactory().
+				MatchCondition("unchanged", "oldObject.metadata.name == object.metadata.name"),
+			operation:       admv1.Update,
+			expectedMatches: true,
+		},
+		{
+			name: "evaluation error with failurePolicy Fail",
+			policyFactory: testutils.NewClusterAdmissionPolicyFactory().
+				MatchCondition("missing", "object.metadata.labels['missing'] == 'value'").
+				FailurePolicy(admissionregistrationv1.Fail),
+			operation:   admv1.Create,
+			expectedErr: true,
+		},
+		{
+			name: "evaluation error with failurePolicy Ignore",
+			policyFactory: testutils.NewClusterAdmissionPolicyFactory().
+				MatchCondition("missing", "object.metadata.labels['missing'] == 'value'").
+				FailurePolicy(admissionregistrationv1.Ignore),
+			operation:       admv1.Create,
+			expectedMatches: false,
+		},
+	}
+
+	evaluator := newMatchConditionsEvaluator()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := test.policyFactory.Name(test.name).Build()
+
+			matches, err := evaluator.matches(t.Context(), policy, newAdmissionRequest(pod, podGVR, test.operation, auditUserInfo))
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedMatches, matches)
+		})
+	}
+}
+
+func TestPolicyMatchesNamespaceSelector(t *testing.T) {
+	namespace := unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName("namespace")
+	namespace.SetLabels(map[string]string{"env": "prod"})
+
+	validatingWebhookConfiguration := unstructured.Unstructured{}
+	validatingWebhookConfiguration.SetAPIVersion("admissionregistration.k8s.io/v1")
+	validatingWebhookConfiguration.SetKind("ValidatingWebhookConfiguration")
+	validatingWebhookConfiguration.SetName("webhook")
+
+	matchingPolicy := testutils.NewClusterAdmissionPolicyFactory().
+		Name("matching").
+		NamespaceSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}).
+		Build()
+	notMatchingPolicy := testutils.NewClusterAdmissionPolicyFactory().
+		Name("not-matching").
+		NamespaceSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"env": "test"}}).
+		Build()
+
+	namespacesGVR := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "namespaces"}
+	validatingWebhookConfigurationsGVR := admissionregistrationv1.SchemeGroupVersion.WithResource("validatingwebhookconfigurations")
+
+	scanner := &Scanner{matchConditions: newMatchConditionsEvaluator()}
+
+	matches, err := scanner.policyMatches(t.Context(), matchingPolicy, namespace, newAdmissionRequest(namespace, namespacesGVR, admv1.Create, auditUserInfo))
+	require.NoError(t, err)
+	assert.True(t, matches)
+
+	matches, err = scanner.policyMatches(t.Context(), notMatchingPolicy, namespace, newAdmissionRequest(namespace, namespacesGVR, admv1.Create, auditUserInfo))
+	require.NoError(t, err)
+	assert.False(t, matches)
+
+	// the namespaceSelector does not apply to the other cluster-wide resources
+	matches, err = scanner.policyMatches(t.Context(), notMatchingPolicy, validatingWebhookConfiguration, newAdmissionRequest(validatingWebhookConfiguration, validatingWebhookConfigurationsGVR, admv1.Create, auditUserInfo))
+	require.NoError(t, err)
+	assert.True(t, matches)
+}
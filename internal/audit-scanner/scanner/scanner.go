@@ -13,22 +13,57 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/auditrun"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/checkpoint"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/events"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/metrics"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/notification"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/output"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/policies"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
+	"go.opentelemetry.io/otel"
 	"golang.org/x/sync/semaphore"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
-const httpClientTimeout = 10 * time.Second
+const (
+	defaultHTTPClientTimeout = 10 * time.Second
+	retryBackoffFactor       = 2.0
+	retryBackoffJitter       = 0.1
+)
+
+// errPolicyServerUnavailable is returned when the evaluations against a
+// PolicyServer are paused by its circuit breaker.
+var errPolicyServerUnavailable = errors.New("PolicyServer unavailable")
+
+// policyServerStatusError is returned when a PolicyServer answers with an
+// unexpected HTTP status code.
+type policyServerStatusError struct {
+	statusCode int
+	body       []byte
+}
+
+func (e *policyServerStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d body: %s", e.statusCode, e.body)
+}
 
 // Scanner verifies that existing resources don't violate any of the policies.
 type Scanner struct {
@@ -38,12 +73,53 @@ type Scanner struct {
 	// http client used to make requests against the Policy Server
 	httpClient               http.Client
 	outputScan               bool
+	output                   output.Writer
 	disableStore             bool
+	incremental              bool
 	parallelNamespacesAudits int
 	parallelResourcesAudits  int
 	parallelPoliciesAudits   int
 	logger                   *slog.Logger
 	reportKind               report.CrdKind
+	// matchConditions evaluates the matchConditions of the policies
+	matchConditions *matchConditionsEvaluator
+	// userInfo is the identity set in the admission requests
+	userInfo authenticationv1.UserInfo
+	// maxRetries and retryBackoff configure the retries of the failed
+	// requests sent to the PolicyServers
+	maxRetries   int
+	retryBackoff wait.Backoff
+	// circuitBreakers pause the evaluations against unhealthy PolicyServers
+	circuitBreakers *circuitBreakers
+	// endpoints spreads the requests across the PolicyServer pods, nil when
+	// the load balancing is disabled
+	endpoints *endpointsBalancer
+	// metrics records the metrics of the audit runs
+	metrics *metrics.Recorder
+	// checkpoint persists the progress of the runs, nil when the runs are not resumable
+	checkpoint *checkpoint.Store
+	// auditRun records the runs in AuditRun resources, nil when they are not recorded
+	auditRun *auditrun.Recorder
+	// shard is the portion of the namespaces and of the cluster-wide resources scanned
+	shard shard.Shard
+	// violations counts the results exceeding the threshold of the scan, nil when they are not counted
+	violations *report.Violations
+	// policyFilter restricts the evaluated policies. The stored results of the
+	// other policies are kept in the reports of the audited resources
+	policyFilter policies.Filter
+	// targeted is true when the scan is restricted to some policies or resources,
+	// or run by an AuditSchedule, in which case the reports of the resources not
+	// audited are not deleted
+	targeted bool
+	// evaluations shares the verdicts of the policies between equivalent resources,
+	// nil when the evaluations are not deduplicated
+	evaluations *evaluationCache
+	// events emits the Events of the results that changed since the previous
+	// reports, nil when they are not emitted
+	events *events.Recorder
+	// notifier sends the failures found by the scans to the notification
+	// sinks, nil when nothing is sent
+	notifier *notification.Notifier
 }
 
 // NewScanner creates a new scanner
@@ -95,22 +171,61 @@ func NewScanner(config Config) (*Scanner, error) {
 	tlsConfig.InsecureSkipVerify = config.TLS.Insecure
 
 	httpClient := *http.DefaultClient
-	httpClient.Timeout = httpClientTimeout
+	httpClient.Timeout = config.PolicyServerClient.Timeout
+	if httpClient.Timeout == 0 {
+		httpClient.Timeout = defaultHTTPClientTimeout
+	}
 	httpClient.Transport = http.DefaultTransport
 	transport, ok := httpClient.Transport.(*http.Transport)
 	if !ok {
 		return nil, errors.New("failed to build httpClient: failed http.Transport type assertion")
 	}
 
+	transport = transport.Clone()
 	transport.TLSClientConfig = tlsConfig
 
 	// By default, the http client reuses connections. This causes
 	// scaling issues when a PolicyServer instance is backed by multiple
 	// replicas. In this scanerio, the requests are sent to the same
 	// PolicyServer Pod, causing the load to be unevenly distributed.
-	// To avoid this, we disable keep-alives, which ensures a
-	// new connection is created for each evaluation request.
-	transport.DisableKeepAlives = true
+	// When the PolicyServer pods are discovered through EndpointSlices,
+	// each pod gets its own pool of keep-alive connections. Otherwise,
+	// we disable keep-alives, which ensures a new connection is created
+	// for each evaluation request.
+	fallbackTransport := transport.Clone()
+	fallbackTransport.DisableKeepAlives = true
+	httpClient.Transport = fallbackTransport
+
+	recorder, err := metrics.NewRecorder(otel.GetMeterProvider())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the metrics recorder: %w", err)
+	}
+
+	var endpoints *endpointsBalancer
+	if config.Endpoints.Clientset != nil {
+		endpoints, err = newEndpointsBalancer(config.Endpoints.Clientset, config.Endpoints.Namespace, transport, fallbackTransport, logger)
+		if err != nil {
+			return nil, err
+		}
+		if err = endpoints.start(); err != nil {
+			return nil, fmt.Errorf("failed to discover the PolicyServer endpoints: %w", err)
+		}
+		httpClient.Transport = endpoints
+	}
+
+	var policyFilter policies.Filter
+	if config.PoliciesClient != nil {
+		policyFilter = config.PoliciesClient.Filter()
+	}
+	targeted := !policyFilter.IsEmpty() || config.AuditSchedule != ""
+	if config.K8sClient != nil {
+		targeted = targeted || !config.K8sClient.Selectors().IsEmpty()
+	}
+
+	var evaluations *evaluationCache
+	if config.DeduplicateEvaluations {
+		evaluations = newEvaluationCache()
+	}
 
 	return &Scanner{
 		policiesClient:           config.PoliciesClient,
@@ -118,24 +233,139 @@ func NewScanner(config Config) (*Scanner, error) {
 		reportStore:              config.ReportStore,
 		httpClient:               httpClient,
 		outputScan:               config.OutputScan,
+		output:                   config.Output,
 		disableStore:             config.DisableStore,
+		incremental:              config.Incremental,
 		parallelNamespacesAudits: config.Parallelization.ParallelNamespacesAudits,
 		parallelResourcesAudits:  config.Parallelization.ParallelResourcesAudits,
 		parallelPoliciesAudits:   config.Parallelization.PoliciesAudits,
 		logger:                   logger,
 		reportKind:               config.ReportKind,
+		matchConditions:          newMatchConditionsEvaluator(),
+		userInfo:                 config.UserInfo,
+		maxRetries:               config.PolicyServerClient.MaxRetries,
+		retryBackoff: wait.Backoff{
+			Duration: config.PolicyServerClient.RetryBackoff,
+			Factor:   retryBackoffFactor,
+			Jitter:   retryBackoffJitter,
+			Steps:    config.PolicyServerClient.MaxRetries,
+			Cap:      config.PolicyServerClient.MaxRetryBackoff,
+		},
+		circuitBreakers: newCircuitBreakers(config.PolicyServerClient.CircuitBreakerThreshold, config.PolicyServerClient.CircuitBreakerCooldown),
+		endpoints:       endpoints,
+		metrics:         recorder,
+		checkpoint:      config.Checkpoint,
+		auditRun:        config.AuditRun,
+		shard:           config.Shard,
+		violations:      config.Violations,
+		policyFilter:    policyFilter,
+		targeted:        targeted,
+		evaluations:     evaluations,
+		events:          config.Events,
+		notifier:        config.Notifier,
 	}, nil
 }
 
+// Close stops watching the PolicyServer endpoints, sends the pending
+// notifications, closes the idle connections, and releases the resources of the
+// report store, e.g. its database connection.
+func (s *Scanner) Close() {
+	if s.endpoints != nil {
+		s.endpoints.stop()
+	}
+	if s.notifier != nil {
+		s.notifier.Close()
+	}
+	s.httpClient.CloseIdleConnections()
+	if closer, ok := s.reportStore.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			s.logger.Warn("failed to close the report store", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// StartRun returns the UID of a new run. When the runs are checkpointed and the
+// previous run was interrupted, the UID of the previous run is returned instead,
+// and the scans skip the resources it already audited.
+// When the runs are recorded, the AuditRun of the run is created. A failure to
+// record the run is logged, and the run is not recorded.
+func (s *Scanner) StartRun(ctx context.Context) (string, error) {
+	runUID := uuid.New().String()
+	if s.checkpoint != nil {
+		var err error
+		runUID, err = s.checkpoint.Start(ctx, runUID)
+		if err != nil {
+			return "", fmt.Errorf("failed to start the run: %w", err)
+		}
+	}
+
+	if s.auditRun != nil {
+		if err := s.auditRun.Start(ctx, runUID); err != nil {
+			s.logger.WarnContext(ctx, "failed to record the run, continuing without AuditRun",
+				slog.String("error", err.Error()),
+				slog.String("RunUID", runUID))
+			s.auditRun = nil
+		}
+	}
+	return runUID, nil
+}
+
+// FinishRun records the end of the run in its AuditRun, if any, with the error
+// returned by the scan. Unless the run was interrupted, the failures of the run
+// are sent to the notification sinks triggered at the end of the runs, and the
+// checkpoint of the run, if any, is deleted so the next run starts from scratch.
+func (s *Scanner) FinishRun(ctx context.Context, runErr error) {
+	interrupted := ctx.Err() != nil
+	if s.auditRun != nil {
+		if err := s.auditRun.Finish(ctx, runErr, interrupted); err != nil {
+			s.logger.WarnContext(ctx, "failed to record the end of the run", slog.String("error", err.Error()))
+		}
+	}
+	if s.notifier != nil && !interrupted {
+		s.notifier.FinishRun(ctx)
+	}
+
+	if s.checkpoint == nil || interrupted {
+		return
+	}
+	if err := s.checkpoint.Finish(ctx); err != nil {
+		s.logger.WarnContext(ctx, "failed to delete the progress of the run", slog.String("error", err.Error()))
+	}
+}
+
+// writeOutput writes the report to the scan output, if any. Failures are
+// logged, so the results are still stored.
+func (s *Scanner) writeOutput(ctx context.Context, r report.Report) {
+	if s.output == nil {
+		return
+	}
+	if err := s.output.Write(r); err != nil {
+		s.logger.ErrorContext(ctx, "error while writing the scan output", slog.String("error", err.Error()))
+	}
+}
+
 // ScanNamespace scans resources for a given namespace.
 // Returns errors if there's any when fetching policies or resources, but only
 // logs them if there's a problem auditing the resource of saving the Report or
 // Result, so it can continue with the next audit, or next Result.
 func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) error {
+	if s.auditRun != nil {
+		s.auditRun.SetNamespacesTotal(1)
+	}
+	return s.scanNamespace(ctx, nsName, runUID)
+}
+
+// scanNamespace scans resources for a given namespace, as part of a scan of
+// one or more namespaces.
+func (s *Scanner) scanNamespace(ctx context.Context, nsName, runUID string) error {
 	s.logger.InfoContext(ctx, "namespace scan started",
 		slog.String("namespace", nsName),
 		slog.String("RunUID", runUID),
 		slog.Int("parallel-resources-audits", s.parallelResourcesAudits))
+	if s.checkpoint != nil && s.checkpoint.IsNamespaceCompleted(nsName) {
+		s.logger.InfoContext(ctx, "namespace already scanned by the resumed run", slog.String("namespace", nsName))
+		return nil
+	}
 	semaphore := semaphore.NewWeighted(int64(s.parallelResourcesAudits))
 	var workers sync.WaitGroup
 
@@ -153,37 +383,18 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 		slog.Int("policies-to-evaluate", policies.PolicyNum),
 		slog.Int("policies-skipped", policies.SkippedNum),
 		slog.Int("policies-errored", policies.ErroredNum))
+	s.recordPolicies(policies)
 
 	for gvr, pols := range policies.PoliciesByGVR {
-		pager := s.k8sClient.GetResources(gvr, nsName)
-
-		err = pager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
-			resource, ok := obj.(*unstructured.Unstructured)
-			if !ok {
-				return errors.New("failed to convert runtime.Object to *unstructured.Unstructured")
+		err = s.auditResources(ctx, gvr, nsName, semaphore, &workers, func(resource unstructured.Unstructured) {
+			if _, auditErr := s.auditResource(ctx, pols, gvr, resource, runUID, policies.NotAudited(gvr)); auditErr != nil {
+				s.logger.ErrorContext(ctx, "error auditing resource",
+					slog.String("error", auditErr.Error()),
+					slog.String("RunUID", runUID))
 			}
-
-			acquireErr := semaphore.Acquire(ctx, 1)
-			if acquireErr != nil {
-				return fmt.Errorf("failed to acquire the permission to audit resource: %w", acquireErr)
-			}
-			workers.Add(1)
-			policiesToAudit := pols
-
-			go func() {
-				defer semaphore.Release(1)
-				defer workers.Done()
-
-				if auditErr := s.auditResource(ctx, policiesToAudit, *resource, runUID, policies.SkippedNum, policies.ErroredNum); auditErr != nil {
-					s.logger.ErrorContext(ctx, "error auditing resource",
-						slog.String("error", auditErr.Error()),
-						slog.String("RunUID", runUID))
-				}
-			}()
-			return nil
 		})
 		if err != nil {
-			// If we fail to get the resources, we log the error inside the pager function
+			// If we fail to get the resources, we log the error
 			// and continue with the next GVR. Otherwise, the scan would stop
 			// and not audit any other resources.
 			s.logger.WarnContext(ctx, "Failed to list resources",
@@ -195,16 +406,26 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 	}
 	workers.Wait()
 
-	if deleteErr := s.reportStore.DeleteOldReports(ctx, runUID, nsName); deleteErr != nil {
+	// The old reports are only deleted once all the resources of the namespace
+	// are audited. An interrupted scan is resumed by the next run, if checkpointed
+	if ctx.Err() != nil {
+		return fmt.Errorf("scan of namespace %s interrupted: %w", nsName, ctx.Err())
+	}
+	if s.targeted {
+		s.logger.DebugContext(ctx, "targeted scan, keeping the reports of the resources not audited", slog.String("namespace", nsName))
+	} else if deleteErr := s.reportStore.DeleteOldReports(ctx, runUID, nsName); deleteErr != nil {
+		s.metrics.RecordReportStoreFailure(ctx, runUID, metrics.OperationDeleteReports)
 		s.logger.ErrorContext(ctx, "error deleting old reports",
 			slog.String("error", deleteErr.Error()),
 			slog.String("RunUID", runUID))
 	}
+	s.completeNamespace(ctx, nsName)
 	s.logger.InfoContext(ctx, "Namespaced resources scan finished")
 	return nil
 }
 
 // ScanAllNamespaces scans resources for all namespaces, except the ones in the skipped list.
+// When the scan is sharded, only the namespaces owned by the shard are scanned.
 // Returns errors if there's any when fetching policies or resources, but only
 // logs them if there's a problem auditing the resource of saving the Report or
 // Result, so it can continue with the next audit, or next Result.
@@ -214,7 +435,19 @@ func (s *Scanner) ScanAllNamespaces(ctx context.Context, runUID string) error {
 			slog.Int("parallel-namespaces-audits", s.parallelNamespacesAudits)))
 	nsList, err := s.k8sClient.GetAuditedNamespaces(ctx)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "error scanning all namespaces", slog.String("error", err.Error()))
+		return fmt.Errorf("error scanning all namespaces: %w", err)
+	}
+	if s.shard.Enabled() {
+		nsList.Items = slices.DeleteFunc(nsList.Items, func(namespace corev1.Namespace) bool {
+			return !s.shard.OwnsNamespace(namespace.Name)
+		})
+		s.logger.InfoContext(ctx, "namespaces owned by the shard",
+			slog.String("shard", s.shard.String()),
+			slog.Int("shard-count", s.shard.Count),
+			slog.Int("namespaces", len(nsList.Items)))
+	}
+	if s.auditRun != nil {
+		s.auditRun.SetNamespacesTotal(len(nsList.Items))
 	}
 	semaphore := semaphore.NewWeighted(int64(s.parallelNamespacesAudits))
 	var workers sync.WaitGroup
@@ -231,7 +464,7 @@ func (s *Scanner) ScanAllNamespaces(ctx context.Context, runUID string) error {
 			defer semaphore.Release(1)
 			defer workers.Done()
 
-			if e := s.ScanNamespace(ctx, namespaceName, runUID); e != nil {
+			if e := s.scanNamespace(ctx, namespaceName, runUID); e != nil {
 				s.logger.ErrorContext(ctx, "error scanning namespace", slog.String("error", e.Error()), slog.String("ns", namespaceName))
 				err = errors.Join(err, e)
 			}
@@ -248,11 +481,16 @@ func (s *Scanner) ScanAllNamespaces(ctx context.Context, runUID string) error {
 }
 
 // ScanClusterWideResources scans all cluster wide resources.
+// When the scan is sharded, only the resources of the GVRs owned by the shard are scanned.
 // Returns errors if there's any when fetching policies or resources, but only
 // logs them if there's a problem auditing the resource of saving the Report or
 // Result, so it can continue with the next audit, or next Result.
 func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) error {
 	s.logger.InfoContext(ctx, "clusterwide resources scan started", slog.String("RunUID", runUID))
+	if s.checkpoint != nil && s.checkpoint.IsNamespaceCompleted("") {
+		s.logger.InfoContext(ctx, "clusterwide resources already scanned by the resumed run")
+		return nil
+	}
 
 	semaphore := semaphore.NewWeighted(int64(s.parallelResourcesAudits))
 	var workers sync.WaitGroup
@@ -267,33 +505,18 @@ func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) e
 		slog.Int("policies-skipped", policies.SkippedNum),
 		slog.Int("policies-errored", policies.ErroredNum),
 		slog.Int("parallel-resources-audits", s.parallelResourcesAudits))
+	s.recordPolicies(policies)
 
 	for gvr, pols := range policies.PoliciesByGVR {
-		pager := s.k8sClient.GetResources(gvr, "")
-		err = pager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
-			resource, ok := obj.(*unstructured.Unstructured)
-			if !ok {
-				return errors.New("failed to convert runtime.Object to *unstructured.Unstructured")
-			}
-
-			workers.Add(1)
-			acquireErr := semaphore.Acquire(ctx, 1)
-			if acquireErr != nil {
-				return fmt.Errorf("failed to acquire the permission to audit a resource: %w", acquireErr)
-			}
-			policiesToAudit := pols
-
-			go func() {
-				defer semaphore.Release(1)
-				defer workers.Done()
-
-				s.auditClusterResource(ctx, policiesToAudit, *resource, runUID, policies.SkippedNum, policies.ErroredNum)
-			}()
-
-			return nil
+		if !s.shard.OwnsGVR(gvr) {
+			s.logger.DebugContext(ctx, "resources owned by another shard, skipping", slog.String("resource-GVK", gvr.String()))
+			continue
+		}
+		err = s.auditResources(ctx, gvr, "", semaphore, &workers, func(resource unstructured.Unstructured) {
+			s.auditClusterResource(ctx, pols, gvr, resource, runUID, policies.NotAudited(gvr))
 		})
 		if err != nil {
-			// If we fail to get the resources, we log the error inside the pager function
+			// If we fail to get the resources, we log the error
 			// and continue with the next GVR. Otherwise, the scan would stop
 			// and not audit any other resources.
 			s.logger.WarnContext(ctx, "Failed to list resources",
@@ -305,57 +528,263 @@ func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) e
 
 	workers.Wait()
 
-	if deleteErr := s.reportStore.DeleteOldClusterReports(ctx, runUID); deleteErr != nil {
+	// The old reports are only deleted once all the cluster-wide resources
+	// are audited. An interrupted scan is resumed by the next run, if checkpointed
+	if ctx.Err() != nil {
+		return fmt.Errorf("scan of cluster-wide resources interrupted: %w", ctx.Err())
+	}
+	if s.targeted {
+		s.logger.DebugContext(ctx, "targeted scan, keeping the ClusterReports of the resources not audited")
+	} else if deleteErr := s.reportStore.DeleteOldClusterReports(ctx, runUID, s.shard); deleteErr != nil {
+		s.metrics.RecordReportStoreFailure(ctx, runUID, metrics.OperationDeleteClusterReport)
 		s.logger.ErrorContext(ctx, "error deleting old ClusterReports",
 			slog.String("error", deleteErr.Error()),
 			slog.String("RunUID", runUID))
 	}
+	s.completeNamespace(ctx, "")
 	s.logger.InfoContext(ctx, "Cluster-wide resources scan finished")
 	return nil
 }
 
+// auditResources lists the resources of the GVR in the namespace, an empty
+// namespace for the cluster-wide resources, and audits each of them in a worker.
+// When the run is checkpointed, the list is resumed from the saved continue
+// token, and the progress is saved once all the resources of a page are audited.
+func (s *Scanner) auditResources(ctx context.Context, gvr schema.GroupVersionResource, nsName string, semaphore *semaphore.Weighted, workers *sync.WaitGroup, audit func(resource unstructured.Unstructured)) error {
+	startWorker := func(resource unstructured.Unstructured, workers *sync.WaitGroup) error {
+		if err := semaphore.Acquire(ctx, 1); err != nil {
+			return fmt.Errorf("failed to acquire the permission to audit resource: %w", err)
+		}
+		workers.Add(1)
+		go func() {
+			defer semaphore.Release(1)
+			defer workers.Done()
+
+			audit(resource)
+		}()
+		return nil
+	}
+
+	if s.checkpoint == nil {
+		pager := s.k8sClient.GetResources(gvr, nsName)
+		err := pager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
+			resource, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return errors.New("failed to convert runtime.Object to *unstructured.Unstructured")
+			}
+			return startWorker(*resource, workers)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list resources: %w", err)
+		}
+		return nil
+	}
+
+	completed, continueToken := s.checkpoint.ResourcesProgress(nsName, gvr)
+	if completed {
+		return nil
+	}
+	for {
+		list, err := s.k8sClient.GetResourcesPage(ctx, gvr, nsName, continueToken)
+		if apierrors.IsResourceExpired(err) && continueToken != "" {
+			// The saved continue token expired, list all the resources again
+			s.logger.WarnContext(ctx, "continue token expired, listing the resources from the beginning",
+				slog.String("resource-GVK", gvr.String()),
+				slog.String("ns", nsName))
+			continueToken = ""
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list resources: %w", err)
+		}
+
+		// The progress is saved once the page is fully audited
+		var pageWorkers sync.WaitGroup
+		for _, resource := range list.Items {
+			if err = startWorker(resource, &pageWorkers); err != nil {
+				pageWorkers.Wait()
+				return err
+			}
+		}
+		pageWorkers.Wait()
+
+		continueToken = list.GetContinue()
+		if saveErr := s.checkpoint.SaveResourcesProgress(ctx, nsName, gvr, continueToken); saveErr != nil {
+			s.logger.WarnContext(ctx, "failed to save the progress of the run", slog.String("error", saveErr.Error()))
+		}
+		if continueToken == "" {
+			return nil
+		}
+	}
+}
+
+// completeNamespace records in the checkpoint and in the AuditRun, if any, that
+// all the resources of the namespace are audited. An empty namespace refers to
+// the cluster-wide resources.
+func (s *Scanner) completeNamespace(ctx context.Context, nsName string) {
+	if s.auditRun != nil {
+		var err error
+		if nsName == "" {
+			err = s.auditRun.Flush(ctx)
+		} else {
+			err = s.auditRun.CompleteNamespace(ctx)
+		}
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to record the progress of the run", slog.String("error", err.Error()))
+		}
+	}
+
+	if s.checkpoint == nil {
+		return
+	}
+	if err := s.checkpoint.CompleteNamespace(ctx, nsName); err != nil {
+		s.logger.WarnContext(ctx, "failed to save the progress of the run", slog.String("error", err.Error()))
+	}
+}
+
+// recordPolicies records the policies skipped or errored in the AuditRun, if any.
+func (s *Scanner) recordPolicies(policies *policies.Policies) {
+	if s.auditRun != nil {
+		s.auditRun.RecordPolicies(policies.SkippedPolicies, policies.ErroredPolicies)
+	}
+}
+
+// recordReport counts the results of the report in the AuditRun and in the
+// violations of the scan, if any, and records its failures for the
+// notifications sent at the end of the run.
+func (s *Scanner) recordReport(runUID string, r report.Report) {
+	if s.auditRun != nil {
+		s.auditRun.RecordReport(r)
+	}
+	if s.violations != nil {
+		s.violations.Record(r)
+	}
+	if s.notifier != nil {
+		s.notifier.RecordReport(runUID, r)
+	}
+}
+
+// ScanResources audits the given resources, which are not read from the cluster,
+// like the ones of local manifests. The policies targeting each resource are
+// selected by the matcher. It returns the reports of the resources, in the same
+// order as the resources.
+func (s *Scanner) ScanResources(ctx context.Context, resources []unstructured.Unstructured, matcher *policies.ResourceMatcher, runUID string) ([]report.Report, error) {
+	s.logger.InfoContext(ctx, "resources scan started",
+		slog.String("RunUID", runUID),
+		slog.Int("resources", len(resources)))
+
+	reports := make([]report.Report, 0, len(resources))
+	for _, resource := range resources {
+		resourcePolicies := matcher.Match(resource)
+		// Like the scans of the cluster, the resources not targeted by any
+		// policy have no report
+		if len(resourcePolicies.Policies) == 0 && len(resourcePolicies.NotAudited) == 0 {
+			s.logger.DebugContext(ctx, "no policies targeting the resource",
+				slog.String("kind", resource.GetKind()),
+				slog.String("resource", resource.GetName()))
+			continue
+		}
+		if resourcePolicies.Namespace == "" {
+			reports = append(reports, s.auditClusterResource(ctx, resourcePolicies.Policies, resourcePolicies.GVR, resource, runUID, resourcePolicies.NotAudited))
+			continue
+		}
+
+		// The namespaced resources without a namespace are audited as if they
+		// were created in the default namespace
+		namespacedResource := *resource.DeepCopy()
+		namespacedResource.SetNamespace(resourcePolicies.Namespace)
+		policyReport, err := s.auditResource(ctx, resourcePolicies.Policies, resourcePolicies.GVR, namespacedResource, runUID, resourcePolicies.NotAudited)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, policyReport)
+	}
+
+	s.logger.InfoContext(ctx, "resources scan finished")
+	return reports, nil
+}
+
 type policyAuditResult struct {
 	policy                  policiesv1.Policy
 	admissionReviewResponse *admissionv1.AdmissionReview
 	errored                 bool
+	// skipReason is set when the policy was not evaluated
+	skipReason string
+	// errorReason is set when the policy could not be matched against the
+	// resource, e.g. a matchCondition failed to evaluate with the Fail policy
+	errorReason string
 }
 
 //gocognit:ignore
-func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy, resource unstructured.Unstructured, runUID string, skippedPoliciesNum, erroredPoliciesNum int) error {
+func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy, gvr schema.GroupVersionResource, resource unstructured.Unstructured, runUID string, notAudited []policies.NotAuditedPolicy) (report.Report, error) {
 	s.logger.InfoContext(ctx, "audit resource",
 		slog.String("resource", resource.GetName()),
 		slog.Int("policies-to-evaluate", len(policies)),
 		slog.Int("parallel-policies-audit", s.parallelPoliciesAudits))
 
+	policyReport := report.NewReportOfKind(s.reportKind, runUID, resource)
+	s.addNotAuditedResults(ctx, policyReport, notAudited, runUID)
+	previousReport := s.getPreviousReport(ctx, resource)
+	s.keepResults(policyReport, previousReport)
+	contentHash := s.contentHash(ctx, resource)
+
 	semaphore := semaphore.NewWeighted(int64(s.parallelPoliciesAudits))
 	var workers sync.WaitGroup
 	auditResults := make(chan policyAuditResult, len(policies))
 
 	for _, policyToUse := range policies {
+		if s.incremental && previousReport != nil && policyReport.ReuseResult(previousReport, policyToUse.Policy) {
+			s.logger.DebugContext(ctx, "reusing result from previous scan",
+				slog.String("policy", policyToUse.GetName()),
+				slog.String("resource", resource.GetName()))
+			continue
+		}
+
 		err := semaphore.Acquire(ctx, 1)
 		if err != nil {
-			return fmt.Errorf("failed to acquire the permission to audit a resource: %w", err)
+			return nil, fmt.Errorf("failed to acquire the permission to audit a resource: %w", err)
 		}
 		workers.Add(1)
 
 		url := policyToUse.PolicyServer
 		policy := policyToUse.Policy
+		operation := policyToUse.Operation
 
 		go func() {
 			defer semaphore.Release(1)
 			defer workers.Done()
 
-			matches, matchErr := policyMatches(policy, resource)
+			admissionReviewRequest := newAdmissionReview(resource, gvr, operation, s.userInfo)
+			matches, matchErr := s.policyMatches(ctx, policy, resource, admissionReviewRequest.Request)
 			if matchErr != nil {
-				s.logger.ErrorContext(ctx, "error matching policy to resource", slog.String("error", matchErr.Error()))
+				s.logger.ErrorContext(ctx, "error matching policy to resource",
+					slog.String("error", matchErr.Error()),
+					slog.String("policy", policy.GetName()),
+					slog.String("resource", resource.GetName()))
+				auditResults <- policyAuditResult{
+					policy:      policy,
+					errorReason: matchErr.Error(),
+				}
+				return
 			}
 
 			if !matches {
 				return
 			}
 
-			admissionReviewRequest := newAdmissionReview(resource)
-			admissionReviewResponse, responseErr := s.sendAdmissionReviewToPolicyServer(ctx, url, admissionReviewRequest)
+			evaluationStart := time.Now()
+			admissionReviewResponse, reused, responseErr := s.evaluate(ctx, runUID, url, policy, gvr, contentHash, admissionReviewRequest)
+			if errors.Is(responseErr, errPolicyServerUnavailable) {
+				s.logger.WarnContext(ctx, "skipping policy evaluation",
+					slog.String("reason", responseErr.Error()),
+					slog.String("policy", policy.GetName()),
+					slog.String("resource", resource.GetName()))
+				auditResults <- policyAuditResult{
+					policy:     policy,
+					skipReason: responseErr.Error(),
+				}
+				return
+			}
 			errored := false
 
 			if responseErr != nil {
@@ -378,6 +807,8 @@ func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy
 						slog.String("resource", resource.GetName())))
 			}
 
+			s.recordEvaluation(ctx, runUID, policy, resource, time.Since(evaluationStart), errored, reused)
+
 			if !errored {
 				s.logger.DebugContext(ctx, "audit review response",
 					slog.Group("response",
@@ -388,21 +819,30 @@ func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy
 			}
 
 			auditResults <- policyAuditResult{
-				policy,
-				admissionReviewResponse,
-				errored,
+				policy:                  policy,
+				admissionReviewResponse: admissionReviewResponse,
+				errored:                 errored,
 			}
 		}()
 	}
 	workers.Wait()
 	close(auditResults)
 
-	policyReport := report.NewReportOfKind(s.reportKind, runUID, resource)
-	policyReport.SetErrorPolicies(erroredPoliciesNum)
-	policyReport.SetSkipPolicies(skippedPoliciesNum)
 	for res := range auditResults {
+		if res.skipReason != "" {
+			policyReport.AddSkippedResult(res.policy, res.skipReason)
+			s.metrics.RecordPolicyResult(ctx, runUID, res.policy.GetUniqueName(), metrics.ResultSkip)
+			continue
+		}
+		if res.errorReason != "" {
+			policyReport.AddErroredResult(res.policy, res.errorReason)
+			s.metrics.RecordPolicyResult(ctx, runUID, res.policy.GetUniqueName(), metrics.ResultError)
+			continue
+		}
 		policyReport.AddResult(res.policy, res.admissionReviewResponse, res.errored)
+		s.metrics.RecordPolicyResult(ctx, runUID, res.policy.GetUniqueName(), evaluationResult(res.policy, res.errored, res.admissionReviewResponse))
 	}
+	s.metrics.RecordResourceAudited(ctx, runUID, gvr, resource.GetNamespace())
 
 	if s.outputScan {
 		policyReportJSON, err := json.Marshal(policyReport)
@@ -412,39 +852,72 @@ func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy
 
 		s.logger.InfoContext(ctx, "PolicyReport summary", slog.String("report", string(policyReportJSON)))
 	}
+	s.writeOutput(ctx, policyReport)
+	s.recordReport(runUID, policyReport)
+	s.recordChanges(ctx, runUID, previousReport, policyReport, policies)
 
 	if !s.disableStore {
 		err := s.reportStore.CreateOrPatchReport(ctx, policyReport)
 		if err != nil {
+			s.metrics.RecordReportStoreFailure(ctx, runUID, metrics.OperationWriteReport)
 			s.logger.ErrorContext(ctx, "error adding PolicyReport to store.", slog.String("error", err.Error()))
 		}
 	}
-	return nil
+	return policyReport, nil
 }
 
-func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies.Policy, resource unstructured.Unstructured, runUID string, skippedPoliciesNum, erroredPoliciesNum int) {
+func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies.Policy, gvr schema.GroupVersionResource, resource unstructured.Unstructured, runUID string, notAudited []policies.NotAuditedPolicy) report.Report {
 	s.logger.InfoContext(ctx, "audit clusterwide resource",
 		slog.String("resource", resource.GetName()),
 		slog.Int("policies-to-evaluate", len(policies)))
 
 	clusterReport := report.NewClusterReportOfKind(s.reportKind, runUID, resource)
-	clusterReport.SetSkipPolicies(skippedPoliciesNum)
-	clusterReport.SetErrorPolicies(erroredPoliciesNum)
+	s.addNotAuditedResults(ctx, clusterReport, notAudited, runUID)
+	if s.shard.Enabled() {
+		clusterReport.SetShardKey(shard.GVRKey(gvr))
+	}
+	previousReport := s.getPreviousClusterReport(ctx, resource)
+	s.keepResults(clusterReport, previousReport)
+	contentHash := s.contentHash(ctx, resource)
 	for _, p := range policies {
 		url := p.PolicyServer
 		policy := p.Policy
+		operation := p.Operation
+
+		if s.incremental && previousReport != nil && clusterReport.ReuseResult(previousReport, policy) {
+			s.logger.DebugContext(ctx, "reusing result from previous scan",
+				slog.String("policy", policy.GetName()),
+				slog.String("resource", resource.GetName()))
+			continue
+		}
 
-		matches, err := policyMatches(policy, resource)
+		admissionReviewRequest := newAdmissionReview(resource, gvr, operation, s.userInfo)
+		matches, err := s.policyMatches(ctx, policy, resource, admissionReviewRequest.Request)
 		if err != nil {
-			s.logger.ErrorContext(ctx, "error matching policy to resource", slog.String("error", err.Error()))
+			s.logger.ErrorContext(ctx, "error matching policy to resource",
+				slog.String("error", err.Error()),
+				slog.String("policy", policy.GetName()),
+				slog.String("resource", resource.GetName()))
+			clusterReport.AddErroredResult(policy, err.Error())
+			s.metrics.RecordPolicyResult(ctx, runUID, policy.GetUniqueName(), metrics.ResultError)
+			continue
 		}
 
 		if !matches {
 			continue
 		}
 
-		admissionReviewRequest := newAdmissionReview(resource)
-		admissionReviewResponse, responseErr := s.sendAdmissionReviewToPolicyServer(ctx, url, admissionReviewRequest)
+		evaluationStart := time.Now()
+		admissionReviewResponse, reused, responseErr := s.evaluate(ctx, runUID, url, policy, gvr, contentHash, admissionReviewRequest)
+		if errors.Is(responseErr, errPolicyServerUnavailable) {
+			s.logger.WarnContext(ctx, "skipping policy evaluation",
+				slog.String("reason", responseErr.Error()),
+				slog.String("policy", policy.GetName()),
+				slog.String("resource", resource.GetName()))
+			clusterReport.AddSkippedResult(policy, responseErr.Error())
+			s.metrics.RecordPolicyResult(ctx, runUID, policy.GetUniqueName(), metrics.ResultSkip)
+			continue
+		}
 		errored := false
 
 		if responseErr != nil {
@@ -466,6 +939,8 @@ func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies
 					slog.String("resource", resource.GetName())))
 		}
 
+		s.recordEvaluation(ctx, runUID, policy, resource, time.Since(evaluationStart), errored, reused)
+
 		if !errored {
 			s.logger.DebugContext(ctx, "audit review response",
 				slog.Group("response",
@@ -476,7 +951,9 @@ func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies
 		}
 
 		clusterReport.AddResult(policy, admissionReviewResponse, errored)
+		s.metrics.RecordPolicyResult(ctx, runUID, policy.GetUniqueName(), evaluationResult(policy, errored, admissionReviewResponse))
 	}
+	s.metrics.RecordResourceAudited(ctx, runUID, gvr, "")
 
 	if s.outputScan {
 		clusterPolicyReportJSON, err := json.Marshal(clusterReport)
@@ -486,39 +963,319 @@ func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies
 
 		s.logger.InfoContext(ctx, "ClusterPolicyReport summary", slog.Any("report", clusterPolicyReportJSON))
 	}
+	s.writeOutput(ctx, clusterReport)
+	s.recordReport(runUID, clusterReport)
+	s.recordChanges(ctx, runUID, previousReport, clusterReport, policies)
 
 	if !s.disableStore {
 		err := s.reportStore.CreateOrPatchClusterReport(ctx, clusterReport)
 		if err != nil {
+			s.metrics.RecordReportStoreFailure(ctx, runUID, metrics.OperationWriteClusterReport)
 			s.logger.ErrorContext(ctx, "error adding ClusterPolicyReport to store", slog.String("error", err.Error()))
 		}
 	}
+	return clusterReport
 }
 
-func policyMatches(policy policiesv1.Policy, resource unstructured.Unstructured) (bool, error) {
-	if policy.GetObjectSelector() == nil {
-		return true, nil
+// getPreviousReport returns the report stored by the previous scan for the given
+// namespaced resource. It returns nil when the previous reports are not used, see
+// usesPreviousReports, or when the report cannot be retrieved, in which case
+// every policy is evaluated.
+func (s *Scanner) getPreviousReport(ctx context.Context, resource unstructured.Unstructured) report.Report {
+	if !s.usesPreviousReports() {
+		return nil
 	}
 
-	selector, err := metav1.LabelSelectorAsSelector(policy.GetObjectSelector())
+	previousReport, err := s.reportStore.GetReport(ctx, resource.GetNamespace(), resource.GetUID())
 	if err != nil {
-		return false, fmt.Errorf("failed to convert label selector from policy object selector: %w", err)
+		if !errors.Is(err, constants.ErrResourceNotFound) {
+			s.logger.WarnContext(ctx, "cannot get previous report, evaluating all the policies",
+				slog.String("error", err.Error()),
+				slog.String("resource", resource.GetName()))
+		}
+		return nil
+	}
+	return previousReport
+}
+
+// getPreviousClusterReport returns the report stored by the previous scan for the given
+// cluster-wide resource. It returns nil when the previous reports are not used, see
+// usesPreviousReports, or when the report cannot be retrieved, in which case
+// every policy is evaluated.
+func (s *Scanner) getPreviousClusterReport(ctx context.Context, resource unstructured.Unstructured) report.Report {
+	if !s.usesPreviousReports() {
+		return nil
 	}
 
-	labels := labels.Set(resource.GetLabels())
-	if !selector.Matches(labels) {
+	previousReport, err := s.reportStore.GetClusterReport(ctx, resource.GetUID())
+	if err != nil {
+		if !errors.Is(err, constants.ErrResourceNotFound) {
+			s.logger.WarnContext(ctx, "cannot get previous cluster report, evaluating all the policies",
+				slog.String("error", err.Error()),
+				slog.String("resource", resource.GetName()))
+		}
+		return nil
+	}
+	return previousReport
+}
+
+// usesPreviousReports returns true when the reports stored by the previous scan
+// are needed, either to reuse their results, to keep the results of the
+// policies not evaluated by a scan targeting some policies, or to find the
+// results that changed since then.
+func (s *Scanner) usesPreviousReports() bool {
+	return !s.disableStore && (s.incremental || len(s.policyFilter.Policies) > 0 || s.comparesResults())
+}
+
+// comparesResults returns true when the results of the audited resources are
+// compared with the ones of their previous report, to emit the Events of the
+// changes or to notify the new failures.
+func (s *Scanner) comparesResults() bool {
+	return s.events != nil || (s.notifier != nil && s.notifier.NotifiesNewFailures())
+}
+
+// keepResults copies to the report the results of the previous report, if any,
+// of the policies not evaluated because of the policy filter, so a scan
+// targeting some policies does not drop the results of the other ones.
+func (s *Scanner) keepResults(r report.Report, previousReport report.Report) {
+	if previousReport == nil || len(s.policyFilter.Policies) == 0 {
+		return
+	}
+	r.KeepResults(previousReport, func(policyName, policyNamespace string) bool {
+		return !s.policyFilter.SelectsPolicy(policyName, policyNamespace)
+	})
+}
+
+// recordChanges emits the Events of the audited policies that started or
+// stopped failing for the resource since its previous report, if any, and
+// counts them in the AuditRun. The new failures are sent to the notification
+// sinks triggered by them.
+func (s *Scanner) recordChanges(ctx context.Context, runUID string, previousReport, r report.Report, auditedPolicies []*policies.Policy) {
+	if !s.comparesResults() || previousReport == nil {
+		return
+	}
+	changes := report.ChangedResults(previousReport, r)
+	if len(changes) == 0 {
+		return
+	}
+
+	policiesByName := make(map[string]policiesv1.Policy, len(auditedPolicies))
+	for _, policy := range auditedPolicies {
+		policiesByName[policy.GetUniqueName()] = policy.Policy
+	}
+	resource := r.ResourceResults().Resource
+	recorded := make([]report.ResultChange, 0, len(changes))
+	for _, change := range changes {
+		policy, found := policiesByName[change.Policy]
+		if !found {
+			continue
+		}
+		recorded = append(recorded, change)
+		if s.events == nil {
+			continue
+		}
+		if err := s.events.RecordChange(ctx, resource, policy, change); err != nil {
+			s.logger.WarnContext(ctx, "failed to emit the Events of a result change",
+				slog.String("error", err.Error()),
+				slog.String("policy", change.Policy),
+				slog.String("resource", resource.Name))
+		}
+	}
+	if s.events != nil && s.auditRun != nil {
+		s.auditRun.RecordChanges(recorded)
+	}
+	if s.notifier != nil {
+		s.notifier.NotifyNewFailures(ctx, runUID, r, recorded)
+	}
+}
+
+// addNotAuditedResults adds to the report the results of the policies targeting
+// the resource that are not audited: skip results for the policies that are
+// not auditable, and error results for the ones that may be misconfigured.
+func (s *Scanner) addNotAuditedResults(ctx context.Context, r report.Report, notAudited []policies.NotAuditedPolicy, runUID string) {
+	for _, policy := range notAudited {
+		if policy.Errored {
+			r.AddErroredResult(policy.Policy, policy.Reason)
+			s.metrics.RecordPolicyResult(ctx, runUID, policy.GetUniqueName(), metrics.ResultError)
+			continue
+		}
+		r.AddSkippedResult(policy.Policy, policy.Reason)
+		s.metrics.RecordPolicyResult(ctx, runUID, policy.GetUniqueName(), metrics.ResultSkip)
+	}
+}
+
+// policyMatches returns true if the policy would evaluate the admission request of the resource at admission time.
+// The objectSelector and the matchConditions of the policy are evaluated. An error is returned when they cannot be
+// evaluated, and the failurePolicy of the policy is Fail: the audit reports an error result for the policy. The namespaceSelector is evaluated only
+// against Namespace objects, policies are already selected by the namespace of namespaced resources.
+func (s *Scanner) policyMatches(ctx context.Context, policy policiesv1.Policy, resource unstructured.Unstructured, request *admissionv1.AdmissionRequest) (bool, error) {
+	matches, err := labelSelectorMatches(policy.GetObjectSelector(), resource)
+	if err != nil {
+		return false, fmt.Errorf("failed to convert label selector from policy object selector: %w", err)
+	}
+	if !matches {
 		return false, nil
 	}
 
-	return true, nil
+	if isNamespace(resource) {
+		matches, err = labelSelectorMatches(policy.GetNamespaceSelector(), resource)
+		if err != nil {
+			return false, fmt.Errorf("failed to convert label selector from policy namespace selector: %w", err)
+		}
+		if !matches {
+			return false, nil
+		}
+	}
+
+	return s.matchConditions.matches(ctx, policy, request)
+}
+
+// labelSelectorMatches returns true if the labels of the resource match the given label selector.
+// A nil label selector matches everything.
+func labelSelectorMatches(labelSelector *metav1.LabelSelector, resource unstructured.Unstructured) (bool, error) {
+	if labelSelector == nil {
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return false, err //nolint:wrapcheck // the callers add the context
+	}
+
+	return selector.Matches(labels.Set(resource.GetLabels())), nil
+}
+
+// evaluationResult returns the result of an evaluation, as reported in the metrics.
+func evaluationResult(policy policiesv1.Policy, errored bool, admissionReview *admissionv1.AdmissionReview) string {
+	if errored {
+		return metrics.ResultError
+	}
+	if report.WouldMutate(policy, admissionReview) {
+		return metrics.ResultWarn
+	}
+	if admissionReview.Response.Allowed {
+		return metrics.ResultPass
+	}
+	return metrics.ResultFail
+}
+
+// isNamespace returns true if the resource is a Namespace object.
+func isNamespace(resource unstructured.Unstructured) bool {
+	gvk := resource.GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Namespace"
 }
 
+// contentHash returns the hash of the content of the resource compared to find
+// the equivalent resources, empty when the evaluations are not deduplicated or
+// the hash cannot be computed.
+func (s *Scanner) contentHash(ctx context.Context, resource unstructured.Unstructured) string {
+	if s.evaluations == nil {
+		return ""
+	}
+	hash, err := contentHash(resource)
+	if err != nil {
+		s.logger.WarnContext(ctx, "cannot hash the resource, evaluating it on its own",
+			slog.String("error", err.Error()),
+			slog.String("resource", resource.GetName()))
+		return ""
+	}
+	return hash
+}
+
+// evaluate sends the admission review to the PolicyServer. When the evaluations
+// are deduplicated, the verdict of the policy for an equivalent resource audited
+// during the run is reused instead, unless the policy is context aware. The
+// returned bool is true when the verdict is reused.
+func (s *Scanner) evaluate(ctx context.Context, runUID string, url *url.URL, policy policiesv1.Policy, gvr schema.GroupVersionResource, contentHash string, admissionRequest *admissionv1.AdmissionReview) (*admissionv1.AdmissionReview, bool, error) {
+	send := func() (*admissionv1.AdmissionReview, error) {
+		return s.sendAdmissionReviewToPolicyServer(ctx, url, admissionRequest)
+	}
+	if contentHash == "" || policy.IsContextAware() {
+		admissionReview, err := send()
+		return admissionReview, false, err
+	}
+	key := evaluationKey(policy, gvr, admissionRequest.Request.Operation, contentHash)
+	return s.evaluations.evaluate(runUID, key, admissionRequest, send)
+}
+
+// recordEvaluation records the metrics of an evaluation. The verdicts reused
+// from equivalent resources are not sent to the PolicyServers, they are
+// counted apart.
+func (s *Scanner) recordEvaluation(ctx context.Context, runUID string, policy policiesv1.Policy, resource unstructured.Unstructured, latency time.Duration, errored, reused bool) {
+	if !reused {
+		s.metrics.RecordEvaluation(ctx, runUID, policy.GetPolicyServer(), latency, errored)
+		return
+	}
+	s.logger.DebugContext(ctx, "reusing the verdict of an equivalent resource",
+		slog.String("policy", policy.GetName()),
+		slog.String("resource", resource.GetName()))
+	s.metrics.RecordReusedEvaluation(ctx, runUID, policy.GetPolicyServer())
+}
+
+// sendAdmissionReviewToPolicyServer sends the admission review to the PolicyServer.
+// Connection errors and retryable status codes are retried with an exponential backoff.
+// When the circuit breaker of the PolicyServer is open, the request is not sent and
+// errPolicyServerUnavailable is returned.
 func (s *Scanner) sendAdmissionReviewToPolicyServer(ctx context.Context, url *url.URL, admissionRequest *admissionv1.AdmissionReview) (*admissionv1.AdmissionReview, error) {
 	payload, err := json.Marshal(admissionRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode the admission request: %w", err)
 	}
 
+	breaker := s.circuitBreakers.get(url.Host)
+	if !breaker.allow() {
+		return nil, fmt.Errorf("%w: evaluations against %s are paused after %d consecutive failed requests",
+			errPolicyServerUnavailable, url.Host, breaker.threshold)
+	}
+
+	backoff := s.retryBackoff
+	for retry := 0; ; retry++ {
+		admissionReview, err := s.postAdmissionReview(ctx, url, payload)
+		if err == nil {
+			breaker.recordSuccess()
+			// The PolicyServer only returns the response, keep track of the request
+			// that produced it.
+			admissionReview.Request = admissionRequest.Request
+			return admissionReview, nil
+		}
+
+		if ctx.Err() != nil {
+			breaker.release()
+			return nil, err
+		}
+		if !isRetryablePolicyServerError(err) {
+			// The PolicyServer is reachable, the request cannot be evaluated
+			breaker.recordSuccess()
+			return nil, err
+		}
+		if retry >= s.maxRetries {
+			breaker.recordFailure()
+			if retry > 0 {
+				return nil, fmt.Errorf("giving up after %d retries: %w", retry, err)
+			}
+			return nil, err
+		}
+
+		delay := backoff.Step()
+		s.logger.DebugContext(ctx, "retrying request to PolicyServer",
+			slog.String("error", err.Error()),
+			slog.String("policy-server", url.Host),
+			slog.Int("retry", retry+1),
+			slog.Duration("backoff", delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			breaker.release()
+			return nil, fmt.Errorf("request to policy server canceled: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// postAdmissionReview performs a single request to the PolicyServer.
+func (s *Scanner) postAdmissionReview(ctx context.Context, url *url.URL, payload []byte) (*admissionv1.AdmissionReview, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url.String(), bytes.NewBuffer(payload))
 	if err != nil {
 		return nil, fmt.Errorf("failed to build the policy server request: %w", err)
@@ -535,7 +1292,7 @@ func (s *Scanner) sendAdmissionReviewToPolicyServer(ctx context.Context, url *ur
 		return nil, fmt.Errorf("cannot read body of response: %w", err)
 	}
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d body: %s", res.StatusCode, body)
+		return nil, &policyServerStatusError{statusCode: res.StatusCode, body: body}
 	}
 
 	admissionReview := admissionv1.AdmissionReview{}
@@ -545,3 +1302,20 @@ func (s *Scanner) sendAdmissionReviewToPolicyServer(ctx context.Context, url *ur
 	}
 	return &admissionReview, nil
 }
+
+// isRetryablePolicyServerError returns true if the request failed because of
+// a connection error, or if the PolicyServer is overloaded or not ready.
+func isRetryablePolicyServerError(err error) bool {
+	var statusErr *policyServerStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.statusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -5,18 +5,30 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/auditrun"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/checkpoint"
 	auditConstants "github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/events"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/notification"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/policies"
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
 	auditscheme "github.com/kubewarden/adm-controller/internal/audit-scanner/scheme"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/shard"
 	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -24,14 +36,19 @@ import (
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
 	apimachineryErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	dynamicFake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	testingclient "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
 	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
 
 	"github.com/kubewarden/adm-controller/internal/audit-scanner/testutils"
@@ -83,6 +100,17 @@ func newMockPolicyServer() *httptest.Server {
 	}))
 }
 
+func newCountingMockPolicyServer(requests *atomic.Int32) *httptest.Server {
+	mockPolicyServer := newMockPolicyServer()
+	handler := mockPolicyServer.Config.Handler
+	mockPolicyServer.Config.Handler = http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handler.ServeHTTP(writer, r)
+	})
+
+	return mockPolicyServer
+}
+
 func newMockPolicyServerWithErrors() *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
 		writer.WriteHeader(http.StatusBadGateway)
@@ -289,7 +317,7 @@ func TestScanAllNamespacesWithPolicyReport(t *testing.T) {
 		Status(policiesv1.PolicyStatusActive).
 		Build()
 
-	// an AdmissionPolicy targeting a GVR with *, should be skipped
+	// an AdmissionPolicy targeting a GVR with *, expanded to the deployments served by the cluster
 	admissionPolicy6 := testutils.
 		NewAdmissionPolicyFactory().
 		Name("admissionPolicy6").
@@ -388,9 +416,9 @@ func TestScanAllNamespacesWithPolicyReport(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
 
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
@@ -411,9 +439,14 @@ func TestScanAllNamespacesWithPolicyReport(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 3, policyReport.Summary.Pass)
 	assert.Equal(t, 1, policyReport.Summary.Error)
-	assert.Equal(t, 1, policyReport.Summary.Skip)
-	assert.Len(t, policyReport.Results, 3)
+	assert.Equal(t, 0, policyReport.Summary.Skip)
+	assert.Len(t, policyReport.Results, 4)
 	assert.Equal(t, runUID, policyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+	erroredResult := policyReport.Results[slices.IndexFunc(policyReport.Results, func(result *wgpolicy.PolicyReportResult) bool {
+		return result.Result == "error"
+	})]
+	assert.Equal(t, "namespaced-namespace1-admissionPolicy5", erroredResult.Policy)
+	assert.Contains(t, erroredResult.Description, "unknown GVR targeted by the policy")
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod2.GetUID()), Namespace: "namespace2"}, &policyReport)
 	require.NoError(t, err)
@@ -423,10 +456,10 @@ func TestScanAllNamespacesWithPolicyReport(t *testing.T) {
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(deployment1.GetUID()), Namespace: "namespace1"}, &policyReport)
 	require.NoError(t, err)
-	assert.Equal(t, 2, policyReport.Summary.Pass)
+	assert.Equal(t, 3, policyReport.Summary.Pass)
 	assert.Equal(t, 1, policyReport.Summary.Error)
-	assert.Equal(t, 1, policyReport.Summary.Skip)
-	assert.Len(t, policyReport.Results, 2)
+	assert.Equal(t, 0, policyReport.Summary.Skip)
+	assert.Len(t, policyReport.Results, 4)
 	assert.Equal(t, runUID, policyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(deployment2.GetUID()), Namespace: "namespace2"}, &policyReport)
@@ -536,7 +569,7 @@ func TestScanClusterWideResourcesWithPolicyReport(t *testing.T) {
 		Status(policiesv1.PolicyStatusActive).
 		Build()
 
-	// a ClusterAdmissionPolicy targeting a GVR with *, should be counted as skipped
+	// a ClusterAdmissionPolicy targeting a GVR with *, expanded to the namespaces served by the cluster
 	clusterAdmissionPolicy5 := testutils.
 		NewClusterAdmissionPolicyFactory().
 		Name("clusterAdmissionPolicy5").
@@ -594,9 +627,9 @@ func TestScanClusterWideResourcesWithPolicyReport(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
 
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
@@ -615,16 +648,16 @@ func TestScanClusterWideResourcesWithPolicyReport(t *testing.T) {
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace1.GetUID())}, &clusterPolicyReport)
 	require.NoError(t, err)
-	assert.Equal(t, 2, clusterPolicyReport.Summary.Pass)
+	assert.Equal(t, 3, clusterPolicyReport.Summary.Pass)
 	assert.Equal(t, 1, clusterPolicyReport.Summary.Error)
-	assert.Equal(t, 1, clusterPolicyReport.Summary.Skip)
-	assert.Len(t, clusterPolicyReport.Results, 2)
+	assert.Equal(t, 0, clusterPolicyReport.Summary.Skip)
+	assert.Len(t, clusterPolicyReport.Results, 4)
 	assert.Equal(t, runUID, clusterPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace2.GetUID())}, &clusterPolicyReport)
 	require.NoError(t, err)
-	assert.Equal(t, 3, clusterPolicyReport.Summary.Pass)
-	assert.Len(t, clusterPolicyReport.Results, 3)
+	assert.Equal(t, 4, clusterPolicyReport.Summary.Pass)
+	assert.Len(t, clusterPolicyReport.Results, 5)
 	assert.Equal(t, runUID, clusterPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 }
 
@@ -704,9 +737,9 @@ func TestScanWithHTTPErrors(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServerWithErrors.URL, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServerWithErrors.URL, nil, policies.Filter{}, logger)
 
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
@@ -737,6 +770,232 @@ func TestScanWithHTTPErrors(t *testing.T) {
 	assert.Len(t, namespacePolicyReport.Results, 1)
 }
 
+func TestScanWithFailingMatchConditions(t *testing.T) {
+	var requests atomic.Int32
+	mockPolicyServer := newCountingMockPolicyServer(&requests)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	// ClusterAdmissionPolicies targeting pods and namespaces, whose
+	// matchCondition fails to evaluate: the object has no such label
+	newPolicy := func(name string, failurePolicy admissionregistrationv1.FailurePolicyType) *policiesv1.ClusterAdmissionPolicy {
+		return testutils.
+			NewClusterAdmissionPolicyFactory().
+			Name(name).
+			Rule(admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods", "namespaces"},
+			}).
+			MatchCondition("missing", "object.metadata.labels['missing'] == 'value'").
+			FailurePolicy(failurePolicy).
+			Status(policiesv1.PolicyStatusActive).
+			Build()
+	}
+	failPolicy := newPolicy("fail", admissionregistrationv1.Fail)
+	ignorePolicy := newPolicy("ignore", admissionregistrationv1.Ignore)
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		failPolicy,
+		ignorePolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	runUID := uuid.New().String()
+	require.NoError(t, scanner.ScanAllNamespaces(t.Context(), runUID))
+	require.NoError(t, scanner.ScanClusterWideResources(t.Context(), runUID))
+
+	// the policy with the Fail policy reports an error, the one with the
+	// Ignore policy does not match, and none of them is evaluated
+	assert.Equal(t, int32(0), requests.Load())
+
+	podPolicyReport := wgpolicy.PolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &podPolicyReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, podPolicyReport.Summary.Error)
+	require.Len(t, podPolicyReport.Results, 1)
+	assert.Equal(t, failPolicy.GetUniqueName(), podPolicyReport.Results[0].Policy)
+	assert.Contains(t, podPolicyReport.Results[0].Description, "failed to evaluate the matchConditions")
+
+	namespacePolicyReport := wgpolicy.ClusterPolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace.GetUID())}, &namespacePolicyReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, namespacePolicyReport.Summary.Error)
+	require.Len(t, namespacePolicyReport.Results, 1)
+	assert.Equal(t, failPolicy.GetUniqueName(), namespacePolicyReport.Results[0].Policy)
+}
+
+func TestScanWithCircuitBreaker(t *testing.T) {
+	mockPolicyServerWithErrors := newMockPolicyServerWithErrors()
+	defer mockPolicyServerWithErrors.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods and namespaces
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServerWithErrors.URL, nil, policies.Filter{}, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	config.PolicyServerClient = PolicyServerClientConfig{
+		CircuitBreakerThreshold: 1,
+		CircuitBreakerCooldown:  time.Hour,
+	}
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	runUID := uuid.New().String()
+	// the failed evaluation of the pod opens the circuit
+	err = scanner.ScanAllNamespaces(t.Context(), runUID)
+	require.NoError(t, err)
+	err = scanner.ScanClusterWideResources(t.Context(), runUID)
+	require.NoError(t, err)
+
+	podPolicyReport := wgpolicy.PolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &podPolicyReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, podPolicyReport.Summary.Error)
+	assert.Equal(t, 0, podPolicyReport.Summary.Skip)
+	assert.Len(t, podPolicyReport.Results, 1)
+
+	// the evaluation of the namespace is skipped
+	namespacePolicyReport := wgpolicy.ClusterPolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace.GetUID())}, &namespacePolicyReport)
+	require.NoError(t, err)
+	assert.Equal(t, 0, namespacePolicyReport.Summary.Error)
+	assert.Equal(t, 1, namespacePolicyReport.Summary.Skip)
+	require.Len(t, namespacePolicyReport.Results, 1)
+	assert.Equal(t, wgpolicy.PolicyResult("skip"), namespacePolicyReport.Results[0].Result)
+	assert.Contains(t, namespacePolicyReport.Results[0].Description, "PolicyServer unavailable")
+}
+
 func TestScanWithMTLS(t *testing.T) {
 	caCertPEM, caKeyPEM, err := testutils.GenerateTestCA()
 	require.NoError(t, err)
@@ -827,9 +1086,9 @@ func TestScanWithMTLS(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
 
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
@@ -964,8 +1223,8 @@ func TestScanFailureClusterWideResources(t *testing.T) {
 	})
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, 1, logger)
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, 1, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
@@ -1143,7 +1402,7 @@ func TestScanFailureAllNamespaces(t *testing.T) {
 		Status(policiesv1.PolicyStatusActive).
 		Build()
 
-	// an AdmissionPolicy targeting a GVR with *, should be skipped
+	// an AdmissionPolicy targeting a GVR with *, expanded to the deployments served by the cluster
 	admissionPolicy6 := testutils.
 		NewAdmissionPolicyFactory().
 		Name("admissionPolicy6").
@@ -1260,8 +1519,8 @@ func TestScanFailureAllNamespaces(t *testing.T) {
 	})
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
@@ -1282,16 +1541,16 @@ func TestScanFailureAllNamespaces(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 3, policyReport.Summary.Pass)
 	assert.Equal(t, 1, policyReport.Summary.Error)
-	assert.Equal(t, 1, policyReport.Summary.Skip)
-	assert.Len(t, policyReport.Results, 3)
+	assert.Equal(t, 0, policyReport.Summary.Skip)
+	assert.Len(t, policyReport.Results, 4)
 	assert.Equal(t, runUID, policyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(deployment1.GetUID()), Namespace: "namespace1"}, &policyReport)
 	require.NoError(t, err)
-	assert.Equal(t, 2, policyReport.Summary.Pass)
+	assert.Equal(t, 3, policyReport.Summary.Pass)
 	assert.Equal(t, 1, policyReport.Summary.Error)
-	assert.Equal(t, 1, policyReport.Summary.Skip)
-	assert.Len(t, policyReport.Results, 2)
+	assert.Equal(t, 0, policyReport.Summary.Skip)
+	assert.Len(t, policyReport.Results, 4)
 	assert.Equal(t, runUID, policyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 
 	// List all policy report from the namespace1
@@ -1301,6 +1560,30 @@ func TestScanFailureAllNamespaces(t *testing.T) {
 	require.Len(t, reports.Items, 2)
 }
 
+func TestScanAllNamespacesListFailure(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	defer mockPolicyServer.Close()
+
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(scheme.Scheme)
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("list", "namespaces", func(testingclient.Action) (bool, runtime.Object, error) {
+		return true, nil, apimachineryErrors.NewBadRequest("reactor error")
+	})
+	client, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	scanner, err := NewScanner(newTestConfig(policiesClient, k8sClient, policyReportStore))
+	require.NoError(t, err)
+
+	err = scanner.ScanAllNamespaces(t.Context(), uuid.New().String())
+	require.ErrorContains(t, err, "reactor error")
+}
+
 func TestScanAllNamespacesWithOpenReport(t *testing.T) {
 	mockPolicyServer := newMockPolicyServer()
 	defer mockPolicyServer.Close()
@@ -1450,7 +1733,7 @@ func TestScanAllNamespacesWithOpenReport(t *testing.T) {
 		Status(policiesv1.PolicyStatusActive).
 		Build()
 
-	// an AdmissionPolicy targeting a GVR with *, should be skipped
+	// an AdmissionPolicy targeting a GVR with *, expanded to the deployments served by the cluster
 	admissionPolicy6 := testutils.
 		NewAdmissionPolicyFactory().
 		Name("admissionPolicy6").
@@ -1549,9 +1832,9 @@ func TestScanAllNamespacesWithOpenReport(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
 
 	openReportStore := report.NewOpenReportStore(client, logger)
 	config := newTestConfig(policiesClient, k8sClient, openReportStore)
@@ -1572,8 +1855,8 @@ func TestScanAllNamespacesWithOpenReport(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 3, report.Summary.Pass)
 	assert.Equal(t, 1, report.Summary.Error)
-	assert.Equal(t, 1, report.Summary.Skip)
-	assert.Len(t, report.Results, 3)
+	assert.Equal(t, 0, report.Summary.Skip)
+	assert.Len(t, report.Results, 4)
 	assert.Equal(t, runUID, report.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod2.GetUID()), Namespace: "namespace2"}, &report)
@@ -1584,10 +1867,10 @@ func TestScanAllNamespacesWithOpenReport(t *testing.T) {
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(deployment1.GetUID()), Namespace: "namespace1"}, &report)
 	require.NoError(t, err)
-	assert.Equal(t, 2, report.Summary.Pass)
+	assert.Equal(t, 3, report.Summary.Pass)
 	assert.Equal(t, 1, report.Summary.Error)
-	assert.Equal(t, 1, report.Summary.Skip)
-	assert.Len(t, report.Results, 2)
+	assert.Equal(t, 0, report.Summary.Skip)
+	assert.Len(t, report.Results, 4)
 	assert.Equal(t, runUID, report.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(deployment2.GetUID()), Namespace: "namespace2"}, &report)
@@ -1696,7 +1979,7 @@ func TestScanClusterWideResourcesWithOpenReport(t *testing.T) {
 		Status(policiesv1.PolicyStatusActive).
 		Build()
 
-	// a ClusterAdmissionPolicy targeting a GVR with *, should be counted as skipped
+	// a ClusterAdmissionPolicy targeting a GVR with *, expanded to the namespaces served by the cluster
 	clusterAdmissionPolicy5 := testutils.
 		NewClusterAdmissionPolicyFactory().
 		Name("clusterAdmissionPolicy5").
@@ -1754,9 +2037,9 @@ func TestScanClusterWideResourcesWithOpenReport(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
 
 	openReportStore := report.NewOpenReportStore(client, logger)
 	config := newTestConfig(policiesClient, k8sClient, openReportStore)
@@ -1775,15 +2058,1356 @@ func TestScanClusterWideResourcesWithOpenReport(t *testing.T) {
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace1.GetUID())}, &clusterPolicyReport)
 	require.NoError(t, err)
-	assert.Equal(t, 2, clusterPolicyReport.Summary.Pass)
+	assert.Equal(t, 3, clusterPolicyReport.Summary.Pass)
 	assert.Equal(t, 1, clusterPolicyReport.Summary.Error)
-	assert.Equal(t, 1, clusterPolicyReport.Summary.Skip)
-	assert.Len(t, clusterPolicyReport.Results, 2)
+	assert.Equal(t, 0, clusterPolicyReport.Summary.Skip)
+	assert.Len(t, clusterPolicyReport.Results, 4)
 	assert.Equal(t, runUID, clusterPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace2.GetUID())}, &clusterPolicyReport)
 	require.NoError(t, err)
-	assert.Equal(t, 3, clusterPolicyReport.Summary.Pass)
-	assert.Len(t, clusterPolicyReport.Results, 3)
+	assert.Equal(t, 4, clusterPolicyReport.Summary.Pass)
+	assert.Len(t, clusterPolicyReport.Results, 5)
 	assert.Equal(t, runUID, clusterPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 }
+
+func TestIncrementalScan(t *testing.T) {
+	var requests atomic.Int32
+	mockPolicyServer := newCountingMockPolicyServer(&requests)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "namespace",
+			UID:             "namespace-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod",
+			Namespace:       "namespace",
+			UID:             "pod-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods and namespaces
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	reportStore := report.NewOpenReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, reportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	config.Incremental = true
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	// the first scan evaluates the pod and the namespace
+	err = scanner.ScanAllNamespaces(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+	err = scanner.ScanClusterWideResources(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+
+	// the second scan reuses the stored results
+	runUID := uuid.New().String()
+	err = scanner.ScanAllNamespaces(t.Context(), runUID)
+	require.NoError(t, err)
+	err = scanner.ScanClusterWideResources(t.Context(), runUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+
+	podReport := openreports.Report{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &podReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, podReport.Summary.Pass)
+	assert.Len(t, podReport.Results, 1)
+	assert.Equal(t, runUID, podReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+
+	namespaceReport := openreports.ClusterReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace.GetUID())}, &namespaceReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, namespaceReport.Summary.Pass)
+	assert.Len(t, namespaceReport.Results, 1)
+	assert.Equal(t, runUID, namespaceReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+
+	// a change of the pod triggers a new evaluation
+	updatedPod := pod.DeepCopy()
+	updatedPod.SetResourceVersion("2")
+	unstructuredPod, err := runtime.DefaultUnstructuredConverter.ToUnstructured(updatedPod)
+	require.NoError(t, err)
+	_, err = dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("pods")).Namespace("namespace").
+		Update(t.Context(), &unstructured.Unstructured{Object: unstructuredPod}, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	err = scanner.ScanAllNamespaces(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestSendAdmissionReviewToPolicyServerRetries(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int32
+		failureCode      int
+		maxRetries       int
+		expectedRequests int32
+		expectedErr      bool
+	}{
+		{
+			name:             "retryable status code",
+			failures:         2,
+			failureCode:      http.StatusServiceUnavailable,
+			maxRetries:       2,
+			expectedRequests: 3,
+		},
+		{
+			name:             "retries exhausted",
+			failures:         3,
+			failureCode:      http.StatusTooManyRequests,
+			maxRetries:       1,
+			expectedRequests: 2,
+			expectedErr:      true,
+		},
+		{
+			name:             "non retryable status code",
+			failures:         1,
+			failureCode:      http.StatusBadRequest,
+			maxRetries:       2,
+			expectedRequests: 1,
+			expectedErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			mockPolicyServer := newMockPolicyServer()
+			defer mockPolicyServer.Close()
+			handler := mockPolicyServer.Config.Handler
+			mockPolicyServer.Config.Handler = http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= test.failures {
+					writer.WriteHeader(test.failureCode)
+					return
+				}
+				handler.ServeHTTP(writer, r)
+			})
+
+			config := newTestConfig(nil, nil, nil)
+			config.PolicyServerClient = PolicyServerClientConfig{
+				MaxRetries:   test.maxRetries,
+				RetryBackoff: time.Millisecond,
+			}
+			scanner, err := NewScanner(config)
+			require.NoError(t, err)
+
+			policyServerURL, err := url.Parse(mockPolicyServer.URL)
+			require.NoError(t, err)
+
+			admissionReview, err := scanner.sendAdmissionReviewToPolicyServer(t.Context(), policyServerURL, newAdmissionReview(unstructured.Unstructured{}, podGVR, admissionv1.Create, auditUserInfo))
+			if test.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, admissionReview.Response.Allowed)
+			}
+			assert.Equal(t, test.expectedRequests, requests.Load())
+		})
+	}
+}
+
+func TestSendAdmissionReviewToPolicyServerConnectionError(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	mockPolicyServer.Close()
+
+	config := newTestConfig(nil, nil, nil)
+	config.PolicyServerClient = PolicyServerClientConfig{
+		MaxRetries:              1,
+		RetryBackoff:            time.Millisecond,
+		CircuitBreakerThreshold: 1,
+		CircuitBreakerCooldown:  time.Hour,
+	}
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	policyServerURL, err := url.Parse(mockPolicyServer.URL)
+	require.NoError(t, err)
+	admissionReview := newAdmissionReview(unstructured.Unstructured{}, podGVR, admissionv1.Create, auditUserInfo)
+
+	_, err = scanner.sendAdmissionReviewToPolicyServer(t.Context(), policyServerURL, admissionReview)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, errPolicyServerUnavailable)
+
+	_, err = scanner.sendAdmissionReviewToPolicyServer(t.Context(), policyServerURL, admissionReview)
+	require.ErrorIs(t, err, errPolicyServerUnavailable)
+}
+
+// recordingOutput is an output.Writer keeping the results written by the scanner.
+type recordingOutput struct {
+	mu      sync.Mutex
+	results []report.ResourceResults
+}
+
+func (o *recordingOutput) Write(r report.Report) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.results = append(o.results, r.ResourceResults())
+	return nil
+}
+
+func (o *recordingOutput) Close() error {
+	return nil
+}
+
+func TestScanWithOutput(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods and namespaces
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(auditScheme, namespace, pod)
+	clientset := fake.NewClientset(namespace)
+	client, err := testutils.NewFakeClient(namespace, policyServer, policyServerService, clusterAdmissionPolicy)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+
+	output := &recordingOutput{}
+	config := newTestConfig(policiesClient, k8sClient, report.NewPolicyReportStore(client, logger))
+	config.DisableStore = true
+	config.Output = output
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	runUID := uuid.New().String()
+	require.NoError(t, scanner.ScanAllNamespaces(t.Context(), runUID))
+	require.NoError(t, scanner.ScanClusterWideResources(t.Context(), runUID))
+
+	require.Len(t, output.results, 2)
+	kinds := []string{output.results[0].Resource.Kind, output.results[1].Resource.Kind}
+	assert.ElementsMatch(t, []string{"Pod", "Namespace"}, kinds)
+	for _, resourceResults := range output.results {
+		require.Len(t, resourceResults.Results, 1)
+		assert.Equal(t, "clusterwide-clusterAdmissionPolicy", resourceResults.Results[0].Policy)
+		assert.Equal(t, report.ResultPass, resourceResults.Results[0].Result)
+	}
+}
+
+func TestScanResources(t *testing.T) {
+	// the PolicyServer rejects the resources evaluated by the deny policy
+	mockPolicyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		admissionReview := admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{
+				Allowed: !strings.HasSuffix(r.URL.Path, "/clusterwide-deny"),
+			},
+		}
+		response, err := json.Marshal(admissionReview)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = writer.Write(response)
+	}))
+	defer mockPolicyServer.Close()
+
+	allow := testutils.NewClusterAdmissionPolicyFactory().
+		Name("allow").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Build()
+	deny := testutils.NewClusterAdmissionPolicyFactory().
+		Name("deny").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Build()
+
+	matcher, err := policies.NewResourceMatcher(policies.ResourceMatcherConfig{
+		Policies:         []policiesv1.Policy{allow, deny},
+		PolicyServerURL:  mockPolicyServer.URL,
+		DefaultNamespace: "default",
+	})
+	require.NoError(t, err)
+
+	pod := unstructured.Unstructured{}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	pod.SetName("nginx")
+	namespace := unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName("team")
+	// not targeted by any policy
+	configMap := unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("config")
+
+	config := newTestConfig(nil, nil, nil)
+	config.DisableStore = true
+	config.Violations = report.NewViolations(report.Threshold{FailOn: report.ThresholdResults})
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	reports, err := scanner.ScanResources(t.Context(), []unstructured.Unstructured{pod, namespace, configMap}, matcher, uuid.New().String())
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, 1, config.Violations.Total())
+
+	podResults := reports[0].ResourceResults()
+	assert.Equal(t, "default", podResults.Resource.Namespace)
+	results := map[string]string{}
+	for _, result := range podResults.Results {
+		results[result.Policy] = result.Result
+	}
+	assert.Equal(t, map[string]string{"clusterwide-allow": report.ResultPass, "clusterwide-deny": report.ResultFail}, results)
+
+	namespaceResults := reports[1].ResourceResults()
+	assert.Equal(t, "Namespace", namespaceResults.Resource.Kind)
+	require.Len(t, namespaceResults.Results, 1)
+	assert.Equal(t, "clusterwide-allow", namespaceResults.Results[0].Policy)
+	assert.Equal(t, report.ResultPass, namespaceResults.Results[0].Result)
+}
+
+func TestCheckpointedScanResumesInterruptedRun(t *testing.T) {
+	var requests atomic.Int32
+	mockPolicyServer := newCountingMockPolicyServer(&requests)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		scheme.Scheme,
+		namespace,
+		pod,
+	)
+	var continueTokens []string
+	dynamicClient.PrependReactor("list", "pods", func(action testingclient.Action) (bool, runtime.Object, error) {
+		listAction, ok := action.(testingclient.ListActionImpl)
+		require.True(t, ok)
+		continueTokens = append(continueTokens, listAction.GetListOptions().Continue)
+		return false, nil, nil
+	})
+	clientset := fake.NewClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+
+	// the interrupted run audited the cluster-wide resources and the first page of pods
+	interrupted := checkpoint.NewStore(clientset, "kubewarden", shard.Shard{}, "", logger)
+	_, err = interrupted.Start(t.Context(), "interrupted-run")
+	require.NoError(t, err)
+	require.NoError(t, interrupted.CompleteNamespace(t.Context(), ""))
+	require.NoError(t, interrupted.SaveResourcesProgress(t.Context(), "namespace", corev1.SchemeGroupVersion.WithResource("pods"), "token"))
+
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	config.Checkpoint = checkpoint.NewStore(clientset, "kubewarden", shard.Shard{}, "", logger)
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	runUID, err := scanner.StartRun(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "interrupted-run", runUID)
+
+	err = scanner.ScanClusterWideResources(t.Context(), runUID)
+	require.NoError(t, err)
+	err = scanner.ScanAllNamespaces(t.Context(), runUID)
+	require.NoError(t, err)
+
+	// only the remaining pods are audited
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Equal(t, []string{"token"}, continueTokens)
+
+	policyReport := wgpolicy.PolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &policyReport)
+	require.NoError(t, err)
+	assert.Equal(t, "interrupted-run", policyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+	assert.True(t, config.Checkpoint.IsNamespaceCompleted("namespace"))
+
+	// once finished, the next run starts from scratch
+	scanner.FinishRun(t.Context(), nil)
+	_, err = clientset.CoreV1().ConfigMaps("kubewarden").Get(t.Context(), checkpoint.ConfigMapName, metav1.GetOptions{})
+	require.True(t, apimachineryErrors.IsNotFound(err))
+}
+
+func TestScanRecordsAuditRun(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// not audited, since it's not active
+	pendingClusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("pendingClusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusPending).
+		Build()
+
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		scheme.Scheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+		pendingClusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	config.AuditRun = auditrun.NewRecorder(client, 0, shard.Shard{}, "", logger)
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	runUID, err := scanner.StartRun(t.Context())
+	require.NoError(t, err)
+	err = scanner.ScanClusterWideResources(t.Context(), runUID)
+	require.NoError(t, err)
+	err = scanner.ScanAllNamespaces(t.Context(), runUID)
+	require.NoError(t, err)
+	scanner.FinishRun(t.Context(), nil)
+
+	auditRun := policiesv1.AuditRun{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: runUID}, &auditRun)
+	require.NoError(t, err)
+	assert.Equal(t, policiesv1.AuditRunPhaseSucceeded, auditRun.Status.Phase)
+	assert.NotNil(t, auditRun.Status.CompletionTime)
+	assert.Equal(t, 1, auditRun.Status.NamespacesTotal)
+	assert.Equal(t, 1, auditRun.Status.NamespacesCompleted)
+	assert.Equal(t, 2, auditRun.Status.ResourcesAudited)
+	assert.Equal(t, policiesv1.AuditRunResults{Pass: 2, Skip: 1}, auditRun.Status.Results)
+	assert.Equal(t, []string{"clusterwide-pendingClusterAdmissionPolicy"}, auditRun.Status.SkippedPolicies)
+	assert.Empty(t, auditRun.Status.ErroredPolicies)
+}
+
+func TestShardedScan(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespaces := []*corev1.Namespace{}
+	pods := []*corev1.Pod{}
+	for i := range 4 {
+		name := fmt.Sprintf("namespace%d", i)
+		namespaces = append(namespaces, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				UID:  types.UID(name + "-uid"),
+			},
+		})
+		pods = append(pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod",
+				Namespace: name,
+				UID:       types.UID(name + "-pod-uid"),
+			},
+		})
+	}
+
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	objects := []runtime.Object{}
+	namespaceObjects := []runtime.Object{}
+	for i := range namespaces {
+		objects = append(objects, namespaces[i], pods[i])
+		namespaceObjects = append(namespaceObjects, namespaces[i])
+	}
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(scheme.Scheme, objects...)
+	clientset := fake.NewClientset(namespaceObjects...)
+	client, err := testutils.NewFakeClient(append(namespaceObjects, policyServer, policyServerService, clusterAdmissionPolicy)...)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	namespacesGVR := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "namespaces"}
+	for index := range 2 {
+		auditShard := shard.Shard{Index: index, Count: 2}
+		config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+		config.Shard = auditShard
+		scanner, err := NewScanner(config)
+		require.NoError(t, err)
+
+		runUID := uuid.New().String()
+		err = scanner.ScanClusterWideResources(t.Context(), runUID)
+		require.NoError(t, err)
+		err = scanner.ScanAllNamespaces(t.Context(), runUID)
+		require.NoError(t, err)
+
+		for _, pod := range pods {
+			policyReport := wgpolicy.PolicyReport{}
+			err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: pod.GetNamespace()}, &policyReport)
+			if !auditShard.OwnsNamespace(pod.GetNamespace()) {
+				// audited by the previous shard, or not yet audited
+				if index == 0 {
+					require.True(t, apimachineryErrors.IsNotFound(err))
+				}
+				continue
+			}
+			require.NoError(t, err)
+			assert.Equal(t, runUID, policyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+		}
+
+		for _, namespace := range namespaces {
+			clusterPolicyReport := wgpolicy.ClusterPolicyReport{}
+			err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace.GetUID())}, &clusterPolicyReport)
+			if !auditShard.OwnsGVR(namespacesGVR) {
+				if index == 0 {
+					require.True(t, apimachineryErrors.IsNotFound(err))
+				} else {
+					// the reports of the other shard are not garbage collected
+					require.NoError(t, err)
+					assert.NotEqual(t, runUID, clusterPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+				}
+				continue
+			}
+			require.NoError(t, err)
+			assert.Equal(t, runUID, clusterPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+			assert.Equal(t, shard.GVRKey(namespacesGVR), clusterPolicyReport.GetLabels()[auditConstants.AuditScannerShardKeyLabel])
+		}
+	}
+
+	// every namespace was audited by one of the shards
+	for _, pod := range pods {
+		policyReport := wgpolicy.PolicyReport{}
+		err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: pod.GetNamespace()}, &policyReport)
+		require.NoError(t, err)
+	}
+}
+
+func TestTargetedScan(t *testing.T) {
+	var requests atomic.Int32
+	mockPolicyServer := newCountingMockPolicyServer(&requests)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	webPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "namespace",
+			UID:       "web-uid",
+			Labels:    map[string]string{"app": "web"},
+		},
+	}
+
+	dbPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "db",
+			Namespace: "namespace",
+			UID:       "db-uid",
+			Labels:    map[string]string{"app": "db"},
+		},
+	}
+
+	newPodPolicy := func(name string) *policiesv1.ClusterAdmissionPolicy {
+		return testutils.
+			NewClusterAdmissionPolicyFactory().
+			Name(name).
+			Rule(admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+			}).
+			Status(policiesv1.PolicyStatusActive).
+			Build()
+	}
+
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(scheme.Scheme, namespace, webPod, dbPod)
+	clientset := fake.NewClientset(namespace)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		newPodPolicy("first"),
+		newPodPolicy("second"),
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	// the full scan evaluates both policies against both pods
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	scanner, err := NewScanner(newTestConfig(policiesClient, k8sClient, policyReportStore))
+	require.NoError(t, err)
+	fullRunUID := uuid.New().String()
+	require.NoError(t, scanner.ScanAllNamespaces(t.Context(), fullRunUID))
+	assert.Equal(t, int32(4), requests.Load())
+
+	// the targeted scan only evaluates the first policy against the web pod
+	k8sClient = k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{
+		Resource: labels.SelectorFromSet(labels.Set{"app": "web"}),
+	}, logger)
+	policiesClient = policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{
+		Policies:  []string{"first"},
+		Resources: []string{"pods"},
+	}, logger)
+	scanner, err = NewScanner(newTestConfig(policiesClient, k8sClient, policyReportStore))
+	require.NoError(t, err)
+	targetedRunUID := uuid.New().String()
+	require.NoError(t, scanner.ScanAllNamespaces(t.Context(), targetedRunUID))
+	assert.Equal(t, int32(5), requests.Load())
+
+	// the result of the second policy is kept in the report of the web pod
+	webReport := wgpolicy.PolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(webPod.GetUID()), Namespace: "namespace"}, &webReport)
+	require.NoError(t, err)
+	assert.Equal(t, targetedRunUID, webReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+	policyNames := []string{}
+	for _, result := range webReport.Results {
+		policyNames = append(policyNames, result.Policy)
+	}
+	assert.ElementsMatch(t, []string{"clusterwide-first", "clusterwide-second"}, policyNames)
+	assert.Equal(t, 2, webReport.Summary.Pass)
+
+	// the report of the pod not audited is not deleted
+	dbReport := wgpolicy.PolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(dbPod.GetUID()), Namespace: "namespace"}, &dbReport)
+	require.NoError(t, err)
+	assert.Equal(t, fullRunUID, dbReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+
+	// the scans of the AuditSchedules, running alongside the other ones,
+	// keep the reports of the resources they did not audit either
+	require.NoError(t, dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("pods")).Namespace("namespace").Delete(t.Context(), "db", metav1.DeleteOptions{}))
+	k8sClient = k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient = policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	config.AuditSchedule = "nightly"
+	scanner, err = NewScanner(config)
+	require.NoError(t, err)
+	require.NoError(t, scanner.ScanAllNamespaces(t.Context(), uuid.New().String()))
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(dbPod.GetUID()), Namespace: "namespace"}, &dbReport)
+	require.NoError(t, err)
+	assert.Equal(t, fullRunUID, dbReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+}
+
+func TestScanWithDeduplicatedEvaluations(t *testing.T) {
+	var requests atomic.Int32
+	mockPolicyServer := newCountingMockPolicyServer(&requests)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+		},
+	}
+
+	// two replicas of the same Deployment, as created by the ReplicaSet
+	// controller and scheduled on different nodes, and a pod with another image
+	newPod := func(name, image, nodeName, tokenVolume string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				GenerateName:    "nginx-7c5ddbdf54-",
+				Namespace:       "namespace",
+				UID:             types.UID(name + "-uid"),
+				ResourceVersion: "1",
+				Labels:          map[string]string{"app": "nginx", "pod-template-hash": "7c5ddbdf54"},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "apps/v1",
+					Kind:       "ReplicaSet",
+					Name:       "nginx-7c5ddbdf54",
+					UID:        "replicaset-uid",
+					Controller: ptr.To(true),
+				}},
+			},
+			Spec: corev1.PodSpec{
+				NodeName: nodeName,
+				Containers: []corev1.Container{{
+					Name:  "nginx",
+					Image: image,
+					VolumeMounts: []corev1.VolumeMount{{
+						Name:      tokenVolume,
+						ReadOnly:  true,
+						MountPath: "/var/run/secrets/kubernetes.io/serviceaccount",
+					}},
+				}},
+				Volumes: []corev1.Volume{{
+					Name: tokenVolume,
+					VolumeSource: corev1.VolumeSource{
+						Projected: &corev1.ProjectedVolumeSource{
+							Sources: []corev1.VolumeProjection{{
+								ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token"},
+							}},
+						},
+					},
+				}},
+			},
+			Status: corev1.PodStatus{
+				PodIP: "10.0.0.1",
+			},
+		}
+	}
+	replica1 := newPod("nginx-7c5ddbdf54-abcde", "nginx:1.27", "node-1", "kube-api-access-x7k2p")
+	replica2 := newPod("nginx-7c5ddbdf54-fghij", "nginx:1.27", "node-2", "kube-api-access-9zqrt")
+	replica2.Status.PodIP = "10.0.0.2"
+	otherPod := newPod("nginx-7c5ddbdf54-klmno", "nginx:1.28", "node-1", "kube-api-access-m4vbn")
+
+	admissionPolicy := testutils.
+		NewAdmissionPolicyFactory().
+		Name("policy").
+		Namespace("namespace").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(auditScheme, replica1, replica2, otherPod)
+	clientset := fake.NewClientset(namespace)
+	client, err := testutils.NewFakeClient(namespace, policyServer, policyServerService, admissionPolicy)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	reportStore := report.NewPolicyReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, reportStore)
+	config.DeduplicateEvaluations = true
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	// the verdict of the first replica is reused for the second one: the
+	// policy server is called once for the replicas, and once for the other pod
+	err = scanner.ScanNamespace(t.Context(), "namespace", uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+
+	// every pod still has its own report
+	for _, pod := range []*corev1.Pod{replica1, replica2, otherPod} {
+		policyReport := wgpolicy.PolicyReport{}
+		err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &policyReport)
+		require.NoError(t, err)
+		assert.Equal(t, 1, policyReport.Summary.Pass)
+		require.Len(t, policyReport.Results, 1)
+		assert.Equal(t, pod.GetName(), policyReport.Scope.Name)
+	}
+
+	// the verdicts are not reused across runs
+	err = scanner.ScanNamespace(t.Context(), "namespace", uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), requests.Load())
+}
+
+func TestScanWithEvents(t *testing.T) {
+	var allowed atomic.Bool
+	allowed.Store(true)
+	mockPolicyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		admissionReview := admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{Allowed: allowed.Load()},
+		}
+		if !admissionReview.Response.Allowed {
+			admissionReview.Response.Result = &metav1.Status{Message: "the image is not allowed"}
+		}
+		response, err := json.Marshal(admissionReview)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = writer.Write(response)
+	}))
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod",
+			Namespace:       "namespace",
+			UID:             "pod-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	admissionPolicy := testutils.
+		NewAdmissionPolicyFactory().
+		Name("policy").
+		Namespace("namespace").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(auditScheme, pod)
+	clientset := fake.NewClientset(namespace)
+	client, err := testutils.NewFakeClient(namespace, policyServer, policyServerService, admissionPolicy)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	reportStore := report.NewPolicyReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, reportStore)
+	config.Events = events.NewRecorder(clientset, auditScheme, logger)
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	listEvents := func(reason string) []eventsv1.Event {
+		t.Helper()
+		eventList, err := clientset.EventsV1().Events("namespace").List(t.Context(), metav1.ListOptions{})
+		require.NoError(t, err)
+		return slices.DeleteFunc(eventList.Items, func(event eventsv1.Event) bool {
+			return event.Reason != reason
+		})
+	}
+
+	// the first scan of the resource has no previous results to compare with
+	err = scanner.ScanNamespace(t.Context(), "namespace", uuid.New().String())
+	require.NoError(t, err)
+	assert.Empty(t, listEvents(events.ReasonPolicyViolation))
+
+	// the policy starts failing
+	allowed.Store(false)
+	err = scanner.ScanNamespace(t.Context(), "namespace", uuid.New().String())
+	require.NoError(t, err)
+	violations := listEvents(events.ReasonPolicyViolation)
+	require.Len(t, violations, 2)
+	regarding := []string{violations[0].Regarding.Kind, violations[1].Regarding.Kind}
+	assert.ElementsMatch(t, []string{"Pod", "AdmissionPolicy"}, regarding)
+	for _, event := range violations {
+		assert.Equal(t, corev1.EventTypeWarning, event.Type)
+		assert.Contains(t, event.Note, "the image is not allowed")
+	}
+
+	// the policy keeps failing
+	err = scanner.ScanNamespace(t.Context(), "namespace", uuid.New().String())
+	require.NoError(t, err)
+	assert.Len(t, listEvents(events.ReasonPolicyViolation), 2)
+	assert.Empty(t, listEvents(events.ReasonPolicyViolationResolved))
+
+	// the policy passes again
+	allowed.Store(true)
+	err = scanner.ScanNamespace(t.Context(), "namespace", uuid.New().String())
+	require.NoError(t, err)
+	resolved := listEvents(events.ReasonPolicyViolationResolved)
+	require.Len(t, resolved, 2)
+	for _, event := range resolved {
+		assert.Equal(t, corev1.EventTypeNormal, event.Type)
+	}
+}
+
+func TestScanWithNotifications(t *testing.T) {
+	var allowed atomic.Bool
+	allowed.Store(true)
+	mockPolicyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		admissionReview := admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{Allowed: allowed.Load()},
+		}
+		if !admissionReview.Response.Allowed {
+			admissionReview.Response.Result = &metav1.Status{Message: "the image is not allowed"}
+		}
+		response, err := json.Marshal(admissionReview)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = writer.Write(response)
+	}))
+	defer mockPolicyServer.Close()
+
+	// the stand-in of the notification sinks records the batches by path
+	var mutex sync.Mutex
+	batches := make(map[string][]notification.Batch)
+	mockSink := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var batch notification.Batch
+		if err := json.NewDecoder(request.Body).Decode(&batch); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		batches[request.URL.Path] = append(batches[request.URL.Path], batch)
+	}))
+	defer mockSink.Close()
+	receivedBatches := func(path string) []notification.Batch {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return batches[path]
+	}
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod",
+			Namespace:       "namespace",
+			UID:             "pod-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	admissionPolicy := testutils.
+		NewAdmissionPolicyFactory().
+		Name("policy").
+		Namespace("namespace").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(auditScheme, pod)
+	clientset := fake.NewClientset(namespace)
+	client, err := testutils.NewFakeClient(namespace, policyServer, policyServerService, admissionPolicy)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	reportStore := report.NewPolicyReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, reportStore)
+	config.Notifier = notification.NewNotifier(notification.Config{Sinks: []notification.SinkConfig{
+		{
+			Name:  "new-failures",
+			Type:  notification.SinkTypeWebhook,
+			URL:   mockSink.URL + "/new-failures",
+			Batch: notification.BatchConfig{MaxSize: 1},
+		},
+		{
+			Name:    "run-end",
+			Type:    notification.SinkTypeWebhook,
+			URL:     mockSink.URL + "/run-end",
+			Trigger: notification.TriggerRunEnd,
+		},
+	}}, logger)
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	scan := func() {
+		t.Helper()
+		runUID, err := scanner.StartRun(t.Context())
+		require.NoError(t, err)
+		require.NoError(t, scanner.ScanNamespace(t.Context(), "namespace", runUID))
+		scanner.FinishRun(t.Context(), nil)
+	}
+
+	// the first scan of the resource has no previous results to compare with,
+	// and no failure
+	scan()
+	assert.Empty(t, receivedBatches("/new-failures"))
+	assert.Empty(t, receivedBatches("/run-end"))
+
+	// the policy starts failing
+	allowed.Store(false)
+	scan()
+	// the batches are sent in the background
+	require.Eventually(t, func() bool {
+		return len(receivedBatches("/new-failures")) == 1 && len(receivedBatches("/run-end")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	newFailures := receivedBatches("/new-failures")
+	require.Len(t, newFailures[0].Findings, 1)
+	assert.Equal(t, "pod", newFailures[0].Findings[0].Name)
+	assert.Equal(t, "namespaced-namespace-policy", newFailures[0].Findings[0].Policy)
+	assert.Equal(t, "the image is not allowed", newFailures[0].Findings[0].Message)
+
+	// the policy keeps failing, which is only notified at the end of the run
+	scan()
+	require.Eventually(t, func() bool { return len(receivedBatches("/run-end")) == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, receivedBatches("/new-failures"), 1)
+	runEnd := receivedBatches("/run-end")
+	require.Len(t, runEnd[1].Findings, 1)
+	assert.Equal(t, notification.TriggerRunEnd, runEnd[1].Trigger)
+	assert.Equal(t, "pod", runEnd[1].Findings[0].Name)
+	assert.NotEqual(t, runEnd[0].Findings[0].RunUID, runEnd[1].Findings[0].RunUID)
+
+	scanner.Close()
+}
@@ -0,0 +1,737 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/metrics"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/policies"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// watchKey identifies an item of the watch queue. It's either a request to
+// refresh the auditable policies, of all the namespaces or of a single one, or
+// a resource to be audited.
+type watchKey struct {
+	refreshPolicies bool
+	// refreshNamespace is the name of the namespace whose policies are refreshed
+	refreshNamespace string
+	gvr              schema.GroupVersionResource
+	namespace        string
+	name             string
+	// policiesChanged is set when the resource is audited because the
+	// policies targeting it changed. In this case the resource is handled
+	// even when no policy targets it anymore, so its report is deleted.
+	policiesChanged bool
+}
+
+// resourceInformer is the informer of the audited resources of a GVR.
+type resourceInformer struct {
+	cache.SharedIndexInformer
+	// stop stops the informer, once no policy targets the GVR anymore
+	stop context.CancelFunc
+}
+
+// watcher keeps the reports up to date by auditing the resources as soon as
+// they, or the policies targeting them, change.
+type watcher struct {
+	scanner *Scanner
+	runUID  string
+	factory dynamicinformer.DynamicSharedInformerFactory
+	queue   workqueue.TypedRateLimitingInterface[watchKey]
+	logger  *slog.Logger
+	// namespaces is the informer of the namespaces
+	namespaces cache.SharedIndexInformer
+
+	// mutex protects the fields below, which are replaced on every refresh of the policies
+	mutex sync.RWMutex
+	// informers of the audited resources, by GVR
+	informers map[schema.GroupVersionResource]*resourceInformer
+	// clusterPolicies are the policies auditing cluster-wide resources
+	clusterPolicies *policies.Policies
+	// namespacedPolicies are the policies auditing namespaced resources, by namespace
+	namespacedPolicies map[string]*policies.Policies
+}
+
+// policyGVRs returns the GVRs of the Kubewarden policies.
+func policyGVRs() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		{Group: constants.KubewardenPoliciesGroup, Version: constants.KubewardenPoliciesVersion, Resource: "clusteradmissionpolicies"},
+		{Group: constants.KubewardenPoliciesGroup, Version: constants.KubewardenPoliciesVersion, Resource: "clusteradmissionpolicygroups"},
+		{Group: constants.KubewardenPoliciesGroup, Version: constants.KubewardenPoliciesVersion, Resource: "admissionpolicies"},
+		{Group: constants.KubewardenPoliciesGroup, Version: constants.KubewardenPoliciesVersion, Resource: "admissionpolicygroups"},
+	}
+}
+
+// Watch audits resources as soon as they change, until the context is cancelled.
+// Informers are started for all the GVRs targeted by the auditable policies,
+// and stopped once no policy targets them anymore.
+// Policies and namespaces are watched too: when a policy changes, all the
+// resources it targets are audited again.
+func (s *Scanner) Watch(ctx context.Context, runUID string, resyncPeriod time.Duration) error {
+	s.logger.InfoContext(ctx, "watch started",
+		slog.String("RunUID", runUID),
+		slog.Duration("resync-period", resyncPeriod),
+		slog.Int("parallel-resources-audits", s.parallelResourcesAudits))
+
+	// The informers never resync: the updates of the resources that did not
+	// change are ignored, the resources are audited again by resyncResources.
+	// The factory only runs the informers of the policies and of the namespaces
+	factory := s.k8sClient.NewInformerFactory(0)
+	w := &watcher{
+		scanner:            s,
+		runUID:             runUID,
+		factory:            factory,
+		queue:              workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[watchKey]()),
+		logger:             s.logger.With("component", "watcher"),
+		namespaces:         factory.ForResource(corev1.SchemeGroupVersion.WithResource("namespaces")).Informer(),
+		informers:          make(map[schema.GroupVersionResource]*resourceInformer),
+		namespacedPolicies: make(map[string]*policies.Policies),
+	}
+	defer w.queue.ShutDown()
+
+	// Any change to the policies or to the namespaces can change the set of
+	// resources to be audited, and the policies to audit them with.
+	for _, gvr := range policyGVRs() {
+		if _, err := w.factory.ForResource(gvr).Informer().AddEventHandler(w.policiesEventHandler()); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", gvr.String(), err)
+		}
+	}
+	if _, err := w.namespaces.AddEventHandler(w.namespacesEventHandler()); err != nil {
+		return fmt.Errorf("failed to watch namespaces: %w", err)
+	}
+	if err := w.startInformers(ctx); err != nil {
+		return err
+	}
+
+	if err := w.refreshPolicies(ctx); err != nil {
+		return err
+	}
+	if resyncPeriod > 0 {
+		go w.resyncResources(ctx, resyncPeriod)
+	}
+
+	var workers sync.WaitGroup
+	for range s.parallelResourcesAudits {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for w.processNextItem(ctx) {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	w.queue.ShutDown()
+	workers.Wait()
+	w.factory.Shutdown()
+
+	s.logger.InfoContext(ctx, "watch finished", slog.String("RunUID", runUID))
+	return nil
+}
+
+// startInformers starts the informers of the factory that are not running yet
+// and waits for their caches to be synced.
+func (w *watcher) startInformers(ctx context.Context) error {
+	w.factory.Start(ctx.Done())
+	for gvr, synced := range w.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync the cache of %s", gvr.String())
+		}
+	}
+	return nil
+}
+
+// resyncResources enqueues all the watched resources to be audited again
+// every period, until the context is cancelled.
+func (w *watcher) resyncResources(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		w.mutex.RLock()
+		informers := maps.Clone(w.informers)
+		w.mutex.RUnlock()
+		for gvr, informer := range informers {
+			for _, obj := range informer.GetIndexer().List() {
+				resource, ok := obj.(*unstructured.Unstructured)
+				if !ok || w.scanner.k8sClient.IsSkippedNamespace(resource.GetNamespace()) {
+					continue
+				}
+				w.queue.Add(watchKey{gvr: gvr, namespace: resource.GetNamespace(), name: resource.GetName()})
+			}
+		}
+	}
+}
+
+// policiesEventHandler requests a refresh of the auditable policies when a policy changes.
+func (w *watcher) policiesEventHandler() cache.ResourceEventHandler {
+	refresh := func() {
+		w.queue.Add(watchKey{refreshPolicies: true})
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(_ any) {
+			refresh()
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			oldResource, oldOk := oldObj.(*unstructured.Unstructured)
+			newResource, newOk := newObj.(*unstructured.Unstructured)
+			if !oldOk || !newOk || auditRelevantChange(oldResource, newResource) {
+				refresh()
+			}
+		},
+		DeleteFunc: func(_ any) {
+			refresh()
+		},
+	}
+}
+
+// namespacesEventHandler requests a refresh of the auditable policies of a
+// namespace when it's created, deleted, or when its labels change. The
+// namespaces listed when the informer starts are handled by the first
+// refresh of all the policies.
+func (w *watcher) namespacesEventHandler() cache.ResourceEventHandler {
+	refresh := func(obj any) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if namespace, ok := obj.(*unstructured.Unstructured); ok {
+			w.queue.Add(watchKey{refreshNamespace: namespace.GetName()})
+		}
+	}
+
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj any, isInInitialList bool) {
+			if !isInInitialList {
+				refresh(obj)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			oldNamespace, oldOk := oldObj.(*unstructured.Unstructured)
+			newNamespace, newOk := newObj.(*unstructured.Unstructured)
+			if oldOk && newOk && maps.Equal(oldNamespace.GetLabels(), newNamespace.GetLabels()) {
+				return
+			}
+			refresh(newObj)
+		},
+		DeleteFunc: refresh,
+	}
+}
+
+// auditRelevantChange returns true if the change of a policy may change the
+// set of auditable policies: that's a change of the spec, of the labels or of
+// the policy status.
+func auditRelevantChange(oldResource, newResource *unstructured.Unstructured) bool {
+	if oldResource.GetGeneration() != newResource.GetGeneration() {
+		return true
+	}
+	if !maps.Equal(oldResource.GetLabels(), newResource.GetLabels()) {
+		return true
+	}
+	oldStatus, _, _ := unstructured.NestedString(oldResource.Object, "status", "policyStatus")
+	newStatus, _, _ := unstructured.NestedString(newResource.Object, "status", "policyStatus")
+	return oldStatus != newStatus
+}
+
+// resourceEventHandler enqueues the resources of the given GVR to be audited when they change.
+func (w *watcher) resourceEventHandler(gvr schema.GroupVersionResource) cache.ResourceEventHandler {
+	enqueue := func(obj any) {
+		resource, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		if w.scanner.k8sClient.IsSkippedNamespace(resource.GetNamespace()) {
+			return
+		}
+		w.queue.Add(watchKey{gvr: gvr, namespace: resource.GetNamespace(), name: resource.GetName()})
+	}
+
+	// Deleted resources are not handled: their reports are garbage collected
+	// by Kubernetes, thanks to the owner reference.
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(oldObj, newObj any) {
+			oldResource, oldOk := oldObj.(*unstructured.Unstructured)
+			newResource, newOk := newObj.(*unstructured.Unstructured)
+			if oldOk && newOk && !auditedContentChanged(oldResource, newResource) {
+				return
+			}
+			enqueue(newObj)
+		},
+	}
+}
+
+// auditedContentChanged returns true if the change of a resource may change
+// the verdicts of the policies. The updates of the status, and of the fields
+// of the metadata maintained by the API server, are ignored. When the
+// resource has a generation, its spec changed only if the generation did.
+func auditedContentChanged(oldResource, newResource *unstructured.Unstructured) bool {
+	if oldResource.GetResourceVersion() == newResource.GetResourceVersion() {
+		return false
+	}
+	if !equality.Semantic.DeepEqual(auditedMetadata(oldResource), auditedMetadata(newResource)) {
+		return true
+	}
+	if newResource.GetGeneration() != 0 {
+		return oldResource.GetGeneration() != newResource.GetGeneration()
+	}
+	for field := range mergedKeys(oldResource.Object, newResource.Object) {
+		if field == "metadata" || field == "status" {
+			continue
+		}
+		if !equality.Semantic.DeepEqual(oldResource.Object[field], newResource.Object[field]) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditedMetadata returns the metadata of the resource without the fields
+// maintained by the API server on every write.
+func auditedMetadata(resource *unstructured.Unstructured) map[string]any {
+	metadata, _ := resource.Object["metadata"].(map[string]any)
+	audited := maps.Clone(metadata)
+	delete(audited, "resourceVersion")
+	delete(audited, "managedFields")
+	delete(audited, "generation")
+	return audited
+}
+
+// mergedKeys returns the keys of both maps.
+func mergedKeys(a, b map[string]any) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a))
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+// refreshPolicies fetches the auditable policies, starts the informers of the
+// GVRs targeted for the first time, stops the ones of the GVRs no longer
+// targeted, and enqueues the resources whose policies changed.
+func (w *watcher) refreshPolicies(ctx context.Context) error {
+	clusterPolicies, err := w.scanner.policiesClient.GetClusterWidePolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to obtain cluster auditable policies: %w", err)
+	}
+	nsList, err := w.scanner.k8sClient.GetAuditedNamespaces(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to obtain audited namespaces: %w", err)
+	}
+	namespacedPolicies := make(map[string]*policies.Policies, len(nsList.Items))
+	for i := range nsList.Items {
+		namespace := &nsList.Items[i]
+		nsPolicies, nsErr := w.scanner.policiesClient.GetPoliciesByNamespace(ctx, namespace)
+		if nsErr != nil {
+			return fmt.Errorf("failed to obtain auditable policies for namespace %s: %w", namespace.GetName(), nsErr)
+		}
+		namespacedPolicies[namespace.GetName()] = nsPolicies
+	}
+
+	w.mutex.Lock()
+	firstRefresh := w.clusterPolicies == nil
+	previousClusterPolicies := w.clusterPolicies
+	previousNamespacedPolicies := w.namespacedPolicies
+	w.clusterPolicies = clusterPolicies
+	w.namespacedPolicies = namespacedPolicies
+	newGVRs, err := w.watchNewGVRs(ctx, append([]*policies.Policies{clusterPolicies}, slices.Collect(maps.Values(namespacedPolicies))...))
+	staleGVRs := w.unwatchStaleGVRs()
+	w.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	w.logger.InfoContext(ctx, "auditable policies refreshed",
+		slog.Int("cluster-policies-to-evaluate", clusterPolicies.PolicyNum),
+		slog.Int("audited-namespaces", len(namespacedPolicies)),
+		slog.Int("new-watched-resources", len(newGVRs)),
+		slog.Int("unwatched-resources", len(staleGVRs)))
+
+	w.forgetResources(ctx, staleGVRs)
+	if err = w.waitForCacheSync(ctx, newGVRs); err != nil {
+		return err
+	}
+	if firstRefresh {
+		return nil
+	}
+
+	w.enqueueChangedResources(previousClusterPolicies, clusterPolicies, "", newGVRs)
+	for nsName, nsPolicies := range namespacedPolicies {
+		w.enqueueChangedResources(previousNamespacedPolicies[nsName], nsPolicies, nsName, newGVRs)
+	}
+	for nsName, previousNsPolicies := range previousNamespacedPolicies {
+		if _, found := namespacedPolicies[nsName]; !found {
+			w.enqueueChangedResources(previousNsPolicies, &policies.Policies{}, nsName, newGVRs)
+		}
+	}
+
+	return nil
+}
+
+// refreshNamespacePolicies fetches the auditable policies of the namespace,
+// starts the informers of the GVRs targeted for the first time, stops the ones
+// of the GVRs no longer targeted, and enqueues the resources of the namespace
+// whose policies changed. The namespace has no
+// auditable policies when it was deleted or is not audited.
+func (w *watcher) refreshNamespacePolicies(ctx context.Context, nsName string) error {
+	w.mutex.RLock()
+	firstRefresh := w.clusterPolicies == nil
+	w.mutex.RUnlock()
+	if firstRefresh {
+		// The namespace is handled by the first refresh of all the policies
+		return nil
+	}
+
+	namespace, err := w.auditedNamespace(nsName)
+	if err != nil {
+		return err
+	}
+	var nsPolicies *policies.Policies
+	if namespace != nil {
+		nsPolicies, err = w.scanner.policiesClient.GetPoliciesByNamespace(ctx, namespace)
+		if err != nil {
+			return fmt.Errorf("failed to obtain auditable policies for namespace %s: %w", nsName, err)
+		}
+	}
+
+	w.mutex.Lock()
+	previous := w.namespacedPolicies[nsName]
+	namespacedPolicies := maps.Clone(w.namespacedPolicies)
+	if nsPolicies != nil {
+		namespacedPolicies[nsName] = nsPolicies
+	} else {
+		delete(namespacedPolicies, nsName)
+	}
+	w.namespacedPolicies = namespacedPolicies
+	var newGVRs map[schema.GroupVersionResource]struct{}
+	if nsPolicies != nil {
+		newGVRs, err = w.watchNewGVRs(ctx, []*policies.Policies{nsPolicies})
+	}
+	staleGVRs := w.unwatchStaleGVRs()
+	w.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	w.logger.InfoContext(ctx, "auditable policies of the namespace refreshed",
+		slog.String("ns", nsName),
+		slog.Bool("audited", nsPolicies != nil),
+		slog.Int("new-watched-resources", len(newGVRs)),
+		slog.Int("unwatched-resources", len(staleGVRs)))
+
+	w.forgetResources(ctx, staleGVRs)
+	if err = w.waitForCacheSync(ctx, newGVRs); err != nil {
+		return err
+	}
+	if nsPolicies == nil {
+		nsPolicies = &policies.Policies{}
+	}
+	w.enqueueChangedResources(previous, nsPolicies, nsName, newGVRs)
+	return nil
+}
+
+// auditedNamespace returns the namespace with the given name from the cache
+// of the informer, or nil when it does not exist or is not audited.
+func (w *watcher) auditedNamespace(nsName string) (*corev1.Namespace, error) {
+	obj, exists, err := w.namespaces.GetIndexer().GetByKey(nsName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespace %s from cache: %w", nsName, err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, errors.New("failed to convert cached object to *unstructured.Unstructured")
+	}
+	namespace := &corev1.Namespace{}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Object, namespace); err != nil {
+		return nil, fmt.Errorf("failed to convert namespace %s: %w", nsName, err)
+	}
+	if !w.scanner.k8sClient.IsAuditedNamespace(namespace) {
+		return nil, nil
+	}
+	return namespace, nil
+}
+
+// watchNewGVRs starts the informers of the GVRs targeted by the policies for
+// the first time, and returns these GVRs. The informers of new GVRs replay all
+// their resources as added, so they are all audited and there's no need to
+// enqueue them explicitly. They run until the context is cancelled, or no
+// policy targets their GVR anymore. It must be called with the mutex held.
+func (w *watcher) watchNewGVRs(ctx context.Context, auditables []*policies.Policies) (map[schema.GroupVersionResource]struct{}, error) {
+	newGVRs := map[schema.GroupVersionResource]struct{}{}
+	for _, auditable := range auditables {
+		for gvr := range auditable.PoliciesByGVR {
+			if _, found := w.informers[gvr]; found {
+				continue
+			}
+			informer := w.scanner.k8sClient.NewInformer(gvr)
+			if _, err := informer.AddEventHandler(w.resourceEventHandler(gvr)); err != nil {
+				return nil, fmt.Errorf("failed to watch %s: %w", gvr.String(), err)
+			}
+			informerCtx, stop := context.WithCancel(ctx)
+			go informer.RunWithContext(informerCtx)
+			w.informers[gvr] = &resourceInformer{SharedIndexInformer: informer, stop: stop}
+			newGVRs[gvr] = struct{}{}
+		}
+	}
+	return newGVRs, nil
+}
+
+// waitForCacheSync waits for the caches of the informers of the given GVRs to
+// be synced.
+func (w *watcher) waitForCacheSync(ctx context.Context, gvrs map[schema.GroupVersionResource]struct{}) error {
+	w.mutex.RLock()
+	synced := make([]cache.InformerSynced, 0, len(gvrs))
+	for gvr := range gvrs {
+		if informer, found := w.informers[gvr]; found {
+			synced = append(synced, informer.HasSynced)
+		}
+	}
+	w.mutex.RUnlock()
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return errors.New("failed to sync the cache of the watched resources")
+	}
+	return nil
+}
+
+// unwatchStaleGVRs removes the informers of the GVRs no longer targeted by any
+// policy, and returns them. It must be called with the mutex held.
+func (w *watcher) unwatchStaleGVRs() map[schema.GroupVersionResource]*resourceInformer {
+	targeted := map[schema.GroupVersionResource]struct{}{}
+	for _, auditable := range append([]*policies.Policies{w.clusterPolicies}, slices.Collect(maps.Values(w.namespacedPolicies))...) {
+		for gvr := range auditable.PoliciesByGVR {
+			targeted[gvr] = struct{}{}
+		}
+	}
+
+	staleGVRs := map[schema.GroupVersionResource]*resourceInformer{}
+	for gvr, informer := range w.informers {
+		if _, found := targeted[gvr]; !found {
+			staleGVRs[gvr] = informer
+			delete(w.informers, gvr)
+		}
+	}
+	return staleGVRs
+}
+
+// forgetResources deletes the reports of the resources of the GVRs no longer
+// targeted by any policy, and stops their informers.
+func (w *watcher) forgetResources(ctx context.Context, staleGVRs map[schema.GroupVersionResource]*resourceInformer) {
+	for _, informer := range staleGVRs {
+		for _, obj := range informer.GetIndexer().List() {
+			resource, ok := obj.(*unstructured.Unstructured)
+			if !ok || w.scanner.k8sClient.IsSkippedNamespace(resource.GetNamespace()) {
+				continue
+			}
+			w.deleteReport(ctx, resource)
+		}
+		informer.stop()
+	}
+}
+
+// deleteReport deletes the report of a resource no longer targeted by any
+// policy. The reports are kept when the watch is targeted, as they hold the
+// results of the policies that are not watched, like the targeted scans keep
+// the reports of the resources they do not audit.
+func (w *watcher) deleteReport(ctx context.Context, resource *unstructured.Unstructured) {
+	if w.scanner.disableStore || w.scanner.targeted {
+		return
+	}
+
+	var err error
+	operation := metrics.OperationDeleteReports
+	if resource.GetNamespace() == "" {
+		operation = metrics.OperationDeleteClusterReport
+		err = w.scanner.reportStore.DeleteClusterReport(ctx, resource.GetUID())
+	} else {
+		err = w.scanner.reportStore.DeleteReport(ctx, resource.GetNamespace(), resource.GetUID())
+	}
+	if err != nil {
+		w.scanner.metrics.RecordReportStoreFailure(ctx, w.runUID, operation)
+		w.logger.ErrorContext(ctx, "error deleting the report of a resource no longer audited",
+			slog.String("error", err.Error()),
+			slog.String("ns", resource.GetNamespace()),
+			slog.String("resource", resource.GetName()))
+	}
+}
+
+// enqueueChangedResources enqueues all the resources of the GVRs whose policies changed.
+// The namespace is empty for cluster-wide resources.
+func (w *watcher) enqueueChangedResources(previous, current *policies.Policies, namespace string, newGVRs map[schema.GroupVersionResource]struct{}) {
+	if previous == nil {
+		previous = &policies.Policies{}
+	}
+	gvrs := map[schema.GroupVersionResource]struct{}{}
+	for gvr := range previous.PoliciesByGVR {
+		gvrs[gvr] = struct{}{}
+	}
+	for gvr := range current.PoliciesByGVR {
+		gvrs[gvr] = struct{}{}
+	}
+
+	for gvr := range gvrs {
+		if _, isNew := newGVRs[gvr]; isNew {
+			continue
+		}
+		if samePolicies(previous.PoliciesByGVR[gvr], current.PoliciesByGVR[gvr]) {
+			continue
+		}
+
+		w.mutex.RLock()
+		informer := w.informers[gvr]
+		w.mutex.RUnlock()
+		if informer == nil {
+			// No policy targets the GVR anymore, its reports were deleted by forgetResources
+			continue
+		}
+
+		var objs []any
+		if namespace == "" {
+			objs = informer.GetIndexer().List()
+		} else {
+			var err error
+			objs, err = informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+			if err != nil {
+				w.logger.Error("failed to list the watched resources",
+					slog.String("error", err.Error()),
+					slog.String("resource-GVR", gvr.String()),
+					slog.String("ns", namespace))
+				continue
+			}
+		}
+
+		for _, obj := range objs {
+			resource, ok := obj.(*unstructured.Unstructured)
+			if !ok || resource.GetNamespace() != namespace {
+				continue
+			}
+			w.queue.Add(watchKey{gvr: gvr, namespace: namespace, name: resource.GetName(), policiesChanged: true})
+		}
+	}
+}
+
+// samePolicies returns true if both lists contain the same version of the same policies.
+func samePolicies(previous, current []*policies.Policy) bool {
+	fingerprints := func(pols []*policies.Policy) []string {
+		result := make([]string, 0, len(pols))
+		for _, policy := range pols {
+			result = append(result, fmt.Sprintf("%s/%d/%s", policy.GetUID(), policy.GetGeneration(), policy.PolicyServer))
+		}
+		slices.Sort(result)
+		return result
+	}
+
+	return slices.Equal(fingerprints(previous), fingerprints(current))
+}
+
+// processNextItem processes one item of the queue. It returns false when the queue is shut down.
+func (w *watcher) processNextItem(ctx context.Context) bool {
+	key, shutdown := w.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.queue.Done(key)
+
+	var err error
+	switch {
+	case key.refreshPolicies:
+		err = w.refreshPolicies(ctx)
+	case key.refreshNamespace != "":
+		err = w.refreshNamespacePolicies(ctx, key.refreshNamespace)
+	default:
+		err = w.auditResource(ctx, key)
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return true
+		}
+		w.logger.ErrorContext(ctx, "error processing watched item, retrying",
+			slog.String("error", err.Error()),
+			slog.Bool("refresh-policies", key.refreshPolicies),
+			slog.String("refresh-namespace", key.refreshNamespace),
+			slog.String("resource-GVR", key.gvr.String()),
+			slog.String("ns", key.namespace),
+			slog.String("resource", key.name))
+		w.queue.AddRateLimited(key)
+		return true
+	}
+	w.queue.Forget(key)
+	return true
+}
+
+// auditResource audits the resource identified by the key with the policies targeting it.
+func (w *watcher) auditResource(ctx context.Context, key watchKey) error {
+	w.mutex.RLock()
+	informer := w.informers[key.gvr]
+	auditable := w.clusterPolicies
+	if key.namespace != "" {
+		auditable = w.namespacedPolicies[key.namespace]
+	}
+	w.mutex.RUnlock()
+
+	// The namespace is not audited, or it's not known yet. In the latter case,
+	// its resources are enqueued again once the policies are refreshed.
+	if informer == nil || auditable == nil {
+		return nil
+	}
+	policiesToAudit := auditable.PoliciesByGVR[key.gvr]
+	if len(policiesToAudit) == 0 && !key.policiesChanged {
+		return nil
+	}
+
+	cacheKey := key.name
+	if key.namespace != "" {
+		cacheKey = key.namespace + "/" + key.name
+	}
+	obj, exists, err := informer.GetIndexer().GetByKey(cacheKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s %s from cache: %w", key.gvr.String(), cacheKey, err)
+	}
+	if !exists {
+		return nil
+	}
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return errors.New("failed to convert cached object to *unstructured.Unstructured")
+	}
+
+	if len(policiesToAudit) == 0 && !w.scanner.targeted {
+		// The policies targeting the resource were removed. When the watch is
+		// targeted, the resource is audited to keep the results of the other
+		// policies in its report
+		w.deleteReport(ctx, resource)
+		return nil
+	}
+	if key.namespace == "" {
+		w.scanner.auditClusterResource(ctx, policiesToAudit, key.gvr, *resource.DeepCopy(), w.runUID, auditable.NotAudited(key.gvr))
+		return nil
+	}
+	_, err = w.scanner.auditResource(ctx, policiesToAudit, key.gvr, *resource.DeepCopy(), w.runUID, auditable.NotAudited(key.gvr))
+	return err
+}
@@ -0,0 +1,291 @@
+package scanner
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/report"
+	auditscheme "github.com/kubewarden/adm-controller/internal/audit-scanner/scheme"
+	"github.com/kubewarden/adm-controller/internal/audit-scanner/testutils"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatch(t *testing.T) {
+	var requests atomic.Int32
+	mockPolicyServer := newCountingMockPolicyServer(&requests)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods
+	clusterAdmissionPolicy1 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy1").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+	clusterAdmissionPolicy1.SetUID("clusterAdmissionPolicy1-uid")
+
+	// another ClusterAdmissionPolicy targeting pods, created while watching
+	clusterAdmissionPolicy2 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy2").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+	clusterAdmissionPolicy2.SetUID("clusterAdmissionPolicy2-uid")
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+		clusterAdmissionPolicy1,
+	)
+	clientset := fake.NewClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy1,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, k8s.Selectors{}, logger)
+	policiesClient := policies.NewClient(client, testutils.NewFakeDiscovery(), "kubewarden", mockPolicyServer.URL, nil, policies.Filter{}, logger)
+	reportStore := report.NewOpenReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, reportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	watchErr := make(chan error)
+	go func() {
+		watchErr <- scanner.Watch(ctx, "runUID", 0)
+	}()
+
+	podReportResults := func() int {
+		podReport := openreports.Report{}
+		if getErr := client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &podReport); getErr != nil {
+			return 0
+		}
+		return len(podReport.Results)
+	}
+
+	// the existing pod is audited as soon as the watch starts
+	require.Eventually(t, func() bool { return podReportResults() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// a new policy triggers a new audit of the pod
+	require.NoError(t, client.Create(t.Context(), clusterAdmissionPolicy2))
+	unstructuredPolicy, err := runtime.DefaultUnstructuredConverter.ToUnstructured(clusterAdmissionPolicy2)
+	require.NoError(t, err)
+	_, err = dynamicClient.Resource(policiesv1.GroupVersion.WithResource("clusteradmissionpolicies")).
+		Create(t.Context(), &unstructured.Unstructured{Object: unstructuredPolicy}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return podReportResults() == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return requests.Load() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	// neither a status update of the pod, nor a change of the labels of the
+	// namespace not changing its policies, trigger a new audit
+	podsClient := dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("pods")).Namespace("namespace")
+	unstructuredPod, err := podsClient.Get(t.Context(), "pod", metav1.GetOptions{})
+	require.NoError(t, err)
+	unstructuredPod.SetResourceVersion("2")
+	require.NoError(t, unstructured.SetNestedField(unstructuredPod.Object, "Running", "status", "phase"))
+	_, err = podsClient.Update(t.Context(), unstructuredPod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	namespacesClient := dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("namespaces"))
+	unstructuredNamespace, err := namespacesClient.Get(t.Context(), "namespace", metav1.GetOptions{})
+	require.NoError(t, err)
+	unstructuredNamespace.SetResourceVersion("2")
+	unstructuredNamespace.SetLabels(map[string]string{"team": "audit"})
+	_, err = namespacesClient.Update(t.Context(), unstructuredNamespace, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.Never(t, func() bool { return requests.Load() != 3 }, 500*time.Millisecond, 10*time.Millisecond)
+
+	// a change of the spec of the pod triggers a new audit
+	unstructuredPod, err = podsClient.Get(t.Context(), "pod", metav1.GetOptions{})
+	require.NoError(t, err)
+	unstructuredPod.SetResourceVersion("3")
+	require.NoError(t, unstructured.SetNestedField(unstructuredPod.Object, "nginx", "spec", "serviceAccountName"))
+	_, err = podsClient.Update(t.Context(), unstructuredPod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return requests.Load() == 5 }, 5*time.Second, 10*time.Millisecond)
+
+	// once no policy targets the pods, the report of the pod is deleted
+	policiesResource := dynamicClient.Resource(policiesv1.GroupVersion.WithResource("clusteradmissionpolicies"))
+	for _, policy := range []*policiesv1.ClusterAdmissionPolicy{clusterAdmissionPolicy1, clusterAdmissionPolicy2} {
+		require.NoError(t, client.Delete(t.Context(), policy))
+		require.NoError(t, policiesResource.Delete(t.Context(), policy.GetName(), metav1.DeleteOptions{}))
+	}
+
+	require.Eventually(t, func() bool {
+		getErr := client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &openreports.Report{})
+		return apierrors.IsNotFound(getErr)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(5), requests.Load())
+
+	cancel()
+	require.NoError(t, <-watchErr)
+}
+
+func TestAuditedContentChanged(t *testing.T) {
+	newResource := func(resourceVersion string, generation int64, mutate func(*unstructured.Unstructured)) *unstructured.Unstructured {
+		resource := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]any{
+				"name":      "deployment",
+				"namespace": "namespace",
+			},
+			"spec": map[string]any{
+				"replicas": int64(1),
+			},
+		}}
+		resource.SetResourceVersion(resourceVersion)
+		resource.SetGeneration(generation)
+		if mutate != nil {
+			mutate(resource)
+		}
+		return resource
+	}
+
+	tests := []struct {
+		name        string
+		oldResource *unstructured.Unstructured
+		newResource *unstructured.Unstructured
+		expected    bool
+	}{
+		{
+			name:        "resync with the same resource version",
+			oldResource: newResource("1", 1, nil),
+			newResource: newResource("1", 1, nil),
+			expected:    false,
+		},
+		{
+			name:        "status update",
+			oldResource: newResource("1", 1, nil),
+			newResource: newResource("2", 1, func(r *unstructured.Unstructured) {
+				r.Object["status"] = map[string]any{"readyReplicas": int64(1)}
+			}),
+			expected: false,
+		},
+		{
+			name:        "managed fields update",
+			oldResource: newResource("1", 1, nil),
+			newResource: newResource("2", 1, func(r *unstructured.Unstructured) {
+				r.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+			}),
+			expected: false,
+		},
+		{
+			name:        "spec update with a new generation",
+			oldResource: newResource("1", 1, nil),
+			newResource: newResource("2", 2, func(r *unstructured.Unstructured) {
+				r.Object["spec"] = map[string]any{"replicas": int64(2)}
+			}),
+			expected: true,
+		},
+		{
+			name:        "labels update",
+			oldResource: newResource("1", 1, nil),
+			newResource: newResource("2", 1, func(r *unstructured.Unstructured) {
+				r.SetLabels(map[string]string{"app": "nginx"})
+			}),
+			expected: true,
+		},
+		{
+			name:        "spec update without generation",
+			oldResource: newResource("1", 0, nil),
+			newResource: newResource("2", 0, func(r *unstructured.Unstructured) {
+				r.Object["spec"] = map[string]any{"replicas": int64(2)}
+			}),
+			expected: true,
+		},
+		{
+			name:        "status update without generation",
+			oldResource: newResource("1", 0, nil),
+			newResource: newResource("2", 0, func(r *unstructured.Unstructured) {
+				r.Object["status"] = map[string]any{"phase": "Running"}
+			}),
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, auditedContentChanged(test.oldResource, test.newResource))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package shard
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Shard is the portion of the namespaces and of the cluster-wide resources
+// audited by one of the Count replicas of the audit scanner. The namespaces
+// and the GVRs of the cluster-wide resources are assigned to the shards by a
+// hash of their name, so every replica computes the same partition.
+// The zero value is a single shard auditing everything.
+type Shard struct {
+	// Index of the shard, between 0 and Count-1
+	Index int
+	// Count is the number of shards
+	Count int
+}
+
+// New returns the shard with the given index out of count shards.
+func New(index, count int) (Shard, error) {
+	if count < 1 {
+		return Shard{}, errors.New("the shard count must be at least 1")
+	}
+	if index < 0 || index >= count {
+		return Shard{}, fmt.Errorf("the shard index must be between 0 and %d", count-1)
+	}
+	return Shard{Index: index, Count: count}, nil
+}
+
+// Enabled returns true when the audit is split across more than one shard.
+func (s Shard) Enabled() bool {
+	return s.Count > 1
+}
+
+// String returns the index of the shard, as set in the labels of the
+// resources it owns.
+func (s Shard) String() string {
+	return strconv.Itoa(s.Index)
+}
+
+// Key returns the shard key of the given name. The key is a valid label value.
+func Key(name string) string {
+	hash := fnv.New32a()
+	// Writing to a hash never fails
+	_, _ = hash.Write([]byte(name))
+	return strconv.FormatUint(uint64(hash.Sum32()), 10)
+}
+
+// OwnsKey returns true if the shard owns the given shard key. Invalid keys,
+// like the ones of the resources created before the audit was sharded, are
+// owned by the first shard.
+func (s Shard) OwnsKey(key string) bool {
+	if !s.Enabled() {
+		return true
+	}
+	hash, err := strconv.ParseUint(key, 10, 32)
+	if err != nil {
+		return s.Index == 0
+	}
+	return hash%uint64(s.Count) == uint64(s.Index)
+}
+
+// OwnsNamespace returns true if the resources of the namespace are audited by the shard.
+func (s Shard) OwnsNamespace(namespace string) bool {
+	return s.OwnsKey(Key(namespace))
+}
+
+// OwnsGVR returns true if the cluster-wide resources of the GVR are audited by the shard.
+func (s Shard) OwnsGVR(gvr schema.GroupVersionResource) bool {
+	return s.OwnsKey(GVRKey(gvr))
+}
+
+// GVRKey returns the shard key of the cluster-wide resources of the GVR.
+func GVRKey(gvr schema.GroupVersionResource) string {
+	return Key(gvr.GroupResource().String())
+}
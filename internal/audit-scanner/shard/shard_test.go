@@ -0,0 +1,73 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		index       int
+		count       int
+		expectedErr string
+	}{
+		{"single shard", 0, 1, ""},
+		{"last shard", 2, 3, ""},
+		{"zero count", 0, 0, "the shard count must be at least 1"},
+		{"negative index", -1, 3, "the shard index must be between 0 and 2"},
+		{"index out of range", 3, 3, "the shard index must be between 0 and 2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shard, err := New(test.index, test.count)
+			if test.expectedErr != "" {
+				require.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, Shard{Index: test.index, Count: test.count}, shard)
+		})
+	}
+}
+
+func TestShardsPartitionNamespaces(t *testing.T) {
+	const count = 3
+	owners := map[int]int{}
+	for i := range 100 {
+		namespace := fmt.Sprintf("namespace-%d", i)
+		owned := 0
+		for index := range count {
+			if (Shard{Index: index, Count: count}).OwnsNamespace(namespace) {
+				owned++
+				owners[index]++
+			}
+		}
+		assert.Equal(t, 1, owned, "namespace %s must be owned by exactly one shard", namespace)
+	}
+	assert.Len(t, owners, count, "every shard must own some namespaces")
+}
+
+func TestOwnsGVR(t *testing.T) {
+	pods := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+	podsV2 := schema.GroupVersionResource{Group: "", Version: "v2", Resource: "pods"}
+
+	for index := range 3 {
+		shard := Shard{Index: index, Count: 3}
+		assert.Equal(t, shard.OwnsGVR(pods), shard.OwnsGVR(podsV2), "the versions of a resource must be owned by the same shard")
+		assert.Equal(t, shard.OwnsGVR(pods), shard.OwnsKey(GVRKey(pods)))
+	}
+}
+
+func TestOwnsKey(t *testing.T) {
+	assert.True(t, Shard{}.OwnsKey("1"), "a disabled shard owns everything")
+	assert.True(t, Shard{Index: 1, Count: 3}.OwnsKey("4"))
+	assert.False(t, Shard{Index: 0, Count: 3}.OwnsKey("4"))
+	assert.True(t, Shard{Index: 0, Count: 3}.OwnsKey(""), "invalid keys are owned by the first shard")
+	assert.False(t, Shard{Index: 1, Count: 3}.OwnsKey("not-a-key"))
+}
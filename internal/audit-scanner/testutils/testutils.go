@@ -21,6 +21,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
@@ -43,7 +46,51 @@ func NewFakeClient(objects ...runtime.Object) (client.Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create audit scheme: %w", err)
 	}
-	return fake.NewClientBuilder().WithRESTMapper(restMapper).WithScheme(auditScheme).WithRuntimeObjects(objects...).Build(), nil
+	return fake.NewClientBuilder().
+		WithRESTMapper(restMapper).
+		WithScheme(auditScheme).
+		WithStatusSubresource(&policiesv1.AuditRun{}).
+		WithRuntimeObjects(objects...).
+		Build(), nil
+}
+
+// NewFakeDiscovery returns a discovery client serving the resources known by the client returned by NewFakeClient,
+// together with a subresource and a resource that cannot be listed.
+func NewFakeDiscovery() discovery.DiscoveryInterface {
+	listVerbs := metav1.Verbs{"create", "delete", "get", "list", "patch", "update", "watch"}
+
+	return &fakediscovery.FakeDiscovery{
+		Fake: &k8stesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods", Namespaced: true, Kind: "Pod", Verbs: listVerbs},
+						{Name: "pods/status", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get", "patch", "update"}},
+						{Name: "namespaces", Namespaced: false, Kind: "Namespace", Verbs: listVerbs},
+					},
+				},
+				{
+					GroupVersion: "apps/v1",
+					APIResources: []metav1.APIResource{
+						{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: listVerbs},
+					},
+				},
+				{
+					GroupVersion: "admissionregistration.k8s.io/v1",
+					APIResources: []metav1.APIResource{
+						{Name: "validatingwebhookconfigurations", Namespaced: false, Kind: "ValidatingWebhookConfiguration", Verbs: listVerbs},
+					},
+				},
+				{
+					GroupVersion: "authentication.k8s.io/v1",
+					APIResources: []metav1.APIResource{
+						{Name: "tokenreviews", Namespaced: false, Kind: "TokenReview", Verbs: metav1.Verbs{"create"}},
+					},
+				},
+			},
+		},
+	}
 }
 
 type PolicyReportFactory struct {
@@ -124,6 +171,12 @@ func (factory *ClusterPolicyReportFactory) RunUID(runUID string) *ClusterPolicyR
 	return factory
 }
 
+func (factory *ClusterPolicyReportFactory) ShardKey(key string) *ClusterPolicyReportFactory {
+	factory.labels[constants.AuditScannerShardKeyLabel] = key
+
+	return factory
+}
+
 func (factory *ClusterPolicyReportFactory) WithAppLabel() *ClusterPolicyReportFactory {
 	factory.labels["app.kubernetes.io/managed-by"] = "kubewarden"
 
@@ -333,6 +386,8 @@ type ClusterAdmissionPolicyFactory struct {
 	namespaceSelector *metav1.LabelSelector
 	objectSelector    *metav1.LabelSelector
 	rules             []admissionregistrationv1.RuleWithOperations
+	matchConditions   []admissionregistrationv1.MatchCondition
+	failurePolicy     *admissionregistrationv1.FailurePolicyType
 	backgroundAudit   bool
 	status            policiesv1.PolicyStatusEnum
 }
@@ -374,6 +429,20 @@ func (factory *ClusterAdmissionPolicyFactory) Rule(rule admissionregistrationv1.
 	return factory
 }
 
+func (factory *ClusterAdmissionPolicyFactory) MatchCondition(name, expression string) *ClusterAdmissionPolicyFactory {
+	factory.matchConditions = append(factory.matchConditions, admissionregistrationv1.MatchCondition{
+		Name: name, Expression: expression,
+	})
+
+	return factory
+}
+
+func (factory *ClusterAdmissionPolicyFactory) FailurePolicy(failurePolicy admissionregistrationv1.FailurePolicyType) *ClusterAdmissionPolicyFactory {
+	factory.failurePolicy = &failurePolicy
+
+	return factory
+}
+
 func (factory *ClusterAdmissionPolicyFactory) BackgroundAudit(backgroundAudit bool) *ClusterAdmissionPolicyFactory {
 	factory.backgroundAudit = backgroundAudit
 
@@ -401,6 +470,8 @@ func (factory *ClusterAdmissionPolicyFactory) Build() *policiesv1.ClusterAdmissi
 				ObjectSelector:  factory.objectSelector,
 				PolicyServer:    "default",
 				Rules:           factory.rules,
+				MatchConditions: factory.matchConditions,
+				FailurePolicy:   factory.failurePolicy,
 				BackgroundAudit: factory.backgroundAudit,
 			},
 		},
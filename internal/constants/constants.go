@@ -56,6 +56,17 @@ const (
 
 	PolicyServerIndexKey = ".spec.policyServer"
 
+	// AuditScheduleLabelKey is the label set on the Jobs created for an
+	// AuditSchedule, holding its name.
+	AuditScheduleLabelKey = "kubewarden.io/audit-schedule"
+	// ComponentAuditScannerLabelValue is the component of the Jobs running the audit scanner.
+	ComponentAuditScannerLabelValue = "audit-scanner"
+	// AuditScannerServiceAccountName is the default ServiceAccount of the audit scanner.
+	AuditScannerServiceAccountName = "audit-scanner"
+	// AuditScannerClientCertSecretName is the Secret with the client certificate
+	// used by the audit scanner to connect to the PolicyServers.
+	AuditScannerClientCertSecretName = "kubewarden-audit-scanner-client-cert" //nolint:gosec // This is not a credential
+
 	KubewardenPoliciesGroup = "policies.kubewarden.io"
 
 	KubewardenFinalizerPre114 = "kubewarden"
@@ -0,0 +1,355 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/constants"
+)
+
+// Warning: this controller is deployed by a helm chart which has its own
+// templated RBAC rules. The rules are kept in sync between what is generated by
+// `make manifests` and the helm chart by hand.
+//
+// The Jobs are only created inside of the namespace where the controller is
+// deployed.
+//+kubebuilder:rbac:groups=policies.kubewarden.io,resources=auditschedules,verbs=get;list;watch
+//+kubebuilder:rbac:groups=policies.kubewarden.io,resources=auditschedules/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=policies.kubewarden.io,resources=auditschedules/finalizers,verbs=update
+//+kubebuilder:rbac:namespace=kubewarden,groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+
+const (
+	// defaultSuccessfulJobsHistoryLimit is the number of successful Jobs kept
+	// when the AuditSchedule does not set it, like for a CronJob.
+	defaultSuccessfulJobsHistoryLimit = 3
+	// defaultFailedJobsHistoryLimit is the number of failed Jobs kept when the
+	// AuditSchedule does not set it, like for a CronJob.
+	defaultFailedJobsHistoryLimit = 1
+	// maxMissedSchedules is the number of missed scans searched for the last
+	// one, like for a CronJob.
+	maxMissedSchedules = 100
+)
+
+// AuditScannerConfiguration is the configuration of the audit scanner Jobs
+// created for the AuditSchedules.
+type AuditScannerConfiguration struct {
+	// Image of the audit scanner.
+	Image string
+	// ServiceAccountName is the ServiceAccount the audit scanner runs as.
+	ServiceAccountName string
+	// ImagePullSecrets are the image pull secrets of the audit scanner pods.
+	ImagePullSecrets []corev1.LocalObjectReference
+	// IgnoredNamespaces are the namespaces never audited by the Jobs, in
+	// addition to the ones ignored by the scope of each AuditSchedule.
+	IgnoredNamespaces []string
+	// ReportKind is the kind of the reports written by the Jobs of the
+	// AuditSchedules not setting their own. The audit scanner default is
+	// used when empty.
+	ReportKind string
+	// LogLevel of the audit scanner. The audit scanner default is used when empty.
+	LogLevel string
+	// PolicyServerClient configures the requests sent to the PolicyServers.
+	PolicyServerClient AuditScannerPolicyServerClient
+}
+
+// AuditScannerPolicyServerClient configures the timeout, the retries and the
+// circuit breaker of the requests sent by the audit scanner Jobs to the
+// PolicyServers. The zero durations and the nil counts keep the audit scanner
+// defaults.
+type AuditScannerPolicyServerClient struct {
+	Timeout                 time.Duration
+	Retries                 *int
+	RetryBackoff            time.Duration
+	MaxRetryBackoff         time.Duration
+	CircuitBreakerThreshold *int
+	CircuitBreakerCooldown  time.Duration
+}
+
+// AuditScheduleReconciler reconciles an AuditSchedule object.
+// It creates the Jobs running the audit scanner according to the schedule,
+// and surfaces the result of the last scan in the AuditSchedule status.
+type AuditScheduleReconciler struct {
+	client.Client
+	Log                  logr.Logger
+	Scheme               *runtime.Scheme
+	DeploymentsNamespace string
+	AuditScanner         AuditScannerConfiguration
+	// clock returns the current time, it's replaced in the tests
+	clock func() time.Time
+}
+
+// Reconcile reconciles audit schedules.
+func (r *AuditScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var auditSchedule policiesv1.AuditSchedule
+	if err := r.Get(ctx, req.NamespacedName, &auditSchedule); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to get audit schedule: %w", err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// The Jobs are garbage collected through their owner reference
+	if auditSchedule.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	activeJobs, err := r.reconcileJobs(ctx, &auditSchedule)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	result, err := r.scheduleJob(ctx, &auditSchedule, activeJobs)
+	if statusErr := r.Client.Status().Update(ctx, &auditSchedule); statusErr != nil {
+		err = errors.Join(err, fmt.Errorf("update audit schedule status error: %w", statusErr))
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return result, nil
+}
+
+// reconcileJobs records the running and the last finished Jobs of the
+// AuditSchedule in its status, and deletes the finished Jobs beyond the
+// history limits. It returns the number of running Jobs.
+func (r *AuditScheduleReconciler) reconcileJobs(ctx context.Context, auditSchedule *policiesv1.AuditSchedule) (int, error) {
+	var jobs batchv1.JobList
+	if err := r.List(ctx, &jobs,
+		client.InNamespace(r.DeploymentsNamespace),
+		client.MatchingLabels{constants.AuditScheduleLabelKey: auditSchedule.Name},
+	); err != nil {
+		return 0, fmt.Errorf("failed to list audit schedule jobs: %w", err)
+	}
+
+	// The oldest Jobs first
+	slices.SortFunc(jobs.Items, func(a, b batchv1.Job) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
+	})
+
+	var activeJobs, successfulJobs, failedJobs []batchv1.Job
+	for _, job := range jobs.Items {
+		switch jobResult(&job) {
+		case policiesv1.AuditScheduleRunSucceeded:
+			successfulJobs = append(successfulJobs, job)
+		case policiesv1.AuditScheduleRunFailed:
+			failedJobs = append(failedJobs, job)
+		default:
+			activeJobs = append(activeJobs, job)
+		}
+	}
+
+	auditSchedule.Status.ActiveJob = ""
+	if len(activeJobs) > 0 {
+		auditSchedule.Status.ActiveJob = activeJobs[len(activeJobs)-1].Name
+	}
+
+	var lastFinishedJob *batchv1.Job
+	for _, job := range slices.Concat(successfulJobs, failedJobs) {
+		if lastFinishedJob == nil || lastFinishedJob.CreationTimestamp.Before(&job.CreationTimestamp) {
+			lastFinishedJob = &job
+		}
+	}
+	if lastFinishedJob != nil {
+		auditSchedule.Status.LastRun = newAuditScheduleRun(lastFinishedJob)
+	}
+
+	successfulJobsHistoryLimit := int32(defaultSuccessfulJobsHistoryLimit)
+	if auditSchedule.Spec.SuccessfulJobsHistoryLimit != nil {
+		successfulJobsHistoryLimit = *auditSchedule.Spec.SuccessfulJobsHistoryLimit
+	}
+	failedJobsHistoryLimit := int32(defaultFailedJobsHistoryLimit)
+	if auditSchedule.Spec.FailedJobsHistoryLimit != nil {
+		failedJobsHistoryLimit = *auditSchedule.Spec.FailedJobsHistoryLimit
+	}
+	if err := r.deleteOldJobs(ctx, successfulJobs, successfulJobsHistoryLimit); err != nil {
+		return 0, err
+	}
+	if err := r.deleteOldJobs(ctx, failedJobs, failedJobsHistoryLimit); err != nil {
+		return 0, err
+	}
+
+	return len(activeJobs), nil
+}
+
+// deleteOldJobs deletes the oldest of the given Jobs, keeping the limit most
+// recent ones.
+func (r *AuditScheduleReconciler) deleteOldJobs(ctx context.Context, jobs []batchv1.Job, limit int32) error {
+	for i := range max(len(jobs)-int(limit), 0) {
+		job := &jobs[i]
+		r.Log.V(1).Info("deleting old audit scanner job", "job", job.Name)
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete audit scanner job %s: %w", job.Name, err)
+		}
+	}
+	return nil
+}
+
+// scheduleJob creates the Job of the last scan scheduled since the previous
+// one, unless a scan is still running, and returns when the AuditSchedule must
+// be reconciled again for the next scan.
+func (r *AuditScheduleReconciler) scheduleJob(ctx context.Context, auditSchedule *policiesv1.AuditSchedule, activeJobs int) (ctrl.Result, error) {
+	schedule, err := cron.ParseStandard(auditSchedule.Spec.Schedule)
+	if err != nil {
+		// The schedule is only fixed by updating the AuditSchedule, which
+		// triggers a new reconciliation
+		setFalseConditionType(
+			&auditSchedule.Status.Conditions,
+			string(policiesv1.AuditScheduleScheduled),
+			fmt.Sprintf("invalid schedule %q: %v", auditSchedule.Spec.Schedule, err),
+		)
+		auditSchedule.Status.NextScheduleTime = nil
+		return ctrl.Result{}, nil
+	}
+	command, err := r.auditScannerCommand(auditSchedule)
+	if err != nil {
+		setFalseConditionType(
+			&auditSchedule.Status.Conditions,
+			string(policiesv1.AuditScheduleScheduled),
+			fmt.Sprintf("invalid scope: %v", err),
+		)
+		auditSchedule.Status.NextScheduleTime = nil
+		return ctrl.Result{}, nil
+	}
+	setTrueConditionType(&auditSchedule.Status.Conditions, string(policiesv1.AuditScheduleScheduled))
+
+	if auditSchedule.Spec.Suspend {
+		auditSchedule.Status.NextScheduleTime = nil
+		return ctrl.Result{}, nil
+	}
+
+	now := r.now()
+	nextScheduleTime := schedule.Next(now)
+	auditSchedule.Status.NextScheduleTime = &metav1.Time{Time: nextScheduleTime}
+	result := ctrl.Result{RequeueAfter: nextScheduleTime.Sub(now)}
+
+	earliestScheduleTime := auditSchedule.CreationTimestamp.Time
+	if auditSchedule.Status.LastScheduleTime != nil {
+		earliestScheduleTime = auditSchedule.Status.LastScheduleTime.Time
+	}
+	scheduledTime, tooManyMissed := lastMissedScheduleTime(schedule, earliestScheduleTime, now)
+	if tooManyMissed {
+		// Like the CronJob controller, the missed scans are not searched any
+		// further, e.g. after a long suspension or a clock skew. They are
+		// skipped and the next scan runs as scheduled
+		r.Log.Info("too many missed audit scans, skipping them",
+			"auditSchedule", auditSchedule.Name, "maxMissedSchedules", maxMissedSchedules)
+		auditSchedule.Status.LastScheduleTime = &metav1.Time{Time: now}
+		return result, nil
+	}
+	if scheduledTime.IsZero() {
+		return result, nil
+	}
+
+	if activeJobs > 0 {
+		// Like the Forbid concurrency policy of a CronJob, the scan is skipped
+		r.Log.Info("skipping the scheduled audit scan, the previous one is still running",
+			"auditSchedule", auditSchedule.Name, "job", auditSchedule.Status.ActiveJob)
+		auditSchedule.Status.LastScheduleTime = &metav1.Time{Time: scheduledTime}
+		return result, nil
+	}
+
+	job := r.newAuditScannerJob(auditSchedule, scheduledTime, command)
+	if err = controllerutil.SetControllerReference(auditSchedule, job, r.Scheme); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to set audit scanner job owner reference: %w", err)
+	}
+	if err = r.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return ctrl.Result{}, fmt.Errorf("failed to create audit scanner job: %w", err)
+	}
+	r.Log.Info("created audit scanner job", "auditSchedule", auditSchedule.Name, "job", job.Name)
+	auditSchedule.Status.LastScheduleTime = &metav1.Time{Time: scheduledTime}
+	auditSchedule.Status.ActiveJob = job.Name
+
+	return result, nil
+}
+
+// now returns the current time.
+func (r *AuditScheduleReconciler) now() time.Time {
+	if r.clock != nil {
+		return r.clock()
+	}
+	return time.Now()
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AuditScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&policiesv1.AuditSchedule{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+	if err != nil {
+		return errors.Join(errors.New("failed enrolling controller with manager"), err)
+	}
+	return nil
+}
+
+// lastMissedScheduleTime returns the last time scheduled after earliest and
+// not after now, or the zero time when no scan was scheduled in between.
+// Only the last missed scan is run, the older ones are skipped. The search
+// stops after maxMissedSchedules scheduled times, in which case tooManyMissed
+// is true.
+func lastMissedScheduleTime(schedule cron.Schedule, earliest, now time.Time) (time.Time, bool) {
+	var lastMissed time.Time
+	missed := 0
+	for scheduledTime := schedule.Next(earliest); !scheduledTime.After(now); scheduledTime = schedule.Next(scheduledTime) {
+		missed++
+		if missed > maxMissedSchedules {
+			return time.Time{}, true
+		}
+		lastMissed = scheduledTime
+	}
+	return lastMissed, false
+}
+
+// jobResult returns the result of a finished Job, or an empty result when
+// the Job is still running.
+func jobResult(job *batchv1.Job) policiesv1.AuditScheduleRunResult {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return policiesv1.AuditScheduleRunSucceeded
+		case batchv1.JobFailed:
+			return policiesv1.AuditScheduleRunFailed
+		default:
+		}
+	}
+	return ""
+}
+
+// newAuditScheduleRun describes the scan performed by a finished Job.
+func newAuditScheduleRun(job *batchv1.Job) *policiesv1.AuditScheduleRun {
+	run := &policiesv1.AuditScheduleRun{
+		JobName:        job.Name,
+		Result:         jobResult(job),
+		StartTime:      job.Status.StartTime,
+		CompletionTime: job.Status.CompletionTime,
+	}
+	if run.Result == policiesv1.AuditScheduleRunFailed {
+		for _, condition := range job.Status.Conditions {
+			if condition.Type == batchv1.JobFailed {
+				run.Message = condition.Message
+				// A failed Job has no completion time
+				run.CompletionTime = &condition.LastTransitionTime
+			}
+		}
+	}
+	return run
+}
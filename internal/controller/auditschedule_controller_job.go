@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/constants"
+)
+
+const (
+	auditScannerContainerName        = "audit-scanner"
+	auditScannerCAVolumeName         = "kubewarden-ca"
+	auditScannerCAVolumePath         = "/pki"
+	auditScannerClientCertVolumeName = "kubewarden-audit-scanner-client-cert"
+	auditScannerClientCertVolumePath = "/client-cert"
+	auditScannerCertsMountMode       = 420
+	// maxAuditScheduleJobNamePrefixLength keeps the Job names, suffixed with
+	// the scheduled time, short enough to be used as label values.
+	maxAuditScheduleJobNamePrefixLength = 52
+)
+
+// auditScannerJobName returns the name of the Job of the scan scheduled at
+// the given time. The name is unique for each scheduled time, so the same
+// scan is never created twice.
+func auditScannerJobName(auditSchedule *policiesv1.AuditSchedule, scheduledTime time.Time) string {
+	prefix := auditSchedule.Name
+	if len(prefix) > maxAuditScheduleJobNamePrefixLength {
+		prefix = strings.TrimRight(prefix[:maxAuditScheduleJobNamePrefixLength], "-.")
+	}
+	return fmt.Sprintf("%s-%d", prefix, scheduledTime.Unix()/int64(time.Minute.Seconds()))
+}
+
+// newAuditScannerJob returns the Job running the given audit scanner command,
+// to perform the scan of the AuditSchedule scheduled at the given time.
+func (r *AuditScheduleReconciler) newAuditScannerJob(auditSchedule *policiesv1.AuditSchedule, scheduledTime time.Time, command []string) *batchv1.Job {
+	labels := map[string]string{
+		constants.AuditScheduleLabelKey: auditSchedule.Name,
+		constants.ComponentLabelKey:     constants.ComponentAuditScannerLabelValue,
+		constants.PartOfLabelKey:        constants.PartOfLabelValue,
+		constants.ManagedByKey:          "kubewarden-controller",
+	}
+	serviceAccountName := r.AuditScanner.ServiceAccountName
+	if serviceAccountName == "" {
+		serviceAccountName = constants.AuditScannerServiceAccountName
+	}
+
+	container := corev1.Container{
+		Name:            auditScannerContainerName,
+		Image:           r.AuditScanner.Image,
+		Command:         command,
+		SecurityContext: defaultContainerSecurityContext(),
+		Resources: corev1.ResourceRequirements{
+			Limits:   auditSchedule.Spec.Limits,
+			Requests: auditSchedule.Spec.Requests,
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      auditScannerCAVolumeName,
+				MountPath: auditScannerCAVolumePath,
+				ReadOnly:  true,
+			},
+			{
+				Name:      auditScannerClientCertVolumeName,
+				MountPath: auditScannerClientCertVolumePath,
+				ReadOnly:  true,
+			},
+		},
+	}
+
+	certsMountMode := int32(auditScannerCertsMountMode)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      auditScannerJobName(auditSchedule, scheduledTime),
+			Namespace: r.DeploymentsNamespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: serviceAccountName,
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ImagePullSecrets:   r.AuditScanner.ImagePullSecrets,
+					SecurityContext:    defaultPodSecurityContext(),
+					Containers:         []corev1.Container{container},
+					Volumes: []corev1.Volume{
+						{
+							Name: auditScannerCAVolumeName,
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName:  constants.CARootSecretName,
+									DefaultMode: &certsMountMode,
+									Items: []corev1.KeyToPath{
+										{Key: constants.CARootCert, Path: constants.CARootCert},
+									},
+								},
+							},
+						},
+						{
+							Name: auditScannerClientCertVolumeName,
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName:  constants.AuditScannerClientCertSecretName,
+									DefaultMode: &certsMountMode,
+									Items: []corev1.KeyToPath{
+										{Key: corev1.TLSCertKey, Path: corev1.TLSCertKey},
+										{Key: corev1.TLSPrivateKeyKey, Path: corev1.TLSPrivateKeyKey},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// auditScannerCommand returns the command running the scan of the AuditSchedule.
+// The settings of the audit scanner shared by all the Jobs come first, so the
+// ones of the AuditSchedule take precedence. It fails when the label selectors
+// of the scope are invalid.
+func (r *AuditScheduleReconciler) auditScannerCommand(auditSchedule *policiesv1.AuditSchedule) ([]string, error) {
+	spec := auditSchedule.Spec
+	command := []string{
+		"/audit-scanner",
+		"--kubewarden-namespace", r.DeploymentsNamespace,
+		"--audit-schedule", auditSchedule.Name,
+		"--extra-ca", auditScannerCAVolumePath + "/" + constants.CARootCert,
+		"--client-cert", auditScannerClientCertVolumePath + "/" + corev1.TLSCertKey,
+		"--client-key", auditScannerClientCertVolumePath + "/" + corev1.TLSPrivateKeyKey,
+	}
+	command = append(command, r.AuditScanner.defaultArgs()...)
+
+	switch {
+	case spec.Scope.Namespace != "":
+		command = append(command, "--namespace", spec.Scope.Namespace)
+	case spec.Scope.ClusterWide:
+		command = append(command, "--cluster")
+	}
+	for _, namespace := range spec.Scope.IgnoredNamespaces {
+		command = append(command, "--ignore-namespaces", namespace)
+	}
+	for _, policy := range spec.Scope.Policies {
+		command = append(command, "--policy", policy)
+	}
+	for _, resource := range spec.Scope.Resources {
+		command = append(command, "--resource", resource)
+	}
+	if spec.Scope.NamespaceSelector != nil {
+		namespaceSelector, err := metav1.LabelSelectorAsSelector(spec.Scope.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector: %w", err)
+		}
+		command = append(command, "--namespace-selector", namespaceSelector.String())
+	}
+	if spec.Scope.ResourceSelector != nil {
+		resourceSelector, err := metav1.LabelSelectorAsSelector(spec.Scope.ResourceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource selector: %w", err)
+		}
+		command = append(command, "--resource-selector", resourceSelector.String())
+	}
+
+	if spec.Parallelism.Namespaces > 0 {
+		command = append(command, "--parallel-namespaces", strconv.Itoa(int(spec.Parallelism.Namespaces)))
+	}
+	if spec.Parallelism.Resources > 0 {
+		command = append(command, "--parallel-resources", strconv.Itoa(int(spec.Parallelism.Resources)))
+	}
+	if spec.Parallelism.Policies > 0 {
+		command = append(command, "--parallel-policies", strconv.Itoa(int(spec.Parallelism.Policies)))
+	}
+	if spec.ReportKind != "" {
+		command = append(command, "--report-kind", spec.ReportKind)
+	}
+
+	return command, nil
+}
+
+// defaultArgs returns the arguments of the audit scanner shared by all the
+// Jobs, matching the ones of the audit scanner CronJob.
+func (c AuditScannerConfiguration) defaultArgs() []string {
+	var args []string
+	if c.LogLevel != "" {
+		args = append(args, "--loglevel", c.LogLevel)
+	}
+	for _, namespace := range c.IgnoredNamespaces {
+		args = append(args, "--ignore-namespaces", namespace)
+	}
+	if c.ReportKind != "" {
+		args = append(args, "--report-kind", c.ReportKind)
+	}
+
+	client := c.PolicyServerClient
+	if client.Timeout > 0 {
+		args = append(args, "--policy-server-timeout", client.Timeout.String())
+	}
+	if client.Retries != nil {
+		args = append(args, "--policy-server-retries", strconv.Itoa(*client.Retries))
+	}
+	if client.RetryBackoff > 0 {
+		args = append(args, "--policy-server-retry-backoff", client.RetryBackoff.String())
+	}
+	if client.MaxRetryBackoff > 0 {
+		args = append(args, "--policy-server-max-retry-backoff", client.MaxRetryBackoff.String())
+	}
+	if client.CircuitBreakerThreshold != nil {
+		args = append(args, "--circuit-breaker-threshold", strconv.Itoa(*client.CircuitBreakerThreshold))
+	}
+	if client.CircuitBreakerCooldown > 0 {
+		args = append(args, "--circuit-breaker-cooldown", client.CircuitBreakerCooldown.String())
+	}
+	return args
+}
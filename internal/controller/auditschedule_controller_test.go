@@ -0,0 +1,366 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	policiesv1 "github.com/kubewarden/adm-controller/api/policies/v1"
+	"github.com/kubewarden/adm-controller/internal/constants"
+)
+
+const auditScheduleTestNamespace = "kubewarden"
+
+func newAuditScheduleReconciler(t *testing.T, now time.Time, objects ...client.Object) *AuditScheduleReconciler {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, policiesv1.AddToScheme(scheme))
+	require.NoError(t, batchv1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	return &AuditScheduleReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objects...).
+			WithStatusSubresource(&policiesv1.AuditSchedule{}).
+			Build(),
+		Log:                  logr.Discard(),
+		Scheme:               scheme,
+		DeploymentsNamespace: auditScheduleTestNamespace,
+		AuditScanner: AuditScannerConfiguration{
+			Image: "ghcr.io/kubewarden/audit-scanner:latest",
+		},
+		clock: func() time.Time { return now },
+	}
+}
+
+func newAuditSchedule(schedule string, creationTime time.Time) *policiesv1.AuditSchedule {
+	return &policiesv1.AuditSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "team",
+			CreationTimestamp: metav1.Time{Time: creationTime},
+		},
+		Spec: policiesv1.AuditScheduleSpec{
+			Schedule: schedule,
+		},
+	}
+}
+
+func newAuditScheduleJob(name string, creationTime time.Time, conditionType batchv1.JobConditionType) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         auditScheduleTestNamespace,
+			CreationTimestamp: metav1.Time{Time: creationTime},
+			Labels:            map[string]string{constants.AuditScheduleLabelKey: "team"},
+		},
+	}
+	if conditionType != "" {
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type:               conditionType,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.Time{Time: creationTime.Add(time.Minute)},
+			Message:            "the audit scanner failed",
+		}}
+	}
+	return job
+}
+
+func reconcileAuditSchedule(t *testing.T, reconciler *AuditScheduleReconciler) (ctrl.Result, *policiesv1.AuditSchedule, []batchv1.Job) {
+	t.Helper()
+
+	result, err := reconciler.Reconcile(t.Context(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "team"}})
+	require.NoError(t, err)
+
+	auditSchedule := &policiesv1.AuditSchedule{}
+	require.NoError(t, reconciler.Get(t.Context(), client.ObjectKey{Name: "team"}, auditSchedule))
+	jobs := &batchv1.JobList{}
+	require.NoError(t, reconciler.List(t.Context(), jobs, client.InNamespace(auditScheduleTestNamespace)))
+	return result, auditSchedule, jobs.Items
+}
+
+func TestAuditScheduleCreatesJob(t *testing.T) {
+	creationTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := creationTime.Add(25 * time.Minute)
+	auditSchedule := newAuditSchedule("*/10 * * * *", creationTime)
+	auditSchedule.Spec.Scope.Namespace = "team"
+	auditSchedule.Spec.Parallelism.Resources = 10
+	auditSchedule.Spec.ReportKind = "policyreport"
+	reconciler := newAuditScheduleReconciler(t, now, auditSchedule)
+
+	result, auditSchedule, jobs := reconcileAuditSchedule(t, reconciler)
+	assert.Equal(t, 5*time.Minute, result.RequeueAfter)
+
+	require.Len(t, jobs, 1)
+	job := jobs[0]
+	scheduledTime := creationTime.Add(20 * time.Minute)
+	assert.Equal(t, auditScannerJobName(auditSchedule, scheduledTime), job.Name)
+	assert.Equal(t, "team", job.Labels[constants.AuditScheduleLabelKey])
+	require.Len(t, job.OwnerReferences, 1)
+	assert.Equal(t, "AuditSchedule", job.OwnerReferences[0].Kind)
+
+	podSpec := job.Spec.Template.Spec
+	assert.Equal(t, constants.AuditScannerServiceAccountName, podSpec.ServiceAccountName)
+	assert.Equal(t, corev1.RestartPolicyNever, podSpec.RestartPolicy)
+	require.Len(t, podSpec.Containers, 1)
+	assert.Equal(t, "ghcr.io/kubewarden/audit-scanner:latest", podSpec.Containers[0].Image)
+	command := podSpec.Containers[0].Command
+	assert.Subset(t, command, []string{"--kubewarden-namespace", auditScheduleTestNamespace, "--audit-schedule", "team", "--namespace", "team", "--parallel-resources", "10", "--report-kind", "policyreport"})
+	assert.NotContains(t, command, "--cluster")
+	assert.NotContains(t, command, "--parallel-namespaces")
+
+	assert.Equal(t, scheduledTime, auditSchedule.Status.LastScheduleTime.Time.UTC())
+	assert.Equal(t, creationTime.Add(30*time.Minute), auditSchedule.Status.NextScheduleTime.Time.UTC())
+	assert.Equal(t, job.Name, auditSchedule.Status.ActiveJob)
+	assert.True(t, apimeta.IsStatusConditionTrue(auditSchedule.Status.Conditions, string(policiesv1.AuditScheduleScheduled)))
+
+	// the scan is not scheduled twice
+	_, _, jobs = reconcileAuditSchedule(t, reconciler)
+	assert.Len(t, jobs, 1)
+}
+
+func TestAuditScheduleSkipsScanWhenPreviousOneIsRunning(t *testing.T) {
+	creationTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := creationTime.Add(10 * time.Minute)
+	auditSchedule := newAuditSchedule("*/10 * * * *", creationTime)
+	runningJob := newAuditScheduleJob("team-running", creationTime, "")
+	reconciler := newAuditScheduleReconciler(t, now, auditSchedule, runningJob)
+
+	_, auditSchedule, jobs := reconcileAuditSchedule(t, reconciler)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "team-running", auditSchedule.Status.ActiveJob)
+	assert.Equal(t, now, auditSchedule.Status.LastScheduleTime.Time.UTC())
+}
+
+func TestAuditScheduleRecordsLastRunAndDeletesOldJobs(t *testing.T) {
+	creationTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	auditSchedule := newAuditSchedule("0 0 1 1 *", creationTime)
+	auditSchedule.Status.LastScheduleTime = &metav1.Time{Time: creationTime}
+	reconciler := newAuditScheduleReconciler(t, creationTime.Add(time.Hour),
+		auditSchedule,
+		newAuditScheduleJob("team-1", creationTime, batchv1.JobComplete),
+		newAuditScheduleJob("team-2", creationTime.Add(10*time.Minute), batchv1.JobFailed),
+		newAuditScheduleJob("team-3", creationTime.Add(20*time.Minute), batchv1.JobComplete),
+		newAuditScheduleJob("team-4", creationTime.Add(30*time.Minute), batchv1.JobFailed),
+	)
+
+	_, auditSchedule, jobs := reconcileAuditSchedule(t, reconciler)
+	names := []string{}
+	for _, job := range jobs {
+		names = append(names, job.Name)
+	}
+	// the 3 successful and the last failed Jobs are kept
+	assert.ElementsMatch(t, []string{"team-1", "team-3", "team-4"}, names)
+
+	require.NotNil(t, auditSchedule.Status.LastRun)
+	assert.Equal(t, "team-4", auditSchedule.Status.LastRun.JobName)
+	assert.Equal(t, policiesv1.AuditScheduleRunFailed, auditSchedule.Status.LastRun.Result)
+	assert.Equal(t, "the audit scanner failed", auditSchedule.Status.LastRun.Message)
+	assert.Empty(t, auditSchedule.Status.ActiveJob)
+}
+
+func TestAuditScheduleInvalidSchedule(t *testing.T) {
+	creationTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	reconciler := newAuditScheduleReconciler(t, creationTime.Add(time.Hour), newAuditSchedule("every minute", creationTime))
+
+	result, auditSchedule, jobs := reconcileAuditSchedule(t, reconciler)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Empty(t, jobs)
+	condition := apimeta.FindStatusCondition(auditSchedule.Status.Conditions, string(policiesv1.AuditScheduleScheduled))
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Contains(t, condition.Message, "invalid schedule")
+}
+
+func TestAuditScheduleTargetedScan(t *testing.T) {
+	creationTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	auditSchedule := newAuditSchedule("*/10 * * * *", creationTime)
+	auditSchedule.Spec.Scope.Policies = []string{"privileged-pods", "team/latest-tag"}
+	auditSchedule.Spec.Scope.Resources = []string{"pods"}
+	auditSchedule.Spec.Scope.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}}
+	auditSchedule.Spec.Scope.ResourceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	reconciler := newAuditScheduleReconciler(t, creationTime.Add(15*time.Minute), auditSchedule)
+
+	_, _, jobs := reconcileAuditSchedule(t, reconciler)
+	require.Len(t, jobs, 1)
+	command := jobs[0].Spec.Template.Spec.Containers[0].Command
+	assert.Subset(t, command, []string{
+		"--policy", "privileged-pods",
+		"--policy", "team/latest-tag",
+		"--resource", "pods",
+		"--namespace-selector", "team=payments",
+		"--resource-selector", "app=web",
+	})
+}
+
+func TestAuditScheduleJobCommand(t *testing.T) {
+	retries := 0
+	circuitBreakerThreshold := 10
+	auditScanner := AuditScannerConfiguration{
+		IgnoredNamespaces: []string{"kube-system"},
+		ReportKind:        "openreports",
+		LogLevel:          "debug",
+		PolicyServerClient: AuditScannerPolicyServerClient{
+			Timeout:                 5 * time.Second,
+			Retries:                 &retries,
+			CircuitBreakerThreshold: &circuitBreakerThreshold,
+			CircuitBreakerCooldown:  time.Minute,
+		},
+	}
+
+	tests := []struct {
+		name       string
+		reportKind string
+		ignored    []string
+		expected   []string
+	}{
+		{
+			name: "defaults of the audit scanner",
+			expected: []string{
+				"/audit-scanner",
+				"--kubewarden-namespace", auditScheduleTestNamespace,
+				"--audit-schedule", "team",
+				"--extra-ca", "/pki/ca.crt",
+				"--client-cert", "/client-cert/tls.crt",
+				"--client-key", "/client-cert/tls.key",
+				"--loglevel", "debug",
+				"--ignore-namespaces", "kube-system",
+				"--report-kind", "openreports",
+				"--policy-server-timeout", "5s",
+				"--policy-server-retries", "0",
+				"--circuit-breaker-threshold", "10",
+				"--circuit-breaker-cooldown", "1m0s",
+			},
+		},
+		{
+			name:       "settings of the AuditSchedule",
+			reportKind: "policyreport",
+			ignored:    []string{"team"},
+			expected: []string{
+				"/audit-scanner",
+				"--kubewarden-namespace", auditScheduleTestNamespace,
+				"--audit-schedule", "team",
+				"--extra-ca", "/pki/ca.crt",
+				"--client-cert", "/client-cert/tls.crt",
+				"--client-key", "/client-cert/tls.key",
+				"--loglevel", "debug",
+				"--ignore-namespaces", "kube-system",
+				"--report-kind", "openreports",
+				"--policy-server-timeout", "5s",
+				"--policy-server-retries", "0",
+				"--circuit-breaker-threshold", "10",
+				"--circuit-breaker-cooldown", "1m0s",
+				// the settings of the AuditSchedule come last, so they take precedence
+				"--ignore-namespaces", "team",
+				"--report-kind", "policyreport",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auditSchedule := newAuditSchedule("*/10 * * * *", time.Now())
+			auditSchedule.Spec.ReportKind = test.reportKind
+			auditSchedule.Spec.Scope.IgnoredNamespaces = test.ignored
+			reconciler := newAuditScheduleReconciler(t, time.Now())
+			reconciler.AuditScanner = auditScanner
+
+			command, err := reconciler.auditScannerCommand(auditSchedule)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, command)
+		})
+	}
+}
+
+func TestAuditScheduleInvalidScope(t *testing.T) {
+	creationTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	auditSchedule := newAuditSchedule("*/10 * * * *", creationTime)
+	auditSchedule.Spec.Scope.ResourceSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Unknown"}},
+	}
+	reconciler := newAuditScheduleReconciler(t, creationTime.Add(time.Hour), auditSchedule)
+
+	result, auditSchedule, jobs := reconcileAuditSchedule(t, reconciler)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Empty(t, jobs)
+	condition := apimeta.FindStatusCondition(auditSchedule.Status.Conditions, string(policiesv1.AuditScheduleScheduled))
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Contains(t, condition.Message, "invalid resource selector")
+}
+
+func TestAuditScheduleSuspended(t *testing.T) {
+	creationTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	auditSchedule := newAuditSchedule("*/10 * * * *", creationTime)
+	auditSchedule.Spec.Suspend = true
+	reconciler := newAuditScheduleReconciler(t, creationTime.Add(time.Hour), auditSchedule)
+
+	result, auditSchedule, jobs := reconcileAuditSchedule(t, reconciler)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Empty(t, jobs)
+	assert.Nil(t, auditSchedule.Status.LastScheduleTime)
+	assert.Nil(t, auditSchedule.Status.NextScheduleTime)
+}
+
+func TestAuditScheduleSkipsTooManyMissedScans(t *testing.T) {
+	creationTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := creationTime.Add(90 * 24 * time.Hour).Add(30 * time.Second)
+	auditSchedule := newAuditSchedule("* * * * *", creationTime)
+	reconciler := newAuditScheduleReconciler(t, now, auditSchedule)
+
+	result, auditSchedule, jobs := reconcileAuditSchedule(t, reconciler)
+	assert.Empty(t, jobs)
+	assert.Equal(t, now, auditSchedule.Status.LastScheduleTime.Time.UTC())
+	assert.Equal(t, 30*time.Second, result.RequeueAfter)
+
+	// the next scan runs as scheduled
+	reconciler.clock = func() time.Time { return now.Add(time.Minute) }
+	_, auditSchedule, jobs = reconcileAuditSchedule(t, reconciler)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, now.Add(30*time.Second), auditSchedule.Status.LastScheduleTime.Time.UTC())
+}
+
+func TestLastMissedScheduleTime(t *testing.T) {
+	schedule, err := cron.ParseStandard("*/10 * * * *")
+	require.NoError(t, err)
+	earliest := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	lastMissed, tooManyMissed := lastMissedScheduleTime(schedule, earliest, earliest.Add(5*time.Minute))
+	assert.Zero(t, lastMissed)
+	assert.False(t, tooManyMissed)
+
+	lastMissed, tooManyMissed = lastMissedScheduleTime(schedule, earliest, earliest.Add(35*time.Minute))
+	assert.Equal(t, earliest.Add(30*time.Minute), lastMissed)
+	assert.False(t, tooManyMissed)
+
+	lastMissed, tooManyMissed = lastMissedScheduleTime(schedule, earliest, earliest.Add(maxMissedSchedules*10*time.Minute))
+	assert.Equal(t, earliest.Add(maxMissedSchedules*10*time.Minute), lastMissed)
+	assert.False(t, tooManyMissed)
+
+	lastMissed, tooManyMissed = lastMissedScheduleTime(schedule, earliest, earliest.Add((maxMissedSchedules+1)*10*time.Minute))
+	assert.Zero(t, lastMissed)
+	assert.True(t, tooManyMissed)
+}
+
+func TestAuditScannerJobName(t *testing.T) {
+	scheduledTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	auditSchedule := newAuditSchedule("*/10 * * * *", scheduledTime)
+	assert.Equal(t, "team-29454480", auditScannerJobName(auditSchedule, scheduledTime))
+
+	auditSchedule.Name = "a-very-long-audit-schedule-name-that-does-not-fit-in-a-label-value"
+	assert.LessOrEqual(t, len(auditScannerJobName(auditSchedule, scheduledTime)), 63)
+}